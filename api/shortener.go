@@ -1,8 +1,20 @@
 package api
 
+import "time"
+
 // ShortenRequest - .
 type ShortenRequest struct {
-	URL string `json:"url"`
+	URL          string     `json:"url"`
+	Alias        string     `json:"alias,omitempty"`         // optional custom short code
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`    // optional expiration moment, RFC 3339
+	TTLSeconds   int64      `json:"ttl_seconds,omitempty"`   // optional time to live, alternative to ExpiresAt
+	MaxClicks    int64      `json:"max_clicks,omitempty"`    // optional count of redirects after which the link dies
+	Tags         []string   `json:"tags,omitempty"`          // optional tags of the user
+	Password     string     `json:"password,omitempty"`      // optional password asked before the redirect
+	RedirectCode int        `json:"redirect_code,omitempty"` // optional redirect status 301, 302, 307 or 308, 307 by default
+	PassQuery    bool       `json:"pass_query,omitempty"`    // optional passing of the request query to the original url
+	Title        string     `json:"title,omitempty"`         // optional title shown on the preview page
+	Preview      bool       `json:"preview,omitempty"`       // optional preview page shown instead of the redirect
 }
 
 // ShortenResponse - .
@@ -12,8 +24,18 @@ type ShortenResponse struct {
 
 // ShortenBatchItemRequest - .
 type ShortenBatchItemRequest struct {
-	CorrelationID string `json:"correlation_id"`
-	OriginalURL   string `json:"original_url"`
+	CorrelationID string     `json:"correlation_id"`
+	OriginalURL   string     `json:"original_url"`
+	Alias         string     `json:"alias,omitempty"`         // optional custom short code
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`    // optional expiration moment, RFC 3339
+	TTLSeconds    int64      `json:"ttl_seconds,omitempty"`   // optional time to live, alternative to ExpiresAt
+	MaxClicks     int64      `json:"max_clicks,omitempty"`    // optional count of redirects after which the link dies
+	Tags          []string   `json:"tags,omitempty"`          // optional tags of the user
+	Password      string     `json:"password,omitempty"`      // optional password asked before the redirect
+	RedirectCode  int        `json:"redirect_code,omitempty"` // optional redirect status 301, 302, 307 or 308, 307 by default
+	PassQuery     bool       `json:"pass_query,omitempty"`    // optional passing of the request query to the original url
+	Title         string     `json:"title,omitempty"`         // optional title shown on the preview page
+	Preview       bool       `json:"preview,omitempty"`       // optional preview page shown instead of the redirect
 }
 
 // ShortenBatchRequest - .
@@ -28,5 +50,119 @@ type ShortenBatchItemResponse struct {
 // ShortenBatchResponse -.
 type ShortenBatchResponse []ShortenBatchItemResponse
 
+// ShortenBulkResult - result of one row of the bulk shortening, ShortURL is empty if Error is set
+type ShortenBulkResult struct {
+	Line          int    `json:"line"` // line of the row in the input
+	CorrelationID string `json:"correlation_id,omitempty"`
+	ShortURL      string `json:"short_url,omitempty"`
+	Duplicate     bool   `json:"duplicate,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// JobResponse - state of the asynchronous shortening job
+type JobResponse struct {
+	ID        string    `json:"id"`
+	Status    string    `json:"status"` // queued, running, done, failed or canceled
+	Total     int       `json:"total"`
+	Processed int       `json:"processed"`
+	Failed    int       `json:"failed"` // processed items with errors
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// JobResultResponse - result of the job item, ShortURL is empty if Error is set
+type JobResultResponse struct {
+	CorrelationID string `json:"correlation_id,omitempty"`
+	ShortURL      string `json:"short_url,omitempty"`
+	Duplicate     bool   `json:"duplicate,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// JobResultsResponse - results of the processed job items in the order of the request
+type JobResultsResponse []JobResultResponse
+
 // ShortenDeleteBatchRequest - .
 type ShortenDeleteBatchRequest []string
+
+// ShortenRestoreBatchRequest - short codes of the deleted urls
+type ShortenRestoreBatchRequest []string
+
+// ShortenRestoreBatchResponse - short urls which are restored
+type ShortenRestoreBatchResponse []string
+
+// UpdateURLRequest - new original url of the short url
+type UpdateURLRequest struct {
+	URL string `json:"url"`
+}
+
+// URLChangeResponse - change of the original url
+type URLChangeResponse struct {
+	OldURL    string    `json:"old_url"`
+	NewURL    string    `json:"new_url"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// URLHistoryResponse - changes of the original url, the oldest first
+type URLHistoryResponse []URLChangeResponse
+
+// URLTagsRequest - tags removed from and then added to the short url
+type URLTagsRequest struct {
+	Add    []string `json:"add,omitempty"`
+	Remove []string `json:"remove,omitempty"`
+}
+
+// URLTagsResponse - tags of the short url after the change
+type URLTagsResponse struct {
+	ShortURL string   `json:"short_url"`
+	Tags     []string `json:"tags"`
+}
+
+// URLStatsResponse - click statistics of the short url
+type URLStatsResponse struct {
+	ShortURL       string          `json:"short_url"`
+	TotalClicks    int64           `json:"total_clicks"`
+	UniqueVisitors int64           `json:"unique_visitors"`
+	Hourly         []StatsBucket   `json:"hourly"`
+	Daily          []StatsBucket   `json:"daily"`
+	TopReferrers   []StatsReferrer `json:"top_referrers"`
+}
+
+// StatsBucket - clicks in the time bucket
+type StatsBucket struct {
+	Start  time.Time `json:"start"`
+	Clicks int64     `json:"clicks"`
+}
+
+// StatsReferrer - clicks from the referrer
+type StatsReferrer struct {
+	Referrer string `json:"referrer"`
+	Clicks   int64  `json:"clicks"`
+}
+
+// InternalStatsResponse - service-wide statistics
+type InternalStatsResponse struct {
+	URLs        int64 `json:"urls"`
+	DeletedURLs int64 `json:"deleted_urls"`
+	Users       int64 `json:"users"`
+
+	DeleteQueue *DeleteQueueStats `json:"delete_queue,omitempty"`
+	Cache       *CacheStats       `json:"cache,omitempty"`
+}
+
+// CacheStats - counters of the url cache
+type CacheStats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+	Size   int   `json:"size"`
+}
+
+// DeleteQueueStats - counters of the asynchronous deletion
+type DeleteQueueStats struct {
+	Pending  int   `json:"pending"`
+	Enqueued int64 `json:"enqueued"`
+	Rejected int64 `json:"rejected"`
+	Deleted  int64 `json:"deleted"`
+	Failed   int64 `json:"failed"`
+	Retries  int64 `json:"retries"`
+}
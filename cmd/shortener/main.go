@@ -51,7 +51,7 @@ func main() {
 		<-sigint
 		ctx, cancelFunc := context.WithTimeout(context.Background(), time.Second*10)
 		defer cancelFunc()
-		if err2 := application.HTTPServer.Shutdown(ctx); err2 != nil {
+		if err2 := application.Shutdown(ctx); err2 != nil {
 			log.Printf("HTTP Server Shutdown Error: %v", err2)
 		}
 		close(doneCh)
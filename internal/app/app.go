@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/itksb/go-url-shortener/internal/config"
@@ -26,6 +27,11 @@ type App struct {
 	logger        logger.Interface
 	urlshortener  *shortener.Service
 	reposhortener shortener.ShortenerStorage
+	reaper        *shortener.Reaper
+	clicks        *shortener.ClickRecorder
+	deletes       *shortener.DeleteQueue
+	jobs          *shortener.JobRunner
+	denylist      *shortener.Denylist
 	enableHTTPS   bool
 
 	io.Closer
@@ -41,6 +47,12 @@ func NewApp(cfg config.Config) (*App, error) {
 	var repo shortener.ShortenerStorage
 	var db *dbstorage.Storage
 
+	codegen, err := shortener.NewCodeGenerator(cfg.ShortCode.Generator, cfg.ShortCode.Alphabet, cfg.ShortCode.Length)
+	if err != nil {
+		l.Error(fmt.Sprintf("short code generator error: %s", err.Error()))
+		return nil, err
+	}
+
 	if cfg.Dsn != "" { // use postgres database as the storage driver
 		// run migrations
 		err = migrate.Migrate(cfg.Dsn, migrate.Migrations)
@@ -48,25 +60,124 @@ func NewApp(cfg config.Config) (*App, error) {
 			l.Error(fmt.Sprintf("migration error: %s", err.Error()))
 			return nil, err
 		}
-		db, err = dbstorage.NewPostgres(cfg.Dsn, l, nil)
+		db, err = dbstorage.NewPostgres(cfg.Dsn, l, nil, codegen)
 		if err != nil {
 			l.Error(fmt.Sprintf("dbstorage.NewPostgres error: %s", err.Error()))
 		}
 		repo = db // pointer nothing criminal
 	} else if cfg.FileStoragePath != "" {
 		// file-based storage
-		repo, err = filestorage.NewStorage(l, cfg.FileStoragePath)
+		repo, err = filestorage.NewStorage(l, cfg.FileStoragePath, codegen)
 		if err != nil {
 			l.Error(fmt.Sprintf("File storage error: %s", err.Error()))
 			return nil, err
 		}
 	} else {
 		// inMemory storage
-		repo = storage.NewStorage(l)
+		repo = storage.NewStorage(l, codegen)
+	}
+	// urls are read and changed through the cache, so it is invalidated on changes
+	urlStorage := repo
+	if cfg.Cache.Enabled {
+		urlStorage = shortener.NewCachedStorage(
+			repo,
+			cfg.Cache.Size,
+			time.Duration(cfg.Cache.TTL)*time.Second,
+			time.Duration(cfg.Cache.NegativeTTL)*time.Second,
+		)
+	}
+	urlshortener := shortener.NewShortener(l, urlStorage)
+	urlValidator, err := shortener.NewURLValidator(cfg.URLValidation.Schemes, cfg.URLValidation.MaxLength)
+	if err != nil {
+		l.Error(fmt.Sprintf("url validation config error: %s", err.Error()))
+		return nil, err
+	}
+	urlshortener.UseURLValidator(urlValidator)
+	urlshortener.UseURLCanonicalizer(shortener.NewURLCanonicalizer(cfg.URLValidation.StripTracking))
+
+	var denylist *shortener.Denylist
+	if cfg.Denylist.Path != "" {
+		denylist, err = shortener.NewDenylist(l, cfg.Denylist.Path, time.Duration(cfg.Denylist.ReloadInterval)*time.Second)
+		if err != nil {
+			l.Error(fmt.Sprintf("denylist error: %s", err.Error()))
+			return nil, err
+		}
+		urlshortener.UseDenylist(denylist)
+	}
+
+	selfLinks, err := shortener.NewSelfLinks(cfg.ShortBaseURL, cfg.BaseURLAliases)
+	if err != nil {
+		l.Error(err.Error())
+		return nil, err
+	}
+	urlshortener.UseSelfLinks(selfLinks)
+	if cfg.Resolver.Enabled {
+		hosts := cfg.Resolver.Hosts
+		if len(hosts) == 0 {
+			hosts = shortener.KnownShortenerHosts
+		}
+		urlshortener.UseURLResolver(shortener.NewHTTPResolver(hosts, time.Duration(cfg.Resolver.Timeout)*time.Second, cfg.Resolver.MaxHops))
+	}
+	urlshortener.UsePasswordLimiter(shortener.NewPasswordLimiter(
+		cfg.Passwords.MaxAttempts,
+		time.Duration(cfg.Passwords.Window)*time.Second,
+	))
+
+	var clicks *shortener.ClickRecorder
+	if clickStorage, ok := repo.(shortener.ClickStorage); ok && !cfg.Clicks.Disabled {
+		clicks = shortener.NewClickRecorder(
+			l,
+			clickStorage,
+			cfg.Clicks.BufferSize,
+			cfg.Clicks.BatchSize,
+			time.Duration(cfg.Clicks.FlushInterval)*time.Millisecond,
+		)
+		urlshortener.UseClickAnalytics(clicks, clickStorage)
+	}
+
+	deletes := shortener.NewDeleteQueue(
+		l,
+		urlStorage,
+		cfg.Deletion.QueueSize,
+		cfg.Deletion.BatchSize,
+		time.Duration(cfg.Deletion.FlushInterval)*time.Millisecond,
+		cfg.Deletion.MaxRetries,
+	)
+	urlshortener.UseDeleteQueue(deletes)
+
+	// jobs survive the restart only in the database
+	var jobStorage shortener.JobStorage = storage.NewJobStorage()
+	if db != nil {
+		jobStorage = db
+	}
+	jobs := shortener.NewJobRunner(
+		l,
+		urlshortener,
+		jobStorage,
+		cfg.Jobs.Workers,
+		cfg.Jobs.QueueSize,
+		cfg.Jobs.ChunkSize,
+		cfg.Jobs.MaxItems,
+	)
+	urlshortener.UseJobs(jobs)
+
+	var reaper *shortener.Reaper
+	if cfg.ReaperInterval > 0 {
+		reaper = shortener.NewReaper(
+			l,
+			urlshortener,
+			time.Duration(cfg.ReaperInterval)*time.Second,
+			time.Duration(cfg.RetentionHours)*time.Hour,
+		)
 	}
-	urlshortener := shortener.NewShortener(l, repo)
 
 	h := handler.NewHandler(l, urlshortener, db, db, cfg)
+	clientIP, err := router.NewClientIPFunc(cfg.RateLimits.TrustedProxy)
+	if err != nil {
+		l.Error(fmt.Sprintf("Client ip resolver creating error: %s", err.Error()))
+		return nil, err
+	}
+	h.UseClientIP(clientIP)
 
 	codec, err := session.NewSecureCookie([]byte(cfg.SessionConfig.HashKey), []byte(cfg.SessionConfig.BlockKey))
 	if err != nil {
@@ -75,7 +186,12 @@ func NewApp(cfg config.Config) (*App, error) {
 	}
 	sessionStore := session.NewCookieStore(codec)
 
-	routeHandler, err := router.NewRouter(h, sessionStore, l, cfg.Debug)
+	routeHandler, err := router.NewRouter(h, sessionStore, l, cfg.Debug, cfg.TrustedSubnet, router.RateLimits{
+		Shorten:      router.RateLimit{Rate: cfg.RateLimits.Shorten.Rate, Burst: cfg.RateLimits.Shorten.Burst},
+		Redirect:     router.RateLimit{Rate: cfg.RateLimits.Redirect.Rate, Burst: cfg.RateLimits.Redirect.Burst},
+		List:         router.RateLimit{Rate: cfg.RateLimits.List.Rate, Burst: cfg.RateLimits.List.Burst},
+		TrustedProxy: cfg.RateLimits.TrustedProxy,
+	})
 	if err != nil {
 		l.Error(fmt.Sprintf("Router creating error: %s", err.Error()))
 		return nil, err
@@ -90,12 +206,26 @@ func NewApp(cfg config.Config) (*App, error) {
 		logger:        l,
 		urlshortener:  urlshortener,
 		reposhortener: repo,
+		reaper:        reaper,
+		clicks:        clicks,
+		deletes:       deletes,
+		jobs:          jobs,
+		denylist:      denylist,
 		enableHTTPS:   cfg.EnableHTTPS,
 	}, nil
 }
 
 // Run - run the application instance
 func (app *App) Run() error {
+	if app.reaper != nil {
+		app.reaper.Start()
+	}
+	if app.denylist != nil {
+		app.denylist.Start()
+	}
+	if err := app.jobs.Resume(context.Background()); err != nil {
+		app.logger.Error(fmt.Sprintf("jobs resume error: %s", err.Error()))
+	}
 	app.logger.Info("server starting", "addr", app.HTTPServer.Addr)
 	if app.enableHTTPS {
 		return app.HTTPServer.ListenAndServeTLS("", "")
@@ -103,8 +233,45 @@ func (app *App) Run() error {
 	return app.HTTPServer.ListenAndServe()
 }
 
-// Close -
+// Shutdown - gracefully stops the http server and the background jobs.
+// Call Close after it to release the storage
+func (app *App) Shutdown(ctx context.Context) error {
+	srvErr := app.HTTPServer.Shutdown(ctx)
+	if app.reaper != nil {
+		if err := app.reaper.Stop(ctx); err != nil {
+			app.logger.Error(err.Error())
+		}
+	}
+	if app.denylist != nil {
+		if err := app.denylist.Stop(ctx); err != nil {
+			app.logger.Error(err.Error())
+		}
+	}
+	// the running jobs are stopped after their current chunks and resumed on start
+	if err := app.jobs.Close(ctx); err != nil {
+		app.logger.Error(err.Error())
+	}
+	// no more redirects, so the buffered clicks can be flushed
+	if app.clicks != nil {
+		if err := app.clicks.Close(ctx); err != nil {
+			app.logger.Error(err.Error())
+		}
+	}
+	return srvErr
+}
+
+// deleteFlushTimeout - time given to the pending deletions on close
+const deleteFlushTimeout = 10 * time.Second
+
+// Close - flushes the pending deletions and releases the storage
 func (app *App) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), deleteFlushTimeout)
+	defer cancel()
+	if err := app.deletes.Close(ctx); err != nil {
+		app.logger.Error(err.Error())
+	}
+	app.logger.Info("delete queue closed", app.deletes.Metrics())
+
 	repoErr := app.reposhortener.Close()
 	urlsErr := app.urlshortener.Close()
 
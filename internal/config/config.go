@@ -18,15 +18,111 @@ type SessionConfig struct {
 
 // Config application configuration structure
 type Config struct {
-	AppPort         int           `json:"-"`                 // application port
-	AppHost         string        `json:"server_address"`    // application host
-	ShortBaseURL    string        `json:"base_url"`          // short base url
-	FileStoragePath string        `json:"file_storage_path"` // file storage path
-	SessionConfig   SessionConfig `json:"-"`                 // session configuration
-	Dsn             string        `json:"database_dsn"`      // data source name
-	Debug           bool          `json:"-"`                 // is debug mode
-	EnableHTTPS     bool          `json:"enable_https"`      // enable https
-	Config          string        `json:"-"`                 // config file path
+	AppPort         int                 `json:"-"`                 // application port
+	AppHost         string              `json:"server_address"`    // application host
+	ShortBaseURL    string              `json:"base_url"`          // short base url
+	BaseURLAliases  []string            `json:"base_url_aliases"`  // other base urls the short links are served at
+	FileStoragePath string              `json:"file_storage_path"` // file storage path
+	SessionConfig   SessionConfig       `json:"-"`                 // session configuration
+	Dsn             string              `json:"database_dsn"`      // data source name
+	Debug           bool                `json:"-"`                 // is debug mode
+	EnableHTTPS     bool                `json:"enable_https"`      // enable https
+	Config          string              `json:"-"`                 // config file path
+	ShortCode       ShortCodeConfig     `json:"short_code"`        // short code generation
+	ReaperInterval  int                 `json:"reaper_interval"`   // seconds between expired urls cleanups, 0 disables
+	RetentionHours  int                 `json:"retention_hours"`   // hours deleted urls are kept before the purge, 0 keeps them forever
+	Clicks          ClicksConfig        `json:"clicks"`            // click analytics
+	TrustedSubnet   string              `json:"trusted_subnet"`    // CIDR allowed to read internal stats, empty denies everyone
+	Deletion        DeletionConfig      `json:"deletion"`          // asynchronous deletion of urls
+	Cache           CacheConfig         `json:"cache"`             // cache of the redirect lookups
+	Jobs            JobsConfig          `json:"jobs"`              // asynchronous shortening jobs
+	URLValidation   URLValidationConfig `json:"url_validation"`    // rules of the original urls
+	Denylist        DenylistConfig      `json:"denylist"`          // hosts the urls are not shortened and redirected to
+	Resolver        ResolverConfig      `json:"resolver"`          // expansion of the third-party short links
+	Passwords       PasswordsConfig     `json:"passwords"`         // password protected links
+	RateLimits      RateLimitsConfig    `json:"rate_limits"`       // requests of one user and one ip
+}
+
+// RateLimitsConfig budgets of the route groups, every group is counted separately
+type RateLimitsConfig struct {
+	Shorten  RateLimitConfig `json:"shorten"`  // shortening of the urls
+	Redirect RateLimitConfig `json:"redirect"` // redirects, previews and qr codes
+	List     RateLimitConfig `json:"list"`     // listing of the user urls
+	// CIDR of the reverse proxies whose X-Real-IP and X-Forwarded-For are trusted,
+	// empty means the remote address is the client ip
+	TrustedProxy string `json:"trusted_proxy"`
+}
+
+// RateLimitConfig token bucket budget
+type RateLimitConfig struct {
+	Rate  float64 `json:"rate"`  // requests per second on average, 0 disables the limit
+	Burst int     `json:"burst"` // requests allowed at once
+}
+
+// PasswordsConfig password protected links configuration
+type PasswordsConfig struct {
+	MaxAttempts int `json:"max_attempts"` // wrong passwords of the link allowed within the window
+	Window      int `json:"window"`       // seconds the wrong passwords are counted
+}
+
+// ResolverConfig third-party short links expansion configuration
+type ResolverConfig struct {
+	Enabled bool     `json:"enabled"`  // expand the short links before they are shortened
+	Hosts   []string `json:"hosts"`    // hosts of the third-party shorteners, empty means the well-known ones
+	Timeout int      `json:"timeout"`  // seconds the request of the short link may take
+	MaxHops int      `json:"max_hops"` // max count of the short links in the chain
+}
+
+// DenylistConfig denied hosts configuration
+type DenylistConfig struct {
+	Path           string `json:"path"`            // file of the rules, empty disables the denylist
+	ReloadInterval int    `json:"reload_interval"` // seconds between checks of the file for changes, it is also reloaded on SIGHUP
+}
+
+// URLValidationConfig original url validation configuration
+type URLValidationConfig struct {
+	Schemes       []string `json:"schemes"`        // allowed schemes of the original urls
+	MaxLength     int      `json:"max_length"`     // max length of the original url in bytes
+	StripTracking bool     `json:"strip_tracking"` // tracking parameters like utm_* are ignored by the duplicate detection
+}
+
+// JobsConfig shortening jobs configuration
+type JobsConfig struct {
+	Workers   int `json:"workers"`    // jobs processed at once
+	QueueSize int `json:"queue_size"` // jobs waiting for the worker, extra jobs are rejected
+	ChunkSize int `json:"chunk_size"` // items shortened by one storage call, progress is saved after every chunk
+	MaxItems  int `json:"max_items"`  // max count of items of the job
+}
+
+// CacheConfig url cache configuration
+type CacheConfig struct {
+	Enabled     bool `json:"enabled"`      // cache GetURL results of the storage
+	Size        int  `json:"size"`         // max count of cached urls
+	TTL         int  `json:"ttl"`          // seconds the found url is cached
+	NegativeTTL int  `json:"negative_ttl"` // seconds the absent code is cached, 0 disables
+}
+
+// DeletionConfig delete queue configuration
+type DeletionConfig struct {
+	QueueSize     int `json:"queue_size"`     // requests waiting for the worker, extra requests are rejected
+	BatchSize     int `json:"batch_size"`     // short codes deleted at once
+	FlushInterval int `json:"flush_interval"` // milliseconds between deletions of incomplete batch
+	MaxRetries    int `json:"max_retries"`    // retries of the failed deletion
+}
+
+// ClicksConfig click analytics pipeline configuration
+type ClicksConfig struct {
+	Disabled      bool `json:"disabled"`       // do not record redirects
+	BufferSize    int  `json:"buffer_size"`    // events waiting for the write, extra events are dropped
+	BatchSize     int  `json:"batch_size"`     // events written at once
+	FlushInterval int  `json:"flush_interval"` // milliseconds between writes of incomplete batch
+}
+
+// ShortCodeConfig short code generator configuration
+type ShortCodeConfig struct {
+	Generator string `json:"generator"` // sequence|random
+	Alphabet  string `json:"alphabet"`  // symbols of the code, empty means base62
+	Length    int    `json:"length"`    // code length, for the sequence generator it is minimal length
 }
 
 // NewConfig  configuration constructor
@@ -45,6 +141,58 @@ func NewConfig() (Config, error) {
 		Debug:       false,
 		EnableHTTPS: false,
 		Config:      "",
+		ShortCode: ShortCodeConfig{
+			Generator: "random",
+			Alphabet:  "", // base62
+			Length:    8,
+		},
+		ReaperInterval: 60,
+		Clicks: ClicksConfig{
+			BufferSize:    10000,
+			BatchSize:     500,
+			FlushInterval: 1000,
+		},
+		Deletion: DeletionConfig{
+			QueueSize:     1000,
+			BatchSize:     100,
+			FlushInterval: 1000,
+			MaxRetries:    3,
+		},
+		Cache: CacheConfig{
+			Enabled:     false,
+			Size:        10000,
+			TTL:         300,
+			NegativeTTL: 10,
+		},
+		Jobs: JobsConfig{
+			Workers:   4,
+			QueueSize: 100,
+			ChunkSize: 500,
+			MaxItems:  100000,
+		},
+		URLValidation: URLValidationConfig{
+			Schemes:   []string{"http", "https"},
+			MaxLength: 2048,
+		},
+		Denylist: DenylistConfig{
+			Path:           "",
+			ReloadInterval: 10,
+		},
+		Resolver: ResolverConfig{
+			Enabled: false,
+			Timeout: 5,
+			MaxHops: 5,
+		},
+		Passwords: PasswordsConfig{
+			MaxAttempts: 5,
+			Window:      900,
+		},
+		RateLimits: RateLimitsConfig{
+			// the limits are disabled, the bursts are used when the rates are set
+			Shorten:  RateLimitConfig{Rate: 0, Burst: 20},
+			Redirect: RateLimitConfig{Rate: 0, Burst: 100},
+			List:     RateLimitConfig{Rate: 0, Burst: 20},
+		},
 	}
 	return cfg, nil
 }
@@ -74,6 +222,11 @@ func (cfg *Config) UseOsEnv() {
 		cfg.ShortBaseURL = baseURL
 	}
 
+	baseURLAliases, ok := os.LookupEnv("BASE_URL_ALIASES")
+	if ok {
+		cfg.BaseURLAliases = strings.Split(baseURLAliases, ",")
+	}
+
 	appPortStr, ok := os.LookupEnv("PORT")
 	if ok {
 		intValue := 8080
@@ -124,6 +277,299 @@ func (cfg *Config) UseOsEnv() {
 	if ok {
 		cfg.Config = configFile
 	}
+
+	codeGenerator, ok := os.LookupEnv("SHORT_CODE_GENERATOR")
+	if ok {
+		cfg.ShortCode.Generator = codeGenerator
+	}
+
+	codeAlphabet, ok := os.LookupEnv("SHORT_CODE_ALPHABET")
+	if ok {
+		cfg.ShortCode.Alphabet = codeAlphabet
+	}
+
+	codeLengthStr, ok := os.LookupEnv("SHORT_CODE_LENGTH")
+	if ok {
+		intValue := 0
+		_, err := fmt.Sscan(codeLengthStr, &intValue)
+		if err != nil {
+			log.Panic("SHORT_CODE_LENGTH value is invalid")
+		}
+		cfg.ShortCode.Length = intValue
+	}
+
+	reaperIntervalStr, ok := os.LookupEnv("REAPER_INTERVAL")
+	if ok {
+		intValue := 0
+		_, err := fmt.Sscan(reaperIntervalStr, &intValue)
+		if err != nil {
+			log.Panic("REAPER_INTERVAL value is invalid")
+		}
+		cfg.ReaperInterval = intValue
+	}
+
+	retentionHoursStr, ok := os.LookupEnv("RETENTION_HOURS")
+	if ok {
+		intValue := 0
+		_, err := fmt.Sscan(retentionHoursStr, &intValue)
+		if err != nil || intValue < 0 {
+			log.Panic("RETENTION_HOURS value is invalid")
+		}
+		cfg.RetentionHours = intValue
+	}
+
+	_, ok = os.LookupEnv("CLICKS_DISABLED")
+	if ok {
+		cfg.Clicks.Disabled = true
+	}
+
+	clicksBufferSizeStr, ok := os.LookupEnv("CLICKS_BUFFER_SIZE")
+	if ok {
+		_, err := fmt.Sscan(clicksBufferSizeStr, &cfg.Clicks.BufferSize)
+		if err != nil || cfg.Clicks.BufferSize < 1 {
+			log.Panic("CLICKS_BUFFER_SIZE value is invalid")
+		}
+	}
+
+	clicksBatchSizeStr, ok := os.LookupEnv("CLICKS_BATCH_SIZE")
+	if ok {
+		_, err := fmt.Sscan(clicksBatchSizeStr, &cfg.Clicks.BatchSize)
+		if err != nil || cfg.Clicks.BatchSize < 1 {
+			log.Panic("CLICKS_BATCH_SIZE value is invalid")
+		}
+	}
+
+	clicksFlushIntervalStr, ok := os.LookupEnv("CLICKS_FLUSH_INTERVAL")
+	if ok {
+		_, err := fmt.Sscan(clicksFlushIntervalStr, &cfg.Clicks.FlushInterval)
+		if err != nil || cfg.Clicks.FlushInterval < 1 {
+			log.Panic("CLICKS_FLUSH_INTERVAL value is invalid")
+		}
+	}
+
+	trustedSubnet, ok := os.LookupEnv("TRUSTED_SUBNET")
+	if ok {
+		cfg.TrustedSubnet = trustedSubnet
+	}
+
+	deleteQueueSizeStr, ok := os.LookupEnv("DELETE_QUEUE_SIZE")
+	if ok {
+		_, err := fmt.Sscan(deleteQueueSizeStr, &cfg.Deletion.QueueSize)
+		if err != nil || cfg.Deletion.QueueSize < 1 {
+			log.Panic("DELETE_QUEUE_SIZE value is invalid")
+		}
+	}
+
+	deleteBatchSizeStr, ok := os.LookupEnv("DELETE_BATCH_SIZE")
+	if ok {
+		_, err := fmt.Sscan(deleteBatchSizeStr, &cfg.Deletion.BatchSize)
+		if err != nil || cfg.Deletion.BatchSize < 1 {
+			log.Panic("DELETE_BATCH_SIZE value is invalid")
+		}
+	}
+
+	deleteFlushIntervalStr, ok := os.LookupEnv("DELETE_FLUSH_INTERVAL")
+	if ok {
+		_, err := fmt.Sscan(deleteFlushIntervalStr, &cfg.Deletion.FlushInterval)
+		if err != nil || cfg.Deletion.FlushInterval < 1 {
+			log.Panic("DELETE_FLUSH_INTERVAL value is invalid")
+		}
+	}
+
+	deleteMaxRetriesStr, ok := os.LookupEnv("DELETE_MAX_RETRIES")
+	if ok {
+		_, err := fmt.Sscan(deleteMaxRetriesStr, &cfg.Deletion.MaxRetries)
+		if err != nil || cfg.Deletion.MaxRetries < 0 {
+			log.Panic("DELETE_MAX_RETRIES value is invalid")
+		}
+	}
+
+	_, ok = os.LookupEnv("CACHE_ENABLED")
+	if ok {
+		cfg.Cache.Enabled = true
+	}
+
+	cacheSizeStr, ok := os.LookupEnv("CACHE_SIZE")
+	if ok {
+		_, err := fmt.Sscan(cacheSizeStr, &cfg.Cache.Size)
+		if err != nil || cfg.Cache.Size < 1 {
+			log.Panic("CACHE_SIZE value is invalid")
+		}
+	}
+
+	cacheTTLStr, ok := os.LookupEnv("CACHE_TTL")
+	if ok {
+		_, err := fmt.Sscan(cacheTTLStr, &cfg.Cache.TTL)
+		if err != nil || cfg.Cache.TTL < 1 {
+			log.Panic("CACHE_TTL value is invalid")
+		}
+	}
+
+	cacheNegativeTTLStr, ok := os.LookupEnv("CACHE_NEGATIVE_TTL")
+	if ok {
+		_, err := fmt.Sscan(cacheNegativeTTLStr, &cfg.Cache.NegativeTTL)
+		if err != nil || cfg.Cache.NegativeTTL < 0 {
+			log.Panic("CACHE_NEGATIVE_TTL value is invalid")
+		}
+	}
+
+	jobsWorkersStr, ok := os.LookupEnv("JOBS_WORKERS")
+	if ok {
+		_, err := fmt.Sscan(jobsWorkersStr, &cfg.Jobs.Workers)
+		if err != nil || cfg.Jobs.Workers < 1 {
+			log.Panic("JOBS_WORKERS value is invalid")
+		}
+	}
+
+	jobsQueueSizeStr, ok := os.LookupEnv("JOBS_QUEUE_SIZE")
+	if ok {
+		_, err := fmt.Sscan(jobsQueueSizeStr, &cfg.Jobs.QueueSize)
+		if err != nil {
+			log.Panic("JOBS_QUEUE_SIZE value is invalid")
+		}
+	}
+
+	jobsChunkSizeStr, ok := os.LookupEnv("JOBS_CHUNK_SIZE")
+	if ok {
+		_, err := fmt.Sscan(jobsChunkSizeStr, &cfg.Jobs.ChunkSize)
+		if err != nil || cfg.Jobs.ChunkSize < 1 {
+			log.Panic("JOBS_CHUNK_SIZE value is invalid")
+		}
+	}
+
+	jobsMaxItemsStr, ok := os.LookupEnv("JOBS_MAX_ITEMS")
+	if ok {
+		_, err := fmt.Sscan(jobsMaxItemsStr, &cfg.Jobs.MaxItems)
+		if err != nil {
+			log.Panic("JOBS_MAX_ITEMS value is invalid")
+		}
+	}
+
+	urlSchemes, ok := os.LookupEnv("URL_SCHEMES")
+	if ok {
+		cfg.URLValidation.Schemes = strings.Split(urlSchemes, ",")
+	}
+
+	urlMaxLengthStr, ok := os.LookupEnv("URL_MAX_LENGTH")
+	if ok {
+		_, err := fmt.Sscan(urlMaxLengthStr, &cfg.URLValidation.MaxLength)
+		if err != nil || cfg.URLValidation.MaxLength < 1 {
+			log.Panic("URL_MAX_LENGTH value is invalid")
+		}
+	}
+
+	_, ok = os.LookupEnv("URL_STRIP_TRACKING")
+	if ok {
+		cfg.URLValidation.StripTracking = true
+	}
+
+	denylistPath, ok := os.LookupEnv("DENYLIST_PATH")
+	if ok {
+		cfg.Denylist.Path = denylistPath
+	}
+
+	denylistReloadIntervalStr, ok := os.LookupEnv("DENYLIST_RELOAD_INTERVAL")
+	if ok {
+		_, err := fmt.Sscan(denylistReloadIntervalStr, &cfg.Denylist.ReloadInterval)
+		if err != nil || cfg.Denylist.ReloadInterval < 1 {
+			log.Panic("DENYLIST_RELOAD_INTERVAL value is invalid")
+		}
+	}
+
+	_, ok = os.LookupEnv("RESOLVER_ENABLED")
+	if ok {
+		cfg.Resolver.Enabled = true
+	}
+
+	resolverHosts, ok := os.LookupEnv("RESOLVER_HOSTS")
+	if ok {
+		cfg.Resolver.Hosts = strings.Split(resolverHosts, ",")
+	}
+
+	resolverTimeoutStr, ok := os.LookupEnv("RESOLVER_TIMEOUT")
+	if ok {
+		_, err := fmt.Sscan(resolverTimeoutStr, &cfg.Resolver.Timeout)
+		if err != nil || cfg.Resolver.Timeout < 1 {
+			log.Panic("RESOLVER_TIMEOUT value is invalid")
+		}
+	}
+
+	resolverMaxHopsStr, ok := os.LookupEnv("RESOLVER_MAX_HOPS")
+	if ok {
+		_, err := fmt.Sscan(resolverMaxHopsStr, &cfg.Resolver.MaxHops)
+		if err != nil || cfg.Resolver.MaxHops < 1 {
+			log.Panic("RESOLVER_MAX_HOPS value is invalid")
+		}
+	}
+
+	passwordMaxAttemptsStr, ok := os.LookupEnv("PASSWORD_MAX_ATTEMPTS")
+	if ok {
+		_, err := fmt.Sscan(passwordMaxAttemptsStr, &cfg.Passwords.MaxAttempts)
+		if err != nil || cfg.Passwords.MaxAttempts < 1 {
+			log.Panic("PASSWORD_MAX_ATTEMPTS value is invalid")
+		}
+	}
+
+	passwordWindowStr, ok := os.LookupEnv("PASSWORD_ATTEMPTS_WINDOW")
+	if ok {
+		_, err := fmt.Sscan(passwordWindowStr, &cfg.Passwords.Window)
+		if err != nil || cfg.Passwords.Window < 1 {
+			log.Panic("PASSWORD_ATTEMPTS_WINDOW value is invalid")
+		}
+	}
+
+	shortenRateStr, ok := os.LookupEnv("RATE_LIMIT_SHORTEN_RATE")
+	if ok {
+		_, err := fmt.Sscan(shortenRateStr, &cfg.RateLimits.Shorten.Rate)
+		if err != nil || cfg.RateLimits.Shorten.Rate < 0 {
+			log.Panic("RATE_LIMIT_SHORTEN_RATE value is invalid")
+		}
+	}
+
+	shortenBurstStr, ok := os.LookupEnv("RATE_LIMIT_SHORTEN_BURST")
+	if ok {
+		_, err := fmt.Sscan(shortenBurstStr, &cfg.RateLimits.Shorten.Burst)
+		if err != nil || cfg.RateLimits.Shorten.Burst < 1 {
+			log.Panic("RATE_LIMIT_SHORTEN_BURST value is invalid")
+		}
+	}
+
+	redirectRateStr, ok := os.LookupEnv("RATE_LIMIT_REDIRECT_RATE")
+	if ok {
+		_, err := fmt.Sscan(redirectRateStr, &cfg.RateLimits.Redirect.Rate)
+		if err != nil || cfg.RateLimits.Redirect.Rate < 0 {
+			log.Panic("RATE_LIMIT_REDIRECT_RATE value is invalid")
+		}
+	}
+
+	redirectBurstStr, ok := os.LookupEnv("RATE_LIMIT_REDIRECT_BURST")
+	if ok {
+		_, err := fmt.Sscan(redirectBurstStr, &cfg.RateLimits.Redirect.Burst)
+		if err != nil || cfg.RateLimits.Redirect.Burst < 1 {
+			log.Panic("RATE_LIMIT_REDIRECT_BURST value is invalid")
+		}
+	}
+
+	listRateStr, ok := os.LookupEnv("RATE_LIMIT_LIST_RATE")
+	if ok {
+		_, err := fmt.Sscan(listRateStr, &cfg.RateLimits.List.Rate)
+		if err != nil || cfg.RateLimits.List.Rate < 0 {
+			log.Panic("RATE_LIMIT_LIST_RATE value is invalid")
+		}
+	}
+
+	listBurstStr, ok := os.LookupEnv("RATE_LIMIT_LIST_BURST")
+	if ok {
+		_, err := fmt.Sscan(listBurstStr, &cfg.RateLimits.List.Burst)
+		if err != nil || cfg.RateLimits.List.Burst < 1 {
+			log.Panic("RATE_LIMIT_LIST_BURST value is invalid")
+		}
+	}
+
+	trustedProxy, ok := os.LookupEnv("RATE_LIMIT_TRUSTED_PROXY")
+	if ok {
+		cfg.RateLimits.TrustedProxy = trustedProxy
+	}
 }
 
 // UseFlags applies run flags
@@ -142,6 +588,12 @@ func (cfg *Config) UseFlags() {
 	flag.Bool("s", cfg.EnableHTTPS, "EnableHTTPS")
 	configFile := flag.String("c", cfg.Config, "CONFIG")
 	configFile2 := flag.String("config", cfg.Config, "CONFIG")
+	codeGenerator := flag.String("code-generator", cfg.ShortCode.Generator, "SHORT_CODE_GENERATOR sequence|random")
+	codeAlphabet := flag.String("code-alphabet", cfg.ShortCode.Alphabet, "SHORT_CODE_ALPHABET")
+	codeLength := flag.Int("code-length", cfg.ShortCode.Length, "SHORT_CODE_LENGTH")
+	reaperInterval := flag.Int("reaper-interval", cfg.ReaperInterval, "REAPER_INTERVAL seconds, 0 disables")
+	retentionHours := flag.Int("retention-hours", cfg.RetentionHours, "RETENTION_HOURS deleted urls are kept, 0 keeps them forever")
+	trustedSubnet := flag.String("t", cfg.TrustedSubnet, "TRUSTED_SUBNET CIDR")
 	flag.Parse()
 
 	var err error
@@ -174,6 +626,12 @@ func (cfg *Config) UseFlags() {
 	if *configFile2 != "" {
 		cfg.Config = *configFile2
 	}
+	cfg.ShortCode.Generator = *codeGenerator
+	cfg.ShortCode.Alphabet = *codeAlphabet
+	cfg.ShortCode.Length = *codeLength
+	cfg.ReaperInterval = *reaperInterval
+	cfg.RetentionHours = *retentionHours
+	cfg.TrustedSubnet = *trustedSubnet
 }
 
 func makeAppHostPort(appHost string) (string, int, error) {
@@ -214,7 +672,7 @@ func (cfg *Config) UseConfigFile() {
 		}
 		err = mergeConfigs(cfg, &config)
 		if err != nil {
-			return
+			log.Panic(fmt.Sprintf("config file %s is invalid: %s", cfg.Config, err.Error()))
 		}
 	}
 }
@@ -255,5 +713,163 @@ func mergeConfigs(result, cfg2 *Config) error {
 		result.EnableHTTPS = cfg2.EnableHTTPS
 	}
 
+	defaults, _ := NewConfig()
+	if result.ShortCode.Generator == defaults.ShortCode.Generator && cfg2.ShortCode.Generator != "" {
+		result.ShortCode.Generator = cfg2.ShortCode.Generator
+	}
+	if result.ShortCode.Alphabet == "" {
+		result.ShortCode.Alphabet = cfg2.ShortCode.Alphabet
+	}
+	if result.ShortCode.Length == defaults.ShortCode.Length && cfg2.ShortCode.Length != 0 {
+		result.ShortCode.Length = cfg2.ShortCode.Length
+	}
+	if result.ReaperInterval == defaults.ReaperInterval && cfg2.ReaperInterval != 0 {
+		result.ReaperInterval = cfg2.ReaperInterval
+	}
+	if result.RetentionHours == defaults.RetentionHours && cfg2.RetentionHours != 0 {
+		result.RetentionHours = cfg2.RetentionHours
+	}
+	if result.TrustedSubnet == "" {
+		result.TrustedSubnet = cfg2.TrustedSubnet
+	}
+	// zero values are not set
+	if cfg2.Deletion.QueueSize < 0 || cfg2.Deletion.BatchSize < 0 || cfg2.Deletion.FlushInterval < 0 {
+		return fmt.Errorf("deletion: queue_size, batch_size and flush_interval must be positive")
+	}
+	if cfg2.Deletion.MaxRetries < 0 {
+		return fmt.Errorf("deletion.max_retries must not be negative")
+	}
+	if result.Deletion.QueueSize == defaults.Deletion.QueueSize && cfg2.Deletion.QueueSize != 0 {
+		result.Deletion.QueueSize = cfg2.Deletion.QueueSize
+	}
+	if result.Deletion.BatchSize == defaults.Deletion.BatchSize && cfg2.Deletion.BatchSize != 0 {
+		result.Deletion.BatchSize = cfg2.Deletion.BatchSize
+	}
+	if result.Deletion.FlushInterval == defaults.Deletion.FlushInterval && cfg2.Deletion.FlushInterval != 0 {
+		result.Deletion.FlushInterval = cfg2.Deletion.FlushInterval
+	}
+	if result.Deletion.MaxRetries == defaults.Deletion.MaxRetries && cfg2.Deletion.MaxRetries != 0 {
+		result.Deletion.MaxRetries = cfg2.Deletion.MaxRetries
+	}
+	if !result.Cache.Enabled {
+		result.Cache.Enabled = cfg2.Cache.Enabled
+	}
+	// zero values are not set
+	if cfg2.Cache.Size < 0 || cfg2.Cache.TTL < 0 {
+		return fmt.Errorf("cache: size and ttl must be positive")
+	}
+	if cfg2.Cache.NegativeTTL < 0 {
+		return fmt.Errorf("cache.negative_ttl must not be negative")
+	}
+	if result.Cache.Size == defaults.Cache.Size && cfg2.Cache.Size != 0 {
+		result.Cache.Size = cfg2.Cache.Size
+	}
+	if result.Cache.TTL == defaults.Cache.TTL && cfg2.Cache.TTL != 0 {
+		result.Cache.TTL = cfg2.Cache.TTL
+	}
+	if result.Cache.NegativeTTL == defaults.Cache.NegativeTTL && cfg2.Cache.NegativeTTL != 0 {
+		result.Cache.NegativeTTL = cfg2.Cache.NegativeTTL
+	}
+	if result.Jobs.Workers == defaults.Jobs.Workers && cfg2.Jobs.Workers != 0 {
+		result.Jobs.Workers = cfg2.Jobs.Workers
+	}
+	if result.Jobs.QueueSize == defaults.Jobs.QueueSize && cfg2.Jobs.QueueSize != 0 {
+		result.Jobs.QueueSize = cfg2.Jobs.QueueSize
+	}
+	if result.Jobs.ChunkSize == defaults.Jobs.ChunkSize && cfg2.Jobs.ChunkSize != 0 {
+		result.Jobs.ChunkSize = cfg2.Jobs.ChunkSize
+	}
+	if result.Jobs.MaxItems == defaults.Jobs.MaxItems && cfg2.Jobs.MaxItems != 0 {
+		result.Jobs.MaxItems = cfg2.Jobs.MaxItems
+	}
+	if strings.Join(result.URLValidation.Schemes, ",") == strings.Join(defaults.URLValidation.Schemes, ",") &&
+		len(cfg2.URLValidation.Schemes) != 0 {
+		result.URLValidation.Schemes = cfg2.URLValidation.Schemes
+	}
+	if result.URLValidation.MaxLength == defaults.URLValidation.MaxLength && cfg2.URLValidation.MaxLength != 0 {
+		result.URLValidation.MaxLength = cfg2.URLValidation.MaxLength
+	}
+	if result.Denylist.Path == "" {
+		result.Denylist.Path = cfg2.Denylist.Path
+	}
+	// zero interval is not set
+	if cfg2.Denylist.ReloadInterval < 0 {
+		return fmt.Errorf("denylist.reload_interval must be positive")
+	}
+	if result.Denylist.ReloadInterval == defaults.Denylist.ReloadInterval && cfg2.Denylist.ReloadInterval != 0 {
+		result.Denylist.ReloadInterval = cfg2.Denylist.ReloadInterval
+	}
+	if len(result.BaseURLAliases) == 0 {
+		result.BaseURLAliases = cfg2.BaseURLAliases
+	}
+	if !result.Resolver.Enabled {
+		result.Resolver.Enabled = cfg2.Resolver.Enabled
+	}
+	if len(result.Resolver.Hosts) == 0 {
+		result.Resolver.Hosts = cfg2.Resolver.Hosts
+	}
+	if result.Resolver.Timeout == defaults.Resolver.Timeout && cfg2.Resolver.Timeout != 0 {
+		result.Resolver.Timeout = cfg2.Resolver.Timeout
+	}
+	if result.Resolver.MaxHops == defaults.Resolver.MaxHops && cfg2.Resolver.MaxHops != 0 {
+		result.Resolver.MaxHops = cfg2.Resolver.MaxHops
+	}
+	if result.Passwords.MaxAttempts == defaults.Passwords.MaxAttempts && cfg2.Passwords.MaxAttempts != 0 {
+		result.Passwords.MaxAttempts = cfg2.Passwords.MaxAttempts
+	}
+	if result.Passwords.Window == defaults.Passwords.Window && cfg2.Passwords.Window != 0 {
+		result.Passwords.Window = cfg2.Passwords.Window
+	}
+	for name, limit := range map[string]RateLimitConfig{
+		"shorten":  cfg2.RateLimits.Shorten,
+		"redirect": cfg2.RateLimits.Redirect,
+		"list":     cfg2.RateLimits.List,
+	} {
+		// zero burst is not set
+		if limit.Rate < 0 || limit.Burst < 0 {
+			return fmt.Errorf("rate_limits.%s: rate must not be negative and burst must be positive", name)
+		}
+	}
+	if result.RateLimits.Shorten.Rate == defaults.RateLimits.Shorten.Rate && cfg2.RateLimits.Shorten.Rate != 0 {
+		result.RateLimits.Shorten.Rate = cfg2.RateLimits.Shorten.Rate
+	}
+	if result.RateLimits.Shorten.Burst == defaults.RateLimits.Shorten.Burst && cfg2.RateLimits.Shorten.Burst != 0 {
+		result.RateLimits.Shorten.Burst = cfg2.RateLimits.Shorten.Burst
+	}
+	if result.RateLimits.Redirect.Rate == defaults.RateLimits.Redirect.Rate && cfg2.RateLimits.Redirect.Rate != 0 {
+		result.RateLimits.Redirect.Rate = cfg2.RateLimits.Redirect.Rate
+	}
+	if result.RateLimits.Redirect.Burst == defaults.RateLimits.Redirect.Burst && cfg2.RateLimits.Redirect.Burst != 0 {
+		result.RateLimits.Redirect.Burst = cfg2.RateLimits.Redirect.Burst
+	}
+	if result.RateLimits.List.Rate == defaults.RateLimits.List.Rate && cfg2.RateLimits.List.Rate != 0 {
+		result.RateLimits.List.Rate = cfg2.RateLimits.List.Rate
+	}
+	if result.RateLimits.List.Burst == defaults.RateLimits.List.Burst && cfg2.RateLimits.List.Burst != 0 {
+		result.RateLimits.List.Burst = cfg2.RateLimits.List.Burst
+	}
+	if result.RateLimits.TrustedProxy == "" {
+		result.RateLimits.TrustedProxy = cfg2.RateLimits.TrustedProxy
+	}
+	if !result.URLValidation.StripTracking {
+		result.URLValidation.StripTracking = cfg2.URLValidation.StripTracking
+	}
+	if !result.Clicks.Disabled {
+		result.Clicks.Disabled = cfg2.Clicks.Disabled
+	}
+	// zero values are not set
+	if cfg2.Clicks.BufferSize < 0 || cfg2.Clicks.BatchSize < 0 || cfg2.Clicks.FlushInterval < 0 {
+		return fmt.Errorf("clicks: buffer_size, batch_size and flush_interval must be positive")
+	}
+	if result.Clicks.BufferSize == defaults.Clicks.BufferSize && cfg2.Clicks.BufferSize != 0 {
+		result.Clicks.BufferSize = cfg2.Clicks.BufferSize
+	}
+	if result.Clicks.BatchSize == defaults.Clicks.BatchSize && cfg2.Clicks.BatchSize != 0 {
+		result.Clicks.BatchSize = cfg2.Clicks.BatchSize
+	}
+	if result.Clicks.FlushInterval == defaults.Clicks.FlushInterval && cfg2.Clicks.FlushInterval != 0 {
+		result.Clicks.FlushInterval = cfg2.Clicks.FlushInterval
+	}
+
 	return nil
 }
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestMergeConfigs(t *testing.T) {
+	result, err := NewConfig()
+	require.NoError(t, err)
+	require.NoError(t, mergeConfigs(&result, &Config{Deletion: DeletionConfig{QueueSize: 7, BatchSize: 3, FlushInterval: 5, MaxRetries: 2}}))
+	assert.Equal(t, DeletionConfig{QueueSize: 7, BatchSize: 3, FlushInterval: 5, MaxRetries: 2}, result.Deletion)
+
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{name: "negative delete queue size", cfg: Config{Deletion: DeletionConfig{QueueSize: -1}}},
+		{name: "negative delete batch size", cfg: Config{Deletion: DeletionConfig{BatchSize: -1}}},
+		{name: "negative delete flush interval", cfg: Config{Deletion: DeletionConfig{FlushInterval: -1}}},
+		{name: "negative delete max retries", cfg: Config{Deletion: DeletionConfig{MaxRetries: -1}}},
+		{name: "negative clicks buffer size", cfg: Config{Clicks: ClicksConfig{BufferSize: -1}}},
+		{name: "negative clicks batch size", cfg: Config{Clicks: ClicksConfig{BatchSize: -1}}},
+		{name: "negative clicks flush interval", cfg: Config{Clicks: ClicksConfig{FlushInterval: -1}}},
+		{name: "negative cache size", cfg: Config{Cache: CacheConfig{Size: -1}}},
+		{name: "negative cache ttl", cfg: Config{Cache: CacheConfig{TTL: -1}}},
+		{name: "negative cache negative ttl", cfg: Config{Cache: CacheConfig{NegativeTTL: -1}}},
+		{name: "negative denylist reload interval", cfg: Config{Denylist: DenylistConfig{ReloadInterval: -1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := NewConfig()
+			require.NoError(t, err)
+			assert.Error(t, mergeConfigs(&result, &tt.cfg))
+		})
+	}
+}
+
+func TestConfig_UseOsEnv(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   string
+		value string
+	}{
+		{name: "zero delete queue size", key: "DELETE_QUEUE_SIZE", value: "0"},
+		{name: "zero delete batch size", key: "DELETE_BATCH_SIZE", value: "0"},
+		{name: "zero delete flush interval", key: "DELETE_FLUSH_INTERVAL", value: "0"},
+		{name: "negative delete max retries", key: "DELETE_MAX_RETRIES", value: "-1"},
+		{name: "invalid delete max retries", key: "DELETE_MAX_RETRIES", value: "many"},
+		{name: "negative clicks buffer size", key: "CLICKS_BUFFER_SIZE", value: "-1"},
+		{name: "zero clicks batch size", key: "CLICKS_BATCH_SIZE", value: "0"},
+		{name: "zero clicks flush interval", key: "CLICKS_FLUSH_INTERVAL", value: "0"},
+		{name: "zero cache size", key: "CACHE_SIZE", value: "0"},
+		{name: "zero cache ttl", key: "CACHE_TTL", value: "0"},
+		{name: "negative cache negative ttl", key: "CACHE_NEGATIVE_TTL", value: "-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.key, tt.value)
+			cfg, err := NewConfig()
+			require.NoError(t, err)
+			assert.Panics(t, cfg.UseOsEnv)
+		})
+	}
+
+	t.Setenv("DELETE_MAX_RETRIES", "0")
+	cfg, err := NewConfig()
+	require.NoError(t, err)
+	cfg.UseOsEnv()
+	assert.Equal(t, 0, cfg.Deletion.MaxRetries)
+}
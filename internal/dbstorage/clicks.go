@@ -0,0 +1,80 @@
+package dbstorage
+
+import (
+	"context"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+)
+
+// SaveClicks persist click events in one transaction
+func (s *Storage) SaveClicks(ctx context.Context, events []shortener.ClickEvent) error {
+	var err error
+	err = s.reconnect(ctx)
+	if err != nil {
+		s.l.Error(err)
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx,
+		`INSERT INTO clicks (url_id, clicked_at, referrer, user_agent, ip_hash) VALUES ($1, $2, $3, $4, $5)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, ev := range events {
+		_, err = stmt.ExecContext(ctx, ev.URLID, ev.ClickedAt.UTC(), ev.Referrer, ev.UserAgent, ev.IPHash)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ClickStats aggregates click events of the url
+func (s *Storage) ClickStats(ctx context.Context, urlID int64, opts shortener.ClickStatsOptions) (shortener.ClickStats, error) {
+	stats := shortener.ClickStats{}
+	var err error
+	err = s.reconnect(ctx)
+	if err != nil {
+		s.l.Error(err)
+		return stats, err
+	}
+
+	err = s.db.QueryRowContext(ctx,
+		`SELECT count(*), count(DISTINCT ip_hash) FROM clicks WHERE url_id = $1`, urlID,
+	).Scan(&stats.Total, &stats.UniqueVisitors)
+	if err != nil {
+		s.l.Error(err)
+		return stats, err
+	}
+
+	bucketsQuery := `SELECT date_trunc($1, clicked_at) AS start, count(*) AS clicks FROM clicks
+                     WHERE url_id = $2 AND clicked_at >= $3 GROUP BY start ORDER BY start`
+	err = s.db.SelectContext(ctx, &stats.Hourly, bucketsQuery, "hour", urlID, opts.HourlyFrom())
+	if err != nil {
+		s.l.Error(err)
+		return stats, err
+	}
+	err = s.db.SelectContext(ctx, &stats.Daily, bucketsQuery, "day", urlID, opts.DailyFrom())
+	if err != nil {
+		s.l.Error(err)
+		return stats, err
+	}
+
+	err = s.db.SelectContext(ctx, &stats.TopReferrers,
+		`SELECT referrer, count(*) AS clicks FROM clicks WHERE url_id = $1 AND referrer <> ''
+         GROUP BY referrer ORDER BY clicks DESC, referrer LIMIT $2`, urlID, opts.TopReferrers)
+	if err != nil {
+		s.l.Error(err)
+		return stats, err
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,44 @@
+package dbstorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+)
+
+// ConsumeClick decrements clicks left of the url.
+// The conditional update makes concurrent redirects safe
+func (s *Storage) ConsumeClick(ctx context.Context, id string) error {
+	var err error
+	err = s.reconnect(ctx)
+	if err != nil {
+		s.l.Error(err)
+		return err
+	}
+
+	query := `UPDATE urls SET clicks_left = clicks_left - 1
+              WHERE short_code = $1 AND clicks_left > 0
+              RETURNING clicks_left`
+	var clicksLeft int64
+	err = s.db.QueryRowContext(ctx, query, id).Scan(&clicksLeft)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		s.l.Error(err)
+		return err
+	}
+
+	// nothing updated: either the clicks are exhausted or the url is unlimited
+	var unlimited bool
+	err = s.db.QueryRowContext(ctx, `SELECT clicks_left IS NULL FROM urls WHERE short_code = $1`, id).Scan(&unlimited)
+	if err != nil {
+		s.l.Error(err)
+		return err
+	}
+	if unlimited {
+		return nil
+	}
+	return shortener.ErrClicksExhausted
+}
@@ -0,0 +1,47 @@
+package dbstorage
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/itksb/go-url-shortener/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestStorage_ConsumeClick(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+
+	t.Run("click consumed", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		storage, err := NewPostgres("dsn", l, db, nil)
+		require.NoError(t, err)
+
+		mock.ExpectQuery("UPDATE urls SET clicks_left = clicks_left - 1").
+			WithArgs("abc").
+			WillReturnRows(sqlmock.NewRows([]string{"clicks_left"}).AddRow(0))
+
+		assert.NoError(t, storage.ConsumeClick(context.Background(), "abc"))
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("clicks exhausted", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		storage, err := NewPostgres("dsn", l, db, nil)
+		require.NoError(t, err)
+
+		mock.ExpectQuery("UPDATE urls SET clicks_left = clicks_left - 1").
+			WithArgs("abc").
+			WillReturnRows(sqlmock.NewRows([]string{"clicks_left"}))
+		mock.ExpectQuery("SELECT clicks_left IS NULL FROM urls").
+			WithArgs("abc").
+			WillReturnRows(sqlmock.NewRows([]string{"unlimited"}).AddRow(false))
+
+		assert.ErrorIs(t, storage.ConsumeClick(context.Background(), "abc"), shortener.ErrClicksExhausted)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
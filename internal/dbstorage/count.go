@@ -0,0 +1,44 @@
+package dbstorage
+
+import (
+	"context"
+)
+
+// CountURLs returns count of live and deleted urls
+func (s *Storage) CountURLs(ctx context.Context) (int64, int64, error) {
+	var err error
+	err = s.reconnect(ctx)
+	if err != nil {
+		s.l.Error(err)
+		return 0, 0, err
+	}
+
+	query := `SELECT count(*) FILTER (WHERE deleted_at IS NULL),
+                     count(*) FILTER (WHERE deleted_at IS NOT NULL)
+              FROM urls`
+	var live, deleted int64
+	err = s.db.QueryRowContext(ctx, query).Scan(&live, &deleted)
+	if err != nil {
+		s.l.Error(err)
+		return 0, 0, err
+	}
+	return live, deleted, nil
+}
+
+// CountUsers returns count of distinct owners
+func (s *Storage) CountUsers(ctx context.Context) (int64, error) {
+	var err error
+	err = s.reconnect(ctx)
+	if err != nil {
+		s.l.Error(err)
+		return 0, err
+	}
+
+	var users int64
+	err = s.db.QueryRowContext(ctx, `SELECT count(DISTINCT user_id) FROM url_owners`).Scan(&users)
+	if err != nil {
+		s.l.Error(err)
+		return 0, err
+	}
+	return users, nil
+}
@@ -0,0 +1,35 @@
+package dbstorage
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/itksb/go-url-shortener/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestStorage_Count(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	storage, err := NewPostgres("dsn", l, db, nil)
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT count\\(\\*\\) FILTER \\(WHERE deleted_at IS NULL\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"live", "deleted"}).AddRow(5, 2))
+	mock.ExpectQuery("SELECT count\\(DISTINCT user_id\\) FROM url_owners").
+		WillReturnRows(sqlmock.NewRows([]string{"users"}).AddRow(3))
+
+	live, deleted, err := storage.CountURLs(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), live)
+	assert.Equal(t, int64(2), deleted)
+
+	users, err := storage.CountUsers(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), users)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
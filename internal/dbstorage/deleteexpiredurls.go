@@ -0,0 +1,26 @@
+package dbstorage
+
+import (
+	"context"
+	"time"
+)
+
+// DeleteExpiredURLs marks urls expired at the moment now as deleted
+func (s *Storage) DeleteExpiredURLs(ctx context.Context, now time.Time) (int64, error) {
+	var err error
+	err = s.reconnect(ctx)
+	if err != nil {
+		s.l.Error(err)
+		return 0, err
+	}
+
+	query := `UPDATE urls SET deleted_at = (now() AT TIME ZONE 'UTC')
+              WHERE expires_at IS NOT NULL AND expires_at <= $1 AND deleted_at IS NULL`
+	res, err := s.db.ExecContext(ctx, query, now.UTC())
+	if err != nil {
+		s.l.Error(err)
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -3,15 +3,12 @@ package dbstorage
 
 import (
 	"context"
-	"fmt"
-	"runtime"
-	"strings"
-	"time"
+	"github.com/lib/pq"
 )
 
-// DeleteURLBatch delete urls by ids
+// DeleteURLBatch removes the user from the owners of the urls by the short codes.
+// The url is marked as deleted when it has no owners left. Foreign and already deleted urls are not changed
 func (s *Storage) DeleteURLBatch(ctx context.Context, userID string, ids []string) error {
-
 	var err error
 	err = s.reconnect(ctx)
 	if err != nil {
@@ -19,41 +16,46 @@ func (s *Storage) DeleteURLBatch(ctx context.Context, userID string, ids []strin
 		return err
 	}
 
-	inputCh := make(chan string)
-
-	// send input ids to the inputCh
-	go func() {
-		for i := 0; i < len(ids); i++ {
-			inputCh <- ids[i]
-		}
-		close(inputCh)
-	}()
-
-	go func() {
-		// здесь fanOut
-		workersCount := runtime.NumCPU()
-		fanOutChs := fanOut(inputCh, workersCount)
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		s.l.Error(err)
+		return err
+	}
+	defer tx.Rollback()
 
-		workerChs := make([]chan int64, 0, workersCount)
-		for _, fanOutCh := range fanOutChs {
-			workerCh := make(chan int64)
-			newWorker(fanOutCh, workerCh)
-			workerChs = append(workerChs, workerCh)
-		}
+	// the urls are locked, so concurrent owners do not miss the deletion of each other
+	var urlIDs []int64
+	err = tx.SelectContext(ctx, &urlIDs, `SELECT id FROM urls WHERE short_code = ANY($1) ORDER BY id FOR UPDATE`, pq.Array(ids))
+	if err != nil {
+		s.l.Error(err)
+		return err
+	}
+	if len(urlIDs) == 0 {
+		return nil
+	}
 
-		resIDs := make([]int64, len(ids))
-		// здесь fanIn
-		for v := range fanIn(workerChs...) {
-			resIDs = append(resIDs, v)
-		}
-		sqlText := fmt.Sprintf(
-			"UPDATE urls SET deleted_at = CURRENT_TIMESTAMP WHERE id in (%s)",
-			strings.Trim(strings.Replace(fmt.Sprint(resIDs), " ", ",", -1), "[]"))
+	query := `UPDATE url_owners SET deleted_at = (now() AT TIME ZONE 'UTC')
+              WHERE user_id = $1 AND url_id = ANY($2) AND deleted_at IS NULL`
+	_, err = tx.ExecContext(ctx, query, userID, pq.Array(urlIDs))
+	if err != nil {
+		s.l.Error(err)
+		return err
+	}
 
-		ctx2, cancelFunc := context.WithTimeout(ctx, time.Second*10)
-		defer cancelFunc()
-		_, err = s.db.ExecContext(ctx2, sqlText)
-	}()
+	query = `UPDATE urls SET deleted_at = (now() AT TIME ZONE 'UTC')
+             WHERE id = ANY($1) AND deleted_at IS NULL
+               AND EXISTS (SELECT 1 FROM url_owners o WHERE o.url_id = urls.id AND o.user_id = $2)
+               AND NOT EXISTS (SELECT 1 FROM url_owners o WHERE o.url_id = urls.id AND o.deleted_at IS NULL)`
+	_, err = tx.ExecContext(ctx, query, pq.Array(urlIDs), userID)
+	if err != nil {
+		s.l.Error(err)
+		return err
+	}
 
-	return err
+	err = tx.Commit()
+	if err != nil {
+		s.l.Error(err)
+		return err
+	}
+	return nil
 }
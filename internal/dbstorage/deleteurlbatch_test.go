@@ -0,0 +1,52 @@
+package dbstorage
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/itksb/go-url-shortener/pkg/logger"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestStorage_DeleteURLBatch(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+
+	t.Run("removes the owner and deletes urls without owners", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		storage, err := NewPostgres("dsn", l, db, nil)
+		require.NoError(t, err)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT id FROM urls WHERE short_code = ANY\\(\\$1\\) ORDER BY id FOR UPDATE").
+			WithArgs(pq.Array([]string{"abc", "def"})).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+		mock.ExpectExec("UPDATE url_owners SET deleted_at = \\(now\\(\\) AT TIME ZONE 'UTC'\\)\\s+WHERE user_id = \\$1 AND url_id = ANY\\(\\$2\\) AND deleted_at IS NULL").
+			WithArgs("user1", pq.Array([]int64{1, 2})).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec("UPDATE urls SET deleted_at = \\(now\\(\\) AT TIME ZONE 'UTC'\\)\\s+WHERE id = ANY\\(\\$1\\) AND deleted_at IS NULL").
+			WithArgs(pq.Array([]int64{1, 2}), "user1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		require.NoError(t, storage.DeleteURLBatch(context.Background(), "user1", []string{"abc", "def"}))
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("unknown codes", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		storage, err := NewPostgres("dsn", l, db, nil)
+		require.NoError(t, err)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT id FROM urls WHERE short_code = ANY").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectRollback()
+
+		require.NoError(t, storage.DeleteURLBatch(context.Background(), "user1", []string{"xyz"}))
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
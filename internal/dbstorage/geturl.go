@@ -2,11 +2,13 @@ package dbstorage
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"github.com/itksb/go-url-shortener/internal/shortener"
-	"strconv"
 )
 
-// GetURL retrieves url from the underlying db by id
+// GetURL retrieves url from the underlying db by the short code
 func (s *Storage) GetURL(ctx context.Context, id string) (shortener.URLListItem, error) {
 	result := shortener.URLListItem{}
 	var err error
@@ -16,14 +18,19 @@ func (s *Storage) GetURL(ctx context.Context, id string) (shortener.URLListItem,
 		return result, err
 	}
 
-	query := `SELECT id, user_id, original_url, deleted_at FROM urls WHERE id = $1`
+	query := `SELECT id, short_code, user_id, original_url, canonical_url, deleted_at, expires_at, clicks_left,
+                     COALESCE(password_hash, ''), redirect_code, pass_query, title, preview, created_at
+              FROM urls WHERE short_code = $1`
 
-	idInt64, err := strconv.ParseInt(id, 10, 64)
-	if err != nil {
-		return result, err
+	res := s.db.QueryRowContext(ctx, query, id)
+	err = res.Scan(
+		&result.ID, &result.ShortCode, &result.UserID, &result.OriginalURL, &result.CanonicalURL,
+		&result.DeletedAt, &result.ExpiresAt, &result.ClicksLeft, &result.PasswordHash,
+		&result.RedirectCode, &result.PassQuery, &result.Title, &result.Preview, &result.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return result, fmt.Errorf("%w: code %s", shortener.ErrNotFound, id)
 	}
-	res := s.db.QueryRowContext(ctx, query, idInt64)
-	err = res.Scan(&result.ID, &result.UserID, &result.OriginalURL, &result.DeletedAt)
 	if err != nil {
 		s.l.Error(err)
 		return result, err
@@ -9,6 +9,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"strconv"
 	"testing"
+	"time"
 )
 
 func TestStorage_GetURL(t *testing.T) {
@@ -20,23 +21,33 @@ func TestStorage_GetURL(t *testing.T) {
 
 	assert.NoError(t, err)
 	// instantiate storage with mock DB
-	storage, err := NewPostgres("dsn", l, db)
+	storage, err := NewPostgres("dsn", l, db, nil)
 	assert.NoError(t, err)
 
 	// test data
 	testID := int64(123)
 	expectedResult := shortener.URLListItem{
-		ID:          testID,
-		UserID:      "user1",
-		OriginalURL: "https://www.example.com",
-		DeletedAt:   nil,
+		ID:           testID,
+		ShortCode:    "123", // migrated numeric link
+		UserID:       "user1",
+		OriginalURL:  "https://www.example.com",
+		CanonicalURL: "https://www.example.com/",
+		DeletedAt:    nil,
+		PasswordHash: "$2a$10$hash",
+		RedirectCode: 308,
+		PassQuery:    true,
+		Title:        "Example",
+		Preview:      true,
+		CreatedAt:    "2023-05-29T10:00:00.123456Z",
 	}
 
 	// prepare mock DB expectations
-	rows := sqlmock.NewRows([]string{"id", "user_id", "original_url", "deleted_at"}).
-		AddRow(testID, "user1", "https://www.example.com", nil)
-	mock.ExpectQuery("SELECT id, user_id, original_url, deleted_at FROM urls WHERE id = ?").
-		WithArgs(testID).
+	rows := sqlmock.NewRows([]string{"id", "short_code", "user_id", "original_url", "canonical_url", "deleted_at", "expires_at", "clicks_left", "password_hash",
+		"redirect_code", "pass_query", "title", "preview", "created_at"}).
+		AddRow(testID, "123", "user1", "https://www.example.com", "https://www.example.com/", nil, nil, nil, "$2a$10$hash", 308, true,
+			"Example", true, time.Date(2023, 5, 29, 10, 0, 0, 123456000, time.UTC))
+	mock.ExpectQuery("SELECT (.+) FROM urls WHERE short_code = ?").
+		WithArgs("123").
 		WillReturnRows(rows)
 
 	// execute GetURL and check the result
@@ -49,3 +60,21 @@ func TestStorage_GetURL(t *testing.T) {
 	require.NoError(t, err)
 
 }
+
+func TestStorage_GetURL_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+	storage, err := NewPostgres("dsn", l, db, nil)
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT (.+) FROM urls WHERE short_code = ?").
+		WithArgs("absent").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "short_code", "user_id", "original_url", "canonical_url", "deleted_at", "expires_at", "clicks_left", "password_hash",
+			"redirect_code", "pass_query", "title", "preview", "created_at"}))
+
+	_, err = storage.GetURL(context.Background(), "absent")
+	assert.ErrorIs(t, err, shortener.ErrNotFound)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,209 @@
+package dbstorage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/lib/pq"
+	"time"
+)
+
+// CreateJob persists the new job with its items in one transaction
+func (s *Storage) CreateJob(ctx context.Context, job shortener.Job, items []shortener.JobItem) error {
+	var err error
+	err = s.reconnect(ctx)
+	if err != nil {
+		s.l.Error(err)
+		return err
+	}
+
+	encoded := make([]string, 0, len(items))
+	for _, item := range items {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		encoded = append(encoded, string(data))
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		s.l.Error(err)
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO jobs (id, user_id, status, total, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6)`,
+		job.ID, job.UserID, job.Status, len(items), job.CreatedAt.UTC(), job.UpdatedAt.UTC())
+	if err != nil {
+		s.l.Error(err)
+		return err
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO job_items (job_id, position, item)
+         SELECT $1, t.ord - 1, t.item FROM unnest($2::jsonb[]) WITH ORDINALITY AS t (item, ord)`,
+		job.ID, pq.Array(encoded))
+	if err != nil {
+		s.l.Error(err)
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetJob returns the job by id
+func (s *Storage) GetJob(ctx context.Context, id string) (shortener.Job, error) {
+	job := shortener.Job{}
+	var err error
+	err = s.reconnect(ctx)
+	if err != nil {
+		s.l.Error(err)
+		return job, err
+	}
+
+	err = s.db.GetContext(ctx, &job,
+		`SELECT id, user_id, status, total, processed, failed, error, created_at, updated_at FROM jobs WHERE id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return job, fmt.Errorf("%w: id %s", shortener.ErrJobNotFound, id)
+	}
+	if err != nil {
+		s.l.Error(err)
+		return job, err
+	}
+	return job, nil
+}
+
+// JobItems returns up to limit items of the job starting from the offset
+func (s *Storage) JobItems(ctx context.Context, id string, offset int, limit int) ([]shortener.JobItem, error) {
+	var err error
+	err = s.reconnect(ctx)
+	if err != nil {
+		s.l.Error(err)
+		return nil, err
+	}
+
+	var encoded [][]byte
+	err = s.db.SelectContext(ctx, &encoded,
+		`SELECT item FROM job_items WHERE job_id = $1 AND position >= $2 ORDER BY position LIMIT $3`, id, offset, limit)
+	if err != nil {
+		s.l.Error(err)
+		return nil, err
+	}
+	items := make([]shortener.JobItem, 0, len(encoded))
+	for _, data := range encoded {
+		item := shortener.JobItem{}
+		if err = json.Unmarshal(data, &item); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// SaveJobResults stores the results of the items starting from the offset and updates the progress.
+// Already processed items are not changed, so they are not counted twice
+func (s *Storage) SaveJobResults(ctx context.Context, id string, offset int, results []shortener.JobResult) error {
+	var err error
+	err = s.reconnect(ctx)
+	if err != nil {
+		s.l.Error(err)
+		return err
+	}
+
+	positions := make([]int64, 0, len(results))
+	codes := make([]string, 0, len(results))
+	duplicates := make([]bool, 0, len(results))
+	errs := make([]string, 0, len(results))
+	for i, result := range results {
+		positions = append(positions, int64(offset+i))
+		codes = append(codes, result.ShortCode)
+		duplicates = append(duplicates, result.Duplicate)
+		errs = append(errs, result.Error)
+	}
+
+	query := `WITH updated AS (
+                  UPDATE job_items i SET processed = true, short_code = r.short_code, duplicate = r.duplicate, error = r.error
+                  FROM unnest($2::integer[], $3::varchar[], $4::boolean[], $5::varchar[]) AS r (position, short_code, duplicate, error)
+                  WHERE i.job_id = $1 AND i.position = r.position AND NOT i.processed
+                  RETURNING i.error
+              )
+              UPDATE jobs SET processed = GREATEST(processed, $6),
+                              failed = failed + (SELECT count(*) FROM updated WHERE error <> ''),
+                              updated_at = $7
+              WHERE id = $1`
+	_, err = s.db.ExecContext(ctx, query,
+		id, pq.Array(positions), pq.Array(codes), pq.Array(duplicates), pq.Array(errs),
+		offset+len(results), time.Now().UTC())
+	if err != nil {
+		s.l.Error(err)
+		return err
+	}
+	return nil
+}
+
+// JobResults returns the results of the processed items in the order of items
+func (s *Storage) JobResults(ctx context.Context, id string) ([]shortener.JobResult, error) {
+	var err error
+	err = s.reconnect(ctx)
+	if err != nil {
+		s.l.Error(err)
+		return nil, err
+	}
+
+	results := []shortener.JobResult{}
+	query := `SELECT COALESCE(item->>'correlation_id', '') AS correlation_id, short_code, duplicate, error
+              FROM job_items WHERE job_id = $1 AND processed ORDER BY position`
+	err = s.db.SelectContext(ctx, &results, query, id)
+	if err != nil {
+		s.l.Error(err)
+		return nil, err
+	}
+	return results, nil
+}
+
+// SetJobStatus changes the status of the job if it is one of the from statuses
+func (s *Storage) SetJobStatus(ctx context.Context, id string, from []string, to string, reason string) (bool, error) {
+	var err error
+	err = s.reconnect(ctx)
+	if err != nil {
+		s.l.Error(err)
+		return false, err
+	}
+
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE jobs SET status = $2, error = $3, updated_at = $4 WHERE id = $1 AND status = ANY($5)`,
+		id, to, reason, time.Now().UTC(), pq.Array(from))
+	if err != nil {
+		s.l.Error(err)
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		s.l.Error(err)
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// UnfinishedJobIDs returns the queued and running jobs, the oldest first
+func (s *Storage) UnfinishedJobIDs(ctx context.Context) ([]string, error) {
+	var err error
+	err = s.reconnect(ctx)
+	if err != nil {
+		s.l.Error(err)
+		return nil, err
+	}
+
+	var ids []string
+	err = s.db.SelectContext(ctx, &ids,
+		`SELECT id FROM jobs WHERE status = ANY($1) ORDER BY created_at, id`,
+		pq.Array([]string{shortener.JobQueued, shortener.JobRunning}))
+	if err != nil {
+		s.l.Error(err)
+		return nil, err
+	}
+	return ids, nil
+}
@@ -0,0 +1,123 @@
+package dbstorage
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/itksb/go-url-shortener/pkg/logger"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestStorage_Jobs(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+
+	newStorage := func(t *testing.T) (*Storage, sqlmock.Sqlmock) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		storage, err := NewPostgres("dsn", l, db, nil)
+		require.NoError(t, err)
+		return storage, mock
+	}
+	ctx := context.Background()
+	now := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	t.Run("create", func(t *testing.T) {
+		storage, mock := newStorage(t)
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO jobs").
+			WithArgs("job1", "user1", shortener.JobQueued, 2, now, now).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO job_items .+ unnest\\(\\$2::jsonb\\[\\]\\) WITH ORDINALITY").
+			WithArgs("job1", pq.Array([]string{
+				`{"correlation_id":"a","original_url":"https://example.com/a"}`,
+				`{"original_url":"https://example.com/b","tags":["work"]}`,
+			})).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+
+		job := shortener.Job{ID: "job1", UserID: "user1", Status: shortener.JobQueued, CreatedAt: now, UpdatedAt: now}
+		err := storage.CreateJob(ctx, job, []shortener.JobItem{
+			{CorrelationID: "a", OriginalURL: "https://example.com/a"},
+			{OriginalURL: "https://example.com/b", Tags: []string{"work"}},
+		})
+		require.NoError(t, err)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("get", func(t *testing.T) {
+		storage, mock := newStorage(t)
+		columns := []string{"id", "user_id", "status", "total", "processed", "failed", "error", "created_at", "updated_at"}
+		mock.ExpectQuery("SELECT .+ FROM jobs WHERE id = \\$1").
+			WithArgs("job1").
+			WillReturnRows(sqlmock.NewRows(columns).AddRow("job1", "user1", shortener.JobRunning, 10, 4, 1, "", now, now))
+		mock.ExpectQuery("SELECT .+ FROM jobs WHERE id = \\$1").
+			WithArgs("unknown").
+			WillReturnRows(sqlmock.NewRows(columns))
+
+		job, err := storage.GetJob(ctx, "job1")
+		require.NoError(t, err)
+		assert.Equal(t, shortener.Job{
+			ID: "job1", UserID: "user1", Status: shortener.JobRunning,
+			Total: 10, Processed: 4, Failed: 1, CreatedAt: now, UpdatedAt: now,
+		}, job)
+		_, err = storage.GetJob(ctx, "unknown")
+		assert.ErrorIs(t, err, shortener.ErrJobNotFound)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("items", func(t *testing.T) {
+		storage, mock := newStorage(t)
+		mock.ExpectQuery("SELECT item FROM job_items").
+			WithArgs("job1", 4, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"item"}).
+				AddRow([]byte(`{"correlation_id":"e","original_url":"https://example.com/e","max_clicks":3}`)).
+				AddRow([]byte(`{"original_url":"https://example.com/f"}`)))
+
+		items, err := storage.JobItems(ctx, "job1", 4, 2)
+		require.NoError(t, err)
+		assert.Equal(t, []shortener.JobItem{
+			{CorrelationID: "e", OriginalURL: "https://example.com/e", MaxClicks: 3},
+			{OriginalURL: "https://example.com/f"},
+		}, items)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("save results", func(t *testing.T) {
+		storage, mock := newStorage(t)
+		mock.ExpectExec("UPDATE job_items .+ AND NOT i.processed .+ UPDATE jobs SET processed = GREATEST\\(processed, \\$6\\)").
+			WithArgs("job1", pq.Array([]int64{4, 5}), pq.Array([]string{"abc", ""}), pq.Array([]bool{true, false}),
+				pq.Array([]string{"", "empty url"}), 6, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := storage.SaveJobResults(ctx, "job1", 4, []shortener.JobResult{
+			{ShortCode: "abc", Duplicate: true},
+			{Error: "empty url"},
+		})
+		require.NoError(t, err)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("set status", func(t *testing.T) {
+		storage, mock := newStorage(t)
+		from := []string{shortener.JobQueued, shortener.JobRunning}
+		mock.ExpectExec("UPDATE jobs SET status = \\$2.+ status = ANY\\(\\$5\\)").
+			WithArgs("job1", shortener.JobCanceled, "", sqlmock.AnyArg(), pq.Array(from)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE jobs SET status = \\$2.+ status = ANY\\(\\$5\\)").
+			WithArgs("job2", shortener.JobCanceled, "", sqlmock.AnyArg(), pq.Array(from)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		changed, err := storage.SetJobStatus(ctx, "job1", from, shortener.JobCanceled, "")
+		require.NoError(t, err)
+		assert.True(t, changed)
+		changed, err = storage.SetJobStatus(ctx, "job2", from, shortener.JobCanceled, "")
+		require.NoError(t, err)
+		assert.False(t, changed)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
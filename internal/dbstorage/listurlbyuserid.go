@@ -4,9 +4,43 @@ package dbstorage
 import (
 	"context"
 	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/lib/pq"
 )
 
-// ListURLByUserID list urls by user
+// ownedURLsQuery selects the urls with their owners and the tags of the owners.
+// The url is deleted for the owner if the owner deleted it or it is deleted for everyone
+const ownedURLsQuery = `SELECT u.id, u.short_code, o.user_id, u.original_url, o.created_at, u.updated_at,
+                               COALESCE(o.deleted_at, u.deleted_at) AS deleted_at, u.expires_at, u.clicks_left,
+                               u.redirect_code, u.pass_query, u.title, u.preview,
+                               ARRAY(SELECT t.name FROM url_tags ut JOIN tags t ON t.id = ut.tag_id
+                                     WHERE ut.url_id = u.id AND ut.user_id = o.user_id ORDER BY t.name) AS tags
+                        FROM url_owners o JOIN urls u ON u.id = o.url_id`
+
+// ownedURLRow - row of ownedURLsQuery, the tags array is scanned by pq
+type ownedURLRow struct {
+	shortener.URLListItem
+	TagNames pq.StringArray `db:"tags"`
+}
+
+// selectOwnedURLs runs the query based on ownedURLsQuery
+func (s *Storage) selectOwnedURLs(ctx context.Context, query string, args ...interface{}) ([]shortener.URLListItem, error) {
+	var rows []ownedURLRow
+	err := s.db.SelectContext(ctx, &rows, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	urls := make([]shortener.URLListItem, 0, len(rows))
+	for _, row := range rows {
+		item := row.URLListItem
+		if len(row.TagNames) > 0 {
+			item.Tags = row.TagNames
+		}
+		urls = append(urls, item)
+	}
+	return urls, nil
+}
+
+// ListURLByUserID list urls owned by the user
 func (s *Storage) ListURLByUserID(ctx context.Context, userID string) ([]shortener.URLListItem, error) {
 	var urls = []shortener.URLListItem{}
 	var err error
@@ -16,12 +50,12 @@ func (s *Storage) ListURLByUserID(ctx context.Context, userID string) ([]shorten
 		return urls, err
 	}
 
-	query := "SELECT * FROM urls WHERE user_id=$1"
+	query := ownedURLsQuery + ` WHERE o.user_id=$1`
 
-	err = s.db.Select(&urls, query, userID)
+	urls, err = s.selectOwnedURLs(ctx, query, userID)
 	if err != nil {
 		s.l.Error(err)
-		return urls, err
+		return []shortener.URLListItem{}, err
 	}
 
 	return urls, nil
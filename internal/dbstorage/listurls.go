@@ -0,0 +1,76 @@
+package dbstorage
+
+import (
+	"context"
+	"fmt"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"strings"
+)
+
+// likeEscaper escapes the wildcards of the LIKE pattern
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// ListURLs returns the page of the urls owned by the user, created_at is the time the user shortened the url.
+// Keyset pagination by the creation time uses url_owners_user_id_created_at_idx
+func (s *Storage) ListURLs(ctx context.Context, userID string, opts shortener.ListOptions) (shortener.URLPage, error) {
+	page := shortener.URLPage{Items: []shortener.URLListItem{}}
+	var err error
+	err = s.reconnect(ctx)
+	if err != nil {
+		s.l.Error(err)
+		return page, err
+	}
+
+	// column names are never taken from the input
+	sortColumn, keyType := "o.created_at", "timestamp"
+	if opts.Sort == shortener.SortOriginalURL {
+		sortColumn, keyType = "u.original_url", "varchar"
+	}
+	direction, compare := "ASC", ">"
+	if opts.Desc {
+		direction, compare = "DESC", "<"
+	}
+
+	conditions := []string{"o.user_id = $1"}
+	args := []interface{}{userID}
+	switch {
+	case opts.OnlyDeleted:
+		conditions = append(conditions, "(o.deleted_at IS NOT NULL OR u.deleted_at IS NOT NULL)")
+	case !opts.IncludeDeleted:
+		conditions = append(conditions, "o.deleted_at IS NULL AND u.deleted_at IS NULL")
+	}
+	if opts.Query != "" {
+		args = append(args, "%"+likeEscaper.Replace(opts.Query)+"%")
+		conditions = append(conditions, fmt.Sprintf(`u.original_url ILIKE $%d ESCAPE '\'`, len(args)))
+	}
+	if opts.Tag != "" {
+		args = append(args, opts.Tag)
+		conditions = append(conditions, fmt.Sprintf(`EXISTS (SELECT 1 FROM url_tags ut JOIN tags t ON t.id = ut.tag_id
+                             WHERE ut.url_id = u.id AND ut.user_id = o.user_id AND t.name = $%d)`, len(args)))
+	}
+	if opts.Cursor != nil {
+		args = append(args, opts.Cursor.Key, opts.Cursor.ID)
+		conditions = append(conditions,
+			fmt.Sprintf("(%s, u.id) %s ($%d::%s, $%d)", sortColumn, compare, len(args)-1, keyType, len(args)))
+	}
+	query := ownedURLsQuery + ` WHERE ` + strings.Join(conditions, " AND ") +
+		fmt.Sprintf(" ORDER BY %s %s, u.id %s", sortColumn, direction, direction)
+	if opts.Limit > 0 {
+		// one more row tells whether there is the next page
+		args = append(args, opts.Limit+1)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	items, err := s.selectOwnedURLs(ctx, query, args...)
+	if err != nil {
+		s.l.Error(err)
+		return page, err
+	}
+	page.Items = items
+
+	if opts.Limit > 0 && len(page.Items) > opts.Limit {
+		page.Items = page.Items[:opts.Limit]
+		page.NextCursor = shortener.NextCursor(page.Items, opts)
+	}
+	return page, nil
+}
@@ -0,0 +1,65 @@
+package dbstorage
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/itksb/go-url-shortener/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestStorage_ListURLs(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	storage, err := NewPostgres("dsn", l, db, nil)
+	require.NoError(t, err)
+
+	columns := []string{"id", "short_code", "user_id", "original_url", "created_at", "updated_at", "deleted_at", "expires_at", "clicks_left"}
+	mock.ExpectQuery("FROM url_owners o JOIN urls u ON u.id = o.url_id WHERE o.user_id = \\$1 "+
+		"AND o.deleted_at IS NULL AND u.deleted_at IS NULL AND u.original_url ILIKE \\$2 ESCAPE '\\\\' "+
+		"AND \\(u.original_url, u.id\\) < \\(\\$3::varchar, \\$4\\) ORDER BY u.original_url DESC, u.id DESC LIMIT \\$5").
+		WithArgs("user1", `%50\%%`, "https://c.example", 7, 3).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(5, "b", "user1", "https://b.example/50%", "2023-03-01T10:00:00Z", "2023-03-01T10:00:00Z", nil, nil, nil).
+			AddRow(4, "a", "user1", "https://a.example/50%", "2023-03-01T10:00:00Z", "2023-03-01T10:00:00Z", nil, nil, nil).
+			AddRow(3, "z", "user1", "https://0.example/50%", "2023-03-01T10:00:00Z", "2023-03-01T10:00:00Z", nil, nil, nil))
+
+	page, err := storage.ListURLs(context.Background(), "user1", shortener.ListOptions{
+		Limit:  2,
+		Sort:   shortener.SortOriginalURL,
+		Desc:   true,
+		Query:  "50%",
+		Cursor: &shortener.ListCursor{Sort: shortener.SortOriginalURL, Desc: true, Key: "https://c.example", ID: 7},
+	})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 2)
+	assert.Equal(t, &shortener.ListCursor{Sort: shortener.SortOriginalURL, Desc: true, Key: "https://a.example/50%", ID: 4}, page.NextCursor)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStorage_ListURLs_Tag(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	storage, err := NewPostgres("dsn", l, db, nil)
+	require.NoError(t, err)
+
+	columns := []string{"id", "short_code", "user_id", "original_url", "created_at", "updated_at", "deleted_at", "expires_at", "clicks_left", "tags"}
+	mock.ExpectQuery("WHERE o.user_id = \\$1 AND o.deleted_at IS NULL AND u.deleted_at IS NULL "+
+		"AND EXISTS \\(SELECT 1 FROM url_tags ut JOIN tags t ON t.id = ut.tag_id\\s+WHERE ut.url_id = u.id AND ut.user_id = o.user_id AND t.name = \\$2\\) "+
+		"ORDER BY o.created_at ASC, u.id ASC").
+		WithArgs("user1", "work").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(1, "a", "user1", "https://a.example", "2023-03-01T10:00:00Z", "2023-03-01T10:00:00Z", nil, nil, nil, "{news,work}"))
+
+	page, err := storage.ListURLs(context.Background(), "user1", shortener.ListOptions{Sort: shortener.SortCreatedAt, Tag: "work"})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, []string{"news", "work"}, page.Items[0].Tags)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
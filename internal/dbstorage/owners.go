@@ -0,0 +1,36 @@
+package dbstorage
+
+import (
+	"context"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// IsURLOwner reports whether the user shortened the url
+func (s *Storage) IsURLOwner(ctx context.Context, id string, userID string) (bool, error) {
+	var err error
+	err = s.reconnect(ctx)
+	if err != nil {
+		s.l.Error(err)
+		return false, err
+	}
+
+	query := `SELECT EXISTS (SELECT 1 FROM url_owners o JOIN urls u ON u.id = o.url_id
+                             WHERE u.short_code = $1 AND o.user_id = $2)`
+	var owner bool
+	err = s.db.QueryRowContext(ctx, query, id, userID).Scan(&owner)
+	if err != nil {
+		s.l.Error(err)
+		return false, err
+	}
+	return owner, nil
+}
+
+// addOwners makes the user the owner of the urls, the ownership deleted by the user is restored.
+// The ids may repeat, a row is not allowed to be updated twice by one statement
+func addOwners(ctx context.Context, db sqlx.ExecerContext, userID string, urlIDs []int64) error {
+	query := `INSERT INTO url_owners (url_id, user_id) SELECT DISTINCT unnest($1::integer[]), $2::varchar
+              ON CONFLICT (url_id, user_id) DO UPDATE SET deleted_at = NULL WHERE url_owners.deleted_at IS NOT NULL`
+	_, err := db.ExecContext(ctx, query, pq.Array(urlIDs), userID)
+	return err
+}
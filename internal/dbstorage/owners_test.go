@@ -0,0 +1,28 @@
+package dbstorage
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/itksb/go-url-shortener/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestStorage_IsURLOwner(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	storage, err := NewPostgres("dsn", l, db, nil)
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM url_owners o JOIN urls u ON u.id = o.url_id").
+		WithArgs("abc", "user2").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	owner, err := storage.IsURLOwner(context.Background(), "abc", "user2")
+	require.NoError(t, err)
+	assert.True(t, owner)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -4,26 +4,36 @@ package dbstorage
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"github.com/itksb/go-url-shortener/internal/shortener"
 	"github.com/itksb/go-url-shortener/pkg/logger"
 	"github.com/jmoiron/sqlx"
-	//Under the hood, the driver registers itself as being available to the database/sql package,
-	//but in general nothing else happens with the exception that the init function is run.
-	_ "github.com/lib/pq"
+	//Under the hood, the driver registers itself as being available to the database/sql package.
+	//Besides that the package is used for inspecting postgres errors.
+	"github.com/lib/pq"
 )
 
 // Storage database service
 // implements ShortenerStorage interface and Closer interface
 type Storage struct {
-	dsn string
-	db  *sqlx.DB
-	l   logger.Interface
+	dsn     string
+	db      *sqlx.DB
+	l       logger.Interface
+	codegen shortener.CodeGenerator
 }
 
 const dbDriverName = "postgres"
 
+// postgres error code, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const pgUniqueViolation = "23505"
+
+const shortCodeConstraint = "urls_short_code_idx"
+
+const canonicalURLConstraint = "urls_canonical_url_idx"
+
 // NewPostgres - postgres service constructor
 // sqlDB can be nil. If nil, then it will be created
-func NewPostgres(dsn string, l logger.Interface, sqlDB *sql.DB) (*Storage, error) {
+func NewPostgres(dsn string, l logger.Interface, sqlDB *sql.DB, codegen shortener.CodeGenerator) (*Storage, error) {
 	var db *sqlx.DB
 	var err error
 	if sqlDB != nil {
@@ -33,9 +43,10 @@ func NewPostgres(dsn string, l logger.Interface, sqlDB *sql.DB) (*Storage, error
 	}
 
 	return &Storage{
-		dsn: dsn,
-		db:  db,
-		l:   l,
+		dsn:     dsn,
+		db:      db,
+		l:       l,
+		codegen: codegen,
 	}, err
 }
 
@@ -74,3 +85,9 @@ func (s *Storage) Close() error {
 	}
 	return nil
 }
+
+// isUniqueViolation checks whether err is the violation of the given unique constraint
+func isUniqueViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == pgUniqueViolation && pqErr.Constraint == constraint
+}
@@ -0,0 +1,35 @@
+package dbstorage
+
+import (
+	"context"
+	"time"
+)
+
+// PurgeDeletedURLs removes urls deleted before the moment, their clicks and owners are removed by the cascade.
+// The ownerships deleted before the moment are removed too
+func (s *Storage) PurgeDeletedURLs(ctx context.Context, before time.Time) (int64, error) {
+	var err error
+	err = s.reconnect(ctx)
+	if err != nil {
+		s.l.Error(err)
+		return 0, err
+	}
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM urls WHERE deleted_at < $1`, before.UTC())
+	if err != nil {
+		s.l.Error(err)
+		return 0, err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		s.l.Error(err)
+		return 0, err
+	}
+
+	_, err = s.db.ExecContext(ctx, `DELETE FROM url_owners WHERE deleted_at < $1`, before.UTC())
+	if err != nil {
+		s.l.Error(err)
+		return count, err
+	}
+	return count, nil
+}
@@ -0,0 +1,33 @@
+package dbstorage
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/itksb/go-url-shortener/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestStorage_PurgeDeletedURLs(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	storage, err := NewPostgres("dsn", l, db, nil)
+	require.NoError(t, err)
+
+	before := time.Date(2023, 4, 1, 10, 0, 0, 0, time.UTC)
+	mock.ExpectExec("DELETE FROM urls WHERE deleted_at < \\$1").
+		WithArgs(before).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("DELETE FROM url_owners WHERE deleted_at < \\$1").
+		WithArgs(before).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	count, err := storage.PurgeDeletedURLs(context.Background(), before)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
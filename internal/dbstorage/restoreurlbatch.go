@@ -0,0 +1,93 @@
+package dbstorage
+
+import (
+	"context"
+	"github.com/lib/pq"
+	"time"
+)
+
+// RestoreURLBatch restores the urls deleted by the user, returns the restored codes.
+// Expired, foreign and live urls are skipped, as well as the deleted urls shortened again after the deletion.
+// Of the deleted urls with the same canonical url only the oldest one is restored, the others are skipped
+func (s *Storage) RestoreURLBatch(ctx context.Context, userID string, ids []string) ([]string, error) {
+	var err error
+	err = s.reconnect(ctx)
+	if err != nil {
+		s.l.Error(err)
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		s.l.Error(err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// the urls are locked like by the deletion, so the restoration does not race with it
+	var rows []struct {
+		ID           int64  `db:"id"`
+		ShortCode    string `db:"short_code"`
+		CanonicalURL string `db:"canonical_url"`
+		Deleted      bool   `db:"deleted"`
+	}
+	query := `SELECT u.id, u.short_code, u.canonical_url, u.deleted_at IS NOT NULL AS deleted FROM urls u JOIN url_owners o ON o.url_id = u.id
+              WHERE u.short_code = ANY($1) AND o.user_id = $2 AND (u.expires_at IS NULL OR u.expires_at > $3)
+                AND (u.deleted_at IS NULL OR NOT EXISTS (SELECT 1 FROM urls l
+                                                         WHERE l.canonical_url = u.canonical_url AND l.deleted_at IS NULL))
+              ORDER BY u.id FOR UPDATE OF u`
+	err = tx.SelectContext(ctx, &rows, query, pq.Array(ids), userID, time.Now().UTC())
+	if err != nil {
+		s.l.Error(err)
+		return nil, err
+	}
+	restored := make([]string, 0, len(rows))
+	if len(rows) == 0 {
+		return restored, nil
+	}
+	// the live index allows one url of the canonical url, the restored duplicates would violate it
+	urlIDs := make([]int64, 0, len(rows))
+	taken := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		if row.Deleted {
+			if taken[row.CanonicalURL] {
+				continue
+			}
+			taken[row.CanonicalURL] = true
+		}
+		urlIDs = append(urlIDs, row.ID)
+	}
+
+	var changed []int64
+	query = `UPDATE url_owners SET deleted_at = NULL
+             WHERE user_id = $1 AND url_id = ANY($2) AND deleted_at IS NOT NULL RETURNING url_id`
+	err = tx.SelectContext(ctx, &changed, query, userID, pq.Array(urlIDs))
+	if err != nil {
+		s.l.Error(err)
+		return nil, err
+	}
+	var undeleted []int64
+	query = `UPDATE urls SET deleted_at = NULL WHERE id = ANY($1) AND deleted_at IS NOT NULL RETURNING id`
+	err = tx.SelectContext(ctx, &undeleted, query, pq.Array(urlIDs))
+	if err != nil {
+		s.l.Error(err)
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		s.l.Error(err)
+		return nil, err
+	}
+
+	isRestored := make(map[int64]bool, len(changed)+len(undeleted))
+	for _, id := range append(changed, undeleted...) {
+		isRestored[id] = true
+	}
+	for _, row := range rows {
+		if isRestored[row.ID] {
+			restored = append(restored, row.ShortCode)
+		}
+	}
+	return restored, nil
+}
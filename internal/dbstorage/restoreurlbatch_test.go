@@ -0,0 +1,42 @@
+package dbstorage
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/itksb/go-url-shortener/pkg/logger"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestStorage_RestoreURLBatch(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	storage, err := NewPostgres("dsn", l, db, nil)
+	require.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT u.id, u.short_code, u.canonical_url, .+ FROM urls u JOIN url_owners o .+ NOT EXISTS .+ FOR UPDATE OF u").
+		WithArgs(pq.Array([]string{"abc", "def", "ghi", "jkl"}), "user1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "short_code", "canonical_url", "deleted"}).
+			AddRow(1, "abc", "https://a.example", false).
+			AddRow(2, "def", "https://d.example", true).
+			AddRow(3, "ghi", "https://g.example", false).
+			AddRow(4, "jkl", "https://d.example", true))
+	// abc is deleted by the user, def is deleted for everyone, ghi is live, jkl duplicates def and stays deleted
+	mock.ExpectQuery("UPDATE url_owners SET deleted_at = NULL").
+		WithArgs("user1", pq.Array([]int64{1, 2, 3})).
+		WillReturnRows(sqlmock.NewRows([]string{"url_id"}).AddRow(1))
+	mock.ExpectQuery("UPDATE urls SET deleted_at = NULL").
+		WithArgs(pq.Array([]int64{1, 2, 3})).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectCommit()
+
+	restored, err := storage.RestoreURLBatch(context.Background(), "user1", []string{"abc", "def", "ghi", "jkl"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"abc", "def"}, restored)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -8,9 +8,9 @@ import (
 	"github.com/itksb/go-url-shortener/internal/shortener"
 )
 
-// SaveURL persist url
+// SaveURL persist url and its owner
 // to the database
-func (s *Storage) SaveURL(ctx context.Context, url string, userID string) (string, error) {
+func (s *Storage) SaveURL(ctx context.Context, url string, userID string, opts shortener.SaveOptions) (string, error) {
 	var err error
 	err = s.reconnect(ctx)
 	if err != nil {
@@ -18,25 +18,91 @@ func (s *Storage) SaveURL(ctx context.Context, url string, userID string) (strin
 		return "", err
 	}
 
-	query := `INSERT INTO urls (user_id, original_url) VALUES ($1, $2)
-              ON CONFLICT ON CONSTRAINT urls_unique_idx DO NOTHING RETURNING id`
-	row := s.db.QueryRowContext(ctx, query, userID, url)
-
-	var returningID int
-	err = row.Scan(&returningID)
+	// id is reserved before the insert, because the short code may depend on it
+	var id int64
+	err = s.db.QueryRowContext(ctx, `SELECT nextval(pg_get_serial_sequence('urls', 'id'))`).Scan(&id)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			//query does not return id, so duplicate conflict, need to retrieve id from db
-			row := s.db.QueryRowContext(ctx, `SELECT id FROM urls WHERE original_url = $1`, url)
-			err = row.Scan(&returningID)
+		s.l.Error(err)
+		return "", err
+	}
+
+	// the owner is inserted by the same statement, so the url is never left without it
+	query := `WITH inserted AS (
+                  INSERT INTO urls (id, short_code, user_id, original_url, canonical_url, expires_at, clicks_left, password_hash,
+                                    redirect_code, pass_query, title, preview)
+                  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+                  ON CONFLICT (canonical_url) WHERE deleted_at IS NULL DO NOTHING RETURNING id, user_id
+              )
+              INSERT INTO url_owners (url_id, user_id) SELECT id, user_id FROM inserted RETURNING url_id`
+
+	clicksLeft := nullClicksLeft(opts)
+	passwordHash := nullPasswordHash(opts)
+	canonicalURL := shortener.DuplicateKey(url, opts.CanonicalURL)
+
+	for attempt := 0; attempt < shortener.MaxCodeAttempts; attempt++ {
+		code := opts.Alias
+		if code == "" {
+			code, err = s.codegen.Generate(id, attempt)
+			if err != nil {
+				s.l.Error(err)
+				return "", err
+			}
+		}
+
+		var returningID int64
+		err = s.db.QueryRowContext(ctx, query, id, code, userID, url, canonicalURL, opts.ExpiresAt, clicksLeft, passwordHash,
+			opts.RedirectCode, opts.PassQuery, opts.Title, opts.Preview).Scan(&returningID)
+		switch {
+		case err == nil:
+			err = addTags(ctx, s.db, userID, []shortener.BatchItem{{Opts: opts}}, []int64{returningID})
+			if err != nil {
+				s.l.Error(err)
+				return "", err
+			}
+			return code, nil
+		case isUniqueViolation(err, shortCodeConstraint):
+			if opts.Alias != "" {
+				return "", fmt.Errorf("%w: %s", shortener.ErrAliasTaken, opts.Alias)
+			}
+			continue
+		case errors.Is(err, sql.ErrNoRows):
+			//query does not return id, so duplicate conflict, need to retrieve code from db
+			var returningCode string
+			row := s.db.QueryRowContext(ctx, `SELECT id, short_code FROM urls WHERE canonical_url = $1 AND deleted_at IS NULL`, canonicalURL)
+			err = row.Scan(&returningID, &returningCode)
+			if err != nil {
+				s.l.Error(err)
+				return "", err
+			}
+			err = addOwners(ctx, s.db, userID, []int64{returningID})
 			if err != nil {
 				s.l.Error(err)
 				return "", err
 			}
-			return fmt.Sprint(returningID), fmt.Errorf("%w", shortener.ErrDuplicate)
+			err = addTags(ctx, s.db, userID, []shortener.BatchItem{{Opts: opts}}, []int64{returningID})
+			if err != nil {
+				s.l.Error(err)
+				return "", err
+			}
+			return returningCode, fmt.Errorf("%w", shortener.ErrDuplicate)
+		default:
+			s.l.Error(err)
+			return "", err
 		}
+	}
 
+	return "", fmt.Errorf("%w: id %d", shortener.ErrCodeCollision, id)
+}
+
+// nullClicksLeft - clicks_left column value, NULL means unlimited
+func nullClicksLeft(opts shortener.SaveOptions) sql.NullInt64 {
+	if opts.MaxClicks > 0 {
+		return sql.NullInt64{Int64: opts.MaxClicks, Valid: true}
 	}
+	return sql.NullInt64{}
+}
 
-	return fmt.Sprint(returningID), nil
+// nullPasswordHash - password_hash column value, NULL means the url is public
+func nullPasswordHash(opts shortener.SaveOptions) sql.NullString {
+	return sql.NullString{String: opts.PasswordHash, Valid: opts.PasswordHash != ""}
 }
@@ -0,0 +1,87 @@
+package dbstorage
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/itksb/go-url-shortener/pkg/logger"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestStorage_SaveURL(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+
+	t.Run("retries on short code collision", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+
+		storage, err := NewPostgres("dsn", l, db, shortener.NewSequenceGenerator(shortener.Base62Alphabet, 0))
+		require.NoError(t, err)
+
+		mock.ExpectQuery("SELECT nextval").
+			WillReturnRows(sqlmock.NewRows([]string{"nextval"}).AddRow(63))
+		mock.ExpectQuery("INSERT INTO urls").
+			WithArgs(63, "11", "user1", "https://www.example.com", "https://www.example.com", nil, nil, nil, 0, false, "", false).
+			WillReturnError(&pq.Error{Code: pgUniqueViolation, Constraint: shortCodeConstraint})
+		mock.ExpectQuery("INSERT INTO urls .+ INSERT INTO url_owners").
+			WithArgs(63, "111", "user1", "https://www.example.com", "https://www.example.com", nil, nil, nil, 0, false, "", false).
+			WillReturnRows(sqlmock.NewRows([]string{"url_id"}).AddRow(63))
+
+		code, err := storage.SaveURL(context.Background(), "https://www.example.com", "user1", shortener.SaveOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "111", code)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("duplicate url", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+
+		storage, err := NewPostgres("dsn", l, db, shortener.NewSequenceGenerator(shortener.Base62Alphabet, 0))
+		require.NoError(t, err)
+
+		mock.ExpectQuery("SELECT nextval").
+			WillReturnRows(sqlmock.NewRows([]string{"nextval"}).AddRow(2))
+		mock.ExpectQuery("INSERT INTO urls .+ ON CONFLICT \\(canonical_url\\) WHERE deleted_at IS NULL DO NOTHING").
+			WithArgs(2, "2", "user1", "HTTPS://www.Example.com", "https://www.example.com/", nil, nil, nil, 0, false, "", false).
+			WillReturnRows(sqlmock.NewRows([]string{"url_id"}))
+		// the url is found by the canonical form
+		mock.ExpectQuery("SELECT id, short_code FROM urls WHERE canonical_url = .+ AND deleted_at IS NULL").
+			WithArgs("https://www.example.com/").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "short_code"}).AddRow(1, "1"))
+		// the url shortened by another user is owned by user1 too
+		mock.ExpectExec("INSERT INTO url_owners .+ ON CONFLICT \\(url_id, user_id\\) DO UPDATE SET deleted_at = NULL").
+			WithArgs(pq.Array([]int64{1}), "user1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		code, err := storage.SaveURL(context.Background(), "HTTPS://www.Example.com", "user1", shortener.SaveOptions{
+			CanonicalURL: "https://www.example.com/",
+		})
+		assert.ErrorIs(t, err, shortener.ErrDuplicate)
+		assert.Equal(t, "1", code)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+	t.Run("alias is taken", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+
+		storage, err := NewPostgres("dsn", l, db, shortener.NewSequenceGenerator(shortener.Base62Alphabet, 0))
+		require.NoError(t, err)
+
+		mock.ExpectQuery("SELECT nextval").
+			WillReturnRows(sqlmock.NewRows([]string{"nextval"}).AddRow(7))
+		mock.ExpectQuery("INSERT INTO urls").
+			WithArgs(7, "spring-sale", "user1", "https://www.example.com", "https://www.example.com", nil, nil, nil, 0, false, "", false).
+			WillReturnError(&pq.Error{Code: pgUniqueViolation, Constraint: shortCodeConstraint})
+
+		_, err = storage.SaveURL(context.Background(), "https://www.example.com", "user1", shortener.SaveOptions{
+			Alias: "spring-sale",
+		})
+		assert.ErrorIs(t, err, shortener.ErrAliasTaken)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,214 @@
+package dbstorage
+
+import (
+	"context"
+	"fmt"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"strings"
+)
+
+// batchChunkRows - rows of one insert, keeps the query under the postgres limit of parameters
+const batchChunkRows = 1000
+
+// batchColumns - inserted columns of one url
+const batchColumns = 12
+
+// SaveURLBatch persist urls and their owner in one transaction
+func (s *Storage) SaveURLBatch(ctx context.Context, userID string, items []shortener.BatchItem) ([]shortener.BatchResult, error) {
+	var err error
+	err = s.reconnect(ctx)
+	if err != nil {
+		s.l.Error(err)
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		s.l.Error(err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// ids are reserved before the insert, because the short codes may depend on them
+	var ids []int64
+	err = tx.SelectContext(ctx, &ids, `SELECT nextval(pg_get_serial_sequence('urls', 'id')) FROM generate_series(1, $1)`, len(items))
+	if err != nil {
+		s.l.Error(err)
+		return nil, err
+	}
+	if len(ids) != len(items) {
+		return nil, fmt.Errorf("%d ids are reserved for %d urls", len(ids), len(items))
+	}
+
+	codes, err := s.batchCodes(ctx, tx, ids, items)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, len(items))
+	for i, item := range items {
+		keys[i] = shortener.DuplicateKey(item.OriginalURL, item.Opts.CanonicalURL)
+	}
+	indexByID := make(map[int64]int, len(ids))
+	for i, id := range ids {
+		indexByID[id] = i
+	}
+	results := make([]shortener.BatchResult, len(items))
+	saved := make([]bool, len(items))
+	for start := 0; start < len(items); start += batchChunkRows {
+		end := start + batchChunkRows
+		if end > len(items) {
+			end = len(items)
+		}
+
+		values := make([]string, 0, end-start)
+		args := make([]interface{}, 0, (end-start)*batchColumns)
+		for i := start; i < end; i++ {
+			placeholders := make([]string, batchColumns)
+			for j := range placeholders {
+				placeholders[j] = fmt.Sprintf("$%d", len(args)+j+1)
+			}
+			values = append(values, "("+strings.Join(placeholders, ", ")+")")
+			opts := items[i].Opts
+			args = append(args, ids[i], codes[i], userID, items[i].OriginalURL, keys[i], opts.ExpiresAt,
+				nullClicksLeft(opts), nullPasswordHash(opts), opts.RedirectCode, opts.PassQuery,
+				opts.Title, opts.Preview)
+		}
+		query := `INSERT INTO urls (id, short_code, user_id, original_url, canonical_url, expires_at, clicks_left, password_hash,
+                                    redirect_code, pass_query, title, preview) VALUES ` +
+			strings.Join(values, ", ") +
+			` ON CONFLICT (canonical_url) WHERE deleted_at IS NULL DO NOTHING RETURNING id`
+
+		var savedIDs []int64
+		err = tx.SelectContext(ctx, &savedIDs, query, args...)
+		if err != nil {
+			s.l.Error(err)
+			return nil, err
+		}
+		for _, id := range savedIDs {
+			i := indexByID[id]
+			saved[i] = true
+			results[i] = shortener.BatchResult{ShortCode: codes[i]}
+		}
+	}
+
+	// urls skipped by the insert are duplicates, their codes are retrieved from db
+	var duplicates []string
+	for i := range items {
+		if !saved[i] {
+			duplicates = append(duplicates, keys[i])
+		}
+	}
+	if len(duplicates) > 0 {
+		var rows []struct {
+			ID           int64  `db:"id"`
+			CanonicalURL string `db:"canonical_url"`
+			ShortCode    string `db:"short_code"`
+		}
+		err = tx.SelectContext(ctx, &rows, `SELECT id, canonical_url, short_code FROM urls WHERE canonical_url = ANY($1) AND deleted_at IS NULL`, pq.Array(duplicates))
+		if err != nil {
+			s.l.Error(err)
+			return nil, err
+		}
+		existing := make(map[string]int, len(rows))
+		for i, row := range rows {
+			existing[row.CanonicalURL] = i
+		}
+		for i, item := range items {
+			if saved[i] {
+				continue
+			}
+			row, ok := existing[keys[i]]
+			if !ok {
+				return nil, fmt.Errorf("url %s is neither saved nor found", item.OriginalURL)
+			}
+			ids[i] = rows[row].ID
+			results[i] = shortener.BatchResult{ShortCode: rows[row].ShortCode, Duplicate: true}
+		}
+	}
+
+	// ids of the duplicates are replaced by the existing ones
+	err = addOwners(ctx, tx, userID, ids)
+	if err != nil {
+		s.l.Error(err)
+		return nil, err
+	}
+	err = addTags(ctx, tx, userID, items, ids)
+	if err != nil {
+		s.l.Error(err)
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		s.l.Error(err)
+		return nil, err
+	}
+	return results, nil
+}
+
+// batchCodes picks the short codes which are taken neither in db nor by other items of the batch
+func (s *Storage) batchCodes(ctx context.Context, tx *sqlx.Tx, ids []int64, items []shortener.BatchItem) ([]string, error) {
+	codes := make([]string, len(items))
+	attempts := make([]int, len(items))
+	settled := make([]bool, len(items))
+	pending := make([]int, 0, len(items))
+	for i := range items {
+		pending = append(pending, i)
+	}
+
+	for round := 0; round < shortener.MaxCodeAttempts && len(pending) > 0; round++ {
+		candidates := make([]string, 0, len(pending))
+		for _, i := range pending {
+			code := items[i].Opts.Alias
+			if code == "" {
+				var err error
+				code, err = s.codegen.Generate(ids[i], attempts[i])
+				if err != nil {
+					s.l.Error(err)
+					return nil, err
+				}
+			}
+			codes[i] = code
+			candidates = append(candidates, code)
+		}
+
+		var taken []string
+		err := tx.SelectContext(ctx, &taken, `SELECT short_code FROM urls WHERE short_code = ANY($1)`, pq.Array(candidates))
+		if err != nil {
+			s.l.Error(err)
+			return nil, err
+		}
+		used := make(map[string]struct{}, len(items)+len(taken))
+		for _, code := range taken {
+			used[code] = struct{}{}
+		}
+		for i, ok := range settled {
+			if ok {
+				used[codes[i]] = struct{}{}
+			}
+		}
+
+		next := pending[:0]
+		for _, i := range pending {
+			if _, ok := used[codes[i]]; !ok {
+				used[codes[i]] = struct{}{}
+				settled[i] = true
+				continue
+			}
+			if items[i].Opts.Alias != "" {
+				return nil, &shortener.BatchItemError{Index: i, Err: fmt.Errorf("%w: %s", shortener.ErrAliasTaken, items[i].Opts.Alias)}
+			}
+			attempts[i]++
+			next = append(next, i)
+		}
+		pending = next
+	}
+
+	if len(pending) > 0 {
+		return nil, &shortener.BatchItemError{Index: pending[0], Err: fmt.Errorf("%w: id %d", shortener.ErrCodeCollision, ids[pending[0]])}
+	}
+	return codes, nil
+}
@@ -0,0 +1,81 @@
+package dbstorage
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/itksb/go-url-shortener/pkg/logger"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestStorage_SaveURLBatch(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+
+	items := []shortener.BatchItem{
+		{OriginalURL: "https://www.example.com/1"},
+		{OriginalURL: "https://www.example.com/2"},
+	}
+
+	t.Run("saves new urls and marks duplicates", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		storage, err := NewPostgres("dsn", l, db, shortener.NewSequenceGenerator(shortener.Base62Alphabet, 0))
+		require.NoError(t, err)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT nextval").
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"nextval"}).AddRow(62).AddRow(63))
+		// the code of the first url is taken, it gets the next attempt
+		mock.ExpectQuery("SELECT short_code FROM urls WHERE short_code = ANY").
+			WillReturnRows(sqlmock.NewRows([]string{"short_code"}).AddRow("10"))
+		mock.ExpectQuery("SELECT short_code FROM urls WHERE short_code = ANY").
+			WillReturnRows(sqlmock.NewRows([]string{"short_code"}))
+		mock.ExpectQuery("INSERT INTO urls .+ VALUES \\(\\$1, \\$2, \\$3, \\$4, \\$5, \\$6, \\$7, \\$8, \\$9, \\$10, \\$11, \\$12\\), \\(\\$13, .+ ON CONFLICT \\(canonical_url\\) WHERE deleted_at IS NULL DO NOTHING RETURNING id").
+			WithArgs(62, "101", "user1", "https://www.example.com/1", "https://www.example.com/1", nil, nil, nil, 0, false, "", false,
+				63, "11", "user1", "https://www.example.com/2", "https://www.example.com/2", nil, nil, nil, 0, false, "", false).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(62))
+		mock.ExpectQuery("SELECT id, canonical_url, short_code FROM urls WHERE canonical_url = ANY.+ AND deleted_at IS NULL").
+			WithArgs(pq.Array([]string{"https://www.example.com/2"})).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "canonical_url", "short_code"}).AddRow(5, "https://www.example.com/2", "abc"))
+		// the user owns both the saved url and the duplicate
+		mock.ExpectExec("INSERT INTO url_owners").
+			WithArgs(pq.Array([]int64{62, 5}), "user1").
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+
+		results, err := storage.SaveURLBatch(context.Background(), "user1", items)
+		require.NoError(t, err)
+		assert.Equal(t, []shortener.BatchResult{
+			{ShortCode: "101"},
+			{ShortCode: "abc", Duplicate: true},
+		}, results)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("taken alias rolls back the batch", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		storage, err := NewPostgres("dsn", l, db, shortener.NewSequenceGenerator(shortener.Base62Alphabet, 0))
+		require.NoError(t, err)
+
+		aliased := []shortener.BatchItem{items[0], {OriginalURL: "https://www.example.com/2", Opts: shortener.SaveOptions{Alias: "promo"}}}
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT nextval").
+			WillReturnRows(sqlmock.NewRows([]string{"nextval"}).AddRow(1).AddRow(2))
+		mock.ExpectQuery("SELECT short_code FROM urls WHERE short_code = ANY").
+			WillReturnRows(sqlmock.NewRows([]string{"short_code"}).AddRow("promo"))
+		mock.ExpectRollback()
+
+		_, err = storage.SaveURLBatch(context.Background(), "user1", aliased)
+		assert.ErrorIs(t, err, shortener.ErrAliasTaken)
+		var itemErr *shortener.BatchItemError
+		require.ErrorAs(t, err, &itemErr)
+		assert.Equal(t, 1, itemErr.Index)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,97 @@
+package dbstorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// UpdateURLTags removes and then adds the tags of the url owned by the user
+func (s *Storage) UpdateURLTags(ctx context.Context, id string, userID string, add []string, remove []string) ([]string, error) {
+	var err error
+	err = s.reconnect(ctx)
+	if err != nil {
+		s.l.Error(err)
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		s.l.Error(err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var urlID int64
+	query := `SELECT o.url_id FROM url_owners o JOIN urls u ON u.id = o.url_id WHERE u.short_code = $1 AND o.user_id = $2`
+	err = tx.QueryRowContext(ctx, query, id, userID).Scan(&urlID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: code %s", shortener.ErrNotFound, id)
+	}
+	if err != nil {
+		s.l.Error(err)
+		return nil, err
+	}
+
+	if len(remove) > 0 {
+		query = `DELETE FROM url_tags ut USING tags t
+                 WHERE t.id = ut.tag_id AND ut.url_id = $1 AND ut.user_id = $2 AND t.name = ANY($3)`
+		_, err = tx.ExecContext(ctx, query, urlID, userID, pq.Array(remove))
+		if err != nil {
+			s.l.Error(err)
+			return nil, err
+		}
+	}
+	err = addTags(ctx, tx, userID, []shortener.BatchItem{{Opts: shortener.SaveOptions{Tags: add}}}, []int64{urlID})
+	if err != nil {
+		s.l.Error(err)
+		return nil, err
+	}
+
+	tags := []string{}
+	query = `SELECT t.name FROM url_tags ut JOIN tags t ON t.id = ut.tag_id
+             WHERE ut.url_id = $1 AND ut.user_id = $2 ORDER BY t.name`
+	err = tx.SelectContext(ctx, &tags, query, urlID, userID)
+	if err != nil {
+		s.l.Error(err)
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		s.l.Error(err)
+		return nil, err
+	}
+	return tags, nil
+}
+
+// addTags tags the urls of the owner with the tags of the items, urlIDs are in the order of items.
+// Tags are upserted with the update, so the conflicting rows are returned too
+func addTags(ctx context.Context, db sqlx.ExecerContext, userID string, items []shortener.BatchItem, urlIDs []int64) error {
+	var pairIDs []int64
+	var pairNames []string
+	for i, item := range items {
+		for _, tag := range item.Opts.Tags {
+			pairIDs = append(pairIDs, urlIDs[i])
+			pairNames = append(pairNames, tag)
+		}
+	}
+	if len(pairIDs) == 0 {
+		return nil
+	}
+
+	query := `WITH pairs AS (SELECT * FROM unnest($1::integer[], $2::varchar[]) AS p (url_id, name)),
+                   upserted AS (
+                       INSERT INTO tags (name) SELECT DISTINCT name FROM pairs
+                       ON CONFLICT ON CONSTRAINT tags_name_idx DO UPDATE SET name = EXCLUDED.name RETURNING id, name
+                   )
+              INSERT INTO url_tags (url_id, user_id, tag_id)
+              SELECT DISTINCT p.url_id, $3::varchar, t.id FROM pairs p JOIN upserted t ON t.name = p.name
+              ON CONFLICT DO NOTHING`
+	_, err := db.ExecContext(ctx, query, pq.Array(pairIDs), pq.Array(pairNames), userID)
+	return err
+}
@@ -0,0 +1,84 @@
+package dbstorage
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/itksb/go-url-shortener/pkg/logger"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestStorage_UpdateURLTags(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+
+	newStorage := func(t *testing.T) (*Storage, sqlmock.Sqlmock) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		storage, err := NewPostgres("dsn", l, db, nil)
+		require.NoError(t, err)
+		return storage, mock
+	}
+
+	t.Run("updated", func(t *testing.T) {
+		storage, mock := newStorage(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT o.url_id FROM url_owners o JOIN urls u").
+			WithArgs("abc", "user1").
+			WillReturnRows(sqlmock.NewRows([]string{"url_id"}).AddRow(1))
+		mock.ExpectExec("DELETE FROM url_tags ut USING tags t").
+			WithArgs(1, "user1", pq.Array([]string{"old"})).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO tags \\(name\\) SELECT DISTINCT name FROM pairs").
+			WithArgs(pq.Array([]int64{1, 1}), pq.Array([]string{"news", "work"}), "user1").
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectQuery("SELECT t.name FROM url_tags ut JOIN tags t").
+			WithArgs(1, "user1").
+			WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("news").AddRow("work"))
+		mock.ExpectCommit()
+
+		tags, err := storage.UpdateURLTags(context.Background(), "abc", "user1", []string{"news", "work"}, []string{"old"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"news", "work"}, tags)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("not owned", func(t *testing.T) {
+		storage, mock := newStorage(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT o.url_id FROM url_owners o JOIN urls u").
+			WithArgs("abc", "user2").
+			WillReturnRows(sqlmock.NewRows([]string{"url_id"}))
+		mock.ExpectRollback()
+
+		_, err := storage.UpdateURLTags(context.Background(), "abc", "user2", []string{"news"}, nil)
+		assert.ErrorIs(t, err, shortener.ErrNotFound)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestStorage_SaveURL_Tags(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	storage, err := NewPostgres("dsn", l, db, shortener.NewSequenceGenerator(shortener.Base62Alphabet, 0))
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT nextval").
+		WillReturnRows(sqlmock.NewRows([]string{"nextval"}).AddRow(1))
+	mock.ExpectQuery("INSERT INTO urls .+ INSERT INTO url_owners").
+		WithArgs(1, "1", "user1", "https://www.example.com", "https://www.example.com", nil, nil, nil, 0, false, "", false).
+		WillReturnRows(sqlmock.NewRows([]string{"url_id"}).AddRow(1))
+	mock.ExpectExec("ON CONFLICT ON CONSTRAINT tags_name_idx DO UPDATE .+ INSERT INTO url_tags").
+		WithArgs(pq.Array([]int64{1}), pq.Array([]string{"work"}), "user1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	code, err := storage.SaveURL(context.Background(), "https://www.example.com", "user1", shortener.SaveOptions{Tags: []string{"work"}})
+	require.NoError(t, err)
+	assert.Equal(t, "1", code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,78 @@
+package dbstorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+)
+
+// UpdateURL changes the original url of the live url owned by the user alone and records the change
+func (s *Storage) UpdateURL(ctx context.Context, id string, userID string, url string, canonicalURL string) error {
+	var err error
+	err = s.reconnect(ctx)
+	if err != nil {
+		s.l.Error(err)
+		return err
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		s.l.Error(err)
+		return err
+	}
+	defer tx.Rollback()
+
+	// the url is locked, so a new owner or the deletion does not race with the change
+	var urlID int64
+	var oldURL string
+	query := `SELECT u.id, u.original_url FROM urls u JOIN url_owners o ON o.url_id = u.id
+              WHERE u.short_code = $1 AND o.user_id = $2 AND o.deleted_at IS NULL AND u.deleted_at IS NULL
+              FOR UPDATE OF u`
+	err = tx.QueryRowContext(ctx, query, id, userID).Scan(&urlID, &oldURL)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: code %s", shortener.ErrNotFound, id)
+	}
+	if err != nil {
+		s.l.Error(err)
+		return err
+	}
+
+	var shared bool
+	query = `SELECT EXISTS (SELECT 1 FROM url_owners WHERE url_id = $1 AND user_id <> $2)`
+	err = tx.QueryRowContext(ctx, query, urlID, userID).Scan(&shared)
+	if err != nil {
+		s.l.Error(err)
+		return err
+	}
+	if shared {
+		return fmt.Errorf("%w: code %s", shortener.ErrURLShared, id)
+	}
+	if oldURL == url {
+		return nil
+	}
+
+	query = `UPDATE urls SET original_url = $1, canonical_url = $2, updated_at = (now() AT TIME ZONE 'UTC') WHERE id = $3`
+	_, err = tx.ExecContext(ctx, query, url, shortener.DuplicateKey(url, canonicalURL), urlID)
+	if isUniqueViolation(err, canonicalURLConstraint) {
+		return fmt.Errorf("%w", shortener.ErrDuplicate)
+	}
+	if err != nil {
+		s.l.Error(err)
+		return err
+	}
+	query = `INSERT INTO url_history (url_id, user_id, old_url, new_url) VALUES ($1, $2, $3, $4)`
+	_, err = tx.ExecContext(ctx, query, urlID, userID, oldURL, url)
+	if err != nil {
+		s.l.Error(err)
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		s.l.Error(err)
+		return err
+	}
+	return nil
+}
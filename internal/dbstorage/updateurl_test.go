@@ -0,0 +1,117 @@
+package dbstorage
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/itksb/go-url-shortener/pkg/logger"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestStorage_UpdateURL(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+
+	newStorage := func(t *testing.T) (*Storage, sqlmock.Sqlmock) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		storage, err := NewPostgres("dsn", l, db, nil)
+		require.NoError(t, err)
+		return storage, mock
+	}
+
+	t.Run("updated", func(t *testing.T) {
+		storage, mock := newStorage(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT u.id, u.original_url FROM urls u JOIN url_owners o .+ FOR UPDATE OF u").
+			WithArgs("abc", "user1").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "original_url"}).AddRow(1, "https://old.example"))
+		mock.ExpectQuery("SELECT EXISTS").WithArgs(1, "user1").
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectExec("UPDATE urls SET original_url = \\$1, canonical_url = \\$2, updated_at = \\(now\\(\\) AT TIME ZONE 'UTC'\\)").
+			WithArgs("https://new.example", "https://new.example", 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO url_history").
+			WithArgs(1, "user1", "https://old.example", "https://new.example").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := storage.UpdateURL(context.Background(), "abc", "user1", "https://new.example", "https://new.example")
+		require.NoError(t, err)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("not owned", func(t *testing.T) {
+		storage, mock := newStorage(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT u.id, u.original_url FROM urls u").
+			WithArgs("abc", "user2").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "original_url"}))
+		mock.ExpectRollback()
+
+		err := storage.UpdateURL(context.Background(), "abc", "user2", "https://new.example", "https://new.example")
+		assert.ErrorIs(t, err, shortener.ErrNotFound)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("shared", func(t *testing.T) {
+		storage, mock := newStorage(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT u.id, u.original_url FROM urls u").
+			WithArgs("abc", "user1").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "original_url"}).AddRow(1, "https://old.example"))
+		mock.ExpectQuery("SELECT EXISTS").WithArgs(1, "user1").
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectRollback()
+
+		err := storage.UpdateURL(context.Background(), "abc", "user1", "https://new.example", "https://new.example")
+		assert.ErrorIs(t, err, shortener.ErrURLShared)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("duplicate", func(t *testing.T) {
+		storage, mock := newStorage(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT u.id, u.original_url FROM urls u").
+			WithArgs("abc", "user1").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "original_url"}).AddRow(1, "https://old.example"))
+		mock.ExpectQuery("SELECT EXISTS").WithArgs(1, "user1").
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectExec("UPDATE urls SET original_url").
+			WithArgs("https://new.example", "https://new.example", 1).
+			WillReturnError(&pq.Error{Code: pgUniqueViolation, Constraint: canonicalURLConstraint})
+		mock.ExpectRollback()
+
+		err := storage.UpdateURL(context.Background(), "abc", "user1", "https://new.example", "https://new.example")
+		assert.ErrorIs(t, err, shortener.ErrDuplicate)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestStorage_URLHistory(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	storage, err := NewPostgres("dsn", l, db, nil)
+	require.NoError(t, err)
+
+	changedAt := time.Date(2023, 4, 17, 10, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT EXISTS").WithArgs("abc").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("SELECT h.user_id, h.old_url, h.new_url, h.changed_at FROM url_history h").
+		WithArgs("abc").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "old_url", "new_url", "changed_at"}).
+			AddRow("user1", "https://old.example", "https://new.example", changedAt))
+
+	changes, err := storage.URLHistory(context.Background(), "abc")
+	require.NoError(t, err)
+	assert.Equal(t, []shortener.URLChange{
+		{UserID: "user1", OldURL: "https://old.example", NewURL: "https://new.example", ChangedAt: changedAt},
+	}, changes)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,37 @@
+package dbstorage
+
+import (
+	"context"
+	"fmt"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+)
+
+// URLHistory returns changes of the original url
+func (s *Storage) URLHistory(ctx context.Context, id string) ([]shortener.URLChange, error) {
+	var err error
+	err = s.reconnect(ctx)
+	if err != nil {
+		s.l.Error(err)
+		return nil, err
+	}
+
+	var exists bool
+	err = s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM urls WHERE short_code = $1)`, id).Scan(&exists)
+	if err != nil {
+		s.l.Error(err)
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: code %s", shortener.ErrNotFound, id)
+	}
+
+	changes := []shortener.URLChange{}
+	query := `SELECT h.user_id, h.old_url, h.new_url, h.changed_at FROM url_history h
+              JOIN urls u ON u.id = h.url_id WHERE u.short_code = $1 ORDER BY h.id`
+	err = s.db.SelectContext(ctx, &changes, query, id)
+	if err != nil {
+		s.l.Error(err)
+		return nil, err
+	}
+	return changes, nil
+}
@@ -0,0 +1,62 @@
+// Package filestorage used for persisting urls in the file system
+package filestorage
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"io"
+)
+
+// clicksFileSuffix - click events are stored next to the urls file
+const clicksFileSuffix = ".clicks"
+
+// SaveClicks appends click events to the clicks file
+func (s *storage) SaveClicks(ctx context.Context, events []shortener.ClickEvent) error {
+	s.clickMtx.Lock()
+	defer s.clickMtx.Unlock()
+
+	w := bufio.NewWriter(s.clicksFile)
+	for _, ev := range events {
+		line, err := json.Marshal(ev)
+		if err != nil {
+			return err
+		}
+		if _, err = w.Write(append(line, '\n')); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// ClickStats aggregates click events of the url. Scans the whole clicks file
+func (s *storage) ClickStats(ctx context.Context, urlID int64, opts shortener.ClickStatsOptions) (shortener.ClickStats, error) {
+	s.clickMtx.Lock()
+	defer s.clickMtx.Unlock()
+
+	_, err := s.clicksFile.Seek(0, io.SeekStart)
+	if err != nil {
+		s.logger.Error(fmt.Sprintf("filestorage: clicksFile.Seek error. Err: %s", err.Error()))
+		return shortener.ClickStats{}, err
+	}
+
+	var events []shortener.ClickEvent
+	reader := newLineScanner(s.clicksFile)
+	for reader.Scan() {
+		ev := shortener.ClickEvent{}
+		if err = json.Unmarshal(reader.Bytes(), &ev); err != nil {
+			continue
+		}
+		if ev.URLID == urlID {
+			events = append(events, ev)
+		}
+	}
+	if err = reader.Err(); err != nil {
+		s.logger.Error(fmt.Sprintf("filestorage: clicks reader.Scan() error. Err: %s", err.Error()))
+		return shortener.ClickStats{}, err
+	}
+
+	return shortener.AggregateClicks(events, opts), nil
+}
@@ -0,0 +1,227 @@
+// Package filestorage used for persisting urls in the file system
+package filestorage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// deletedAtLayout - format of the deletion and update time
+const deletedAtLayout = "2006-01-02T15:04:05"
+
+// deletedBefore reports whether the deletion time in UTC is before the moment
+func deletedBefore(deletedAt string, before time.Time) bool {
+	t, err := time.Parse(deletedAtLayout, deletedAt)
+	return err == nil && t.Before(before)
+}
+
+// PurgeDeletedURLs removes urls deleted before the moment with their clicks, and the ownerships deleted before it with their tags.
+// The files are compacted: they are rewritten with the last state of every record.
+// The record with the greatest id is kept, because the id sequence is restored from it on start
+func (s *storage) PurgeDeletedURLs(ctx context.Context, before time.Time) (int64, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	records, err := s.loadAll()
+	if err != nil {
+		return 0, err
+	}
+	owners, err := s.loadOwners()
+	if err != nil {
+		return 0, err
+	}
+
+	purged := make(map[int64]struct{})
+	kept := make([]record, 0, len(records))
+	creators := make(map[int64]string, len(records))
+	for i, rec := range records {
+		if deletedBefore(rec.DeletedAt, before) && i != len(records)-1 {
+			purged[rec.ID] = struct{}{}
+			continue
+		}
+		kept = append(kept, rec)
+		creators[rec.ID] = rec.UserID
+	}
+
+	dropped := 0
+	var lines []ownerRecord
+	for urlID, users := range owners {
+		creator, ok := creators[urlID]
+		for userID, deletedAt := range users {
+			// the deleted ownership of the creator overrides the implicit one, so it is kept
+			if !ok || (userID != creator && deletedBefore(deletedAt, before)) {
+				dropped++
+				continue
+			}
+			lines = append(lines, ownerRecord{URLID: urlID, UserID: userID, DeletedAt: deletedAt})
+		}
+	}
+	if len(purged) == 0 && dropped == 0 {
+		return 0, nil
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].URLID != lines[j].URLID {
+			return lines[i].URLID < lines[j].URLID
+		}
+		return lines[i].UserID < lines[j].UserID
+	})
+
+	if err = s.compactURLs(kept); err != nil {
+		s.logger.Error(fmt.Sprintf("filestorage: compact urls error: %s", err.Error()))
+		return 0, err
+	}
+	if err = s.compactOwners(lines); err != nil {
+		s.logger.Error(fmt.Sprintf("filestorage: compact owners error: %s", err.Error()))
+		return 0, err
+	}
+	keptOwners := make(ownerLines)
+	for _, line := range lines {
+		keptOwners.set(line)
+	}
+	err = s.compactTags(func(urlID int64, userID string) bool {
+		creator, ok := creators[urlID]
+		_, owner := keptOwners[urlID][userID]
+		return ok && (userID == creator || owner)
+	})
+	if err != nil {
+		s.logger.Error(fmt.Sprintf("filestorage: compact tags error: %s", err.Error()))
+		return 0, err
+	}
+	if len(purged) > 0 {
+		if err = s.purgeHistory(purged); err != nil {
+			s.logger.Error(fmt.Sprintf("filestorage: purge history error: %s", err.Error()))
+			return 0, err
+		}
+		if err = s.purgeClicks(purged); err != nil {
+			s.logger.Error(fmt.Sprintf("filestorage: purge clicks error: %s", err.Error()))
+			return 0, err
+		}
+	}
+	return int64(len(purged)), nil
+}
+
+// compactURLs replaces the urls file with the records, the caller holds the lock
+func (s *storage) compactURLs(recs []record) error {
+	data, err := marshalLines(len(recs), func(i int) interface{} { return recs[i] })
+	if err != nil {
+		return err
+	}
+	if err = replaceFile(s.filename, data); err != nil {
+		return err
+	}
+
+	fileRead, err := os.OpenFile(s.filename, os.O_RDONLY, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	fileWrite, err := os.OpenFile(s.filename, os.O_WRONLY|os.O_APPEND|os.O_SYNC, 0777)
+	if err != nil {
+		_ = fileRead.Close()
+		return err
+	}
+	_ = s.fileRead.Close()
+	_ = s.fileWrite.Close()
+	s.fileRead, s.fileWrite = fileRead, fileWrite
+	s.reader = newLineScanner(fileRead)
+	return nil
+}
+
+// compactOwners replaces the owners file with the lines, the caller holds the lock
+func (s *storage) compactOwners(lines []ownerRecord) error {
+	data, err := marshalLines(len(lines), func(i int) interface{} { return lines[i] })
+	if err != nil {
+		return err
+	}
+	ownersFile, err := reopenReplaced(s.filename+ownersFileSuffix, data)
+	if err != nil {
+		return err
+	}
+	_ = s.ownersFile.Close()
+	s.ownersFile = ownersFile
+	return nil
+}
+
+// purgeClicks removes click events of the purged urls from the clicks file
+func (s *storage) purgeClicks(purged map[int64]struct{}) error {
+	s.clickMtx.Lock()
+	defer s.clickMtx.Unlock()
+
+	_, err := s.clicksFile.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	var events []shortener.ClickEvent
+	reader := newLineScanner(s.clicksFile)
+	for reader.Scan() {
+		ev := shortener.ClickEvent{}
+		if err = json.Unmarshal(reader.Bytes(), &ev); err != nil {
+			continue
+		}
+		if _, ok := purged[ev.URLID]; !ok {
+			events = append(events, ev)
+		}
+	}
+	if err = reader.Err(); err != nil {
+		return err
+	}
+
+	data, err := marshalLines(len(events), func(i int) interface{} { return events[i] })
+	if err != nil {
+		return err
+	}
+	clicksFile, err := reopenReplaced(s.filename+clicksFileSuffix, data)
+	if err != nil {
+		return err
+	}
+	_ = s.clicksFile.Close()
+	s.clicksFile = clicksFile
+	return nil
+}
+
+// reopenReplaced replaces the append-only file and opens it for reads and appends
+func reopenReplaced(name string, data []byte) (*os.File, error) {
+	if err := replaceFile(name, data); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(name, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0777)
+}
+
+// replaceFile writes the data to the temporary file and renames it to the name,
+// so the file is never left half-written
+func replaceFile(name string, data []byte) error {
+	tmp := name + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0777)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// marshalLines encodes n values as json lines
+func marshalLines(n int, value func(i int) interface{}) ([]byte, error) {
+	var data []byte
+	for i := 0; i < n; i++ {
+		line, err := json.Marshal(value(i))
+		if err != nil {
+			return nil, err
+		}
+		data = append(append(data, line...), '\n')
+	}
+	return data, nil
+}
@@ -0,0 +1,109 @@
+package filestorage
+
+import (
+	"bytes"
+	"context"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// countLines returns count of the lines of the file
+func countLines(t *testing.T, filename string) int {
+	data, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	return bytes.Count(data, []byte("\n"))
+}
+
+func TestStorage_PurgeDeletedURLs(t *testing.T) {
+	ctx := context.Background()
+	filename := filepath.Join(t.TempDir(), "urls.json")
+	s := openStorage(t, filename)
+
+	deleted, err := s.SaveURL(ctx, "https://example.com/deleted", "user", shortener.SaveOptions{})
+	require.NoError(t, err)
+	past := time.Now().Add(-time.Hour)
+	expired, err := s.SaveURL(ctx, "https://example.com/expired", "user", shortener.SaveOptions{ExpiresAt: &past})
+	require.NoError(t, err)
+	live, err := s.SaveURL(ctx, "https://example.com/live", "user", shortener.SaveOptions{Tags: []string{"kept"}})
+	require.NoError(t, err)
+	require.NoError(t, s.UpdateURL(ctx, live, "user", "https://example.com/updated", ""))
+	last, err := s.SaveURL(ctx, "https://example.com/last", "user", shortener.SaveOptions{})
+	require.NoError(t, err)
+
+	deletedItem, err := s.GetURL(ctx, deleted)
+	require.NoError(t, err)
+	liveItem, err := s.GetURL(ctx, live)
+	require.NoError(t, err)
+	require.NoError(t, s.SaveClicks(ctx, []shortener.ClickEvent{
+		{URLID: deletedItem.ID, ClickedAt: time.Now()},
+		{URLID: liveItem.ID, ClickedAt: time.Now()},
+	}))
+
+	require.NoError(t, s.DeleteURLBatch(ctx, "user", []string{deleted, last}))
+	count, err := s.DeleteExpiredURLs(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	// nothing is deleted before the moment
+	count, err = s.PurgeDeletedURLs(ctx, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+
+	// the last record keeps the id sequence, so it is not purged
+	count, err = s.PurgeDeletedURLs(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+	assert.Equal(t, 2, countLines(t, filename))
+
+	check := func(t *testing.T, s *storage) {
+		for _, code := range []string{deleted, expired} {
+			item, err := s.GetURL(ctx, code)
+			require.NoError(t, err)
+			assert.Empty(t, item.OriginalURL)
+		}
+		item, err := s.GetURL(ctx, live)
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/updated", item.OriginalURL)
+		item, err = s.GetURL(ctx, last)
+		require.NoError(t, err)
+		assert.True(t, item.IsDeleted())
+
+		urls, err := s.ListURLByUserID(ctx, "user")
+		require.NoError(t, err)
+		require.NotEmpty(t, urls)
+		assert.Equal(t, live, urls[0].ShortCode)
+		assert.Equal(t, []string{"kept"}, urls[0].Tags)
+
+		history, err := s.URLHistory(ctx, live)
+		require.NoError(t, err)
+		assert.Len(t, history, 1)
+		stats, err := s.ClickStats(ctx, deletedItem.ID, shortener.ClickStatsOptions{Now: time.Now(), Hours: 1, Days: 1})
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), stats.Total)
+		stats, err = s.ClickStats(ctx, liveItem.ID, shortener.ClickStatsOptions{Now: time.Now(), Hours: 1, Days: 1})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), stats.Total)
+	}
+
+	t.Run("compacted", func(t *testing.T) {
+		check(t, s)
+		// the compacted file is appended to
+		code, err := s.SaveURL(ctx, "https://example.com/new", "user", shortener.SaveOptions{})
+		require.NoError(t, err)
+		item, err := s.GetURL(ctx, code)
+		require.NoError(t, err)
+		assert.Equal(t, int64(5), item.ID)
+	})
+
+	t.Run("reloaded", func(t *testing.T) {
+		require.NoError(t, s.Close())
+		s := openStorage(t, filename)
+		check(t, s)
+		assert.Equal(t, int64(5), s.currentURLID)
+	})
+}
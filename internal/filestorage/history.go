@@ -0,0 +1,157 @@
+// Package filestorage used for persisting urls in the file system
+package filestorage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"io"
+	"time"
+)
+
+// historyFileSuffix - changes of the original urls are stored next to the urls file
+const historyFileSuffix = ".history"
+
+// historyRecord - one line of the history file
+type historyRecord struct {
+	URLID     int64     `json:"url_id"`
+	UserID    string    `json:"user_id"`
+	OldURL    string    `json:"old_url"`
+	NewURL    string    `json:"new_url"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// UpdateURL changes the original url of the live url owned by the user alone and records the change
+func (s *storage) UpdateURL(ctx context.Context, id string, userID string, url string, canonicalURL string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	records, err := s.loadAll()
+	if err != nil {
+		return err
+	}
+	owners, err := s.loadOwners()
+	if err != nil {
+		return err
+	}
+	var rec record
+	found := false
+	for _, current := range records {
+		if current.ShortCode == id {
+			rec, found = current, true
+			break
+		}
+	}
+	urlOwners := owners.of(rec)
+	deletedAt, owned := urlOwners[userID]
+	if !found || !owned || deletedAt != "" || rec.DeletedAt != "" {
+		return fmt.Errorf("%w: code %s", shortener.ErrNotFound, id)
+	}
+	if len(urlOwners) > 1 {
+		return fmt.Errorf("%w: code %s", shortener.ErrURLShared, id)
+	}
+	if rec.OriginalURL == url {
+		return nil
+	}
+	key := shortener.DuplicateKey(url, canonicalURL)
+	now := time.Now().UTC()
+	for _, current := range records {
+		if current.isDuplicateOf(key, now) && current.ID != rec.ID {
+			return fmt.Errorf("%w", shortener.ErrDuplicate)
+		}
+	}
+
+	line, err := json.Marshal(historyRecord{URLID: rec.ID, UserID: userID, OldURL: rec.OriginalURL, NewURL: url, ChangedAt: now})
+	if err != nil {
+		return err
+	}
+	if _, err = s.historyFile.Write(append(line, '\n')); err != nil {
+		s.logger.Error(err.Error())
+		return err
+	}
+	rec.OriginalURL = url
+	rec.CanonicalURL = canonicalURL
+	rec.UpdatedAt = now.Format(deletedAtLayout)
+	if err = s.persist(rec); err != nil {
+		s.logger.Error(err.Error())
+		return err
+	}
+	return nil
+}
+
+// URLHistory returns changes of the original url. Scans the whole history file
+func (s *storage) URLHistory(ctx context.Context, id string) ([]shortener.URLChange, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	item, ok := s.findByCode(id, &shortener.URLListItem{})
+	if !ok {
+		return nil, fmt.Errorf("%w: code %s", shortener.ErrNotFound, id)
+	}
+	lines, err := s.loadHistory()
+	if err != nil {
+		return nil, err
+	}
+	var changes []shortener.URLChange
+	for _, line := range lines {
+		if line.URLID == item.ID {
+			changes = append(changes, shortener.URLChange{
+				UserID:    line.UserID,
+				OldURL:    line.OldURL,
+				NewURL:    line.NewURL,
+				ChangedAt: line.ChangedAt,
+			})
+		}
+	}
+	return changes, nil
+}
+
+// loadHistory reads the history file, the caller holds the lock
+func (s *storage) loadHistory() ([]historyRecord, error) {
+	_, err := s.historyFile.Seek(0, io.SeekStart)
+	if err != nil {
+		s.logger.Error(fmt.Sprintf("filestorage: historyFile.Seek error. Err: %s", err.Error()))
+		return nil, err
+	}
+
+	var lines []historyRecord
+	reader := newLineScanner(s.historyFile)
+	for reader.Scan() {
+		line := historyRecord{}
+		if err = json.Unmarshal(reader.Bytes(), &line); err != nil {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err = reader.Err(); err != nil {
+		s.logger.Error(fmt.Sprintf("filestorage: history reader.Scan() error. Err: %s", err.Error()))
+		return nil, err
+	}
+	return lines, nil
+}
+
+// purgeHistory removes changes of the purged urls from the history file, the caller holds the lock
+func (s *storage) purgeHistory(purged map[int64]struct{}) error {
+	lines, err := s.loadHistory()
+	if err != nil {
+		return err
+	}
+	kept := lines[:0]
+	for _, line := range lines {
+		if _, ok := purged[line.URLID]; !ok {
+			kept = append(kept, line)
+		}
+	}
+	data, err := marshalLines(len(kept), func(i int) interface{} { return kept[i] })
+	if err != nil {
+		return err
+	}
+	historyFile, err := reopenReplaced(s.filename+historyFileSuffix, data)
+	if err != nil {
+		return err
+	}
+	_ = s.historyFile.Close()
+	s.historyFile = historyFile
+	return nil
+}
@@ -0,0 +1,59 @@
+package filestorage
+
+import (
+	"context"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func TestStorage_UpdateURL(t *testing.T) {
+	ctx := context.Background()
+	filename := filepath.Join(t.TempDir(), "urls.json")
+	s := openStorage(t, filename)
+
+	code, err := s.SaveURL(ctx, "https://example.com/a", "alice", shortener.SaveOptions{})
+	require.NoError(t, err)
+	_, err = s.SaveURL(ctx, "https://example.com/taken", "alice", shortener.SaveOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, s.UpdateURL(ctx, code, "alice", "https://example.com/b", ""))
+	require.NoError(t, s.UpdateURL(ctx, code, "alice", "https://example.com/c", ""))
+	// the same url is not a change
+	require.NoError(t, s.UpdateURL(ctx, code, "alice", "https://example.com/c", ""))
+	assert.ErrorIs(t, s.UpdateURL(ctx, code, "alice", "https://example.com/taken", ""), shortener.ErrDuplicate)
+	assert.ErrorIs(t, s.UpdateURL(ctx, code, "bob", "https://example.com/d", ""), shortener.ErrNotFound)
+	assert.ErrorIs(t, s.UpdateURL(ctx, "unknown", "alice", "https://example.com/d", ""), shortener.ErrNotFound)
+
+	// the url is found by the new key, the old one is free
+	dup, err := s.SaveURL(ctx, "https://example.com/c", "bob", shortener.SaveOptions{})
+	assert.ErrorIs(t, err, shortener.ErrDuplicate)
+	assert.Equal(t, code, dup)
+	_, err = s.SaveURL(ctx, "https://example.com/a", "alice", shortener.SaveOptions{})
+	require.NoError(t, err)
+	// the shared url is not changed
+	assert.ErrorIs(t, s.UpdateURL(ctx, code, "alice", "https://example.com/d", ""), shortener.ErrURLShared)
+
+	_, err = s.URLHistory(ctx, "unknown")
+	assert.ErrorIs(t, err, shortener.ErrNotFound)
+	check := func(s *storage) {
+		item, err := s.GetURL(ctx, code)
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/c", item.OriginalURL)
+		assert.NotEmpty(t, item.UpdatedAt)
+
+		history, err := s.URLHistory(ctx, code)
+		require.NoError(t, err)
+		require.Len(t, history, 2)
+		assert.Equal(t, shortener.URLChange{UserID: "alice", OldURL: "https://example.com/a", NewURL: "https://example.com/b", ChangedAt: history[0].ChangedAt}, history[0])
+		assert.Equal(t, shortener.URLChange{UserID: "alice", OldURL: "https://example.com/b", NewURL: "https://example.com/c", ChangedAt: history[1].ChangedAt}, history[1])
+		assert.False(t, history[1].ChangedAt.Before(history[0].ChangedAt))
+	}
+	check(s)
+
+	// the history is reloaded after the restart
+	require.NoError(t, s.Close())
+	check(openStorage(t, filename))
+}
@@ -0,0 +1,167 @@
+// Package filestorage used for persisting urls in the file system
+package filestorage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"io"
+)
+
+// ownersFileSuffix - owners of the urls are stored next to the urls file
+const ownersFileSuffix = ".owners"
+
+// ownerRecord - one line of the owners file.
+// The last line with the same url id and user id wins
+type ownerRecord struct {
+	URLID     int64  `json:"url_id"`
+	UserID    string `json:"user_id"`
+	DeletedAt string `json:"deleted_at,omitempty"`
+}
+
+// ownerLines - url id -> user id -> deletion time of the ownership, empty if the user owns the url
+type ownerLines map[int64]map[string]string
+
+// of returns the owners of the url. The user who shortened the url owns it unless the owners file says otherwise
+func (o ownerLines) of(rec record) map[string]string {
+	owners := map[string]string{rec.UserID: ""}
+	for userID, deletedAt := range o[rec.ID] {
+		owners[userID] = deletedAt
+	}
+	return owners
+}
+
+// set applies the line to the owners
+func (o ownerLines) set(line ownerRecord) {
+	if o[line.URLID] == nil {
+		o[line.URLID] = make(map[string]string)
+	}
+	o[line.URLID][line.UserID] = line.DeletedAt
+}
+
+// IsURLOwner reports whether the user shortened the url
+func (s *storage) IsURLOwner(ctx context.Context, id string, userID string) (bool, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	records, err := s.loadAll()
+	if err != nil {
+		return false, err
+	}
+	owners, err := s.loadOwners()
+	if err != nil {
+		return false, err
+	}
+	for _, rec := range records {
+		if rec.ShortCode == id {
+			_, ok := owners.of(rec)[userID]
+			return ok, nil
+		}
+	}
+	return false, nil
+}
+
+// ownedURLs returns urls of the user, deleted by the user are marked as deleted. The caller holds the lock
+func (s *storage) ownedURLs(userID string) ([]shortener.URLListItem, error) {
+	records, err := s.loadAll()
+	if err != nil {
+		return nil, err
+	}
+	owners, err := s.loadOwners()
+	if err != nil {
+		return nil, err
+	}
+	tags, err := s.loadTags()
+	if err != nil {
+		return nil, err
+	}
+	var items []shortener.URLListItem
+	for _, rec := range records {
+		deletedAt, ok := owners.of(rec)[userID]
+		if !ok {
+			continue
+		}
+		item := rec.toListItem()
+		item.UserID = userID
+		item.Tags = tags[rec.ID][userID]
+		if deletedAt != "" {
+			item.DeletedAt = &deletedAt
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// own makes the user the owner of the urls, the caller holds the lock
+func (s *storage) own(userID string, recs ...record) error {
+	if len(recs) == 0 {
+		return nil
+	}
+	owners, err := s.loadOwners()
+	if err != nil {
+		return err
+	}
+	var lines []ownerRecord
+	for _, rec := range recs {
+		if deletedAt, ok := owners.of(rec)[userID]; ok && deletedAt == "" {
+			continue
+		}
+		line := ownerRecord{URLID: rec.ID, UserID: userID}
+		owners.set(line)
+		lines = append(lines, line)
+	}
+	return s.persistOwners(lines...)
+}
+
+// loadOwners reads the owners file, the caller holds the lock
+func (s *storage) loadOwners() (ownerLines, error) {
+	_, err := s.ownersFile.Seek(0, io.SeekStart)
+	if err != nil {
+		s.logger.Error(fmt.Sprintf("filestorage: ownersFile.Seek error. Err: %s", err.Error()))
+		return nil, err
+	}
+
+	owners := make(ownerLines)
+	reader := newLineScanner(s.ownersFile)
+	for reader.Scan() {
+		line := ownerRecord{}
+		if err = json.Unmarshal(reader.Bytes(), &line); err != nil {
+			continue
+		}
+		owners.set(line)
+	}
+
+	if err = reader.Err(); err != nil {
+		s.logger.Error(fmt.Sprintf("filestorage: owners reader.Scan() error. Err: %s", err.Error()))
+		return nil, err
+	}
+	return owners, nil
+}
+
+// persistOwners appends the lines to the owners file with one write
+func (s *storage) persistOwners(lines ...ownerRecord) error {
+	var data []byte
+	for _, line := range lines {
+		encoded, err := json.Marshal(line)
+		if err != nil {
+			return err
+		}
+		data = append(append(data, encoded...), '\n')
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	_, err := s.ownersFile.Write(data)
+	return err
+}
+
+// hasLiveOwner reports whether some owner has not deleted the url
+func hasLiveOwner(owners map[string]string) bool {
+	for _, deletedAt := range owners {
+		if deletedAt == "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package filestorage
+
+import (
+	"context"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func TestStorage_Owners(t *testing.T) {
+	ctx := context.Background()
+	filename := filepath.Join(t.TempDir(), "urls.json")
+	s := openStorage(t, filename)
+
+	code, err := s.SaveURL(ctx, "https://example.com", "alice", shortener.SaveOptions{})
+	require.NoError(t, err)
+	shared, err := s.SaveURL(ctx, "https://example.com", "bob", shortener.SaveOptions{})
+	assert.ErrorIs(t, err, shortener.ErrDuplicate)
+	assert.Equal(t, code, shared)
+
+	isOwner := func(s *storage, code string, userID string) bool {
+		owner, err := s.IsURLOwner(ctx, code, userID)
+		require.NoError(t, err)
+		return owner
+	}
+	isDeletedBy := func(s *storage, userID string) bool {
+		urls, err := s.ListURLByUserID(ctx, userID)
+		require.NoError(t, err)
+		require.Len(t, urls, 1)
+		assert.Equal(t, userID, urls[0].UserID)
+		return urls[0].IsDeleted()
+	}
+	isDeleted := func(s *storage) bool {
+		item, err := s.GetURL(ctx, code)
+		require.NoError(t, err)
+		return item.IsDeleted()
+	}
+
+	assert.True(t, isOwner(s, code, "alice"))
+	assert.True(t, isOwner(s, code, "bob"))
+	assert.False(t, isOwner(s, code, "carol"))
+	assert.False(t, isOwner(s, "unknown", "alice"))
+
+	// the url is deleted when its last owner deletes it
+	require.NoError(t, s.DeleteURLBatch(ctx, "alice", []string{code}))
+	assert.True(t, isDeletedBy(s, "alice"))
+	assert.False(t, isDeletedBy(s, "bob"))
+	assert.False(t, isDeleted(s))
+	require.NoError(t, s.DeleteURLBatch(ctx, "bob", []string{code}))
+	assert.True(t, isDeleted(s))
+
+	restored, err := s.RestoreURLBatch(ctx, "alice", []string{code})
+	require.NoError(t, err)
+	assert.Equal(t, []string{code}, restored)
+	assert.False(t, isDeleted(s))
+	assert.False(t, isDeletedBy(s, "alice"))
+	assert.True(t, isDeletedBy(s, "bob"))
+
+	// the ownerships are reloaded after the restart
+	require.NoError(t, s.Close())
+	s = openStorage(t, filename)
+	assert.True(t, isOwner(s, code, "alice"))
+	assert.True(t, isOwner(s, code, "bob"))
+	assert.False(t, isDeletedBy(s, "alice"))
+	assert.True(t, isDeletedBy(s, "bob"))
+	assert.False(t, isDeleted(s))
+}
@@ -0,0 +1,133 @@
+// Package filestorage used for persisting urls in the file system
+package filestorage
+
+import (
+	"encoding/json"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// record - one line of the storage file.
+// The last line with the same id wins
+type record struct {
+	ID           int64      `json:"id"`
+	ShortCode    string     `json:"short_code"`
+	OriginalURL  string     `json:"original_url"`
+	CanonicalURL string     `json:"canonical_url,omitempty"` // empty for the legacy records
+	UserID       string     `json:"user_id"`
+	DeletedAt    string     `json:"deleted_at,omitempty"`
+	UpdatedAt    string     `json:"updated_at,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	ClicksLeft   *int64     `json:"clicks_left,omitempty"`
+	PasswordHash string     `json:"password_hash,omitempty"` // empty for the public url
+	RedirectCode int        `json:"redirect_code,omitempty"`
+	PassQuery    bool       `json:"pass_query,omitempty"`
+	Title        string     `json:"title,omitempty"`
+	Preview      bool       `json:"preview,omitempty"`
+	CreatedAt    string     `json:"created_at,omitempty"` // empty for the legacy records
+}
+
+func newRecord(item shortener.URLListItem) record {
+	rec := record{
+		ID:           item.ID,
+		ShortCode:    item.ShortCode,
+		OriginalURL:  item.OriginalURL,
+		CanonicalURL: item.CanonicalURL,
+		UserID:       item.UserID,
+		UpdatedAt:    item.UpdatedAt,
+		ExpiresAt:    item.ExpiresAt,
+		ClicksLeft:   item.ClicksLeft,
+		PasswordHash: item.PasswordHash,
+		RedirectCode: item.RedirectCode,
+		PassQuery:    item.PassQuery,
+		Title:        item.Title,
+		Preview:      item.Preview,
+		CreatedAt:    item.CreatedAt,
+	}
+	if item.DeletedAt != nil {
+		rec.DeletedAt = *item.DeletedAt
+	}
+	return rec
+}
+
+// newSavedRecord - record of the new url
+func newSavedRecord(id int64, code string, url string, userID string, opts shortener.SaveOptions) record {
+	rec := record{
+		ID:           id,
+		ShortCode:    code,
+		OriginalURL:  url,
+		CanonicalURL: opts.CanonicalURL,
+		UserID:       userID,
+		ExpiresAt:    opts.ExpiresAt,
+		PasswordHash: opts.PasswordHash,
+		RedirectCode: opts.RedirectCode,
+		PassQuery:    opts.PassQuery,
+		Title:        opts.Title,
+		Preview:      opts.Preview,
+		CreatedAt:    time.Now().UTC().Format(shortener.CreatedAtLayout),
+	}
+	if opts.MaxClicks > 0 {
+		clicksLeft := opts.MaxClicks
+		rec.ClicksLeft = &clicksLeft
+	}
+	return rec
+}
+
+// duplicateKey - the key the duplicates are detected by, see shortener.DuplicateKey
+func (rec record) duplicateKey() string {
+	return shortener.DuplicateKey(rec.OriginalURL, rec.CanonicalURL)
+}
+
+// isDuplicateOf reports whether the record is returned as the duplicate of the url with the key at the moment now
+func (rec record) isDuplicateOf(key string, now time.Time) bool {
+	return rec.duplicateKey() == key && rec.toListItem().IsReusable(now)
+}
+
+func (rec record) toListItem() shortener.URLListItem {
+	deletedAt := rec.DeletedAt
+	return shortener.URLListItem{
+		ID:           rec.ID,
+		ShortCode:    rec.ShortCode,
+		UserID:       rec.UserID,
+		OriginalURL:  rec.OriginalURL,
+		CanonicalURL: rec.CanonicalURL,
+		UpdatedAt:    rec.UpdatedAt,
+		DeletedAt:    &deletedAt,
+		ExpiresAt:    rec.ExpiresAt,
+		ClicksLeft:   rec.ClicksLeft,
+		PasswordHash: rec.PasswordHash,
+		RedirectCode: rec.RedirectCode,
+		PassQuery:    rec.PassQuery,
+		Title:        rec.Title,
+		Preview:      rec.Preview,
+		CreatedAt:    rec.CreatedAt,
+	}
+}
+
+// parseLine parses json line or the legacy "id::url::userID::deletedAt" line.
+// Legacy records use the numeric id as the short code
+func parseLine(line string) (record, bool) {
+	rec := record{}
+	if strings.HasPrefix(line, "{") {
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			return rec, false
+		}
+		return rec, true
+	}
+
+	res := strings.SplitN(line, "::", 4)
+	if len(res) == 4 {
+		curID, err := strconv.ParseInt(res[0], 10, 64)
+		if err == nil {
+			rec.ID = curID
+			rec.ShortCode = res[0]
+			rec.OriginalURL = res[1]
+			rec.UserID = res[2]
+			rec.DeletedAt = res[3]
+			return rec, true
+		}
+	}
+	return rec, false
+}
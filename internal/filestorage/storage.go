@@ -4,13 +4,14 @@ package filestorage
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/itksb/go-url-shortener/internal/shortener"
 	"github.com/itksb/go-url-shortener/pkg/logger"
 	"io"
+	"math"
 	"os"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -18,24 +19,34 @@ import (
 
 type storage struct {
 	logger    logger.Interface
+	filename  string
 	fileRead  *os.File
 	fileWrite *os.File
 	reader    *bufio.Scanner
 
+	codegen shortener.CodeGenerator
+
 	currentURLID int64
 	mtx          sync.RWMutex
+
+	clicksFile *os.File
+	clickMtx   sync.Mutex
+
+	ownersFile  *os.File // guarded by mtx
+	historyFile *os.File // guarded by mtx
+	tagsFile    *os.File // guarded by mtx
 }
 
 // NewStorage constructor
-func NewStorage(logger logger.Interface, filename string) (*storage, error) {
+func NewStorage(logger logger.Interface, filename string, codegen shortener.CodeGenerator) (*storage, error) {
 	fileRead, err := os.OpenFile(filename, os.O_CREATE|os.O_RDONLY, os.ModePerm)
 	if err != nil {
 		logger.Error(err.Error())
 		return nil, err
 	}
-	lastID, err := getLastIDOrDefault(fileRead)
+	lastID, err := getMaxIDOrDefault(fileRead)
 	if err != nil {
-		logger.Error(fmt.Sprintf("filestorage:getLastIDOrDefault error: %s", err.Error()))
+		logger.Error(fmt.Sprintf("filestorage:getMaxIDOrDefault error: %s", err.Error()))
 		return nil, err
 	}
 
@@ -47,11 +58,42 @@ func NewStorage(logger logger.Interface, filename string) (*storage, error) {
 		return nil, err
 	}
 
+	// O_APPEND keeps writes at the end, reads use Seek
+	clicksFile, err := os.OpenFile(filename+clicksFileSuffix, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0777)
+	if err != nil {
+		logger.Error(fmt.Sprintf("filestorage: open clicksFile error: %s", err.Error()))
+		return nil, err
+	}
+
+	ownersFile, err := os.OpenFile(filename+ownersFileSuffix, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0777)
+	if err != nil {
+		logger.Error(fmt.Sprintf("filestorage: open ownersFile error: %s", err.Error()))
+		return nil, err
+	}
+
+	historyFile, err := os.OpenFile(filename+historyFileSuffix, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0777)
+	if err != nil {
+		logger.Error(fmt.Sprintf("filestorage: open historyFile error: %s", err.Error()))
+		return nil, err
+	}
+
+	tagsFile, err := os.OpenFile(filename+tagsFileSuffix, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0777)
+	if err != nil {
+		logger.Error(fmt.Sprintf("filestorage: open tagsFile error: %s", err.Error()))
+		return nil, err
+	}
+
 	s := &storage{
 		logger:       logger,
+		filename:     filename,
+		clicksFile:   clicksFile,
+		ownersFile:   ownersFile,
+		historyFile:  historyFile,
+		tagsFile:     tagsFile,
 		fileWrite:    fileWrite,
 		fileRead:     fileRead,
-		reader:       bufio.NewScanner(fileRead),
+		reader:       newLineScanner(fileRead),
+		codegen:      codegen,
 		currentURLID: lastID,
 		mtx:          sync.RWMutex{},
 	}
@@ -61,19 +103,52 @@ func NewStorage(logger logger.Interface, filename string) (*storage, error) {
 
 // Close destructor
 func (s *storage) Close() error {
-	err1 := s.fileRead.Close()
-	err2 := s.fileWrite.Close()
-	if err1 == nil && err2 == nil {
+	var msgs []string
+	names := []string{"fileRead", "fileWrite", "clicksFile", "ownersFile", "historyFile", "tagsFile"}
+	for i, f := range []*os.File{s.fileRead, s.fileWrite, s.clicksFile, s.ownersFile, s.historyFile, s.tagsFile} {
+		if err := f.Close(); err != nil {
+			msgs = append(msgs, fmt.Sprintf("%s: %s", names[i], err.Error()))
+		}
+	}
+	if len(msgs) == 0 {
 		return nil
 	}
-	return fmt.Errorf("fileRead: %s. fileWrite: %s", err1.Error(), err2.Error())
+	return errors.New(strings.Join(msgs, ". "))
 }
 
 // SaveURL persist the given url to the file system
-func (s *storage) SaveURL(ctx context.Context, url string, userID string) (string, error) {
+func (s *storage) SaveURL(ctx context.Context, url string, userID string, opts shortener.SaveOptions) (string, error) {
 	s.mtx.Lock()
 	defer s.mtx.Unlock()
 
+	if opts.Alias != "" {
+		if _, ok := s.findByCode(opts.Alias, &shortener.URLListItem{}); ok {
+			return "", fmt.Errorf("%w: %s", shortener.ErrAliasTaken, opts.Alias)
+		}
+	}
+
+	// the earlier lines of the record are stale, so the last state of the records is searched
+	records, err := s.loadAll()
+	if err != nil {
+		return "", err
+	}
+	key := shortener.DuplicateKey(url, opts.CanonicalURL)
+	now := time.Now()
+	for _, existing := range records {
+		if !existing.isDuplicateOf(key, now) {
+			continue
+		}
+		if err := s.own(userID, existing); err != nil {
+			s.logger.Error(err.Error())
+			return "", err
+		}
+		if _, err := s.tag(userID, tagChange{urlID: existing.ID, add: opts.Tags}); err != nil {
+			s.logger.Error(err.Error())
+			return "", err
+		}
+		return existing.ShortCode, fmt.Errorf("%w", shortener.ErrDuplicate)
+	}
+
 	s.currentURLID++
 	id := s.currentURLID
 
@@ -81,117 +156,411 @@ func (s *storage) SaveURL(ctx context.Context, url string, userID string) (strin
 		return "", fmt.Errorf("url with id %d already exists", id)
 	}
 
-	if err := s.persist(id, url, userID, ""); err != nil {
+	code := opts.Alias
+	if code == "" {
+		code, err = shortener.GenerateUniqueCode(s.codegen, id, func(code string) (bool, error) {
+			_, ok := s.findByCode(code, &shortener.URLListItem{})
+			return ok, nil
+		})
+		if err != nil {
+			s.logger.Error(err.Error())
+			return "", err
+		}
+	}
+
+	if err := s.persist(newSavedRecord(id, code, url, userID, opts)); err != nil {
+		s.logger.Error(err.Error())
+		return "", err
+	}
+	if _, err := s.tag(userID, tagChange{urlID: id, add: opts.Tags}); err != nil {
 		s.logger.Error(err.Error())
 		return "", err
 	}
 
-	return strconv.FormatInt(id, 10), nil
+	return code, nil
 }
 
-// GetURL retrieves the url from the file system by id
-func (s *storage) GetURL(ctx context.Context, id string) (shortener.URLListItem, error) {
+// SaveURLBatch persist the given urls to the file system with one write,
+// the user becomes the owner of the duplicates with one more write
+func (s *storage) SaveURLBatch(ctx context.Context, userID string, items []shortener.BatchItem) ([]shortener.BatchResult, error) {
 	s.mtx.Lock()
 	defer s.mtx.Unlock()
 
-	result := shortener.URLListItem{}
-
-	idInt64, err := strconv.ParseInt(id, 10, 64)
+	records, err := s.loadAll()
 	if err != nil {
-		return result, err
+		return nil, err
+	}
+	codes := make(map[string]struct{}, len(records))
+	ids := make(map[int64]struct{}, len(records))
+	urls := make(map[string]record, len(records))
+	now := time.Now()
+	for _, rec := range records {
+		codes[rec.ShortCode] = struct{}{}
+		ids[rec.ID] = struct{}{}
+		if rec.toListItem().IsReusable(now) {
+			urls[rec.duplicateKey()] = rec
+		}
 	}
 
-	if result, ok := s.findByID(idInt64, &result); ok {
+	id := s.currentURLID
+	results := make([]shortener.BatchResult, 0, len(items))
+	saved := make([]record, 0, len(items))
+	var duplicates []record
+	changes := make([]tagChange, 0, len(items))
+	for i, item := range items {
+		if alias := item.Opts.Alias; alias != "" {
+			if _, ok := codes[alias]; ok {
+				return nil, &shortener.BatchItemError{Index: i, Err: fmt.Errorf("%w: %s", shortener.ErrAliasTaken, alias)}
+			}
+		}
+		key := shortener.DuplicateKey(item.OriginalURL, item.Opts.CanonicalURL)
+		if rec, ok := urls[key]; ok {
+			results = append(results, shortener.BatchResult{ShortCode: rec.ShortCode, Duplicate: true})
+			duplicates = append(duplicates, rec)
+			changes = append(changes, tagChange{urlID: rec.ID, add: item.Opts.Tags})
+			continue
+		}
+
+		// the ids are checked like by SaveURL
+		for id++; ; id++ {
+			if _, ok := ids[id]; !ok {
+				break
+			}
+		}
+		ids[id] = struct{}{}
+		code := item.Opts.Alias
+		if code == "" {
+			code, err = shortener.GenerateUniqueCode(s.codegen, id, func(code string) (bool, error) {
+				_, ok := codes[code]
+				return ok, nil
+			})
+			if err != nil {
+				s.logger.Error(err.Error())
+				return nil, err
+			}
+		}
+		rec := newSavedRecord(id, code, item.OriginalURL, userID, item.Opts)
+		codes[code] = struct{}{}
+		urls[key] = rec
+		saved = append(saved, rec)
+		results = append(results, shortener.BatchResult{ShortCode: code})
+		changes = append(changes, tagChange{urlID: id, add: item.Opts.Tags})
+	}
+
+	if err = s.persist(saved...); err != nil {
+		s.logger.Error(err.Error())
+		return nil, err
+	}
+	s.currentURLID = id
+	if err = s.own(userID, duplicates...); err != nil {
+		s.logger.Error(err.Error())
+		return nil, err
+	}
+	if _, err = s.tag(userID, changes...); err != nil {
+		s.logger.Error(err.Error())
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// GetURL retrieves the url from the file system by the short code
+func (s *storage) GetURL(ctx context.Context, id string) (shortener.URLListItem, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	result := shortener.URLListItem{}
+
+	if result, ok := s.findByCode(id, &result); ok {
 		return *result, nil
 	}
 
 	return result, nil
 }
 
-// ListURLByUserID returns list of urls
+// ListURLByUserID returns list of urls owned by the user
 func (s *storage) ListURLByUserID(ctx context.Context, userID string) ([]shortener.URLListItem, error) {
-	var line string
-	var foundItems []shortener.URLListItem
-	_, err := s.fileRead.Seek(0, io.SeekStart)
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.ownedURLs(userID)
+}
+
+// ListURLs returns the page of the urls owned by the user
+func (s *storage) ListURLs(ctx context.Context, userID string, opts shortener.ListOptions) (shortener.URLPage, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	items, err := s.ownedURLs(userID)
 	if err != nil {
-		s.logger.Error(fmt.Sprintf("filestorage: fileWrite.Seek error. Err: %s", err.Error()))
-		return foundItems, err
+		return shortener.URLPage{}, err
 	}
+	return shortener.PageURLs(items, opts), nil
+}
 
-	reader := bufio.NewScanner(s.fileRead)
-	for reader.Scan() {
-		line = reader.Text()
-		curID, url, user, deletedAt, ok := extractValuesFromTheLine(line)
-		if ok && user == userID {
-			foundItems = append(foundItems, shortener.URLListItem{
-				ID:          curID,
-				UserID:      user,
-				ShortURL:    "",
-				OriginalURL: url,
-				DeletedAt:   &deletedAt,
-			})
+// DeleteURLBatch removes the user from the owners of the urls, unknown and foreign codes are skipped.
+// The url is marked as deleted when it has no owners left
+func (s *storage) DeleteURLBatch(ctx context.Context, userID string, ids []string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	records, err := s.loadAll()
+	if err != nil {
+		return err
+	}
+	owners, err := s.loadOwners()
+	if err != nil {
+		return err
+	}
+	byCode := make(map[string]record, len(records))
+	for _, rec := range records {
+		byCode[rec.ShortCode] = rec
+	}
+
+	deletedAt := time.Now().UTC().Format(deletedAtLayout)
+	var lines []ownerRecord
+	var recs []record
+	for i := 0; i < len(ids); i++ {
+		rec, ok := byCode[ids[i]]
+		if !ok {
+			continue
+		}
+		urlOwners := owners.of(rec)
+		if current, ok := urlOwners[userID]; !ok || current != "" {
+			continue
 		}
+		line := ownerRecord{URLID: rec.ID, UserID: userID, DeletedAt: deletedAt}
+		owners.set(line)
+		lines = append(lines, line)
+		if hasLiveOwner(owners.of(rec)) || rec.DeletedAt != "" {
+			continue
+		}
+		rec.DeletedAt = deletedAt
+		byCode[rec.ShortCode] = rec
+		// creates duplicates in a file, but it is not a problem for this project
+		recs = append(recs, rec)
+	}
+	if err = s.persistOwners(lines...); err != nil {
+		s.logger.Error(err.Error())
+		return err
 	}
+	if err = s.persist(recs...); err != nil {
+		s.logger.Error(err.Error())
+		return err
+	}
+	return nil
+}
 
-	err = s.reader.Err()
+// RestoreURLBatch restores the urls deleted by the user, expired and foreign urls are skipped
+func (s *storage) RestoreURLBatch(ctx context.Context, userID string, ids []string) ([]string, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	records, err := s.loadAll()
 	if err != nil {
-		s.logger.Error(fmt.Sprintf("filestorage: reader.Scan() error. Err: %s", err.Error()))
-		return foundItems, err
+		return nil, err
+	}
+	owners, err := s.loadOwners()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	byCode := make(map[string]record, len(records))
+	taken := make(map[string]struct{}, len(records))
+	for _, rec := range records {
+		byCode[rec.ShortCode] = rec
+		if rec.toListItem().IsReusable(now) {
+			taken[rec.duplicateKey()] = struct{}{}
+		}
+	}
+
+	restored := make([]string, 0, len(ids))
+	var lines []ownerRecord
+	var recs []record
+	for _, code := range ids {
+		rec, ok := byCode[code]
+		if !ok || rec.toListItem().IsExpired(now) {
+			continue
+		}
+		deletedAt, ok := owners.of(rec)[userID]
+		if !ok || (deletedAt == "" && rec.DeletedAt == "") {
+			continue
+		}
+		// the url shortened again after the deletion keeps the key
+		if _, ok = taken[rec.duplicateKey()]; ok && rec.DeletedAt != "" {
+			continue
+		}
+		if deletedAt != "" {
+			line := ownerRecord{URLID: rec.ID, UserID: userID}
+			owners.set(line)
+			lines = append(lines, line)
+		}
+		if rec.DeletedAt != "" {
+			rec.DeletedAt = ""
+			byCode[code] = rec
+			recs = append(recs, rec)
+			taken[rec.duplicateKey()] = struct{}{}
+		}
+		restored = append(restored, code)
+	}
+	if err = s.persistOwners(lines...); err != nil {
+		s.logger.Error(err.Error())
+		return nil, err
+	}
+	if err = s.persist(recs...); err != nil {
+		s.logger.Error(err.Error())
+		return nil, err
 	}
+	return restored, nil
+}
 
-	return foundItems, nil
+// ConsumeClick decrements clicks left of the url.
+// Every click appends the new state of the record to the file
+func (s *storage) ConsumeClick(ctx context.Context, id string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	item, ok := s.findByCode(id, &shortener.URLListItem{})
+	if !ok {
+		return fmt.Errorf("%w: code %s", shortener.ErrNotFound, id)
+	}
+	if item.ClicksLeft == nil {
+		return nil
+	}
+	if item.IsExhausted() {
+		return shortener.ErrClicksExhausted
+	}
+	rec := newRecord(*item)
+	clicksLeft := *item.ClicksLeft - 1
+	rec.ClicksLeft = &clicksLeft
+	return s.persist(rec)
 }
 
-// DeleteURLBatch removes urls from the file system
-func (s *storage) DeleteURLBatch(ctx context.Context, userID string, ids []string) error {
+// DeleteExpiredURLs marks expired urls as deleted
+func (s *storage) DeleteExpiredURLs(ctx context.Context, now time.Time) (int64, error) {
 	s.mtx.Lock()
 	defer s.mtx.Unlock()
 
-	var hasError bool
-	for i := 0; i < len(ids); i++ {
-		idInt64, err := strconv.ParseInt(ids[i], 10, 64)
-		if err != nil {
-			return err
+	records, err := s.loadAll()
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	for _, rec := range records {
+		if rec.DeletedAt != "" || !rec.toListItem().IsExpired(now) {
+			continue
 		}
+		rec.DeletedAt = now.UTC().Format(deletedAtLayout)
+		if err = s.persist(rec); err != nil {
+			s.logger.Error(err.Error())
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
 
-		if item, ok := s.findByID(idInt64, &shortener.URLListItem{}); ok {
-			tCurr := time.Now().Format("2006-01-02T15:04:05")
-			item.DeletedAt = &tCurr
-			// creates duplicates in a file, but it is not a problem for this project
-			err = s.persist(item.ID, item.OriginalURL, item.UserID, *item.DeletedAt)
-			if err != nil {
-				hasError = true
-			}
+// CountURLs returns count of live and deleted urls
+func (s *storage) CountURLs(ctx context.Context) (int64, int64, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	records, err := s.loadAll()
+	if err != nil {
+		return 0, 0, err
+	}
+	var live, deleted int64
+	for _, rec := range records {
+		if rec.DeletedAt != "" {
+			deleted++
+		} else {
+			live++
 		}
+	}
+	return live, deleted, nil
+}
 
+// CountUsers returns count of distinct owners
+func (s *storage) CountUsers(ctx context.Context) (int64, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	records, err := s.loadAll()
+	if err != nil {
+		return 0, err
+	}
+	owners, err := s.loadOwners()
+	if err != nil {
+		return 0, err
 	}
-	if hasError {
-		return errors.New("some keys not used")
+	users := make(map[string]struct{})
+	for _, rec := range records {
+		for userID := range owners.of(rec) {
+			users[userID] = struct{}{}
+		}
 	}
-	return nil
+	return int64(len(users)), nil
+}
+
+// loadAll returns the last state of every record in a file ordered by id
+func (s *storage) loadAll() ([]record, error) {
+	_, err := s.fileRead.Seek(0, io.SeekStart)
+	if err != nil {
+		s.logger.Error(fmt.Sprintf("filestorage: fileWrite.Seek error. Err: %s", err.Error()))
+		return nil, err
+	}
+
+	byID := make(map[int64]int)
+	var records []record
+	reader := newLineScanner(s.fileRead)
+	for reader.Scan() {
+		rec, ok := parseLine(reader.Text())
+		if !ok {
+			continue
+		}
+		if idx, ok := byID[rec.ID]; ok {
+			records[idx] = rec
+			continue
+		}
+		byID[rec.ID] = len(records)
+		records = append(records, rec)
+	}
+
+	if err = reader.Err(); err != nil {
+		s.logger.Error(fmt.Sprintf("filestorage: reader.Scan() error. Err: %s", err.Error()))
+		return nil, err
+	}
+	return records, nil
 }
 
 func (s *storage) findByID(id int64, listItem *shortener.URLListItem) (*shortener.URLListItem, bool) {
+	return s.find(func(rec record) bool { return rec.ID == id }, listItem)
+}
+
+func (s *storage) findByCode(code string, listItem *shortener.URLListItem) (*shortener.URLListItem, bool) {
+	return s.find(func(rec record) bool { return rec.ShortCode == code }, listItem)
+}
+
+// find fills listItem with the last record in a file matched by the given predicate
+func (s *storage) find(match func(rec record) bool, listItem *shortener.URLListItem) (*shortener.URLListItem, bool) {
 	var line string
 	_, err := s.fileRead.Seek(0, io.SeekStart)
 	if err != nil {
 		s.logger.Error(fmt.Sprintf("filestorage: fileWrite.Seek error. Err: %s", err.Error()))
 	}
 
-	reader := bufio.NewScanner(s.fileRead)
-	// find the last value with id in a file
+	reader := newLineScanner(s.fileRead)
+	// find the last matched value in a file
 	for reader.Scan() {
 		line = reader.Text()
-		curID, url, userID, deletedAt, ok := extractValuesFromTheLine(line)
-		if ok && curID == id {
-			listItem.ID = curID
-			listItem.UserID = userID
-			listItem.OriginalURL = url
-			listItem.DeletedAt = &deletedAt
+		rec, ok := parseLine(line)
+		if ok && match(rec) {
+			*listItem = rec.toListItem()
 		}
 	}
 
-	err = s.reader.Err()
+	err = reader.Err()
 	if err != nil {
 		s.logger.Error(fmt.Sprintf("filestorage: reader.Scan() error. Err: %s", err.Error()))
 	}
@@ -199,36 +568,41 @@ func (s *storage) findByID(id int64, listItem *shortener.URLListItem) (*shortene
 	return listItem, len(listItem.OriginalURL) != 0 && err == nil
 }
 
-func extractValuesFromTheLine(line string) (int64, string, string, string, bool) {
-	res := strings.SplitN(line, "::", 4)
-	if len(res) == 4 {
-		curID, err := strconv.ParseInt(res[0], 10, 64)
-		if err == nil {
-			return curID, res[1], res[2], res[3], true
+// persist appends the records to the file with one write
+func (s *storage) persist(recs ...record) error {
+	var lines []byte
+	for _, rec := range recs {
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return err
 		}
+		lines = append(append(lines, line...), '\n')
 	}
-	return 0, "", "", "", false
-}
-
-func (s *storage) persist(id int64, value string, userID string, deletedAt string) error {
-	_, err := s.fileWrite.WriteString(fmt.Sprintf("%d::%s::%s::%s\n", id, value, userID, deletedAt))
+	if len(lines) == 0 {
+		return nil
+	}
+	_, err := s.fileWrite.Write(lines)
 	return err
 }
 
-func getLastIDOrDefault(file *os.File) (int64, error) {
-	line, err := getLastLineOfTheFile(file)
-	if err != nil {
-		return 0, err
-	}
-
-	if len(line) == 0 {
-		return 0, nil
-	}
-
-	id, _, _, _, ok := extractValuesFromTheLine(line)
-	if ok {
-		return id, err
+// getMaxIDOrDefault returns the max id of the records in a file. The updated records are appended
+// with their old ids, so the last line is not the last saved record
+func getMaxIDOrDefault(file *os.File) (int64, error) {
+	var maxID int64
+	reader := newLineScanner(file)
+	for reader.Scan() {
+		rec, ok := parseLine(reader.Text())
+		if ok && rec.ID > maxID {
+			maxID = rec.ID
+		}
 	}
-	return 0, fmt.Errorf("error while parsing last line of the fileWrite")
+	return maxID, reader.Err()
+}
 
+// newLineScanner returns the scanner of the file lines. The line is not limited by the 64KB token size
+// of bufio.Scanner, a longer record would silently stop the scan
+func newLineScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), math.MaxInt32)
+	return scanner
 }
@@ -0,0 +1,54 @@
+package filestorage
+
+import (
+	"context"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/itksb/go-url-shortener/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// openStorage opens the file storage, it is closed by the test cleanup
+func openStorage(t *testing.T, filename string) *storage {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+	s, err := NewStorage(l, filename, shortener.NewSequenceGenerator(shortener.Base62Alphabet, 0))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+func TestStorage_LongLine(t *testing.T) {
+	ctx := context.Background()
+	filename := filepath.Join(t.TempDir(), "urls.json")
+	s := openStorage(t, filename)
+
+	long := "https://example.com/" + strings.Repeat("a", 100*1024)
+	_, err := s.SaveURL(ctx, "https://example.com/short", "user", shortener.SaveOptions{})
+	require.NoError(t, err)
+	longCode, err := s.SaveURL(ctx, long, "user", shortener.SaveOptions{})
+	require.NoError(t, err)
+	lastCode, err := s.SaveURL(ctx, "https://example.com/last", "user", shortener.SaveOptions{})
+	require.NoError(t, err)
+
+	// the records after the long line are found
+	item, err := s.GetURL(ctx, lastCode)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/last", item.OriginalURL)
+	item, err = s.GetURL(ctx, longCode)
+	require.NoError(t, err)
+	assert.Equal(t, long, item.OriginalURL)
+
+	// the long url is a duplicate, not a new record
+	code, err := s.SaveURL(ctx, long, "user", shortener.SaveOptions{})
+	assert.ErrorIs(t, err, shortener.ErrDuplicate)
+	assert.Equal(t, longCode, code)
+
+	// the max id is read past the long line after the restart
+	require.NoError(t, s.Close())
+	s = openStorage(t, filename)
+	assert.Equal(t, int64(3), s.currentURLID)
+}
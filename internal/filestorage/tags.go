@@ -0,0 +1,161 @@
+// Package filestorage used for persisting urls in the file system
+package filestorage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"io"
+	"sort"
+)
+
+// tagsFileSuffix - tags of the owners are stored next to the urls file
+const tagsFileSuffix = ".tags"
+
+// tagRecord - one line of the tags file, all the tags of the owner.
+// The last line with the same url id and user id wins
+type tagRecord struct {
+	URLID  int64    `json:"url_id"`
+	UserID string   `json:"user_id"`
+	Tags   []string `json:"tags"`
+}
+
+// tagLines - url id -> user id -> sorted tags
+type tagLines map[int64]map[string][]string
+
+// set applies the line to the tags
+func (t tagLines) set(line tagRecord) {
+	if t[line.URLID] == nil {
+		t[line.URLID] = make(map[string][]string)
+	}
+	t[line.URLID][line.UserID] = line.Tags
+}
+
+// tagChange - tags added to and removed from the url
+type tagChange struct {
+	urlID  int64
+	add    []string
+	remove []string
+}
+
+// UpdateURLTags removes and then adds the tags of the url owned by the user
+func (s *storage) UpdateURLTags(ctx context.Context, id string, userID string, add []string, remove []string) ([]string, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	records, err := s.loadAll()
+	if err != nil {
+		return nil, err
+	}
+	owners, err := s.loadOwners()
+	if err != nil {
+		return nil, err
+	}
+	for _, rec := range records {
+		if rec.ShortCode != id {
+			continue
+		}
+		if _, ok := owners.of(rec)[userID]; !ok {
+			break
+		}
+		tags, err := s.tag(userID, tagChange{urlID: rec.ID, add: add, remove: remove})
+		if err != nil {
+			s.logger.Error(err.Error())
+			return nil, err
+		}
+		return tags[rec.ID][userID], nil
+	}
+	return nil, fmt.Errorf("%w: code %s", shortener.ErrNotFound, id)
+}
+
+// tag applies the changes to the tags of the owner with one write, returns the resulting tags.
+// The caller holds the lock
+func (s *storage) tag(userID string, changes ...tagChange) (tagLines, error) {
+	tags, err := s.loadTags()
+	if err != nil {
+		return nil, err
+	}
+	var lines []tagRecord
+	for _, change := range changes {
+		if len(change.add) == 0 && len(change.remove) == 0 {
+			continue
+		}
+		line := tagRecord{
+			URLID:  change.urlID,
+			UserID: userID,
+			Tags:   shortener.MergeTags(tags[change.urlID][userID], change.add, change.remove),
+		}
+		tags.set(line)
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return tags, nil
+	}
+	data, err := marshalLines(len(lines), func(i int) interface{} { return lines[i] })
+	if err != nil {
+		return nil, err
+	}
+	if _, err = s.tagsFile.Write(data); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// loadTags reads the tags file, the caller holds the lock
+func (s *storage) loadTags() (tagLines, error) {
+	_, err := s.tagsFile.Seek(0, io.SeekStart)
+	if err != nil {
+		s.logger.Error(fmt.Sprintf("filestorage: tagsFile.Seek error. Err: %s", err.Error()))
+		return nil, err
+	}
+
+	tags := make(tagLines)
+	reader := newLineScanner(s.tagsFile)
+	for reader.Scan() {
+		line := tagRecord{}
+		if err = json.Unmarshal(reader.Bytes(), &line); err != nil {
+			continue
+		}
+		tags.set(line)
+	}
+	if err = reader.Err(); err != nil {
+		s.logger.Error(fmt.Sprintf("filestorage: tags reader.Scan() error. Err: %s", err.Error()))
+		return nil, err
+	}
+	return tags, nil
+}
+
+// compactTags replaces the tags file with the last tags of the owners kept by the compaction, the caller holds the lock
+func (s *storage) compactTags(kept func(urlID int64, userID string) bool) error {
+	tags, err := s.loadTags()
+	if err != nil {
+		return err
+	}
+	var lines []tagRecord
+	for urlID, users := range tags {
+		for userID, userTags := range users {
+			if len(userTags) > 0 && kept(urlID, userID) {
+				lines = append(lines, tagRecord{URLID: urlID, UserID: userID, Tags: userTags})
+			}
+		}
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].URLID != lines[j].URLID {
+			return lines[i].URLID < lines[j].URLID
+		}
+		return lines[i].UserID < lines[j].UserID
+	})
+
+	data, err := marshalLines(len(lines), func(i int) interface{} { return lines[i] })
+	if err != nil {
+		return err
+	}
+	tagsFile, err := reopenReplaced(s.filename+tagsFileSuffix, data)
+	if err != nil {
+		return err
+	}
+	_ = s.tagsFile.Close()
+	s.tagsFile = tagsFile
+	return nil
+}
@@ -0,0 +1,51 @@
+package filestorage
+
+import (
+	"context"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func TestStorage_UpdateURLTags(t *testing.T) {
+	ctx := context.Background()
+	filename := filepath.Join(t.TempDir(), "urls.json")
+	s := openStorage(t, filename)
+
+	code, err := s.SaveURL(ctx, "https://example.com", "alice", shortener.SaveOptions{Tags: []string{"b", "a"}})
+	require.NoError(t, err)
+	_, err = s.SaveURL(ctx, "https://example.com", "bob", shortener.SaveOptions{Tags: []string{"c"}})
+	require.ErrorIs(t, err, shortener.ErrDuplicate)
+
+	tags, err := s.UpdateURLTags(ctx, code, "alice", []string{"d"}, []string{"a"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b", "d"}, tags)
+	_, err = s.UpdateURLTags(ctx, code, "carol", []string{"d"}, nil)
+	assert.ErrorIs(t, err, shortener.ErrNotFound)
+	_, err = s.UpdateURLTags(ctx, "unknown", "alice", []string{"d"}, nil)
+	assert.ErrorIs(t, err, shortener.ErrNotFound)
+
+	// every owner has its own tags
+	check := func(s *storage) {
+		for userID, want := range map[string][]string{"alice": {"b", "d"}, "bob": {"c"}} {
+			urls, err := s.ListURLByUserID(ctx, userID)
+			require.NoError(t, err)
+			require.Len(t, urls, 1)
+			assert.Equal(t, want, urls[0].Tags)
+		}
+		page, err := s.ListURLs(ctx, "alice", shortener.ListOptions{Limit: 10, Tag: "d"})
+		require.NoError(t, err)
+		require.Len(t, page.Items, 1)
+		assert.Equal(t, code, page.Items[0].ShortCode)
+		page, err = s.ListURLs(ctx, "alice", shortener.ListOptions{Limit: 10, Tag: "c"})
+		require.NoError(t, err)
+		assert.Empty(t, page.Items)
+	}
+	check(s)
+
+	// the tags are reloaded after the restart
+	require.NoError(t, s.Close())
+	check(openStorage(t, filename))
+}
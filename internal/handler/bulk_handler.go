@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/itksb/go-url-shortener/api"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/itksb/go-url-shortener/internal/user"
+	"io"
+	"net/http"
+	"time"
+)
+
+// bulkChunkRows - rows shortened by one storage call of the bulk request
+const bulkChunkRows = 500
+
+// bulkChunkWriteTimeout - time the chunk is shortened and its results are written in,
+// the write timeout of the server is extended by it for every chunk
+const bulkChunkWriteTimeout = time.Minute
+
+// writeDeadliner - the response writer of the http server which deadline can be moved
+type writeDeadliner interface {
+	SetWriteDeadline(deadline time.Time) error
+}
+
+// APIShortenURLBulk - shortens ndjson or csv rows read from the stream in chunks,
+// responds with one ndjson result per row in the input order as soon as its chunk is shortened.
+// Invalid rows get their own error and do not stop the others
+func (h *Handler) APIShortenURLBulk(w http.ResponseWriter, r *http.Request) {
+	defer func() {
+		err := r.Body.Close()
+		if err != nil {
+			h.logger.Error(err.Error())
+		}
+	}()
+
+	ctx := r.Context()
+	userID, ok := ctx.Value(user.FieldID).(string)
+	if !ok {
+		h.logger.Error("no user id found")
+		SendJSONError(w, "no user found", http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := newBulkReader(r)
+	if errors.Is(err, errUnsupportedBulkType) {
+		SendJSONError(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+	if err != nil {
+		SendJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// http/1 server discards the unread body when the response starts, unless the connection is closed after it
+	if r.ProtoMajor == 1 {
+		w.Header().Set("Connection", "close")
+	}
+	w.Header().Set("Content-Type", contentTypeNDJSON)
+	w.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	flusher, _ := w.(http.Flusher)
+
+	chunk := make([]bulkRow, 0, bulkChunkRows)
+	for {
+		row, err := rows.Next()
+		if err != nil && !errors.Is(err, io.EOF) {
+			// the rest of the input can not be decoded, the client sees where it stopped
+			chunk = append(chunk, bulkRow{line: row.line, err: fmt.Errorf("read error: %w", err)})
+		} else if err == nil {
+			chunk = append(chunk, row)
+			if len(chunk) < bulkChunkRows {
+				continue
+			}
+		}
+
+		if err := extendWriteDeadline(w, bulkChunkWriteTimeout); err != nil {
+			h.logger.Error(err)
+		}
+		for _, result := range h.shortenBulkChunk(ctx, userID, chunk) {
+			if encodeErr := encoder.Encode(result); encodeErr != nil {
+				h.logger.Error(encodeErr)
+				return
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		chunk = chunk[:0]
+		if err != nil || ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// shortenBulkChunk shortens the valid rows of the chunk, see Service.ShortenURLBatchPartial
+func (h *Handler) shortenBulkChunk(ctx context.Context, userID string, rows []bulkRow) []api.ShortenBulkResult {
+	results := make([]api.ShortenBulkResult, len(rows))
+	items := make([]shortener.BatchItem, 0, len(rows))
+	indexes := make([]int, 0, len(rows)) // item -> row
+	for i, row := range rows {
+		results[i] = api.ShortenBulkResult{Line: row.line, CorrelationID: row.item.CorrelationID}
+		if row.err != nil {
+			results[i].Error = row.err.Error()
+			continue
+		}
+		expiresAt, err := makeExpiresAt(row.item.ExpiresAt, row.item.TTLSeconds)
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		items = append(items, shortener.BatchItem{
+			OriginalURL: row.item.OriginalURL,
+			Opts: shortener.SaveOptions{
+				Alias:        row.item.Alias,
+				ExpiresAt:    expiresAt,
+				MaxClicks:    row.item.MaxClicks,
+				Tags:         row.item.Tags,
+				Password:     row.item.Password,
+				RedirectCode: row.item.RedirectCode,
+				PassQuery:    row.item.PassQuery,
+				Title:        row.item.Title,
+				Preview:      row.item.Preview,
+			},
+		})
+		indexes = append(indexes, i)
+	}
+
+	saved, errs, err := h.urlshortener.ShortenURLBatchPartial(ctx, userID, items)
+	if err != nil {
+		h.logger.Error("ApiShortenUrlBulk. urlshortener.ShortenURLBatchPartial(...) call error", err.Error())
+	}
+	for j, i := range indexes {
+		switch {
+		case errs[j] != nil:
+			results[i].Error = errs[j].Error()
+		case err != nil:
+			results[i].Error = "shortener service error"
+		default:
+			results[i].ShortURL = createShortenURL(saved[j].ShortCode, h.cfg.ShortBaseURL)
+			results[i].Duplicate = saved[j].Duplicate
+		}
+	}
+	return results
+}
+
+// extendWriteDeadline moves the write deadline of the response by the timeout from now, the wrapped writers are unwrapped.
+// The writer without the deadline is skipped
+func extendWriteDeadline(w http.ResponseWriter, timeout time.Duration) error {
+	for {
+		switch rw := w.(type) {
+		case writeDeadliner:
+			return rw.SetWriteDeadline(time.Now().Add(timeout))
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = rw.Unwrap()
+		default:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/itksb/go-url-shortener/api"
+	"github.com/itksb/go-url-shortener/internal/config"
+	"github.com/itksb/go-url-shortener/internal/dbstorage"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/itksb/go-url-shortener/internal/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandler_APIShortenURLBulk(t *testing.T) {
+	l := &loggerMock{}
+	newHandler := func() *Handler {
+		storage := newStorageMock(map[int64]shortener.URLListItem{
+			100: {ID: 100, ShortCode: "old", OriginalURL: "https://old.example", UserID: "user1"},
+		})
+		return NewHandler(l, shortener.NewShortener(l, storage), &dbstorage.Storage{}, &dbstorage.Storage{}, config.Config{ShortBaseURL: "http://short.base"})
+	}
+	send := func(h *Handler, contentType string, body io.Reader) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/shorten/bulk", body)
+		req.Header.Set("Content-Type", contentType)
+		rr := httptest.NewRecorder()
+		h.APIShortenURLBulk(rr, req.WithContext(context.WithValue(req.Context(), user.FieldID, "user1")))
+		return rr
+	}
+	decode := func(t *testing.T, rr *httptest.ResponseRecorder) []api.ShortenBulkResult {
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "application/x-ndjson", rr.Header().Get("Content-Type"))
+		var results []api.ShortenBulkResult
+		scanner := bufio.NewScanner(rr.Body)
+		for scanner.Scan() {
+			result := api.ShortenBulkResult{}
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &result))
+			results = append(results, result)
+		}
+		return results
+	}
+
+	t.Run("ndjson", func(t *testing.T) {
+		body := strings.Join([]string{
+			`{"correlation_id":"a","original_url":"https://a.example"}`,
+			`{"correlation_id":"b","original_url":`,
+			``,
+			`{"correlation_id":"c","original_url":""}`,
+			`{"correlation_id":"d","original_url":"https://old.example"}`,
+			`{"correlation_id":"e","original_url":"https://e.example","alias":"no"}`,
+			`{"correlation_id":"f","original_url":"https://f.example","ttl_seconds":-1}`,
+		}, "\n")
+		results := decode(t, send(newHandler(), "application/x-ndjson", strings.NewReader(body)))
+		require.Len(t, results, 6)
+
+		assert.Equal(t, api.ShortenBulkResult{Line: 1, CorrelationID: "a", ShortURL: "http://short.base/0"}, results[0])
+		assert.Equal(t, api.ShortenBulkResult{Line: 2, Error: "bad input json"}, results[1])
+		assert.Equal(t, 4, results[2].Line)
+		assert.NotEmpty(t, results[2].Error)
+		assert.Equal(t, api.ShortenBulkResult{Line: 5, CorrelationID: "d", ShortURL: "http://short.base/old", Duplicate: true}, results[3])
+		assert.Contains(t, results[4].Error, shortener.ErrInvalidAlias.Error())
+		assert.NotEmpty(t, results[5].Error)
+		assert.Empty(t, results[5].ShortURL)
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		body := "correlation_id,original_url,tags,max_clicks\n" +
+			"a,https://a.example,work;news,\n" +
+			"b,https://b.example,,many\n" +
+			"c,https://c.example,,5\n"
+		results := decode(t, send(newHandler(), "text/csv; charset=utf-8", strings.NewReader(body)))
+		require.Len(t, results, 3)
+		assert.Equal(t, api.ShortenBulkResult{Line: 2, CorrelationID: "a", ShortURL: "http://short.base/0"}, results[0])
+		assert.Equal(t, api.ShortenBulkResult{Line: 3, CorrelationID: "b", Error: "max_clicks must be an integer"}, results[1])
+		assert.Equal(t, api.ShortenBulkResult{Line: 4, CorrelationID: "c", ShortURL: "http://short.base/1"}, results[2])
+	})
+
+	t.Run("multipart csv", func(t *testing.T) {
+		body := &bytes.Buffer{}
+		mw := multipart.NewWriter(body)
+		require.NoError(t, mw.WriteField("comment", "import"))
+		file, err := mw.CreateFormFile("file", "urls.csv")
+		require.NoError(t, err)
+		_, err = io.WriteString(file, "original_url\nhttps://a.example\n")
+		require.NoError(t, err)
+		require.NoError(t, mw.Close())
+
+		results := decode(t, send(newHandler(), mw.FormDataContentType(), body))
+		assert.Equal(t, []api.ShortenBulkResult{{Line: 2, ShortURL: "http://short.base/0"}}, results)
+	})
+
+	t.Run("options", func(t *testing.T) {
+		storage := newStorageMock(map[int64]shortener.URLListItem{})
+		h := NewHandler(l, shortener.NewShortener(l, storage), &dbstorage.Storage{}, &dbstorage.Storage{}, config.Config{ShortBaseURL: "http://short.base"})
+		body := `{"original_url":"https://a.example","password":"secret","redirect_code":301,"pass_query":true,"title":" Docs ","preview":true}`
+		results := decode(t, send(h, "application/x-ndjson", strings.NewReader(body)))
+		assert.Equal(t, []api.ShortenBulkResult{{Line: 1, ShortURL: "http://short.base/0"}}, results)
+		body = "original_url,password,redirect_code,pass_query,title,preview\n" +
+			"https://b.example,secret,308,true,Report,1\n"
+		results = decode(t, send(h, "text/csv", strings.NewReader(body)))
+		assert.Equal(t, []api.ShortenBulkResult{{Line: 2, ShortURL: "http://short.base/1"}}, results)
+
+		item := storage.urls[0]
+		assert.True(t, item.IsProtected())
+		assert.Equal(t, http.StatusMovedPermanently, item.RedirectStatus())
+		assert.True(t, item.PassQuery)
+		assert.Equal(t, "Docs", item.Title)
+		assert.True(t, item.Preview)
+		item = storage.urls[1]
+		assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(item.PasswordHash), []byte("secret")))
+		assert.Equal(t, http.StatusPermanentRedirect, item.RedirectStatus())
+		assert.True(t, item.PassQuery)
+		assert.Equal(t, "Report", item.Title)
+		assert.True(t, item.Preview)
+	})
+
+	t.Run("write deadline", func(t *testing.T) {
+		rows := make([]string, 0, bulkChunkRows+1)
+		for i := 0; i <= bulkChunkRows; i++ {
+			rows = append(rows, fmt.Sprintf(`{"original_url":"https://example.com/%d"}`, i))
+		}
+		req := httptest.NewRequest(http.MethodPost, "/api/shorten/bulk", strings.NewReader(strings.Join(rows, "\n")))
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		rr := &deadlineRecorder{ResponseRecorder: httptest.NewRecorder()}
+		started := time.Now()
+		newHandler().APIShortenURLBulk(rr, req.WithContext(context.WithValue(req.Context(), user.FieldID, "user1")))
+
+		assert.Len(t, decode(t, rr.ResponseRecorder), bulkChunkRows+1)
+		// the deadline is extended for each of the two chunks
+		require.Len(t, rr.deadlines, 2)
+		assert.False(t, rr.deadlines[0].Before(started.Add(bulkChunkWriteTimeout)))
+	})
+
+	t.Run("bad input", func(t *testing.T) {
+		rr := send(newHandler(), "application/json", strings.NewReader(`[]`))
+		assert.Equal(t, http.StatusUnsupportedMediaType, rr.Code)
+		rr = send(newHandler(), "text/csv", strings.NewReader("url\nhttps://a.example\n"))
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+// deadlineRecorder records the write deadlines set by the handler
+type deadlineRecorder struct {
+	*httptest.ResponseRecorder
+	deadlines []time.Time
+}
+
+func (r *deadlineRecorder) SetWriteDeadline(deadline time.Time) error {
+	r.deadlines = append(r.deadlines, deadline)
+	return nil
+}
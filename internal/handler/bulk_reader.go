@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/itksb/go-url-shortener/api"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// content types of the bulk shortening
+const (
+	contentTypeNDJSON    = "application/x-ndjson"
+	contentTypeCSV       = "text/csv"
+	contentTypeMultipart = "multipart/form-data"
+)
+
+// bulkMaxLineBytes - max length of one ndjson line
+const bulkMaxLineBytes = 1 << 20
+
+// bulkCSVTagSeparator separates the tags in the csv column
+const bulkCSVTagSeparator = ";"
+
+// errUnsupportedBulkType - the body of the bulk request is neither ndjson nor csv
+var errUnsupportedBulkType = errors.New("content type must be application/x-ndjson, text/csv or multipart/form-data with the csv file")
+
+// bulkRow - one input row, err is set if the row can not be decoded
+type bulkRow struct {
+	line int
+	item api.ShortenBatchItemRequest
+	err  error
+}
+
+// bulkReader decodes the rows one by one, returns io.EOF after the last row.
+// Other errors stop the reading
+type bulkReader interface {
+	Next() (bulkRow, error)
+}
+
+// newBulkReader chooses the decoder by the content type. Multipart body is read up to the file part
+func newBulkReader(r *http.Request) (bulkReader, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, errUnsupportedBulkType
+	}
+	switch mediaType {
+	case contentTypeNDJSON:
+		return newNDJSONReader(r.Body), nil
+	case contentTypeCSV:
+		return newCSVReader(r.Body)
+	case contentTypeMultipart:
+		parts, err := r.MultipartReader()
+		if err != nil {
+			return nil, err
+		}
+		for {
+			part, err := parts.NextPart()
+			if errors.Is(err, io.EOF) {
+				return nil, errors.New("multipart body has no file part")
+			}
+			if err != nil {
+				return nil, err
+			}
+			if part.FileName() == "" {
+				continue
+			}
+			if partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type")); partType == contentTypeNDJSON {
+				return newNDJSONReader(part), nil
+			}
+			return newCSVReader(part)
+		}
+	}
+	return nil, errUnsupportedBulkType
+}
+
+// ndjsonReader - one json object of api.ShortenBatchItemRequest per line, empty lines are skipped
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func newNDJSONReader(r io.Reader) *ndjsonReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), bulkMaxLineBytes)
+	return &ndjsonReader{scanner: scanner}
+}
+
+// Next implements bulkReader
+func (d *ndjsonReader) Next() (bulkRow, error) {
+	for d.scanner.Scan() {
+		d.line++
+		data := d.scanner.Bytes()
+		if len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+		row := bulkRow{line: d.line}
+		if err := json.Unmarshal(data, &row.item); err != nil {
+			row.err = errors.New("bad input json")
+		}
+		return row, nil
+	}
+	if err := d.scanner.Err(); err != nil {
+		return bulkRow{line: d.line + 1}, err
+	}
+	return bulkRow{}, io.EOF
+}
+
+// csvReader - the first record is the header naming the columns, original_url is required.
+// Tags are separated by bulkCSVTagSeparator
+type csvReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+}
+
+// csvColumns - known columns of the csv header
+var csvColumns = []string{"correlation_id", "original_url", "alias", "expires_at", "ttl_seconds", "max_clicks", "tags", "password",
+	"redirect_code", "pass_query", "title", "preview"}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("csv header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		known := false
+		for _, column := range csvColumns {
+			known = known || column == name
+		}
+		if !known {
+			return nil, fmt.Errorf("csv header: unknown column %q, columns are %s", name, strings.Join(csvColumns, ", "))
+		}
+		columns[name] = i
+	}
+	if _, ok := columns["original_url"]; !ok {
+		return nil, errors.New("csv header: original_url column is required")
+	}
+	return &csvReader{reader: reader, columns: columns}, nil
+}
+
+// Next implements bulkReader
+func (d *csvReader) Next() (bulkRow, error) {
+	record, err := d.reader.Read()
+	if errors.Is(err, io.EOF) {
+		return bulkRow{}, io.EOF
+	}
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return bulkRow{line: parseErr.StartLine, err: parseErr.Err}, nil
+	}
+	if err != nil {
+		return bulkRow{}, err
+	}
+
+	line, _ := d.reader.FieldPos(0)
+	row := bulkRow{line: line}
+	field := func(name string) string {
+		i, ok := d.columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	row.item.CorrelationID = field("correlation_id")
+	row.item.OriginalURL = field("original_url")
+	row.item.Alias = field("alias")
+	row.item.Password = field("password")
+	row.item.Title = field("title")
+	if tags := field("tags"); tags != "" {
+		row.item.Tags = strings.Split(tags, bulkCSVTagSeparator)
+	}
+	if value := field("expires_at"); value != "" {
+		expiresAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			row.err = errors.New("expires_at must be RFC 3339 time")
+			return row, nil
+		}
+		row.item.ExpiresAt = &expiresAt
+	}
+	if value := field("ttl_seconds"); value != "" {
+		if row.item.TTLSeconds, err = strconv.ParseInt(value, 10, 64); err != nil {
+			row.err = errors.New("ttl_seconds must be an integer")
+			return row, nil
+		}
+	}
+	if value := field("max_clicks"); value != "" {
+		if row.item.MaxClicks, err = strconv.ParseInt(value, 10, 64); err != nil {
+			row.err = errors.New("max_clicks must be an integer")
+			return row, nil
+		}
+	}
+	if value := field("redirect_code"); value != "" {
+		if row.item.RedirectCode, err = strconv.Atoi(value); err != nil {
+			row.err = errors.New("redirect_code must be an integer")
+			return row, nil
+		}
+	}
+	if value := field("pass_query"); value != "" {
+		if row.item.PassQuery, err = strconv.ParseBool(value); err != nil {
+			row.err = errors.New("pass_query must be a boolean")
+			return row, nil
+		}
+	}
+	if value := field("preview"); value != "" {
+		if row.item.Preview, err = strconv.ParseBool(value); err != nil {
+			row.err = errors.New("preview must be a boolean")
+			return row, nil
+		}
+	}
+	return row, nil
+}
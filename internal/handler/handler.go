@@ -5,6 +5,7 @@ import (
 	"github.com/itksb/go-url-shortener/internal/dbstorage"
 	"github.com/itksb/go-url-shortener/internal/shortener"
 	"github.com/itksb/go-url-shortener/pkg/logger"
+	"net/http"
 )
 
 // Handler - endpoint handlers
@@ -14,6 +15,7 @@ type Handler struct {
 	cfg          config.Config
 	dbservice    *dbstorage.Storage
 	dbping       IPingableDB
+	clientIP     func(r *http.Request) string // nil resolves the remote address
 }
 
 // NewHandler - constructor
@@ -32,3 +34,16 @@ func NewHandler(
 		dbping:       dbping,
 	}
 }
+
+// UseClientIP replaces the resolver of the client ip of the recorded clicks, the remote address by default
+func (h *Handler) UseClientIP(clientIP func(r *http.Request) string) {
+	h.clientIP = clientIP
+}
+
+// clickIP - client ip of the recorded click
+func (h *Handler) clickIP(r *http.Request) string {
+	if h.clientIP == nil {
+		return RemoteIP(r)
+	}
+	return h.clientIP(r)
+}
@@ -1,15 +1,81 @@
 package handler
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func createShortenURL(id string, baseURL string) string {
 	return fmt.Sprintf("%s/%s", baseURL, id)
 }
 
+// ClientIP returns the real client ip: X-Real-IP, the first X-Forwarded-For or the remote address
+func ClientIP(r *http.Request) string {
+	if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); ip != "" {
+		return ip
+	}
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		first, _, _ := strings.Cut(forwarded, ",")
+		if ip := strings.TrimSpace(first); ip != "" {
+			return ip
+		}
+	}
+	return RemoteIP(r)
+}
+
+// RemoteIP returns the ip of the remote address, the headers are ignored
+func RemoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// hashIP - keyed hash of the ip, so the stored value can not be reversed by brute force
+func hashIP(key string, ip string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// isInvalidOptionsErr checks whether err is caused by the invalid url or shortening options, i.e. it is client error
+func isInvalidOptionsErr(err error) bool {
+	return errors.Is(err, shortener.ErrInvalidURL) ||
+		errors.Is(err, shortener.ErrInvalidAlias) ||
+		errors.Is(err, shortener.ErrInvalidExpiration) ||
+		errors.Is(err, shortener.ErrInvalidMaxClicks) ||
+		errors.Is(err, shortener.ErrInvalidTag) ||
+		errors.Is(err, shortener.ErrInvalidPassword) ||
+		errors.Is(err, shortener.ErrInvalidRedirectCode) ||
+		errors.Is(err, shortener.ErrInvalidTitle)
+}
+
+// makeExpiresAt converts optional expires_at and ttl_seconds request fields into the expiration moment
+func makeExpiresAt(expiresAt *time.Time, ttlSeconds int64) (*time.Time, error) {
+	switch {
+	case expiresAt != nil && ttlSeconds != 0:
+		return nil, fmt.Errorf("%w: use either expires_at or ttl_seconds", shortener.ErrInvalidExpiration)
+	case ttlSeconds < 0:
+		return nil, fmt.Errorf("%w: ttl_seconds must be positive", shortener.ErrInvalidExpiration)
+	case ttlSeconds > 0:
+		at := time.Now().Add(time.Duration(ttlSeconds) * time.Second)
+		return &at, nil
+	}
+	return expiresAt, nil
+}
+
 // APIError - base struct for error response
 type APIError struct {
 	Error string `json:"error"`
@@ -65,3 +131,87 @@ func SendJSONOk(w http.ResponseWriter, content interface{}, code int) error {
 	}
 	return err
 }
+
+// listing page size
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+// parseListOptions parses limit, cursor, sort, order, q, deleted and include_deleted query parameters.
+// deleted=only lists the deleted urls, deleted=include is the same as include_deleted=true
+func parseListOptions(query url.Values) (shortener.ListOptions, error) {
+	opts := shortener.ListOptions{
+		Limit: defaultListLimit,
+		Sort:  shortener.SortCreatedAt,
+		Query: query.Get("q"),
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil || value < 1 || value > maxListLimit {
+			return opts, fmt.Errorf("limit must be from 1 to %d", maxListLimit)
+		}
+		opts.Limit = value
+	}
+
+	switch sortField := query.Get("sort"); sortField {
+	case "":
+	case shortener.SortCreatedAt, shortener.SortOriginalURL:
+		opts.Sort = sortField
+	default:
+		return opts, fmt.Errorf("sort must be %s or %s", shortener.SortCreatedAt, shortener.SortOriginalURL)
+	}
+
+	switch order := query.Get("order"); order {
+	case "", "asc":
+	case "desc":
+		opts.Desc = true
+	default:
+		return opts, errors.New("order must be asc or desc")
+	}
+
+	if includeDeleted := query.Get("include_deleted"); includeDeleted != "" {
+		value, err := strconv.ParseBool(includeDeleted)
+		if err != nil {
+			return opts, errors.New("include_deleted must be a boolean")
+		}
+		opts.IncludeDeleted = value
+	}
+
+	switch deleted := query.Get("deleted"); deleted {
+	case "", "exclude":
+	case "include":
+		opts.IncludeDeleted = true
+	case "only":
+		opts.OnlyDeleted = true
+	default:
+		return opts, errors.New("deleted must be only, include or exclude")
+	}
+
+	if tag := query.Get("tag"); tag != "" {
+		value, err := shortener.NormalizeTag(tag)
+		if err != nil {
+			return opts, err
+		}
+		opts.Tag = value
+	}
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		value, err := shortener.DecodeCursor(cursor)
+		if err != nil {
+			return opts, err
+		}
+		opts.Cursor = &value
+	}
+	return opts, nil
+}
+
+// nextPageLink returns the Link header value of the next page, other query parameters are kept
+func nextPageLink(current *url.URL, cursor shortener.ListCursor, limit int) string {
+	query := current.Query()
+	query.Set("cursor", shortener.EncodeCursor(cursor))
+	query.Set("limit", strconv.Itoa(limit))
+	next := url.URL{Path: current.Path, RawQuery: query.Encode()}
+	return fmt.Sprintf(`<%s>; rel="next"`, next.String())
+}
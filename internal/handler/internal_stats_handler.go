@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"github.com/itksb/go-url-shortener/api"
+	"net/http"
+)
+
+// APIInternalStats - service-wide statistics. Access is restricted by the router
+func (h *Handler) APIInternalStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.urlshortener.Stats(r.Context())
+	if err != nil {
+		h.logger.Error("internal stats error", err.Error())
+		SendJSONError(w, "shortener service error", http.StatusInternalServerError)
+		return
+	}
+
+	response := api.InternalStatsResponse{
+		URLs:        stats.URLs,
+		DeletedURLs: stats.DeletedURLs,
+		Users:       stats.Users,
+	}
+	if q := stats.DeleteQueue; q != nil {
+		response.DeleteQueue = &api.DeleteQueueStats{
+			Pending:  q.Pending,
+			Enqueued: q.Enqueued,
+			Rejected: q.Rejected,
+			Deleted:  q.Deleted,
+			Failed:   q.Failed,
+			Retries:  q.Retries,
+		}
+	}
+	if c := stats.Cache; c != nil {
+		response.Cache = &api.CacheStats{Hits: c.Hits, Misses: c.Misses, Size: c.Size}
+	}
+	if err := SendJSONOk(w, response, http.StatusOK); err != nil {
+		h.logger.Error(err)
+	}
+}
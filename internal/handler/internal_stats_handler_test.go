@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/itksb/go-url-shortener/api"
+	"github.com/itksb/go-url-shortener/internal/config"
+	"github.com/itksb/go-url-shortener/internal/dbstorage"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandler_APIInternalStats(t *testing.T) {
+	l := &loggerMock{}
+	deletedAt := "2023-03-01 10:00:00"
+	storage := newStorageMock(map[int64]shortener.URLListItem{
+		1: {ID: 1, OriginalURL: "https://example.com/1", UserID: "user1"},
+		2: {ID: 2, OriginalURL: "https://example.com/2", UserID: "user1"},
+		3: {ID: 3, OriginalURL: "https://example.com/3", UserID: "user2", DeletedAt: &deletedAt},
+	})
+	service := shortener.NewShortener(l, storage)
+	h := NewHandler(l, service, &dbstorage.Storage{}, &dbstorage.Storage{}, config.Config{})
+
+	rr := httptest.NewRecorder()
+	h.APIInternalStats(rr, httptest.NewRequest(http.MethodGet, "/api/internal/stats", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	resp := api.InternalStatsResponse{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.Equal(t, api.InternalStatsResponse{URLs: 2, DeletedURLs: 1, Users: 2}, resp)
+}
@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/itksb/go-url-shortener/api"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/itksb/go-url-shortener/internal/user"
+	"net/http"
+)
+
+// APIShortenURLJob - accepts the batch for the asynchronous shortening, responds with the queued job.
+// The job is polled by the Location url, its results are downloaded when it is finished
+func (h *Handler) APIShortenURLJob(w http.ResponseWriter, r *http.Request) {
+	defer func() {
+		err := r.Body.Close()
+		if err != nil {
+			h.logger.Error(err.Error())
+		}
+	}()
+
+	request := api.ShortenBatchRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		SendJSONError(w, "bad input json", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	userID, ok := ctx.Value(user.FieldID).(string)
+	if !ok {
+		h.logger.Error("no user id found")
+		SendJSONError(w, "no user found", http.StatusInternalServerError)
+		return
+	}
+
+	items := make([]shortener.JobItem, 0, len(request))
+	for i, item := range request {
+		expiresAt, err := makeExpiresAt(item.ExpiresAt, item.TTLSeconds)
+		if err != nil {
+			SendJSONError(w, fmt.Sprintf("item %d: %s", i, err.Error()), http.StatusBadRequest)
+			return
+		}
+		items = append(items, shortener.JobItem{
+			CorrelationID: item.CorrelationID,
+			OriginalURL:   item.OriginalURL,
+			Alias:         item.Alias,
+			ExpiresAt:     expiresAt,
+			MaxClicks:     item.MaxClicks,
+			Tags:          item.Tags,
+			Password:      item.Password,
+			RedirectCode:  item.RedirectCode,
+			PassQuery:     item.PassQuery,
+			Title:         item.Title,
+			Preview:       item.Preview,
+		})
+	}
+
+	job, err := h.urlshortener.SubmitJob(ctx, userID, items)
+	if h.sendJobError(w, err) {
+		return
+	}
+
+	w.Header().Set("Location", "/api/jobs/"+job.ID)
+	if err := SendJSONOk(w, makeJobResponse(job), http.StatusAccepted); err != nil {
+		h.logger.Error(err)
+	}
+}
+
+// APIGetJob - state and progress of the job of the user
+func (h *Handler) APIGetJob(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := ctx.Value(user.FieldID).(string)
+	if !ok {
+		h.logger.Error("no user id found")
+		SendJSONError(w, "no user found", http.StatusInternalServerError)
+		return
+	}
+
+	job, err := h.urlshortener.GetJob(ctx, chi.URLParam(r, "id"), userID)
+	if h.sendJobError(w, err) {
+		return
+	}
+	if err := SendJSONOk(w, makeJobResponse(job), http.StatusOK); err != nil {
+		h.logger.Error(err)
+	}
+}
+
+// APIGetJobResults - results of the finished job of the user, the canceled and failed jobs have results of the processed items
+func (h *Handler) APIGetJobResults(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := ctx.Value(user.FieldID).(string)
+	if !ok {
+		h.logger.Error("no user id found")
+		SendJSONError(w, "no user found", http.StatusInternalServerError)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	job, err := h.urlshortener.GetJob(ctx, id, userID)
+	if h.sendJobError(w, err) {
+		return
+	}
+	if !job.IsFinished() {
+		SendJSONError(w, "job is not finished", http.StatusConflict)
+		return
+	}
+
+	results, err := h.urlshortener.JobResults(ctx, id, userID)
+	if h.sendJobError(w, err) {
+		return
+	}
+	response := make(api.JobResultsResponse, 0, len(results))
+	for _, result := range results {
+		item := api.JobResultResponse{
+			CorrelationID: result.CorrelationID,
+			Duplicate:     result.Duplicate,
+			Error:         result.Error,
+		}
+		if result.ShortCode != "" {
+			item.ShortURL = createShortenURL(result.ShortCode, h.cfg.ShortBaseURL)
+		}
+		response = append(response, item)
+	}
+	if err := SendJSONOk(w, response, http.StatusOK); err != nil {
+		h.logger.Error(err)
+	}
+}
+
+// APICancelJob - stops the job of the user, the results of the processed items are kept
+func (h *Handler) APICancelJob(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := ctx.Value(user.FieldID).(string)
+	if !ok {
+		h.logger.Error("no user id found")
+		SendJSONError(w, "no user found", http.StatusInternalServerError)
+		return
+	}
+
+	job, err := h.urlshortener.CancelJob(ctx, chi.URLParam(r, "id"), userID)
+	if h.sendJobError(w, err) {
+		return
+	}
+	if err := SendJSONOk(w, makeJobResponse(job), http.StatusOK); err != nil {
+		h.logger.Error(err)
+	}
+}
+
+// sendJobError sends the error of the job call, reports whether err is not nil
+func (h *Handler) sendJobError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, shortener.ErrInvalidJob):
+		SendJSONError(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, shortener.ErrJobNotFound):
+		SendJSONError(w, "job not found", http.StatusNotFound)
+	case errors.Is(err, shortener.ErrJobFinished):
+		SendJSONError(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, shortener.ErrJobQueueFull), errors.Is(err, shortener.ErrJobRunnerClosed):
+		w.Header().Set("Retry-After", "1")
+		SendJSONError(w, err.Error(), http.StatusServiceUnavailable)
+	case errors.Is(err, shortener.ErrJobsDisabled):
+		SendJSONError(w, err.Error(), http.StatusNotImplemented)
+	default:
+		h.logger.Error("job error", err.Error())
+		SendJSONError(w, "shortener service error", http.StatusInternalServerError)
+	}
+	return true
+}
+
+func makeJobResponse(job shortener.Job) api.JobResponse {
+	return api.JobResponse{
+		ID:        job.ID,
+		Status:    job.Status,
+		Total:     job.Total,
+		Processed: job.Processed,
+		Failed:    job.Failed,
+		Error:     job.Error,
+		CreatedAt: job.CreatedAt,
+		UpdatedAt: job.UpdatedAt,
+	}
+}
@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"github.com/itksb/go-url-shortener/api"
+	"github.com/itksb/go-url-shortener/internal/config"
+	"github.com/itksb/go-url-shortener/internal/dbstorage"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/itksb/go-url-shortener/internal/storage"
+	"github.com/itksb/go-url-shortener/internal/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandler_Jobs(t *testing.T) {
+	l := &loggerMock{}
+	repo := newStorageMock(map[int64]shortener.URLListItem{})
+	service := shortener.NewShortener(l, repo)
+	service.UsePasswordCost(bcrypt.MinCost)
+	runner := shortener.NewJobRunner(l, service, storage.NewJobStorage(), 1, 10, 2, 3)
+	service.UseJobs(runner)
+	defer runner.Close(context.Background())
+	h := NewHandler(l, service, &dbstorage.Storage{}, &dbstorage.Storage{}, config.Config{ShortBaseURL: "http://short.base"})
+
+	call := func(handler http.HandlerFunc, method string, id string, body string, userID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/jobs/"+id, strings.NewReader(body))
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id)
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+		rr := httptest.NewRecorder()
+		handler(rr, req.WithContext(context.WithValue(ctx, user.FieldID, userID)))
+		return rr
+	}
+
+	t.Run("bad input", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, call(h.APIShortenURLJob, http.MethodPost, "", `[{`, "owner").Code)
+		assert.Equal(t, http.StatusBadRequest, call(h.APIShortenURLJob, http.MethodPost, "", `[]`, "owner").Code)
+		assert.Equal(t, http.StatusBadRequest, call(h.APIShortenURLJob, http.MethodPost, "", `[{},{},{},{}]`, "owner").Code)
+		assert.Equal(t, http.StatusBadRequest,
+			call(h.APIShortenURLJob, http.MethodPost, "", `[{"original_url":"https://a.example","ttl_seconds":-1}]`, "owner").Code)
+	})
+
+	t.Run("submit and poll", func(t *testing.T) {
+		body := `[{"correlation_id":"a","original_url":"https://a.example"},
+                  {"correlation_id":"b","original_url":""},
+                  {"correlation_id":"c","original_url":"https://c.example","tags":["work"]}]`
+		rr := call(h.APIShortenURLJob, http.MethodPost, "", body, "owner")
+		require.Equal(t, http.StatusAccepted, rr.Code)
+		job := api.JobResponse{}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &job))
+		assert.Equal(t, "/api/jobs/"+job.ID, rr.Header().Get("Location"))
+		assert.Equal(t, shortener.JobQueued, job.Status)
+		assert.Equal(t, 3, job.Total)
+
+		require.Eventually(t, func() bool {
+			rr = call(h.APIGetJob, http.MethodGet, job.ID, "", "owner")
+			require.Equal(t, http.StatusOK, rr.Code)
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &job))
+			return job.Status == shortener.JobDone
+		}, time.Second, 5*time.Millisecond)
+		assert.Equal(t, 3, job.Processed)
+		assert.Equal(t, 1, job.Failed)
+
+		rr = call(h.APIGetJobResults, http.MethodGet, job.ID, "", "owner")
+		require.Equal(t, http.StatusOK, rr.Code)
+		results := api.JobResultsResponse{}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &results))
+		require.Len(t, results, 3)
+		assert.Equal(t, api.JobResultResponse{CorrelationID: "a", ShortURL: "http://short.base/0"}, results[0])
+		assert.Equal(t, "b", results[1].CorrelationID)
+		assert.NotEmpty(t, results[1].Error)
+		assert.Equal(t, api.JobResultResponse{CorrelationID: "c", ShortURL: "http://short.base/1"}, results[2])
+
+		assert.Equal(t, http.StatusConflict, call(h.APICancelJob, http.MethodDelete, job.ID, "", "owner").Code)
+		assert.Equal(t, http.StatusNotFound, call(h.APIGetJob, http.MethodGet, job.ID, "", "stranger").Code)
+		assert.Equal(t, http.StatusNotFound, call(h.APIGetJobResults, http.MethodGet, job.ID, "", "stranger").Code)
+		assert.Equal(t, http.StatusNotFound, call(h.APICancelJob, http.MethodDelete, job.ID, "", "stranger").Code)
+		assert.Equal(t, http.StatusNotFound, call(h.APIGetJob, http.MethodGet, "unknown", "", "owner").Code)
+	})
+
+	t.Run("options", func(t *testing.T) {
+		body := `[{"original_url":"https://options.example","password":"secret","redirect_code":302,"pass_query":true,
+                   "title":"Options","preview":true}]`
+		rr := call(h.APIShortenURLJob, http.MethodPost, "", body, "owner")
+		require.Equal(t, http.StatusAccepted, rr.Code)
+		job := api.JobResponse{}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &job))
+		require.Eventually(t, func() bool {
+			rr = call(h.APIGetJob, http.MethodGet, job.ID, "", "owner")
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &job))
+			return job.Status == shortener.JobDone
+		}, time.Second, 5*time.Millisecond)
+
+		rr = call(h.APIGetJobResults, http.MethodGet, job.ID, "", "owner")
+		results := api.JobResultsResponse{}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &results))
+		require.Len(t, results, 1)
+		require.Empty(t, results[0].Error)
+		item, err := repo.GetURL(context.Background(), strings.TrimPrefix(results[0].ShortURL, "http://short.base/"))
+		require.NoError(t, err)
+		assert.True(t, item.IsProtected())
+		assert.Equal(t, http.StatusFound, item.RedirectStatus())
+		assert.True(t, item.PassQuery)
+		assert.Equal(t, "Options", item.Title)
+		assert.True(t, item.Preview)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		h := NewHandler(l, shortener.NewShortener(l, repo), &dbstorage.Storage{}, &dbstorage.Storage{}, config.Config{})
+		rr := call(h.APIShortenURLJob, http.MethodPost, "", `[{"original_url":"https://a.example"}]`, "owner")
+		assert.Equal(t, http.StatusNotImplemented, rr.Code)
+	})
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"html/template"
+	"net/http"
+)
+
+// passwordFormTemplate - page asking the password of the protected link, it is posted to the link itself
+var passwordFormTemplate = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Password required</title>
+</head>
+<body>
+<form method="post" action="">
+<p><label for="password">The link is protected by the password</label></p>
+{{if .Message}}<p role="alert">{{.Message}}</p>{{end}}
+<p><input type="password" id="password" name="password" autocomplete="current-password" autofocus required></p>
+<p><button type="submit">Open the link</button></p>
+</form>
+</body>
+</html>
+`))
+
+// sendPasswordForm renders the password form of the protected link with the optional message
+func (h *Handler) sendPasswordForm(w http.ResponseWriter, message string, statusCode int) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	// the form must not be cached by the shared caches, the response depends on the posted password
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+	if err := passwordFormTemplate.Execute(w, struct{ Message string }{Message: message}); err != nil {
+		h.logger.Error("password form error", err.Error())
+	}
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"html/template"
+	"net/http"
+	"time"
+)
+
+// previewPageTemplate - page showing where the link leads instead of redirecting to it.
+// The template escapes the destination, the unsafe schemes are replaced in href
+var previewPageTemplate = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex, nofollow">
+<title>{{if .Title}}{{.Title}}{{else}}Link preview{{end}}</title>
+</head>
+<body>
+{{if .Title}}<h1>{{.Title}}</h1>{{end}}
+<p>The link <code>{{.ShortURL}}</code> leads to:</p>
+{{if .Destination}}<p><a href="{{.Destination}}" rel="noopener noreferrer nofollow">{{.Destination}}</a></p>
+{{else}}<p>The destination is protected by the password, <a href="{{.ShortURL}}">open the link</a> to enter it.</p>
+{{end}}
+{{if .Created}}<p>Created on <time datetime="{{.CreatedAt}}">{{.Created}}</time></p>{{end}}
+</body>
+</html>
+`))
+
+// previewPage - data of previewPageTemplate
+type previewPage struct {
+	Title       string
+	ShortURL    string
+	Destination string // empty for the protected link
+	CreatedAt   string // creation time in RFC 3339
+	Created     string // creation date for humans
+}
+
+// sendPreviewPage renders the preview of the link leading to the destination, the empty destination is hidden
+func (h *Handler) sendPreviewPage(w http.ResponseWriter, listItem shortener.URLListItem, destination string) {
+	page := previewPage{
+		Title:       listItem.Title,
+		ShortURL:    createShortenURL(listItem.ShortCode, h.cfg.ShortBaseURL),
+		Destination: destination,
+	}
+	if createdAt, ok := listItem.CreatedTime(); ok {
+		page.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+		page.Created = createdAt.UTC().Format("2 January 2006")
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	// the link may be changed, deleted or run out of clicks
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if err := previewPageTemplate.Execute(w, page); err != nil {
+		h.logger.Error("preview page error", err.Error())
+	}
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/itksb/go-url-shortener/pkg/qrcode"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// qr code image limits
+const (
+	defaultQRSize   = 256 // in pixels
+	minQRSize       = 64
+	maxQRSize       = 2048
+	maxQRMargin     = 16 // in modules
+	qrCacheDuration = time.Hour
+)
+
+// qrOptions - query parameters of the qr code image
+type qrOptions struct {
+	format string // png or svg
+	size   int
+	margin int
+	level  qrcode.Level
+}
+
+// GetURLQRCode - qr code image of the short url. The deleted and expired urls are gone
+func (h *Handler) GetURLQRCode(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseQROptions(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	listItem, err := h.urlshortener.GetURL(r.Context(), id)
+	if err != nil {
+		h.logger.Info("Id not found", id)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if len(listItem.OriginalURL) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if listItem.IsDeleted() || listItem.IsExpired(time.Now()) || listItem.IsExhausted() {
+		w.WriteHeader(http.StatusGone)
+		return
+	}
+
+	code, err := qrcode.Encode(createShortenURL(listItem.ShortCode, h.cfg.ShortBaseURL), opts.level)
+	if err != nil {
+		h.logger.Error("qr code error", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	contentType := "image/png"
+	if opts.format == "svg" {
+		contentType = "image/svg+xml"
+		err = code.WriteSVG(buf, opts.size, opts.margin)
+	} else {
+		// the modules are whole pixels, the image is not bigger than the size unless the code does not fit it
+		err = code.WritePNG(buf, opts.size/(code.Size()+2*opts.margin), opts.margin)
+	}
+	if err != nil {
+		h.logger.Error("qr code render error", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	// the link may be deleted later
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(qrCacheDuration.Seconds())))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// parseQROptions parses format, size, margin and level query parameters
+func parseQROptions(query url.Values) (qrOptions, error) {
+	opts := qrOptions{
+		format: "png",
+		size:   defaultQRSize,
+		margin: qrcode.DefaultMargin,
+		level:  qrcode.Medium,
+	}
+
+	switch format := query.Get("format"); format {
+	case "":
+	case "png", "svg":
+		opts.format = format
+	default:
+		return opts, errors.New("format must be png or svg")
+	}
+
+	if size := query.Get("size"); size != "" {
+		value, err := strconv.Atoi(size)
+		if err != nil || value < minQRSize || value > maxQRSize {
+			return opts, fmt.Errorf("size must be from %d to %d", minQRSize, maxQRSize)
+		}
+		opts.size = value
+	}
+
+	if margin := query.Get("margin"); margin != "" {
+		value, err := strconv.Atoi(margin)
+		if err != nil || value < 0 || value > maxQRMargin {
+			return opts, fmt.Errorf("margin must be from 0 to %d", maxQRMargin)
+		}
+		opts.margin = value
+	}
+
+	if level := query.Get("level"); level != "" {
+		value, err := qrcode.ParseLevel(level)
+		if err != nil {
+			return opts, errors.New("level must be L, M, Q or H")
+		}
+		opts.level = value
+	}
+	return opts, nil
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"context"
+	"github.com/go-chi/chi/v5"
+	"github.com/itksb/go-url-shortener/internal/config"
+	"github.com/itksb/go-url-shortener/internal/dbstorage"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler_GetURLQRCode(t *testing.T) {
+	l := &loggerMock{}
+	deletedAt := "2023-05-29T10:00:00Z"
+	storage := newStorageMock(map[int64]shortener.URLListItem{
+		1: {ID: 1, ShortCode: "abc", OriginalURL: "https://example.com", UserID: "owner"},
+		2: {ID: 2, ShortCode: "gone", OriginalURL: "https://example.com/gone", UserID: "owner", DeletedAt: &deletedAt},
+	})
+	h := NewHandler(l, shortener.NewShortener(l, storage), &dbstorage.Storage{}, &dbstorage.Storage{}, config.Config{ShortBaseURL: "http://short.base"})
+
+	get := func(id string, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/"+id+"/qr?"+query, nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", id)
+		rr := httptest.NewRecorder()
+		h.GetURLQRCode(rr, req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx)))
+		return rr
+	}
+
+	t.Run("png", func(t *testing.T) {
+		rr := get("abc", "size=300&margin=2&level=h")
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "image/png", rr.Header().Get("Content-Type"))
+		img, err := png.Decode(rr.Body)
+		require.NoError(t, err)
+		// "http://short.base/abc" of the level H is the version 3 of 29 modules, 33 with the margin
+		assert.Equal(t, 300/33*33, img.Bounds().Dx())
+		assert.Equal(t, img.Bounds().Dx(), img.Bounds().Dy())
+	})
+
+	t.Run("svg", func(t *testing.T) {
+		rr := get("abc", "format=svg")
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "image/svg+xml", rr.Header().Get("Content-Type"))
+		assert.True(t, strings.HasPrefix(rr.Body.String(), "<svg "))
+		assert.Contains(t, rr.Body.String(), `width="256"`)
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		for _, query := range []string{"format=gif", "size=10", "size=big", "margin=-1", "margin=17", "level=X"} {
+			assert.Equal(t, http.StatusBadRequest, get("abc", query).Code, query)
+		}
+	})
+
+	t.Run("deleted", func(t *testing.T) {
+		assert.Equal(t, http.StatusGone, get("gone", "").Code)
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, get("unknown", "").Code)
+	})
+}
@@ -48,7 +48,35 @@ func (h *Handler) APIShortenURL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sURLId, err := h.urlshortener.ShortenURL(r.Context(), request.URL, userID)
+	expiresAt, err := makeExpiresAt(request.ExpiresAt, request.TTLSeconds)
+	if err != nil {
+		SendJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sURLId, err := h.urlshortener.ShortenURL(r.Context(), request.URL, userID, shortener.SaveOptions{
+		Alias:        request.Alias,
+		ExpiresAt:    expiresAt,
+		MaxClicks:    request.MaxClicks,
+		Tags:         request.Tags,
+		Password:     request.Password,
+		RedirectCode: request.RedirectCode,
+		PassQuery:    request.PassQuery,
+		Title:        request.Title,
+		Preview:      request.Preview,
+	})
+	if isInvalidOptionsErr(err) {
+		SendJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, shortener.ErrURLDenied) {
+		SendJSONError(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, shortener.ErrAliasTaken) {
+		SendJSONError(w, err.Error(), http.StatusConflict)
+		return
+	}
 	if err != nil && !errors.Is(err, shortener.ErrDuplicate) {
 		h.logger.Error("ApiShortenUrl. urlshortener.ShortenURL(...) call error", err.Error())
 		SendJSONError(w, "shortener service error", http.StatusInternalServerError)
@@ -82,16 +110,30 @@ func (h *Handler) APIListUserURL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	urlListItems, err := h.urlshortener.ListURLByUserID(ctx, userID)
+	opts, err := parseListOptions(r.URL.Query())
 	if err != nil {
-		h.logger.Error("error while searching user urls")
+		SendJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.urlshortener.ListURLs(ctx, userID, opts)
+	if errors.Is(err, shortener.ErrInvalidCursor) {
+		SendJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		h.logger.Error("error while searching user urls", err.Error())
 		SendJSONError(w, "shortener service error", http.StatusInternalServerError)
 		return
 	}
+	urlListItems := page.Items
+	if page.NextCursor != nil {
+		w.Header().Set("Link", nextPageLink(r.URL, *page.NextCursor, opts.Limit))
+	}
 
 	// creating short urls is infrastructure layer responsibility, that`s why it is here
 	for idx := range urlListItems {
-		urlListItems[idx].ShortURL = createShortenURL(fmt.Sprint(urlListItems[idx].ID), h.cfg.ShortBaseURL)
+		urlListItems[idx].ShortURL = createShortenURL(urlListItems[idx].ShortCode, h.cfg.ShortBaseURL)
 	}
 
 	if len(urlListItems) > 0 {
@@ -139,24 +181,59 @@ func (h *Handler) APIShortenURLBatch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	response := api.ShortenBatchResponse{}
-	conflict := false
+	items := make([]shortener.BatchItem, 0, len(requestItems))
 	for _, shortenBatchItemRequest := range requestItems {
-		sURLId, err := h.urlshortener.ShortenURL(r.Context(), shortenBatchItemRequest.OriginalURL, userID)
-		if err != nil && !errors.Is(err, shortener.ErrDuplicate) {
-			h.logger.Error("ApiShortenUrl. urlshortener.ShortenURL(...) call error", err.Error())
-			SendJSONError(w, "shortener service error", http.StatusInternalServerError)
+		expiresAt, err := makeExpiresAt(shortenBatchItemRequest.ExpiresAt, shortenBatchItemRequest.TTLSeconds)
+		if err != nil {
+			SendJSONError(w, fmt.Sprintf("correlation_id %s: %s", shortenBatchItemRequest.CorrelationID, err.Error()), http.StatusBadRequest)
 			return
 		}
-		if errors.Is(err, shortener.ErrDuplicate) {
-			conflict = true
+		items = append(items, shortener.BatchItem{
+			OriginalURL: shortenBatchItemRequest.OriginalURL,
+			Opts: shortener.SaveOptions{
+				Alias:        shortenBatchItemRequest.Alias,
+				ExpiresAt:    expiresAt,
+				MaxClicks:    shortenBatchItemRequest.MaxClicks,
+				Tags:         shortenBatchItemRequest.Tags,
+				Password:     shortenBatchItemRequest.Password,
+				RedirectCode: shortenBatchItemRequest.RedirectCode,
+				PassQuery:    shortenBatchItemRequest.PassQuery,
+				Title:        shortenBatchItemRequest.Title,
+				Preview:      shortenBatchItemRequest.Preview,
+			},
+		})
+	}
+
+	results, err := h.urlshortener.ShortenURLBatch(ctx, userID, items)
+	var itemErr *shortener.BatchItemError
+	if errors.As(err, &itemErr) &&
+		(isInvalidOptionsErr(err) || errors.Is(err, shortener.ErrAliasTaken) || errors.Is(err, shortener.ErrURLDenied)) {
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, shortener.ErrAliasTaken):
+			status = http.StatusConflict
+		case errors.Is(err, shortener.ErrURLDenied):
+			status = http.StatusForbidden
 		}
-		shortURL := createShortenURL(sURLId, h.cfg.ShortBaseURL)
-		responseItem := api.ShortenBatchItemResponse{
-			CorrelationID: shortenBatchItemRequest.CorrelationID,
-			ShortURL:      shortURL,
+		SendJSONError(w, fmt.Sprintf("correlation_id %s: %s", requestItems[itemErr.Index].CorrelationID, itemErr.Err.Error()), status)
+		return
+	}
+	if err != nil {
+		h.logger.Error("ApiShortenUrlBatch. urlshortener.ShortenURLBatch(...) call error", err.Error())
+		SendJSONError(w, "shortener service error", http.StatusInternalServerError)
+		return
+	}
+
+	response := make(api.ShortenBatchResponse, 0, len(results))
+	conflict := false
+	for i, result := range results {
+		if result.Duplicate {
+			conflict = true
 		}
-		response = append(response, responseItem)
+		response = append(response, api.ShortenBatchItemResponse{
+			CorrelationID: requestItems[i].CorrelationID,
+			ShortURL:      createShortenURL(result.ShortCode, h.cfg.ShortBaseURL),
+		})
 	}
 	if conflict {
 		SendJSONOk(w, response, http.StatusConflict)
@@ -166,6 +243,51 @@ func (h *Handler) APIShortenURLBatch(w http.ResponseWriter, r *http.Request) {
 
 }
 
+// APIRestoreURLBatch - restores urls deleted by the user, responds with the restored short urls
+func (h *Handler) APIRestoreURLBatch(w http.ResponseWriter, r *http.Request) {
+	defer func() {
+		err := r.Body.Close()
+		if err != nil {
+			h.logger.Error(err.Error())
+		}
+	}()
+
+	ids := api.ShortenRestoreBatchRequest{}
+	err := json.NewDecoder(r.Body).Decode(&ids)
+	if err != nil {
+		h.logger.Error(err)
+		SendJSONError(w, "bad input json", http.StatusBadRequest)
+		return
+	}
+	if len(ids) == 0 {
+		SendJSONError(w, "bad input request: empty input", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	userID, ok := ctx.Value(user.FieldID).(string)
+	if !ok {
+		h.logger.Error("no user id found")
+		SendJSONError(w, "no user found", http.StatusInternalServerError)
+		return
+	}
+
+	codes, err := h.urlshortener.RestoreURLBatch(ctx, userID, ids)
+	if err != nil {
+		h.logger.Error(fmt.Sprintf("error while RestoreURLBatch: %s", err.Error()))
+		SendJSONError(w, "shortener service error", http.StatusInternalServerError)
+		return
+	}
+
+	response := make(api.ShortenRestoreBatchResponse, 0, len(codes))
+	for _, code := range codes {
+		response = append(response, createShortenURL(code, h.cfg.ShortBaseURL))
+	}
+	if err := SendJSONOk(w, response, http.StatusOK); err != nil {
+		h.logger.Error(err)
+	}
+}
+
 // APIDeleteURLBatch - .
 func (h *Handler) APIDeleteURLBatch(w http.ResponseWriter, r *http.Request) {
 	defer func() {
@@ -197,6 +319,11 @@ func (h *Handler) APIDeleteURLBatch(w http.ResponseWriter, r *http.Request) {
 	}
 
 	err = h.urlshortener.DeleteURLBatch(context.Background(), userID, ids)
+	if errors.Is(err, shortener.ErrDeleteQueueFull) || errors.Is(err, shortener.ErrDeleteQueueClosed) {
+		w.Header().Set("Retry-After", "1")
+		SendJSONError(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
 	if err != nil {
 		h.logger.Error(fmt.Sprintf("error while DeleteURLBatch: %s", err.Error()))
 	}
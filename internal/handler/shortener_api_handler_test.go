@@ -16,6 +16,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -785,3 +786,212 @@ func TestHandler_APIDeleteURLBatch(t *testing.T) {
 	})
 
 }
+
+func TestHandler_APIShortenURL_Alias(t *testing.T) {
+	l := &loggerMock{}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantCode   int
+		wantResult string
+	}{
+		{
+			name:       "alias is used as short code",
+			body:       `{"url":"https://example.com/sale","alias":"spring-sale"}`,
+			wantCode:   http.StatusCreated,
+			wantResult: "http://short.base/spring-sale",
+		},
+		{
+			name:     "alias is taken",
+			body:     `{"url":"https://example.com/other","alias":"taken"}`,
+			wantCode: http.StatusConflict,
+		},
+		{
+			name:     "reserved alias",
+			body:     `{"url":"https://example.com/other","alias":"api"}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "not a url",
+			body:     `{"url":"not a url"}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "scheme is not allowed",
+			body:     `{"url":"javascript:alert(1)"}`,
+			wantCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := newStorageMock(map[int64]shortener.URLListItem{
+				100: {ID: 100, ShortCode: "taken", OriginalURL: "https://example.com", UserID: "1"},
+			})
+			h := NewHandler(l, shortener.NewShortener(l, storage), &dbstorage.Storage{}, &dbstorage.Storage{},
+				config.Config{ShortBaseURL: "http://short.base"})
+
+			req := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(tt.body))
+			req = req.WithContext(context.WithValue(req.Context(), user.FieldID, "1"))
+			rr := httptest.NewRecorder()
+
+			h.APIShortenURL(rr, req)
+
+			require.Equal(t, tt.wantCode, rr.Code)
+			if tt.wantResult != "" {
+				expectedBody, _ := json.Marshal(api.ShortenResponse{Result: tt.wantResult})
+				assert.JSONEq(t, string(expectedBody), rr.Body.String())
+			} else {
+				var apiError APIError
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &apiError))
+				assert.NotEmpty(t, apiError.Error)
+			}
+		})
+	}
+}
+
+func TestHandler_APIShortenURLBatch_Conflict(t *testing.T) {
+	l := &loggerMock{}
+	newHandler := func() *Handler {
+		storage := newStorageMock(map[int64]shortener.URLListItem{
+			100: {ID: 100, ShortCode: "taken", OriginalURL: "https://example.com", UserID: "1"},
+		})
+		return NewHandler(l, shortener.NewShortener(l, storage), &dbstorage.Storage{}, &dbstorage.Storage{},
+			config.Config{ShortBaseURL: "http://short.base"})
+	}
+	newRequest := func(body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/api/shorten/batch", strings.NewReader(body))
+		return req.WithContext(context.WithValue(req.Context(), user.FieldID, "1"))
+	}
+
+	t.Run("duplicate url", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		newHandler().APIShortenURLBatch(rr, newRequest(
+			`[{"correlation_id":"1","original_url":"https://example.com"},{"correlation_id":"2","original_url":"https://example.com/new"}]`))
+
+		require.Equal(t, http.StatusConflict, rr.Code)
+		expectedBody, _ := json.Marshal(api.ShortenBatchResponse{
+			{CorrelationID: "1", ShortURL: "http://short.base/taken"},
+			{CorrelationID: "2", ShortURL: "http://short.base/0"},
+		})
+		assert.JSONEq(t, string(expectedBody), rr.Body.String())
+	})
+
+	t.Run("alias is taken", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		newHandler().APIShortenURLBatch(rr, newRequest(
+			`[{"correlation_id":"1","original_url":"https://example.com/new"},{"correlation_id":"2","original_url":"https://example.com/other","alias":"taken"}]`))
+
+		require.Equal(t, http.StatusConflict, rr.Code)
+		var apiError APIError
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &apiError))
+		assert.Contains(t, apiError.Error, "correlation_id 2")
+	})
+}
+
+func TestHandler_APIDeleteURLBatch_QueueClosed(t *testing.T) {
+	l := &loggerMock{}
+	storage := newStorageMock(map[int64]shortener.URLListItem{})
+	service := shortener.NewShortener(l, storage)
+	queue := shortener.NewDeleteQueue(l, storage, 1, 1, time.Hour, 0)
+	require.NoError(t, queue.Close(context.Background()))
+	service.UseDeleteQueue(queue)
+	h := NewHandler(l, service, &dbstorage.Storage{}, &dbstorage.Storage{}, config.Config{})
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/user/urls", strings.NewReader(`["abc"]`))
+	req = req.WithContext(context.WithValue(req.Context(), user.FieldID, "1"))
+	rr := httptest.NewRecorder()
+	h.APIDeleteURLBatch(rr, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Equal(t, "1", rr.Header().Get("Retry-After"))
+}
+
+func TestHandler_APIListUserURL_Pagination(t *testing.T) {
+	l := &loggerMock{}
+	urls := map[int64]shortener.URLListItem{}
+	for id := int64(1); id <= 3; id++ {
+		urls[id] = shortener.URLListItem{ID: id, UserID: "1", OriginalURL: "https://example.com/" + strconv.FormatInt(id, 10)}
+	}
+	h := NewHandler(l, shortener.NewShortener(l, newStorageMock(urls)), &dbstorage.Storage{}, &dbstorage.Storage{},
+		config.Config{ShortBaseURL: "http://short.base"})
+
+	list := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req = req.WithContext(context.WithValue(req.Context(), user.FieldID, "1"))
+		rr := httptest.NewRecorder()
+		h.APIListUserURL(rr, req)
+		return rr
+	}
+
+	var shortURLs []string
+	target := "/api/user/urls?limit=2&order=desc&q=example"
+	for target != "" {
+		rr := list(target)
+		require.Equal(t, http.StatusOK, rr.Code)
+		var items []shortener.URLListItem
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &items))
+		for _, item := range items {
+			shortURLs = append(shortURLs, item.ShortURL)
+		}
+
+		target = ""
+		if link := rr.Header().Get("Link"); link != "" {
+			require.True(t, strings.HasSuffix(link, `>; rel="next"`))
+			target = strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`)
+			assert.Contains(t, target, "q=example")
+		}
+	}
+	assert.Equal(t, []string{"http://short.base/3", "http://short.base/2", "http://short.base/1"}, shortURLs)
+
+	for _, target := range []string{
+		"/api/user/urls?limit=0",
+		"/api/user/urls?sort=user_id",
+		"/api/user/urls?order=up",
+		"/api/user/urls?include_deleted=maybe",
+		"/api/user/urls?deleted=all",
+		"/api/user/urls?cursor=broken",
+	} {
+		assert.Equal(t, http.StatusBadRequest, list(target).Code, target)
+	}
+}
+
+func TestHandler_APIRestoreURLBatch(t *testing.T) {
+	l := &loggerMock{}
+	deletedAt := "2023-04-01T10:00:00"
+	urls := map[int64]shortener.URLListItem{
+		1: {ID: 1, UserID: "1", OriginalURL: "https://example.com/1", DeletedAt: &deletedAt},
+		2: {ID: 2, UserID: "1", OriginalURL: "https://example.com/2"},
+		3: {ID: 3, UserID: "2", OriginalURL: "https://example.com/3", DeletedAt: &deletedAt},
+	}
+	h := NewHandler(l, shortener.NewShortener(l, newStorageMock(urls)), &dbstorage.Storage{}, &dbstorage.Storage{},
+		config.Config{ShortBaseURL: "http://short.base"})
+
+	request := func(method string, target string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req = req.WithContext(context.WithValue(req.Context(), user.FieldID, "1"))
+		rr := httptest.NewRecorder()
+		if method == http.MethodGet {
+			h.APIListUserURL(rr, req)
+		} else {
+			h.APIRestoreURLBatch(rr, req)
+		}
+		return rr
+	}
+
+	rr := request(http.MethodGet, "/api/user/urls?deleted=only", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var items []shortener.URLListItem
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &items))
+	require.Len(t, items, 1)
+	assert.Equal(t, "http://short.base/1", items[0].ShortURL)
+	assert.True(t, items[0].Deleted)
+
+	rr = request(http.MethodPost, "/api/user/urls/restore", `["1", "2", "3"]`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `["http://short.base/1"]`, rr.Body.String())
+
+	assert.Equal(t, http.StatusNoContent, request(http.MethodGet, "/api/user/urls?deleted=only", "").Code)
+	assert.Equal(t, http.StatusBadRequest, request(http.MethodPost, "/api/user/urls/restore", `[]`).Code)
+	assert.Equal(t, http.StatusBadRequest, request(http.MethodPost, "/api/user/urls/restore", `{`).Code)
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"time"
 )
 
 // ShortenURL -.
@@ -17,7 +18,8 @@ func (h *Handler) ShortenURL(w http.ResponseWriter, r *http.Request) {
 		h.logger.Error("shorten request", err)
 		return
 	}
-	inURL := string(bytes)
+	// the trailing newline of the command line clients is not the part of the url
+	inURL := strings.TrimSpace(string(bytes))
 
 	ctx := r.Context()
 	userID, ok := ctx.Value(user.FieldID).(string)
@@ -27,7 +29,15 @@ func (h *Handler) ShortenURL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sURLId, err := h.urlshortener.ShortenURL(r.Context(), inURL, userID)
+	sURLId, err := h.urlshortener.ShortenURL(r.Context(), inURL, userID, shortener.SaveOptions{})
+	if errors.Is(err, shortener.ErrInvalidURL) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, shortener.ErrURLDenied) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
 	if err != nil && !errors.Is(err, shortener.ErrDuplicate) {
 		h.logger.Error("Shorten url failed", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -46,7 +56,9 @@ func (h *Handler) ShortenURL(w http.ResponseWriter, r *http.Request) {
 
 }
 
-// GetURL - endpoint handler
+// GetURL - endpoint handler, redirects to the original url.
+// The password form is served for the protected url, the url is redirected to after the right password is posted.
+// The preview page is served for "/{id}+" and instead of the redirect for the url with the forced preview
 func (h *Handler) GetURL(w http.ResponseWriter, r *http.Request) {
 	_, id, ok := strings.Cut(r.URL.Path, "/")
 	if !ok {
@@ -54,6 +66,9 @@ func (h *Handler) GetURL(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	// the plus is never the part of the short code
+	preview := strings.HasSuffix(id, "+")
+	id = strings.TrimSuffix(id, "+")
 
 	listItem, err := h.urlshortener.GetURL(r.Context(), id)
 	if err != nil {
@@ -74,7 +89,91 @@ func (h *Handler) GetURL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Location", listItem.OriginalURL)
-	w.WriteHeader(http.StatusTemporaryRedirect)
+	if listItem.IsExpired(time.Now()) {
+		h.logger.Info("Url is expired id:", id)
+		w.WriteHeader(http.StatusGone)
+		return
+	}
+
+	// the host may be denied after the url is shortened
+	if err = h.urlshortener.CheckURLDenied(listItem.OriginalURL); errors.Is(err, shortener.ErrURLDenied) {
+		h.logger.Info("Url host is denied id:", id)
+		w.WriteHeader(http.StatusUnavailableForLegalReasons)
+		return
+	}
+
+	// the preview neither counts the click nor reveals the destination of the protected url
+	if preview {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if listItem.IsExhausted() {
+			h.logger.Info("Url clicks are exhausted id:", id)
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		destination := listItem.RedirectURL("")
+		if listItem.IsProtected() {
+			destination = ""
+		}
+		h.sendPreviewPage(w, listItem, destination)
+		return
+	}
+
+	// the protected url is redirected to only after the right password is posted
+	redirectStatus := listItem.RedirectStatus()
+	if r.Method == http.MethodPost {
+		if !listItem.IsProtected() {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		err = h.urlshortener.CheckURLPassword(listItem, r.PostFormValue("password"))
+		switch {
+		case errors.Is(err, shortener.ErrTooManyAttempts):
+			h.logger.Info("Too many password attempts id:", id)
+			h.sendPasswordForm(w, "Too many wrong passwords, try again later", http.StatusTooManyRequests)
+			return
+		case errors.Is(err, shortener.ErrWrongPassword):
+			h.sendPasswordForm(w, "Wrong password", http.StatusForbidden)
+			return
+		}
+		// the browser must not repeat the post to the original url
+		redirectStatus = http.StatusSeeOther
+	} else if listItem.IsProtected() {
+		h.sendPasswordForm(w, "", http.StatusOK)
+		return
+	}
+
+	if listItem.ClicksLeft != nil {
+		err = h.urlshortener.ConsumeClick(r.Context(), id)
+		if errors.Is(err, shortener.ErrClicksExhausted) {
+			h.logger.Info("Url clicks are exhausted id:", id)
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		if err != nil {
+			h.logger.Error("consume click error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	h.urlshortener.RecordClick(shortener.ClickEvent{
+		URLID:     listItem.ID,
+		ClickedAt: time.Now().UTC(),
+		Referrer:  r.Referer(),
+		UserAgent: r.UserAgent(),
+		IPHash:    hashIP(h.cfg.SessionConfig.HashKey, h.clickIP(r)),
+	})
+
+	// the forced preview replaces the redirect, the link is followed by the visitor
+	if listItem.Preview {
+		h.sendPreviewPage(w, listItem, listItem.RedirectURL(r.URL.RawQuery))
+		return
+	}
+
+	w.Header().Set("Location", listItem.RedirectURL(r.URL.RawQuery))
+	w.WriteHeader(redirectStatus)
 
 }
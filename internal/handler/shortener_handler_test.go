@@ -11,8 +11,12 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 //goland:noinspection HttpUrlsUsage
@@ -294,8 +298,8 @@ func TestHandler_ShortenURL(t *testing.T) {
 		h.ShortenURL(resp, req)
 
 		// Check response status code
-		if resp.Code != http.StatusInternalServerError {
-			t.Errorf("expected status code %d, but got %d", http.StatusInternalServerError, resp.Code)
+		if resp.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d, but got %d", http.StatusBadRequest, resp.Code)
 		}
 
 	})
@@ -336,3 +340,402 @@ func TestHandler_ShortenURL(t *testing.T) {
 
 	})
 }
+
+func TestHandler_ShortenURL_InvalidURL(t *testing.T) {
+	l := &loggerMock{}
+	storage := newStorageMock(map[int64]shortener.URLListItem{})
+	h := NewHandler(l, shortener.NewShortener(l, storage), &dbstorage.Storage{}, &dbstorage.Storage{},
+		config.Config{ShortBaseURL: "http://short.base"})
+
+	shorten := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		h.ShortenURL(rr, req.WithContext(context.WithValue(req.Context(), user.FieldID, "1")))
+		return rr
+	}
+
+	tests := []struct {
+		body    string
+		wantErr error
+	}{
+		{body: "not a url", wantErr: shortener.ErrURLNotAbsolute},
+		{body: "javascript:alert(1)", wantErr: shortener.ErrURLSchemeNotAllowed},
+		{body: "https://example.com/\x00\x01", wantErr: shortener.ErrURLControlChars},
+		{body: "https://", wantErr: shortener.ErrURLNoHost},
+	}
+	for _, tt := range tests {
+		rr := shorten(tt.body)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected status code %d, but got %d", tt.body, http.StatusBadRequest, rr.Code)
+		}
+		if !strings.Contains(rr.Body.String(), tt.wantErr.Error()) {
+			t.Errorf("%q: expected reason %q, but got %q", tt.body, tt.wantErr.Error(), rr.Body.String())
+		}
+	}
+	if len(storage.urls) != 0 {
+		t.Errorf("invalid urls are stored: %v", storage.urls)
+	}
+
+	// the trailing newline is trimmed
+	rr := shorten("https://example.com\n")
+	if rr.Code != http.StatusCreated {
+		t.Errorf("expected status code %d, but got %d", http.StatusCreated, rr.Code)
+	}
+	if storage.urls[0].OriginalURL != "https://example.com" {
+		t.Errorf("unexpected stored url %q", storage.urls[0].OriginalURL)
+	}
+}
+
+func TestHandler_GetURL_Expired(t *testing.T) {
+	l := &loggerMock{}
+	expiredAt := time.Now().Add(-time.Minute)
+	storage := newStorageMock(map[int64]shortener.URLListItem{
+		1: {ID: 1, ShortCode: "expired", OriginalURL: "https://example.com", UserID: "1", ExpiresAt: &expiredAt},
+	})
+	h := NewHandler(l, shortener.NewShortener(l, storage), &dbstorage.Storage{}, &dbstorage.Storage{}, config.Config{})
+
+	rr := httptest.NewRecorder()
+	h.GetURL(rr, httptest.NewRequest(http.MethodGet, "/expired", nil))
+
+	if rr.Code != http.StatusGone {
+		t.Errorf("expected status code %d, but got %d", http.StatusGone, rr.Code)
+	}
+}
+
+func TestHandler_GetURL_MaxClicks(t *testing.T) {
+	l := &loggerMock{}
+	clicksLeft := int64(1)
+	storage := newStorageMock(map[int64]shortener.URLListItem{
+		1: {ID: 1, ShortCode: "once", OriginalURL: "https://example.com", UserID: "1", ClicksLeft: &clicksLeft},
+	})
+	h := NewHandler(l, shortener.NewShortener(l, storage), &dbstorage.Storage{}, &dbstorage.Storage{}, config.Config{})
+
+	rr := httptest.NewRecorder()
+	h.GetURL(rr, httptest.NewRequest(http.MethodGet, "/once", nil))
+	if rr.Code != http.StatusTemporaryRedirect {
+		t.Errorf("expected status code %d, but got %d", http.StatusTemporaryRedirect, rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	h.GetURL(rr, httptest.NewRequest(http.MethodGet, "/once", nil))
+	if rr.Code != http.StatusGone {
+		t.Errorf("expected status code %d, but got %d", http.StatusGone, rr.Code)
+	}
+}
+
+func TestHandler_GetURL_ClickIP(t *testing.T) {
+	l := &loggerMock{}
+	cfg := config.Config{SessionConfig: config.SessionConfig{HashKey: "key"}}
+	click := func(h *Handler, storage *storageMock, recorder *shortener.ClickRecorder) shortener.ClickEvent {
+		req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+		req.RemoteAddr = "203.0.113.7:1234"
+		req.Header.Set("X-Real-IP", "198.51.100.1")
+		h.GetURL(httptest.NewRecorder(), req)
+		if err := recorder.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if len(storage.clicks) != 1 {
+			t.Fatalf("expected 1 click, but got %d", len(storage.clicks))
+		}
+		return storage.clicks[0]
+	}
+	setup := func() (*Handler, *storageMock, *shortener.ClickRecorder) {
+		storage := newStorageMock(map[int64]shortener.URLListItem{
+			1: {ID: 1, ShortCode: "abc", OriginalURL: "https://example.com", UserID: "1"},
+		})
+		service := shortener.NewShortener(l, storage)
+		recorder := shortener.NewClickRecorder(l, storage, 10, 1, time.Hour)
+		service.UseClickAnalytics(recorder, storage)
+		return NewHandler(l, service, &dbstorage.Storage{}, &dbstorage.Storage{}, cfg), storage, recorder
+	}
+
+	t.Run("spoofed header", func(t *testing.T) {
+		h, storage, recorder := setup()
+		if got := click(h, storage, recorder).IPHash; got != hashIP("key", "203.0.113.7") {
+			t.Errorf("expected the hash of the remote address, but got %s", got)
+		}
+	})
+
+	t.Run("trusted proxy", func(t *testing.T) {
+		h, storage, recorder := setup()
+		h.UseClientIP(ClientIP)
+		if got := click(h, storage, recorder).IPHash; got != hashIP("key", "198.51.100.1") {
+			t.Errorf("expected the hash of the forwarded ip, but got %s", got)
+		}
+	})
+}
+
+func TestHandler_Denylist(t *testing.T) {
+	l := &loggerMock{}
+	path := filepath.Join(t.TempDir(), "denylist.txt")
+	if err := os.WriteFile(path, []byte("*.phish.example\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	denylist, err := shortener.NewDenylist(l, path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the url is shortened before its host is denied
+	storage := newStorageMock(map[int64]shortener.URLListItem{
+		1: {ID: 1, ShortCode: "phish", OriginalURL: "https://login.phish.example", UserID: "1"},
+	})
+	service := shortener.NewShortener(l, storage)
+	service.UseDenylist(denylist)
+	h := NewHandler(l, service, &dbstorage.Storage{}, &dbstorage.Storage{}, config.Config{ShortBaseURL: "http://short.base"})
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://www.phish.example/login"))
+	rr := httptest.NewRecorder()
+	h.ShortenURL(rr, req.WithContext(context.WithValue(req.Context(), user.FieldID, "1")))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected status code %d, but got %d", http.StatusForbidden, rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url":"https://www.phish.example/login"}`))
+	rr = httptest.NewRecorder()
+	h.APIShortenURL(rr, req.WithContext(context.WithValue(req.Context(), user.FieldID, "1")))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected status code %d, but got %d", http.StatusForbidden, rr.Code)
+	}
+	if len(storage.urls) != 1 {
+		t.Errorf("denied urls are stored: %v", storage.urls)
+	}
+
+	rr = httptest.NewRecorder()
+	h.GetURL(rr, httptest.NewRequest(http.MethodGet, "/phish", nil))
+	if rr.Code != http.StatusUnavailableForLegalReasons {
+		t.Errorf("expected status code %d, but got %d", http.StatusUnavailableForLegalReasons, rr.Code)
+	}
+	if location := rr.Header().Get("Location"); location != "" {
+		t.Errorf("unexpected redirect to %q", location)
+	}
+}
+
+func TestHandler_ShortenURL_SelfLink(t *testing.T) {
+	l := &loggerMock{}
+	storage := newStorageMock(map[int64]shortener.URLListItem{
+		1: {ID: 1, ShortCode: "abc", OriginalURL: "https://example.com", UserID: "1"},
+	})
+	service := shortener.NewShortener(l, storage)
+	links, err := shortener.NewSelfLinks("http://short.base", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.UseSelfLinks(links)
+	h := NewHandler(l, service, &dbstorage.Storage{}, &dbstorage.Storage{}, config.Config{ShortBaseURL: "http://short.base"})
+
+	shorten := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		h.ShortenURL(rr, req.WithContext(context.WithValue(req.Context(), user.FieldID, "2")))
+		return rr
+	}
+
+	// the existing link is returned instead of the chained one
+	rr := shorten("http://short.base/abc")
+	if rr.Code != http.StatusConflict {
+		t.Errorf("expected status code %d, but got %d", http.StatusConflict, rr.Code)
+	}
+	if rr.Body.String() != "http://short.base/abc" {
+		t.Errorf("unexpected short url %q", rr.Body.String())
+	}
+
+	rr = shorten("http://short.base/missing")
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status code %d, but got %d", http.StatusBadRequest, rr.Code)
+	}
+	if len(storage.urls) != 1 {
+		t.Errorf("self links are stored: %v", storage.urls)
+	}
+}
+
+func TestHandler_GetURL_Password(t *testing.T) {
+	l := &loggerMock{}
+	storage := newStorageMock(map[int64]shortener.URLListItem{})
+	service := shortener.NewShortener(l, storage)
+	service.UsePasswordLimiter(shortener.NewPasswordLimiter(2, time.Hour))
+	h := NewHandler(l, service, &dbstorage.Storage{}, &dbstorage.Storage{}, config.Config{ShortBaseURL: "http://short.base"})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url":"https://example.com/doc","password":"secret"}`))
+	rr := httptest.NewRecorder()
+	h.APIShortenURL(rr, req.WithContext(context.WithValue(req.Context(), user.FieldID, "1")))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status code %d, but got %d", http.StatusCreated, rr.Code)
+	}
+	code := storage.urls[0].ShortCode
+	if storage.urls[0].PasswordHash == "" || strings.Contains(storage.urls[0].PasswordHash, "secret") {
+		t.Errorf("unexpected stored password hash %q", storage.urls[0].PasswordHash)
+	}
+
+	unlock := func(password string) *httptest.ResponseRecorder {
+		form := url.Values{"password": {password}}
+		req := httptest.NewRequest(http.MethodPost, "/"+code, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		h.GetURL(rr, req)
+		return rr
+	}
+
+	rr = httptest.NewRecorder()
+	h.GetURL(rr, httptest.NewRequest(http.MethodGet, "/"+code, nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status code %d, but got %d", http.StatusOK, rr.Code)
+	}
+	if location := rr.Header().Get("Location"); location != "" {
+		t.Errorf("unexpected redirect to %q", location)
+	}
+	if !strings.Contains(rr.Body.String(), `<form method="post"`) || strings.Contains(rr.Body.String(), "example.com") {
+		t.Errorf("unexpected password form %q", rr.Body.String())
+	}
+
+	rr = unlock("secret")
+	if rr.Code != http.StatusSeeOther {
+		t.Errorf("expected status code %d, but got %d", http.StatusSeeOther, rr.Code)
+	}
+	if location := rr.Header().Get("Location"); location != "https://example.com/doc" {
+		t.Errorf("unexpected redirect to %q", location)
+	}
+
+	for i := 0; i < 2; i++ {
+		rr = unlock("guess")
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d, but got %d", http.StatusForbidden, rr.Code)
+		}
+	}
+	rr = unlock("secret")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected status code %d, but got %d", http.StatusTooManyRequests, rr.Code)
+	}
+	if location := rr.Header().Get("Location"); location != "" {
+		t.Errorf("unexpected redirect to %q", location)
+	}
+
+	// the public url is not posted to
+	req = httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url":"https://example.com/public"}`))
+	h.APIShortenURL(httptest.NewRecorder(), req.WithContext(context.WithValue(req.Context(), user.FieldID, "1")))
+	rr = httptest.NewRecorder()
+	h.GetURL(rr, httptest.NewRequest(http.MethodPost, "/"+storage.urls[1].ShortCode, nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status code %d, but got %d", http.StatusMethodNotAllowed, rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url":"https://example.com/long","password":"`+strings.Repeat("x", 73)+`"}`))
+	rr = httptest.NewRecorder()
+	h.APIShortenURL(rr, req.WithContext(context.WithValue(req.Context(), user.FieldID, "1")))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status code %d, but got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestHandler_GetURL_RedirectOptions(t *testing.T) {
+	l := &loggerMock{}
+	storage := newStorageMock(map[int64]shortener.URLListItem{})
+	h := NewHandler(l, shortener.NewShortener(l, storage), &dbstorage.Storage{}, &dbstorage.Storage{}, config.Config{ShortBaseURL: "http://short.base"})
+
+	shorten := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		h.APIShortenURL(rr, req.WithContext(context.WithValue(req.Context(), user.FieldID, "1")))
+		return rr
+	}
+	if rr := shorten(`{"url":"https://example.com/a?utm_source=mail","redirect_code":301,"pass_query":true}`); rr.Code != http.StatusCreated {
+		t.Fatalf("expected status code %d, but got %d", http.StatusCreated, rr.Code)
+	}
+	if rr := shorten(`{"url":"https://example.com/b","redirect_code":302}`); rr.Code != http.StatusCreated {
+		t.Fatalf("expected status code %d, but got %d", http.StatusCreated, rr.Code)
+	}
+	if rr := shorten(`{"url":"https://example.com/c","redirect_code":303}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status code %d, but got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	tests := []struct {
+		target       string
+		wantCode     int
+		wantLocation string
+	}{
+		{target: "/" + storage.urls[0].ShortCode + "?utm_source=ad&ref=1", wantCode: http.StatusMovedPermanently,
+			wantLocation: "https://example.com/a?utm_source=ad&ref=1"},
+		{target: "/" + storage.urls[0].ShortCode, wantCode: http.StatusMovedPermanently,
+			wantLocation: "https://example.com/a?utm_source=mail"},
+		// the query is not passed by default
+		{target: "/" + storage.urls[1].ShortCode + "?utm_source=ad", wantCode: http.StatusFound,
+			wantLocation: "https://example.com/b"},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		h.GetURL(rr, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if rr.Code != tt.wantCode {
+			t.Errorf("%s: expected status code %d, but got %d", tt.target, tt.wantCode, rr.Code)
+		}
+		if location := rr.Header().Get("Location"); location != tt.wantLocation {
+			t.Errorf("%s: expected location %q, but got %q", tt.target, tt.wantLocation, location)
+		}
+	}
+}
+
+func TestHandler_GetURL_Preview(t *testing.T) {
+	l := &loggerMock{}
+	storage := newStorageMock(map[int64]shortener.URLListItem{})
+	h := NewHandler(l, shortener.NewShortener(l, storage), &dbstorage.Storage{}, &dbstorage.Storage{}, config.Config{ShortBaseURL: "http://short.base"})
+
+	shorten := func(body string) {
+		req := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		h.APIShortenURL(rr, req.WithContext(context.WithValue(req.Context(), user.FieldID, "1")))
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("%s: expected status code %d, but got %d", body, http.StatusCreated, rr.Code)
+		}
+	}
+	shorten(`{"url":"https://example.com/a","title":"Spring <sale>","max_clicks":1}`)
+	shorten(`{"url":"https://untrusted.example/b","preview":true,"pass_query":true}`)
+	shorten(`{"url":"https://example.com/secret","password":"secret"}`)
+	shorten(`{"url":"https://example.com/deleted"}`)
+	deletedAt := "2023-05-29T10:00:00Z"
+	deleted := storage.urls[3]
+	deleted.DeletedAt = &deletedAt
+	storage.urls[3] = deleted
+	// the url without the original one is not found
+	storage.urls[4] = shortener.URLListItem{ID: 4, ShortCode: "empty"}
+
+	tests := []struct {
+		name         string
+		target       string
+		wantCode     int
+		wantBody     []string
+		wantNotBody  []string
+		wantLocation string
+	}{
+		{name: "preview", target: "/" + storage.urls[0].ShortCode + "+", wantCode: http.StatusOK,
+			wantBody: []string{`href="https://example.com/a"`, "Spring &lt;sale&gt;", "Created on"}},
+		// the preview does not consume the only click
+		{name: "redirect after preview", target: "/" + storage.urls[0].ShortCode, wantCode: http.StatusTemporaryRedirect,
+			wantLocation: "https://example.com/a"},
+		{name: "forced preview", target: "/" + storage.urls[1].ShortCode + "?ref=1", wantCode: http.StatusOK,
+			wantBody: []string{`href="https://untrusted.example/b?ref=1"`}},
+		{name: "protected preview", target: "/" + storage.urls[2].ShortCode + "+", wantCode: http.StatusOK,
+			wantNotBody: []string{"example.com/secret"}},
+		{name: "deleted", target: "/" + storage.urls[3].ShortCode + "+", wantCode: http.StatusGone},
+		{name: "exhausted", target: "/" + storage.urls[0].ShortCode + "+", wantCode: http.StatusGone},
+		{name: "not found", target: "/empty+", wantCode: http.StatusNotFound},
+		{name: "unknown code", target: "/unknown+", wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		h.GetURL(rr, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if rr.Code != tt.wantCode {
+			t.Errorf("%s: expected status code %d, but got %d", tt.name, tt.wantCode, rr.Code)
+		}
+		if location := rr.Header().Get("Location"); location != tt.wantLocation {
+			t.Errorf("%s: expected location %q, but got %q", tt.name, tt.wantLocation, location)
+		}
+		for _, want := range tt.wantBody {
+			if !strings.Contains(rr.Body.String(), want) {
+				t.Errorf("%s: expected %q in the page %q", tt.name, want, rr.Body.String())
+			}
+		}
+		for _, notWant := range tt.wantNotBody {
+			if strings.Contains(rr.Body.String(), notWant) {
+				t.Errorf("%s: unexpected %q in the page %q", tt.name, notWant, rr.Body.String())
+			}
+		}
+	}
+}
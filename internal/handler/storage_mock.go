@@ -5,11 +5,15 @@ import (
 	"context"
 	"fmt"
 	"github.com/itksb/go-url-shortener/internal/shortener"
+	"sort"
 	"strconv"
+	"time"
 )
 
 type storageMock struct {
 	urls         map[int64]shortener.URLListItem
+	clicks       []shortener.ClickEvent
+	history      map[int64][]shortener.URLChange
 	currentURLID int64
 }
 
@@ -18,53 +22,274 @@ func newStorageMock(urls map[int64]shortener.URLListItem) *storageMock {
 }
 
 // SaveURL persist given url
-func (s *storageMock) SaveURL(ctx context.Context, url string, userID string) (string, error) {
+func (s *storageMock) SaveURL(ctx context.Context, url string, userID string, opts shortener.SaveOptions) (string, error) {
+	if opts.Alias != "" {
+		if _, err := s.GetURL(ctx, opts.Alias); err == nil {
+			return "", shortener.ErrAliasTaken
+		}
+	}
 	id := s.currentURLID
 	s.currentURLID++
 	if _, ok := s.urls[id]; ok {
 		return "0", fmt.Errorf("url with id %d already exists", id)
 	}
+	code := opts.Alias
+	if code == "" {
+		code = fmt.Sprint(id)
+	}
 	s.urls[id] = shortener.URLListItem{
-		ID:          id,
-		UserID:      userID,
-		OriginalURL: url,
+		ID:           id,
+		ShortCode:    code,
+		UserID:       userID,
+		OriginalURL:  url,
+		CanonicalURL: shortener.DuplicateKey(url, opts.CanonicalURL),
+		ExpiresAt:    opts.ExpiresAt,
+		Tags:         opts.Tags,
+		PasswordHash: opts.PasswordHash,
+		RedirectCode: opts.RedirectCode,
+		PassQuery:    opts.PassQuery,
+		Title:        opts.Title,
+		Preview:      opts.Preview,
+		CreatedAt:    time.Now().UTC().Format(shortener.CreatedAtLayout),
+	}
+	if opts.MaxClicks > 0 {
+		item := s.urls[id]
+		item.ClicksLeft = &opts.MaxClicks
+		s.urls[id] = item
+	}
+	return code, nil
+}
+
+// SaveURLBatch persist urls, already stored urls are marked as duplicates
+func (s *storageMock) SaveURLBatch(ctx context.Context, userID string, items []shortener.BatchItem) ([]shortener.BatchResult, error) {
+	results := make([]shortener.BatchResult, 0, len(items))
+	for i, item := range items {
+		duplicate := false
+		key := shortener.DuplicateKey(item.OriginalURL, item.Opts.CanonicalURL)
+		for _, url := range s.urls {
+			if shortener.DuplicateKey(url.OriginalURL, url.CanonicalURL) == key && url.IsReusable(time.Now()) {
+				results = append(results, shortener.BatchResult{ShortCode: url.ShortCode, Duplicate: true})
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			continue
+		}
+		code, err := s.SaveURL(ctx, item.OriginalURL, userID, item.Opts)
+		if err != nil {
+			return nil, &shortener.BatchItemError{Index: i, Err: err}
+		}
+		results = append(results, shortener.BatchResult{ShortCode: code})
 	}
-	return fmt.Sprint(id), nil
+	return results, nil
 }
 
-// GetURL retrieve url
+// GetURL retrieve url by the short code. Items without code are resolved by the numeric key
 func (s *storageMock) GetURL(ctx context.Context, id string) (shortener.URLListItem, error) {
-	idInt64, err := strconv.ParseInt(id, 10, 64)
 	item := shortener.URLListItem{}
+	for _, url := range s.urls {
+		if url.ShortCode != "" && url.ShortCode == id {
+			return url, nil
+		}
+	}
+
+	idInt64, err := strconv.ParseInt(id, 10, 64)
 	if err != nil {
-		return item, err
+		return item, fmt.Errorf("%w: code %s", shortener.ErrNotFound, id)
 	}
 
 	url, ok := s.urls[idInt64]
 	if !ok {
-		return item, fmt.Errorf("url with id %d is not exists", idInt64)
+		return item, fmt.Errorf("%w: id %d", shortener.ErrNotFound, idInt64)
 	}
 
-	return url, nil
+	return withShortCode(idInt64, url), nil
 }
 
 // ListURLByUserID returns list of urls
 func (s *storageMock) ListURLByUserID(ctx context.Context, userID string) ([]shortener.URLListItem, error) {
 	var items []shortener.URLListItem
 
-	for _, item := range s.urls {
+	for id, item := range s.urls {
 		if item.UserID == userID {
-			items = append(items, item)
+			items = append(items, withShortCode(id, item))
 		}
 	}
+	// map iteration order is random, keep the output stable
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
 
 	return items, nil
 }
 
+// RestoreURLBatch restores urls of the user
+func (s *storageMock) RestoreURLBatch(ctx context.Context, userID string, ids []string) ([]string, error) {
+	restored := make([]string, 0, len(ids))
+	for _, id := range ids {
+		item, err := s.GetURL(ctx, id)
+		if err != nil || item.UserID != userID || item.DeletedAt == nil {
+			continue
+		}
+		item.DeletedAt = nil
+		s.urls[item.ID] = item
+		restored = append(restored, item.ShortCode)
+	}
+	return restored, nil
+}
+
+// PurgeDeletedURLs removes urls deleted before the moment
+func (s *storageMock) PurgeDeletedURLs(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+// IsURLOwner reports whether the user saved the url
+func (s *storageMock) IsURLOwner(ctx context.Context, id string, userID string) (bool, error) {
+	item, err := s.GetURL(ctx, id)
+	if err != nil {
+		return false, nil
+	}
+	return item.UserID == userID, nil
+}
+
+// UpdateURL changes the original url of the user url
+func (s *storageMock) UpdateURL(ctx context.Context, id string, userID string, url string, canonicalURL string) error {
+	item, err := s.GetURL(ctx, id)
+	if err != nil || item.UserID != userID || item.DeletedAt != nil {
+		return fmt.Errorf("%w: code %s", shortener.ErrNotFound, id)
+	}
+	key := shortener.DuplicateKey(url, canonicalURL)
+	for _, other := range s.urls {
+		if shortener.DuplicateKey(other.OriginalURL, other.CanonicalURL) == key && other.IsReusable(time.Now()) {
+			return fmt.Errorf("%w", shortener.ErrDuplicate)
+		}
+	}
+	if s.history == nil {
+		s.history = make(map[int64][]shortener.URLChange)
+	}
+	s.history[item.ID] = append(s.history[item.ID], shortener.URLChange{
+		UserID:    userID,
+		OldURL:    item.OriginalURL,
+		NewURL:    url,
+		ChangedAt: time.Now(),
+	})
+	item.OriginalURL = url
+	item.CanonicalURL = key
+	s.urls[item.ID] = item
+	return nil
+}
+
+// URLHistory returns changes of the original url
+func (s *storageMock) URLHistory(ctx context.Context, id string) ([]shortener.URLChange, error) {
+	item, err := s.GetURL(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.history[item.ID], nil
+}
+
+// UpdateURLTags changes the tags of the user url
+func (s *storageMock) UpdateURLTags(ctx context.Context, id string, userID string, add []string, remove []string) ([]string, error) {
+	item, err := s.GetURL(ctx, id)
+	if err != nil || item.UserID != userID {
+		return nil, fmt.Errorf("%w: code %s", shortener.ErrNotFound, id)
+	}
+	item.Tags = shortener.MergeTags(item.Tags, add, remove)
+	s.urls[item.ID] = item
+	return item.Tags, nil
+}
+
 // DeleteURLBatch removes urls
 func (s *storageMock) DeleteURLBatch(ctx context.Context, userID string, ids []string) error {
 	return nil
 }
 
+// ConsumeClick decrements clicks left
+func (s *storageMock) ConsumeClick(ctx context.Context, id string) error {
+	item, err := s.GetURL(ctx, id)
+	if err != nil {
+		return err
+	}
+	if item.ClicksLeft == nil {
+		return nil
+	}
+	if item.IsExhausted() {
+		return shortener.ErrClicksExhausted
+	}
+	clicksLeft := *item.ClicksLeft - 1
+	item.ClicksLeft = &clicksLeft
+	s.urls[item.ID] = item
+	return nil
+}
+
+// DeleteExpiredURLs marks expired urls as deleted
+func (s *storageMock) DeleteExpiredURLs(ctx context.Context, now time.Time) (int64, error) {
+	var count int64
+	for id, item := range s.urls {
+		if item.IsExpired(now) && item.DeletedAt == nil {
+			deletedAt := now.String()
+			item.DeletedAt = &deletedAt
+			s.urls[id] = item
+			count++
+		}
+	}
+	return count, nil
+}
+
+// CountURLs returns count of live and deleted urls
+func (s *storageMock) CountURLs(ctx context.Context) (int64, int64, error) {
+	var live, deleted int64
+	for _, item := range s.urls {
+		if item.DeletedAt != nil {
+			deleted++
+		} else {
+			live++
+		}
+	}
+	return live, deleted, nil
+}
+
+// CountUsers returns count of distinct users
+func (s *storageMock) CountUsers(ctx context.Context) (int64, error) {
+	users := make(map[string]struct{})
+	for _, item := range s.urls {
+		users[item.UserID] = struct{}{}
+	}
+	return int64(len(users)), nil
+}
+
+// ListURLs returns the page of the user urls
+func (s *storageMock) ListURLs(ctx context.Context, userID string, opts shortener.ListOptions) (shortener.URLPage, error) {
+	items, err := s.ListURLByUserID(ctx, userID)
+	if err != nil {
+		return shortener.URLPage{}, err
+	}
+	return shortener.PageURLs(items, opts), nil
+}
+
+// SaveClicks persist click events
+func (s *storageMock) SaveClicks(ctx context.Context, events []shortener.ClickEvent) error {
+	s.clicks = append(s.clicks, events...)
+	return nil
+}
+
+// ClickStats aggregates click events of the url
+func (s *storageMock) ClickStats(ctx context.Context, urlID int64, opts shortener.ClickStatsOptions) (shortener.ClickStats, error) {
+	var events []shortener.ClickEvent
+	for _, ev := range s.clicks {
+		if ev.URLID == urlID {
+			events = append(events, ev)
+		}
+	}
+	return shortener.AggregateClicks(events, opts), nil
+}
+
 // Close destructor
 func (s *storageMock) Close() error { return nil }
+
+// withShortCode fills the code of the fixtures like migration does for the old links
+func withShortCode(id int64, item shortener.URLListItem) shortener.URLListItem {
+	if item.ShortCode == "" {
+		item.ShortCode = fmt.Sprint(id)
+	}
+	return item
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/itksb/go-url-shortener/api"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/itksb/go-url-shortener/internal/user"
+	"net/http"
+)
+
+// APIUpdateUserURL - changes the original url of the short url owned by the user alone
+func (h *Handler) APIUpdateUserURL(w http.ResponseWriter, r *http.Request) {
+	defer func() {
+		err := r.Body.Close()
+		if err != nil {
+			h.logger.Error(err.Error())
+		}
+	}()
+
+	request := api.UpdateURLRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		SendJSONError(w, "bad input json", http.StatusBadRequest)
+		return
+	}
+	if request.URL == "" {
+		SendJSONError(w, "bad input request: URL is empty", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	userID, ok := ctx.Value(user.FieldID).(string)
+	if !ok {
+		h.logger.Error("no user id found")
+		SendJSONError(w, "no user found", http.StatusInternalServerError)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	err := h.urlshortener.UpdateURL(ctx, id, userID, request.URL)
+	switch {
+	case errors.Is(err, shortener.ErrInvalidURL):
+		SendJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, shortener.ErrURLDenied):
+		SendJSONError(w, err.Error(), http.StatusForbidden)
+		return
+	case errors.Is(err, shortener.ErrNotFound):
+		SendJSONError(w, "url not found", http.StatusNotFound)
+		return
+	case errors.Is(err, shortener.ErrURLShared), errors.Is(err, shortener.ErrDuplicate):
+		SendJSONError(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		h.logger.Error("url update error", err.Error())
+		SendJSONError(w, "shortener service error", http.StatusInternalServerError)
+		return
+	}
+
+	response := api.ShortenResponse{Result: createShortenURL(id, h.cfg.ShortBaseURL)}
+	if err := SendJSONOk(w, response, http.StatusOK); err != nil {
+		h.logger.Error(err)
+	}
+}
+
+// APIUserURLHistory - changes of the original url of the short url owned by the user
+func (h *Handler) APIUserURLHistory(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := ctx.Value(user.FieldID).(string)
+	if !ok {
+		h.logger.Error("no user id found")
+		SendJSONError(w, "no user found", http.StatusInternalServerError)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	owner, err := h.urlshortener.IsURLOwner(ctx, id, userID)
+	if err != nil {
+		h.logger.Error("url owner error", err.Error())
+		SendJSONError(w, "shortener service error", http.StatusInternalServerError)
+		return
+	}
+	// foreign urls are reported as absent, not to disclose them
+	if !owner {
+		SendJSONError(w, "url not found", http.StatusNotFound)
+		return
+	}
+
+	changes, err := h.urlshortener.URLHistory(ctx, id)
+	if errors.Is(err, shortener.ErrNotFound) {
+		SendJSONError(w, "url not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.Error("url history error", err.Error())
+		SendJSONError(w, "shortener service error", http.StatusInternalServerError)
+		return
+	}
+
+	response := make(api.URLHistoryResponse, 0, len(changes))
+	for _, change := range changes {
+		response = append(response, api.URLChangeResponse{
+			OldURL:    change.OldURL,
+			NewURL:    change.NewURL,
+			ChangedAt: change.ChangedAt,
+		})
+	}
+	if err := SendJSONOk(w, response, http.StatusOK); err != nil {
+		h.logger.Error(err)
+	}
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"github.com/itksb/go-url-shortener/api"
+	"github.com/itksb/go-url-shortener/internal/config"
+	"github.com/itksb/go-url-shortener/internal/dbstorage"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/itksb/go-url-shortener/internal/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler_APIUpdateUserURL(t *testing.T) {
+	l := &loggerMock{}
+	storage := newStorageMock(map[int64]shortener.URLListItem{
+		1: {ID: 1, ShortCode: "abc", OriginalURL: "https://old.example", UserID: "owner"},
+		2: {ID: 2, ShortCode: "def", OriginalURL: "https://other.example", UserID: "owner"},
+	})
+	service := shortener.NewShortener(l, storage)
+	h := NewHandler(l, service, &dbstorage.Storage{}, &dbstorage.Storage{}, config.Config{ShortBaseURL: "http://short.base"})
+
+	newRequest := func(method string, target string, body io.Reader, userID string) *http.Request {
+		req := httptest.NewRequest(method, target, body)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", "abc")
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+		return req.WithContext(context.WithValue(ctx, user.FieldID, userID))
+	}
+	update := func(body string, userID string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.APIUpdateUserURL(rr, newRequest(http.MethodPatch, "/api/user/urls/abc", strings.NewReader(body), userID))
+		return rr
+	}
+
+	t.Run("bad json", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, update(`{"url":`, "owner").Code)
+		assert.Equal(t, http.StatusBadRequest, update(`{}`, "owner").Code)
+	})
+
+	t.Run("stranger", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, update(`{"url":"https://stranger.example"}`, "stranger").Code)
+	})
+
+	t.Run("duplicate", func(t *testing.T) {
+		assert.Equal(t, http.StatusConflict, update(`{"url":"https://other.example"}`, "owner").Code)
+	})
+
+	t.Run("owner", func(t *testing.T) {
+		rr := update(`{"url":"https://new.example"}`, "owner")
+		require.Equal(t, http.StatusOK, rr.Code)
+		resp := api.ShortenResponse{}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		assert.Equal(t, "http://short.base/abc", resp.Result)
+
+		rr = httptest.NewRecorder()
+		h.APIUserURLHistory(rr, newRequest(http.MethodGet, "/api/user/urls/abc/history", nil, "owner"))
+		require.Equal(t, http.StatusOK, rr.Code)
+		history := api.URLHistoryResponse{}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &history))
+		require.Len(t, history, 1)
+		assert.Equal(t, "https://old.example", history[0].OldURL)
+		assert.Equal(t, "https://new.example", history[0].NewURL)
+
+		rr = httptest.NewRecorder()
+		h.APIUserURLHistory(rr, newRequest(http.MethodGet, "/api/user/urls/abc/history", nil, "stranger"))
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
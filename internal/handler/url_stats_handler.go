@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/itksb/go-url-shortener/api"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/itksb/go-url-shortener/internal/user"
+	"net/http"
+	"time"
+)
+
+// click statistics window
+const (
+	statsHours        = 24
+	statsDays         = 30
+	statsTopReferrers = 10
+)
+
+// APIUserURLStats - click statistics of the url owned by the user
+func (h *Handler) APIUserURLStats(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := ctx.Value(user.FieldID).(string)
+	if !ok {
+		h.logger.Error("no user id found")
+		SendJSONError(w, "no user found", http.StatusInternalServerError)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	listItem, err := h.urlshortener.GetURL(ctx, id)
+	if err != nil || len(listItem.OriginalURL) == 0 {
+		SendJSONError(w, "url not found", http.StatusNotFound)
+		return
+	}
+	owner, err := h.urlshortener.IsURLOwner(ctx, listItem.ShortCode, userID)
+	if err != nil {
+		h.logger.Error("url owner error", err.Error())
+		SendJSONError(w, "shortener service error", http.StatusInternalServerError)
+		return
+	}
+	// foreign urls are reported as absent, not to disclose them
+	if !owner {
+		SendJSONError(w, "url not found", http.StatusNotFound)
+		return
+	}
+
+	stats, err := h.urlshortener.URLStats(ctx, listItem.ID, shortener.ClickStatsOptions{
+		Now:          time.Now(),
+		Hours:        statsHours,
+		Days:         statsDays,
+		TopReferrers: statsTopReferrers,
+	})
+	if errors.Is(err, shortener.ErrAnalyticsDisabled) {
+		SendJSONError(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		h.logger.Error("url stats error", err.Error())
+		SendJSONError(w, "shortener service error", http.StatusInternalServerError)
+		return
+	}
+
+	response := api.URLStatsResponse{
+		ShortURL:       createShortenURL(listItem.ShortCode, h.cfg.ShortBaseURL),
+		TotalClicks:    stats.Total,
+		UniqueVisitors: stats.UniqueVisitors,
+		Hourly:         make([]api.StatsBucket, 0, len(stats.Hourly)),
+		Daily:          make([]api.StatsBucket, 0, len(stats.Daily)),
+		TopReferrers:   make([]api.StatsReferrer, 0, len(stats.TopReferrers)),
+	}
+	for _, b := range stats.Hourly {
+		response.Hourly = append(response.Hourly, api.StatsBucket{Start: b.Start, Clicks: b.Clicks})
+	}
+	for _, b := range stats.Daily {
+		response.Daily = append(response.Daily, api.StatsBucket{Start: b.Start, Clicks: b.Clicks})
+	}
+	for _, ref := range stats.TopReferrers {
+		response.TopReferrers = append(response.TopReferrers, api.StatsReferrer{Referrer: ref.Referrer, Clicks: ref.Clicks})
+	}
+
+	if err := SendJSONOk(w, response, http.StatusOK); err != nil {
+		h.logger.Error(err)
+	}
+}
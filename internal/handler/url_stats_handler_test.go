@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"github.com/itksb/go-url-shortener/api"
+	"github.com/itksb/go-url-shortener/internal/config"
+	"github.com/itksb/go-url-shortener/internal/dbstorage"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/itksb/go-url-shortener/internal/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHandler_APIUserURLStats(t *testing.T) {
+	l := &loggerMock{}
+	storage := newStorageMock(map[int64]shortener.URLListItem{
+		1: {ID: 1, ShortCode: "abc", OriginalURL: "https://example.com", UserID: "owner"},
+	})
+	now := time.Now()
+	require.NoError(t, storage.SaveClicks(context.Background(), []shortener.ClickEvent{
+		{URLID: 1, ClickedAt: now, Referrer: "https://ref.example", IPHash: "ip1"},
+		{URLID: 1, ClickedAt: now, IPHash: "ip2"},
+	}))
+
+	service := shortener.NewShortener(l, storage)
+	service.UseClickAnalytics(nil, storage)
+	h := NewHandler(l, service, &dbstorage.Storage{}, &dbstorage.Storage{}, config.Config{ShortBaseURL: "http://short.base"})
+
+	newRequest := func(userID string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/api/user/urls/abc/stats", nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", "abc")
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+		return req.WithContext(context.WithValue(ctx, user.FieldID, userID))
+	}
+
+	t.Run("owner", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		h.APIUserURLStats(rr, newRequest("owner"))
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		resp := api.URLStatsResponse{}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		assert.Equal(t, "http://short.base/abc", resp.ShortURL)
+		assert.Equal(t, int64(2), resp.TotalClicks)
+		assert.Equal(t, int64(2), resp.UniqueVisitors)
+		assert.Len(t, resp.Hourly, statsHours)
+		assert.Len(t, resp.Daily, statsDays)
+		assert.Equal(t, []api.StatsReferrer{{Referrer: "https://ref.example", Clicks: 1}}, resp.TopReferrers)
+	})
+
+	t.Run("stranger", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		h.APIUserURLStats(rr, newRequest("stranger"))
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/itksb/go-url-shortener/api"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/itksb/go-url-shortener/internal/user"
+	"net/http"
+)
+
+// APIUpdateUserURLTags - removes and then adds the tags of the short url owned by the user
+func (h *Handler) APIUpdateUserURLTags(w http.ResponseWriter, r *http.Request) {
+	defer func() {
+		err := r.Body.Close()
+		if err != nil {
+			h.logger.Error(err.Error())
+		}
+	}()
+
+	request := api.URLTagsRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		SendJSONError(w, "bad input json", http.StatusBadRequest)
+		return
+	}
+	if len(request.Add) == 0 && len(request.Remove) == 0 {
+		SendJSONError(w, "bad input request: no tags to add or remove", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	userID, ok := ctx.Value(user.FieldID).(string)
+	if !ok {
+		h.logger.Error("no user id found")
+		SendJSONError(w, "no user found", http.StatusInternalServerError)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	tags, err := h.urlshortener.UpdateURLTags(ctx, id, userID, request.Add, request.Remove)
+	switch {
+	case errors.Is(err, shortener.ErrInvalidTag):
+		SendJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, shortener.ErrNotFound):
+		SendJSONError(w, "url not found", http.StatusNotFound)
+		return
+	case err != nil:
+		h.logger.Error("url tags error", err.Error())
+		SendJSONError(w, "shortener service error", http.StatusInternalServerError)
+		return
+	}
+
+	response := api.URLTagsResponse{ShortURL: createShortenURL(id, h.cfg.ShortBaseURL), Tags: tags}
+	if err := SendJSONOk(w, response, http.StatusOK); err != nil {
+		h.logger.Error(err)
+	}
+}
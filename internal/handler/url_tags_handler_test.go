@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"github.com/itksb/go-url-shortener/api"
+	"github.com/itksb/go-url-shortener/internal/config"
+	"github.com/itksb/go-url-shortener/internal/dbstorage"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/itksb/go-url-shortener/internal/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler_APIUpdateUserURLTags(t *testing.T) {
+	l := &loggerMock{}
+	storage := newStorageMock(map[int64]shortener.URLListItem{
+		1: {ID: 1, ShortCode: "abc", OriginalURL: "https://a.example", UserID: "owner", Tags: []string{"old"}},
+		2: {ID: 2, ShortCode: "def", OriginalURL: "https://b.example", UserID: "owner"},
+	})
+	service := shortener.NewShortener(l, storage)
+	h := NewHandler(l, service, &dbstorage.Storage{}, &dbstorage.Storage{}, config.Config{ShortBaseURL: "http://short.base"})
+
+	update := func(body string, userID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, "/api/user/urls/abc/tags", strings.NewReader(body))
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", "abc")
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+		rr := httptest.NewRecorder()
+		h.APIUpdateUserURLTags(rr, req.WithContext(context.WithValue(ctx, user.FieldID, userID)))
+		return rr
+	}
+
+	t.Run("bad input", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, update(`{"add":`, "owner").Code)
+		assert.Equal(t, http.StatusBadRequest, update(`{}`, "owner").Code)
+		assert.Equal(t, http.StatusBadRequest, update(`{"add":["  "]}`, "owner").Code)
+	})
+
+	t.Run("stranger", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, update(`{"add":["spam"]}`, "stranger").Code)
+	})
+
+	t.Run("owner", func(t *testing.T) {
+		rr := update(`{"add":["Work","news"],"remove":["old"]}`, "owner")
+		require.Equal(t, http.StatusOK, rr.Code)
+		resp := api.URLTagsResponse{}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		assert.Equal(t, api.URLTagsResponse{ShortURL: "http://short.base/abc", Tags: []string{"news", "work"}}, resp)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/user/urls?tag=work", nil)
+		rr = httptest.NewRecorder()
+		h.APIListUserURL(rr, req.WithContext(context.WithValue(req.Context(), user.FieldID, "owner")))
+		require.Equal(t, http.StatusOK, rr.Code)
+		var items []shortener.URLListItem
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &items))
+		require.Len(t, items, 1)
+		assert.Equal(t, "https://a.example", items[0].OriginalURL)
+		assert.Equal(t, []string{"news", "work"}, items[0].Tags)
+	})
+}
@@ -0,0 +1,82 @@
+package router
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/itksb/go-url-shortener/api"
+	"github.com/itksb/go-url-shortener/internal/config"
+	"github.com/itksb/go-url-shortener/internal/dbstorage"
+	"github.com/itksb/go-url-shortener/internal/handler"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/itksb/go-url-shortener/internal/storage"
+	"github.com/itksb/go-url-shortener/internal/user"
+	"github.com/itksb/go-url-shortener/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// the results of the bulk shortening come back while the gzipped input is still being sent
+func TestGzipMiddlewares_BulkStreaming(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+	service := shortener.NewShortener(l, storage.NewStorage(l, shortener.NewSequenceGenerator(shortener.Base62Alphabet, 0)))
+	h := handler.NewHandler(l, service, &dbstorage.Storage{}, &dbstorage.Storage{}, config.Config{ShortBaseURL: "http://short.base"})
+	withUser := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.APIShortenURLBulk(w, r.WithContext(context.WithValue(r.Context(), user.FieldID, "user1")))
+	})
+	server := httptest.NewServer(gzipUnpackMiddleware(gzipMiddleware(withUser)))
+	defer server.Close()
+
+	body, bodyWriter := io.Pipe()
+	firstResult := make(chan struct{})
+	sent := make(chan int, 1)
+	go func() {
+		gz := gzip.NewWriter(bodyWriter)
+		n := 0
+	loop:
+		for ; ; n++ {
+			select {
+			case <-firstResult:
+				break loop
+			default:
+			}
+			if _, err := fmt.Fprintf(gz, "{\"original_url\":\"https://example.com/%d\"}\n", n); err != nil {
+				break
+			}
+			_ = gz.Flush()
+		}
+		_ = gz.Close()
+		_ = bodyWriter.Close()
+		sent <- n
+	}()
+
+	req, err := http.NewRequest(http.MethodPost, server.URL, body)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("Content-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	scanner := bufio.NewScanner(resp.Body)
+	require.True(t, scanner.Scan())
+	close(firstResult)
+	received, last := 1, scanner.Text()
+	for scanner.Scan() {
+		received, last = received+1, scanner.Text()
+	}
+	require.NoError(t, scanner.Err())
+
+	result := api.ShortenBulkResult{}
+	require.NoError(t, json.Unmarshal([]byte(last), &result))
+	assert.Empty(t, result.Error)
+	assert.Equal(t, <-sent, received)
+}
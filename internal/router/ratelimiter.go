@@ -0,0 +1,189 @@
+package router
+
+import (
+	"fmt"
+	"github.com/itksb/go-url-shortener/internal/handler"
+	"github.com/itksb/go-url-shortener/internal/user"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// rateLimitSweepInterval - how often the idle buckets are evicted
+const rateLimitSweepInterval = time.Minute
+
+// RateLimit - token bucket budget: Burst requests at once and Rate requests per second on average.
+// The zero Rate does not limit the requests
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimits - budgets of the route groups, every group is counted separately
+type RateLimits struct {
+	Shorten      RateLimit // shortening of the urls
+	Redirect     RateLimit // redirects, password forms, previews and qr codes
+	List         RateLimit // listing of the user urls
+	TrustedProxy string    // CIDR of the proxies whose client ip headers are trusted, empty trusts none
+}
+
+// tokenBucket - tokens of the key refilled at the moment updated
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// rateLimitResult - state of the buckets after the request is counted
+type rateLimitResult struct {
+	allowed    bool
+	remaining  int           // requests allowed right now
+	reset      time.Duration // until the buckets are full
+	retryAfter time.Duration // until the request is allowed, if it is not
+}
+
+// RateLimiter - token buckets of the keys. The bucket idle long enough to be refilled is the same
+// as the new one, so it is evicted and the memory is bounded by the keys active within the refill time
+type RateLimiter struct {
+	limit   RateLimit
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	swept   time.Time
+}
+
+// NewRateLimiter - constructor
+func NewRateLimiter(limit RateLimit) *RateLimiter {
+	return &RateLimiter{
+		limit:   limit,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// take takes the token from the buckets of all the keys at the moment now,
+// nothing is taken if one of the buckets is empty
+func (l *RateLimiter) take(now time.Time, keys ...string) rateLimitResult {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	result := rateLimitResult{allowed: true, remaining: l.limit.Burst}
+	buckets := make([]*tokenBucket, 0, len(keys))
+	for _, key := range keys {
+		bucket, ok := l.buckets[key]
+		if !ok {
+			bucket = &tokenBucket{tokens: float64(l.limit.Burst), updated: now}
+			l.buckets[key] = bucket
+		}
+		l.refill(bucket, now)
+		if bucket.tokens < 1 {
+			result.allowed = false
+			if wait := l.refillTime(1 - bucket.tokens); wait > result.retryAfter {
+				result.retryAfter = wait
+			}
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	for _, bucket := range buckets {
+		if result.allowed {
+			bucket.tokens--
+		}
+		if remaining := int(bucket.tokens); remaining < result.remaining {
+			result.remaining = remaining
+		}
+		if reset := l.refillTime(float64(l.limit.Burst) - bucket.tokens); reset > result.reset {
+			result.reset = reset
+		}
+	}
+	return result
+}
+
+// refill adds the tokens of the time passed since the bucket was updated
+func (l *RateLimiter) refill(bucket *tokenBucket, now time.Time) {
+	elapsed := now.Sub(bucket.updated).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+	bucket.tokens = math.Min(float64(l.limit.Burst), bucket.tokens+elapsed*l.limit.Rate)
+	bucket.updated = now
+}
+
+// refillTime - time the tokens are added in
+func (l *RateLimiter) refillTime(tokens float64) time.Duration {
+	return time.Duration(tokens / l.limit.Rate * float64(time.Second))
+}
+
+// sweep evicts the full buckets, it runs once in rateLimitSweepInterval
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < rateLimitSweepInterval {
+		return
+	}
+	l.swept = now
+	for key, bucket := range l.buckets {
+		l.refill(bucket, now)
+		if bucket.tokens >= float64(l.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// NewClientIPFunc returns the client ip of the request: the remote address, or the one of the X-Real-IP
+// and X-Forwarded-For headers if the remote address is the proxy of the subnet. Empty subnet trusts no proxies,
+// so the headers set by the client itself do not change its ip
+func NewClientIPFunc(cidr string) (func(r *http.Request) string, error) {
+	var subnet *net.IPNet
+	if cidr != "" {
+		var err error
+		_, subnet, err = net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q is invalid: %w", cidr, err)
+		}
+	}
+
+	return func(r *http.Request) string {
+		host := handler.RemoteIP(r)
+		if ip := net.ParseIP(host); subnet != nil && ip != nil && subnet.Contains(ip) {
+			return handler.ClientIP(r)
+		}
+		return host
+	}, nil
+}
+
+// NewRateLimitMiddleware counts the requests of the user and of the client ip by the limiter,
+// the request exceeding either budget gets 429. The user must be set by the auth middleware before
+func NewRateLimitMiddleware(limiter *RateLimiter, clientIP func(r *http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			keys := []string{"ip:" + clientIP(r)}
+			if userID, ok := r.Context().Value(user.FieldID).(string); ok && userID != "" {
+				keys = append(keys, "user:"+userID)
+			}
+			result := limiter.take(time.Now(), keys...)
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(limiter.limit.Burst))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.reset)))
+			if !result.allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.retryAfter)))
+				handler.SendJSONError(w, "too many requests", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimitMiddlewares - middleware of the budget, none if the budget does not limit the requests
+func rateLimitMiddlewares(limit RateLimit, clientIP func(r *http.Request) string) []func(http.Handler) http.Handler {
+	if limit.Rate <= 0 {
+		return nil
+	}
+	return []func(http.Handler) http.Handler{NewRateLimitMiddleware(NewRateLimiter(limit), clientIP)}
+}
+
+// ceilSeconds - whole seconds of the duration rounded up
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
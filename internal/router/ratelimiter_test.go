@@ -0,0 +1,110 @@
+package router
+
+import (
+	"context"
+	"github.com/itksb/go-url-shortener/internal/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_Take(t *testing.T) {
+	limiter := NewRateLimiter(RateLimit{Rate: 2, Burst: 3})
+	now := time.Now()
+
+	for i := 2; i >= 0; i-- {
+		result := limiter.take(now, "a")
+		require.True(t, result.allowed)
+		assert.Equal(t, i, result.remaining)
+	}
+	result := limiter.take(now, "a")
+	assert.False(t, result.allowed)
+	assert.Equal(t, 500*time.Millisecond, result.retryAfter)
+	assert.Equal(t, 1500*time.Millisecond, result.reset)
+
+	// the tokens are refilled by the rate
+	assert.True(t, limiter.take(now.Add(500*time.Millisecond), "a").allowed)
+	assert.False(t, limiter.take(now.Add(500*time.Millisecond), "a").allowed)
+
+	// the other key has its own bucket, the token is not taken if one of the buckets is empty
+	assert.False(t, limiter.take(now.Add(500*time.Millisecond), "b", "a").allowed)
+	result = limiter.take(now.Add(500*time.Millisecond), "b")
+	assert.True(t, result.allowed)
+	assert.Equal(t, 2, result.remaining)
+}
+
+func TestRateLimiter_Sweep(t *testing.T) {
+	limiter := NewRateLimiter(RateLimit{Rate: 1, Burst: 100})
+	now := time.Now()
+	limiter.take(now, "a")
+	for i := 0; i < 100; i++ {
+		limiter.take(now.Add(time.Second), "b")
+	}
+	require.Len(t, limiter.buckets, 2)
+
+	// the bucket refilled within the interval is evicted, the emptied one is kept
+	limiter.take(now.Add(time.Second+rateLimitSweepInterval), "c")
+	assert.Len(t, limiter.buckets, 2)
+	assert.NotContains(t, limiter.buckets, "a")
+	assert.Contains(t, limiter.buckets, "b")
+}
+
+func TestNewRateLimitMiddleware(t *testing.T) {
+	clientIP, err := NewClientIPFunc("")
+	require.NoError(t, err)
+	mdl := NewRateLimitMiddleware(NewRateLimiter(RateLimit{Rate: 0.1, Burst: 2}), clientIP)
+	ok := mdl(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	request := func(userID string, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/shorten/batch", nil)
+		req.RemoteAddr = ip + ":1234"
+		// the header of the untrusted client is ignored
+		req.Header.Set("X-Real-IP", "10.0.0.100")
+		rr := httptest.NewRecorder()
+		ok.ServeHTTP(rr, req.WithContext(context.WithValue(req.Context(), user.FieldID, userID)))
+		return rr
+	}
+
+	rr := request("user1", "10.0.0.1")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "2", rr.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", rr.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "10", rr.Header().Get("RateLimit-Reset"))
+	assert.Equal(t, http.StatusOK, request("user1", "10.0.0.1").Code)
+
+	rr = request("user1", "10.0.0.1")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "10", rr.Header().Get("Retry-After"))
+	assert.Equal(t, "0", rr.Header().Get("RateLimit-Remaining"))
+
+	// the user is limited from the other ip, the ip is limited for the other user
+	assert.Equal(t, http.StatusTooManyRequests, request("user1", "10.0.0.2").Code)
+	assert.Equal(t, http.StatusTooManyRequests, request("user2", "10.0.0.1").Code)
+	assert.Equal(t, http.StatusOK, request("user2", "10.0.0.2").Code)
+}
+
+func TestNewClientIPFunc(t *testing.T) {
+	_, err := NewClientIPFunc("10.0.0.0/33")
+	assert.Error(t, err)
+
+	clientIP, err := NewClientIPFunc("10.0.0.0/8")
+	require.NoError(t, err)
+	request := func(remoteAddr string, header string, value string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+		req.RemoteAddr = remoteAddr
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		return req
+	}
+	assert.Equal(t, "192.0.2.1", clientIP(request("192.0.2.1:1234", "", "")))
+	// the headers are trusted from the proxy only
+	assert.Equal(t, "192.0.2.1", clientIP(request("192.0.2.1:1234", "X-Real-IP", "192.0.2.2")))
+	assert.Equal(t, "192.0.2.2", clientIP(request("10.0.0.1:1234", "X-Real-IP", "192.0.2.2")))
+	assert.Equal(t, "192.0.2.3", clientIP(request("10.0.0.1:1234", "X-Forwarded-For", "192.0.2.3, 10.0.0.2")))
+	assert.Equal(t, "10.0.0.1", clientIP(request("10.0.0.1:1234", "", "")))
+}
@@ -10,25 +10,59 @@ import (
 )
 
 // NewRouter - constructor
-func NewRouter(h *handler.Handler, sessionStore session.Store, l *logger.Logger, debug bool) (http.Handler, error) {
+func NewRouter(h *handler.Handler, sessionStore session.Store, l *logger.Logger, debug bool, trustedSubnet string, rateLimits RateLimits) (http.Handler, error) {
 	r := chi.NewRouter()
 
+	clientIP, err := NewClientIPFunc(rateLimits.TrustedProxy)
+	if err != nil {
+		return nil, err
+	}
+	trustedSubnetMdl, err := NewTrustedSubnetMiddleware(trustedSubnet, clientIP)
+	if err != nil {
+		return nil, err
+	}
+
 	r.Use(gzipUnpackMiddleware)
 	authMdl := NewAuthMiddleware(sessionStore, l)
 	r.Use(authMdl)
 	r.Use(gzipMiddleware)
 
-	r.MethodFunc(http.MethodPost, "/", h.ShortenURL)
-	r.MethodFunc(http.MethodGet, "/{id:[0-9]+}", h.GetURL)
+	// the budgets are counted after the user is known
+	shortenLimit := rateLimitMiddlewares(rateLimits.Shorten, clientIP)
+	redirectLimit := rateLimitMiddlewares(rateLimits.Redirect, clientIP)
+	listLimit := rateLimitMiddlewares(rateLimits.List, clientIP)
+
+	r.With(shortenLimit...).MethodFunc(http.MethodPost, "/", h.ShortenURL)
+	// short codes (and numeric ids of the old links)
+	r.With(redirectLimit...).MethodFunc(http.MethodGet, "/{id}", h.GetURL)
+	// password of the protected link
+	r.With(redirectLimit...).MethodFunc(http.MethodPost, "/{id}", h.GetURL)
+	// qr code of the short url
+	r.With(redirectLimit...).MethodFunc(http.MethodGet, "/{id}/qr", h.GetURLQRCode)
 
 	r.Group(func(r2 chi.Router) {
 		// apply CORS middleware for api routes
 		r2.Use(NewCors())
 		// api routes
-		r2.MethodFunc(http.MethodPost, "/api/shorten", h.APIShortenURL)
-		r2.MethodFunc(http.MethodGet, "/api/user/urls", h.APIListUserURL)
-		r2.MethodFunc(http.MethodPost, "/api/shorten/batch", h.APIShortenURLBatch)
+		r2.With(shortenLimit...).MethodFunc(http.MethodPost, "/api/shorten", h.APIShortenURL)
+		r2.With(listLimit...).MethodFunc(http.MethodGet, "/api/user/urls", h.APIListUserURL)
+		r2.With(shortenLimit...).MethodFunc(http.MethodPost, "/api/shorten/batch", h.APIShortenURLBatch)
+		r2.With(shortenLimit...).MethodFunc(http.MethodPost, "/api/shorten/bulk", h.APIShortenURLBulk)
 		r2.MethodFunc(http.MethodDelete, "/api/user/urls", h.APIDeleteURLBatch)
+		r2.MethodFunc(http.MethodPost, "/api/user/urls/restore", h.APIRestoreURLBatch)
+		r2.MethodFunc(http.MethodGet, "/api/user/urls/{id}/stats", h.APIUserURLStats)
+		r2.MethodFunc(http.MethodPatch, "/api/user/urls/{id}", h.APIUpdateUserURL)
+		r2.MethodFunc(http.MethodGet, "/api/user/urls/{id}/history", h.APIUserURLHistory)
+		r2.MethodFunc(http.MethodPatch, "/api/user/urls/{id}/tags", h.APIUpdateUserURLTags)
+		r2.With(shortenLimit...).MethodFunc(http.MethodPost, "/api/jobs/shorten", h.APIShortenURLJob)
+		r2.MethodFunc(http.MethodGet, "/api/jobs/{id}", h.APIGetJob)
+		r2.MethodFunc(http.MethodGet, "/api/jobs/{id}/results", h.APIGetJobResults)
+		r2.MethodFunc(http.MethodDelete, "/api/jobs/{id}", h.APICancelJob)
+	})
+
+	r.Group(func(r2 chi.Router) {
+		r2.Use(trustedSubnetMdl)
+		r2.MethodFunc(http.MethodGet, "/api/internal/stats", h.APIInternalStats)
 	})
 
 	r.MethodFunc(http.MethodGet, "/health", h.HealthCheck)
@@ -0,0 +1,33 @@
+package router
+
+import (
+	"fmt"
+	"github.com/itksb/go-url-shortener/internal/handler"
+	"net"
+	"net/http"
+)
+
+// NewTrustedSubnetMiddleware allows requests only from the clients whose ip is in the subnet.
+// The ip is resolved by clientIP, see NewClientIPFunc: the headers of the untrusted clients are ignored.
+// Empty subnet denies everyone
+func NewTrustedSubnetMiddleware(cidr string, clientIP func(r *http.Request) string) (func(http.Handler) http.Handler, error) {
+	var subnet *net.IPNet
+	if cidr != "" {
+		var err error
+		_, subnet, err = net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("trusted subnet %q is invalid: %w", cidr, err)
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := net.ParseIP(clientIP(r))
+			if subnet == nil || ip == nil || !subnet.Contains(ip) {
+				handler.SendJSONError(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}, nil
+}
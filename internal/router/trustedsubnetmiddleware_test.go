@@ -0,0 +1,50 @@
+package router
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewTrustedSubnetMiddleware(t *testing.T) {
+	clientIP, err := NewClientIPFunc("10.0.0.0/8")
+	require.NoError(t, err)
+	_, err = NewTrustedSubnetMiddleware("10.0.0.1", clientIP)
+	assert.Error(t, err)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name       string
+		subnet     string
+		remoteAddr string
+		realIP     string
+		want       int
+	}{
+		{name: "inside subnet", subnet: "192.168.1.0/24", remoteAddr: "192.168.1.15:1234", want: http.StatusOK},
+		{name: "outside subnet", subnet: "192.168.1.0/24", remoteAddr: "192.168.2.15:1234", want: http.StatusForbidden},
+		{name: "inside subnet behind proxy", subnet: "192.168.1.0/24", remoteAddr: "10.0.0.1:1234", realIP: "192.168.1.15", want: http.StatusOK},
+		{name: "outside subnet behind proxy", subnet: "192.168.1.0/24", remoteAddr: "10.0.0.1:1234", realIP: "192.168.2.15", want: http.StatusForbidden},
+		{name: "spoofed real ip", subnet: "192.168.1.0/24", remoteAddr: "203.0.113.7:1234", realIP: "192.168.1.15", want: http.StatusForbidden},
+		{name: "empty subnet", subnet: "", remoteAddr: "192.168.1.15:1234", want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mdl, err := NewTrustedSubnetMiddleware(tt.subnet, clientIP)
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/internal/stats", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			rr := httptest.NewRecorder()
+			mdl(ok).ServeHTTP(rr, req)
+			assert.Equal(t, tt.want, rr.Code)
+		})
+	}
+}
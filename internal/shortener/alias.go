@@ -0,0 +1,44 @@
+package shortener
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Alias length limits
+const (
+	AliasMinLength = 3
+	AliasMaxLength = 64
+)
+
+// ReservedAliases - aliases clashing with the service routes
+var ReservedAliases = []string{"api", "health", "ping", "debug"}
+
+// ErrInvalidAlias - alias does not pass validation
+var ErrInvalidAlias = errors.New(`invalid alias`)
+
+// ValidateAlias checks allowed symbols (latin letters, digits, '-' and '_'), length and reserved words
+func ValidateAlias(alias string) error {
+	if len(alias) < AliasMinLength || len(alias) > AliasMaxLength {
+		return fmt.Errorf("%w: length must be from %d to %d", ErrInvalidAlias, AliasMinLength, AliasMaxLength)
+	}
+	for _, r := range alias {
+		if !isAliasSymbol(r) {
+			return fmt.Errorf("%w: symbol %q is not allowed", ErrInvalidAlias, r)
+		}
+	}
+	for _, reserved := range ReservedAliases {
+		if strings.EqualFold(alias, reserved) {
+			return fmt.Errorf("%w: %q is reserved", ErrInvalidAlias, alias)
+		}
+	}
+	return nil
+}
+
+func isAliasSymbol(r rune) bool {
+	return (r >= 'a' && r <= 'z') ||
+		(r >= 'A' && r <= 'Z') ||
+		(r >= '0' && r <= '9') ||
+		r == '-' || r == '_'
+}
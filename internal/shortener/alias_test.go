@@ -0,0 +1,29 @@
+package shortener
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestValidateAlias(t *testing.T) {
+	tests := []struct {
+		alias   string
+		wantErr bool
+	}{
+		{alias: "spring-sale"},
+		{alias: "Spring_Sale_2023"},
+		{alias: "ab", wantErr: true},
+		{alias: "spring sale", wantErr: true},
+		{alias: "весна", wantErr: true},
+		{alias: "api", wantErr: true},
+		{alias: "Health", wantErr: true},
+	}
+	for _, tt := range tests {
+		err := ValidateAlias(tt.alias)
+		if tt.wantErr {
+			assert.ErrorIs(t, err, ErrInvalidAlias, tt.alias)
+		} else {
+			assert.NoError(t, err, tt.alias)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package shortener
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CacheMetrics - counters of the url cache
+type CacheMetrics struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+	Size   int   `json:"size"`
+}
+
+// CachedStorage - ShortenerStorage decorator with the LRU cache of GetURL results.
+// Absent codes are cached too, but for the shorter time.
+// Changes made through the decorator invalidate the cached urls
+type CachedStorage struct {
+	ShortenerStorage
+	capacity    int
+	ttl         time.Duration
+	negativeTTL time.Duration
+
+	mtx     sync.Mutex
+	order   *list.List // front is the most recently used
+	entries map[string]*list.Element
+	// generation is incremented by every invalidation,
+	// so the lookup started before it does not cache the stale url
+	generation uint64
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+type cacheEntry struct {
+	code      string
+	item      URLListItem
+	found     bool
+	expiresAt time.Time
+}
+
+// NewCachedStorage - constructor. Zero negativeTTL disables caching of absent codes
+func NewCachedStorage(storage ShortenerStorage, capacity int, ttl time.Duration, negativeTTL time.Duration) *CachedStorage {
+	return &CachedStorage{
+		ShortenerStorage: storage,
+		capacity:         capacity,
+		ttl:              ttl,
+		negativeTTL:      negativeTTL,
+		order:            list.New(),
+		entries:          make(map[string]*list.Element),
+	}
+}
+
+// GetURL returns the cached url or reads it from the storage
+func (c *CachedStorage) GetURL(ctx context.Context, id string) (URLListItem, error) {
+	now := time.Now()
+	c.mtx.Lock()
+	if el, ok := c.entries[id]; ok {
+		entry := el.Value.(*cacheEntry)
+		if now.Before(entry.expiresAt) {
+			c.order.MoveToFront(el)
+			c.mtx.Unlock()
+			c.hits.Add(1)
+			if !entry.found {
+				return URLListItem{}, fmt.Errorf("%w: code %s", ErrNotFound, id)
+			}
+			return entry.item, nil
+		}
+		c.remove(el)
+	}
+	generation := c.generation
+	c.mtx.Unlock()
+	c.misses.Add(1)
+
+	item, err := c.ShortenerStorage.GetURL(ctx, id)
+	switch {
+	case err == nil && len(item.OriginalURL) != 0:
+		c.put(generation, &cacheEntry{code: id, item: item, found: true, expiresAt: now.Add(c.ttl)})
+	case (err == nil || errors.Is(err, ErrNotFound)) && c.negativeTTL > 0:
+		c.put(generation, &cacheEntry{code: id, expiresAt: now.Add(c.negativeTTL)})
+	}
+	return item, err
+}
+
+// SaveURL invalidates the code of the saved url, it may be cached as absent
+func (c *CachedStorage) SaveURL(ctx context.Context, url string, userID string, opts SaveOptions) (string, error) {
+	code, err := c.ShortenerStorage.SaveURL(ctx, url, userID, opts)
+	c.invalidate(code)
+	return code, err
+}
+
+// SaveURLBatch invalidates the codes of the saved urls
+func (c *CachedStorage) SaveURLBatch(ctx context.Context, userID string, items []BatchItem) ([]BatchResult, error) {
+	results, err := c.ShortenerStorage.SaveURLBatch(ctx, userID, items)
+	codes := make([]string, 0, len(results))
+	for _, result := range results {
+		codes = append(codes, result.ShortCode)
+	}
+	c.invalidate(codes...)
+	return results, err
+}
+
+// DeleteURLBatch invalidates the deleted codes
+func (c *CachedStorage) DeleteURLBatch(ctx context.Context, userID string, ids []string) error {
+	err := c.ShortenerStorage.DeleteURLBatch(ctx, userID, ids)
+	c.invalidate(ids...)
+	return err
+}
+
+// ConsumeClick invalidates the code, because clicks left of the url are changed
+func (c *CachedStorage) ConsumeClick(ctx context.Context, id string) error {
+	err := c.ShortenerStorage.ConsumeClick(ctx, id)
+	c.invalidate(id)
+	return err
+}
+
+// UpdateURL invalidates the code, because its original url is changed
+func (c *CachedStorage) UpdateURL(ctx context.Context, id string, userID string, url string, canonicalURL string) error {
+	err := c.ShortenerStorage.UpdateURL(ctx, id, userID, url, canonicalURL)
+	c.invalidate(id)
+	return err
+}
+
+// RestoreURLBatch invalidates the restored codes
+func (c *CachedStorage) RestoreURLBatch(ctx context.Context, userID string, ids []string) ([]string, error) {
+	codes, err := c.ShortenerStorage.RestoreURLBatch(ctx, userID, ids)
+	c.invalidate(ids...)
+	return codes, err
+}
+
+// DeleteExpiredURLs clears the cache if some urls are deleted
+func (c *CachedStorage) DeleteExpiredURLs(ctx context.Context, now time.Time) (int64, error) {
+	count, err := c.ShortenerStorage.DeleteExpiredURLs(ctx, now)
+	if count > 0 {
+		c.clear()
+	}
+	return count, err
+}
+
+// PurgeDeletedURLs clears the cache if some urls are removed
+func (c *CachedStorage) PurgeDeletedURLs(ctx context.Context, before time.Time) (int64, error) {
+	count, err := c.ShortenerStorage.PurgeDeletedURLs(ctx, before)
+	if count > 0 {
+		c.clear()
+	}
+	return count, err
+}
+
+// Metrics returns the current counters
+func (c *CachedStorage) Metrics() CacheMetrics {
+	c.mtx.Lock()
+	size := c.order.Len()
+	c.mtx.Unlock()
+	return CacheMetrics{
+		Hits:   c.hits.Load(),
+		Misses: c.misses.Load(),
+		Size:   size,
+	}
+}
+
+// put caches the entry unless the cache is invalidated after the lookup started
+func (c *CachedStorage) put(generation uint64, entry *cacheEntry) {
+	if c.capacity <= 0 {
+		return
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if generation != c.generation {
+		return
+	}
+	if el, ok := c.entries[entry.code]; ok {
+		c.remove(el)
+	}
+	c.entries[entry.code] = c.order.PushFront(entry)
+	for c.order.Len() > c.capacity {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *CachedStorage) invalidate(codes ...string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.generation++
+	for _, code := range codes {
+		if el, ok := c.entries[code]; ok {
+			c.remove(el)
+		}
+	}
+}
+
+func (c *CachedStorage) clear() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.generation++
+	c.order.Init()
+	c.entries = make(map[string]*list.Element)
+}
+
+// remove deletes the element, the caller holds the lock
+func (c *CachedStorage) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*cacheEntry).code)
+}
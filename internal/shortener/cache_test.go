@@ -0,0 +1,84 @@
+package shortener_test
+
+import (
+	"context"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/itksb/go-url-shortener/internal/storage"
+	"github.com/itksb/go-url-shortener/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func newCachedStorage(t *testing.T, capacity int) *shortener.CachedStorage {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+	repo := storage.NewStorage(l, shortener.NewSequenceGenerator(shortener.Base62Alphabet, 0))
+	return shortener.NewCachedStorage(repo, capacity, time.Minute, time.Minute)
+}
+
+func TestCachedStorage_GetURL(t *testing.T) {
+	ctx := context.Background()
+	cache := newCachedStorage(t, 10)
+	code, err := cache.SaveURL(ctx, "https://example.com", "user1", shortener.SaveOptions{})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		item, err := cache.GetURL(ctx, code)
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com", item.OriginalURL)
+	}
+	assert.Equal(t, shortener.CacheMetrics{Hits: 2, Misses: 1, Size: 1}, cache.Metrics())
+
+	// deletion invalidates the cached url
+	require.NoError(t, cache.DeleteURLBatch(ctx, "user1", []string{code}))
+	item, err := cache.GetURL(ctx, code)
+	require.NoError(t, err)
+	assert.NotNil(t, item.DeletedAt)
+	assert.Equal(t, int64(2), cache.Metrics().Misses)
+}
+
+func TestCachedStorage_NegativeCaching(t *testing.T) {
+	ctx := context.Background()
+	cache := newCachedStorage(t, 10)
+
+	for i := 0; i < 2; i++ {
+		_, err := cache.GetURL(ctx, "promo")
+		assert.ErrorIs(t, err, shortener.ErrNotFound)
+	}
+	assert.Equal(t, shortener.CacheMetrics{Hits: 1, Misses: 1, Size: 1}, cache.Metrics())
+
+	// the alias saved after the miss must be visible at once
+	_, err := cache.SaveURL(ctx, "https://example.com", "user1", shortener.SaveOptions{Alias: "promo"})
+	require.NoError(t, err)
+	item, err := cache.GetURL(ctx, "promo")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com", item.OriginalURL)
+}
+
+func TestCachedStorage_Eviction(t *testing.T) {
+	ctx := context.Background()
+	cache := newCachedStorage(t, 2)
+	codes := make([]string, 0, 3)
+	for _, url := range []string{"https://a.example", "https://b.example", "https://c.example"} {
+		code, err := cache.SaveURL(ctx, url, "user1", shortener.SaveOptions{})
+		require.NoError(t, err)
+		codes = append(codes, code)
+	}
+
+	// a is used recently, so b is evicted by c
+	for _, code := range []string{codes[0], codes[1], codes[0], codes[2]} {
+		_, err := cache.GetURL(ctx, code)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 2, cache.Metrics().Size)
+
+	misses := cache.Metrics().Misses
+	_, err := cache.GetURL(ctx, codes[0])
+	require.NoError(t, err)
+	assert.Equal(t, misses, cache.Metrics().Misses)
+	_, err = cache.GetURL(ctx, codes[1])
+	require.NoError(t, err)
+	assert.Equal(t, misses+1, cache.Metrics().Misses)
+}
@@ -0,0 +1,150 @@
+package shortener
+
+import (
+	"github.com/google/uuid"
+	"golang.org/x/net/idna"
+	"net/url"
+	"strings"
+)
+
+// TrackingParams - query parameters stripped by the canonicalizer if enabled, see TrackingParamPrefixes
+var TrackingParams = []string{"fbclid", "gclid", "dclid", "msclkid", "yclid", "mc_cid", "mc_eid"}
+
+// TrackingParamPrefixes - prefixes of the query parameters stripped by the canonicalizer if enabled
+var TrackingParamPrefixes = []string{"utm_"}
+
+// defaultPorts - ports dropped from the host
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ftp":   "21",
+	"ws":    "80",
+	"wss":   "443",
+}
+
+// hostProfile converts the host to punycode, lowercases it, underscores are allowed
+var hostProfile = idna.New(idna.MapForLookup(), idna.StrictDomainName(false))
+
+// URLCanonicalizer - brings the equivalent original urls to the same form, the form is used as the duplicate key
+type URLCanonicalizer struct {
+	stripTracking bool
+	tracking      map[string]struct{}
+}
+
+// NewURLCanonicalizer - constructor. stripTracking enables removal of the tracking query parameters
+func NewURLCanonicalizer(stripTracking bool) *URLCanonicalizer {
+	c := &URLCanonicalizer{stripTracking: stripTracking, tracking: make(map[string]struct{}, len(TrackingParams))}
+	for _, param := range TrackingParams {
+		c.tracking[param] = struct{}{}
+	}
+	return c
+}
+
+// Canonicalize lowercases the scheme and the host, converts the international host to punycode,
+// drops the default port, resolves dot segments of the path and optionally strips the tracking parameters.
+// The url is expected to pass URLValidator, ErrURLMalformed is returned if it can not be parsed
+func (c *URLCanonicalizer) Canonicalize(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", ErrURLMalformed
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Host != "" {
+		u.Host = canonicalHost(u.Scheme, u.Hostname(), u.Port())
+	}
+	if escaped := u.EscapedPath(); strings.Contains(escaped, ".") {
+		resolved := removeDotSegments(escaped)
+		if u.Path, err = url.PathUnescape(resolved); err != nil {
+			return "", ErrURLMalformed
+		}
+		u.RawPath = resolved
+	}
+	if c.stripTracking && u.RawQuery != "" {
+		u.RawQuery = c.stripTrackingParams(u.RawQuery)
+	}
+	return u.String(), nil
+}
+
+// canonicalHost - lowercased punycode host with the port if it is not the default one of the scheme
+func canonicalHost(scheme string, hostname string, port string) string {
+	host, err := hostProfile.ToASCII(hostname)
+	if err != nil {
+		// the host is kept as is except the case, validation is not the business of the canonicalizer
+		host = strings.ToLower(hostname)
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port != "" && port != defaultPorts[scheme] {
+		host += ":" + port
+	}
+	return host
+}
+
+// removeDotSegments resolves "." and ".." segments of the escaped path as RFC 3986 section 5.2.4 does
+func removeDotSegments(path string) string {
+	segments := strings.Split(path, "/")
+	result := make([]string, 0, len(segments))
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch segment {
+		case ".":
+		case "..":
+			// the leading empty segment of the absolute path is never removed
+			if len(result) > 1 || (len(result) == 1 && result[0] != "") {
+				result = result[:len(result)-1]
+			}
+		default:
+			result = append(result, segment)
+			continue
+		}
+		if last {
+			result = append(result, "")
+		}
+	}
+	return strings.Join(result, "/")
+}
+
+// stripTrackingParams removes the tracking parameters from the raw query, the order of others is kept
+func (c *URLCanonicalizer) stripTrackingParams(rawQuery string) string {
+	params := strings.Split(rawQuery, "&")
+	kept := params[:0]
+	for _, param := range params {
+		key, _, _ := strings.Cut(param, "=")
+		if unescaped, err := url.QueryUnescape(key); err == nil {
+			key = unescaped
+		}
+		if !c.isTracking(strings.ToLower(key)) {
+			kept = append(kept, param)
+		}
+	}
+	return strings.Join(kept, "&")
+}
+
+func (c *URLCanonicalizer) isTracking(key string) bool {
+	if _, ok := c.tracking[key]; ok {
+		return true
+	}
+	for _, prefix := range TrackingParamPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// DuplicateKey - the key the storages detect duplicates by: the canonical url, or the url itself if it is not set
+func DuplicateKey(url string, canonicalURL string) string {
+	if canonicalURL != "" {
+		return canonicalURL
+	}
+	return url
+}
+
+// uniqueKey - duplicate key of the url which is never the duplicate: password protected, click-limited or expiring.
+// The protected url is never returned for the public one and its password is never dropped as the one
+// of the duplicate, the limited url is never shared with the other user and never outlives its clicks or time
+func uniqueKey(canonicalURL string) string {
+	// the space is escaped in the canonical url, so the key never equals the key of the public url
+	return canonicalURL + " " + uuid.NewString()
+}
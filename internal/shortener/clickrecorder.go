@@ -0,0 +1,119 @@
+package shortener
+
+import (
+	"context"
+	"fmt"
+	"github.com/itksb/go-url-shortener/pkg/logger"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ClickRecorder - buffered asynchronous pipeline of click events.
+// Record never blocks the redirect: events are dropped when the buffer is full
+type ClickRecorder struct {
+	logger        logger.Interface
+	storage       ClickStorage
+	events        chan ClickEvent
+	batchSize     int
+	flushInterval time.Duration
+
+	mtx     sync.RWMutex
+	closed  bool
+	doneCh  chan struct{}
+	dropped atomic.Int64
+}
+
+// NewClickRecorder - constructor. Starts the background writer
+func NewClickRecorder(
+	l logger.Interface,
+	storage ClickStorage,
+	bufferSize int,
+	batchSize int,
+	flushInterval time.Duration,
+) *ClickRecorder {
+	r := &ClickRecorder{
+		logger:        l,
+		storage:       storage,
+		events:        make(chan ClickEvent, bufferSize),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		doneCh:        make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+// Record enqueues the event. Returns false if the event is dropped
+func (r *ClickRecorder) Record(ev ClickEvent) bool {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	if r.closed {
+		return false
+	}
+	select {
+	case r.events <- ev:
+		return true
+	default:
+		r.dropped.Add(1)
+		return false
+	}
+}
+
+// Dropped returns count of events dropped due to the full buffer
+func (r *ClickRecorder) Dropped() int64 {
+	return r.dropped.Load()
+}
+
+// Close stops accepting events and waits until the buffered ones are written or ctx is done
+func (r *ClickRecorder) Close(ctx context.Context) error {
+	r.mtx.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.events)
+	}
+	r.mtx.Unlock()
+
+	select {
+	case <-r.doneCh:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("click recorder close: %w", ctx.Err())
+	}
+}
+
+func (r *ClickRecorder) run() {
+	defer close(r.doneCh)
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]ClickEvent, 0, r.batchSize)
+	for {
+		select {
+		case ev, ok := <-r.events:
+			if !ok {
+				r.flush(batch)
+				return
+			}
+			batch = append(batch, ev)
+			if len(batch) >= r.batchSize {
+				r.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			r.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+func (r *ClickRecorder) flush(batch []ClickEvent) {
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := r.storage.SaveClicks(ctx, batch); err != nil {
+		r.logger.Error(fmt.Sprintf("click recorder: %d events are lost: %s", len(batch), err.Error()))
+	}
+}
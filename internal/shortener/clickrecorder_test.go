@@ -0,0 +1,72 @@
+package shortener_test
+
+import (
+	"context"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/itksb/go-url-shortener/internal/storage"
+	"github.com/itksb/go-url-shortener/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestClickRecorder(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+
+	repo := storage.NewStorage(l, shortener.NewSequenceGenerator(shortener.Base62Alphabet, 0))
+	service := shortener.NewShortener(l, repo)
+	// flush interval is long: events must be written by the batch size and by Close
+	recorder := shortener.NewClickRecorder(l, repo, 100, 2, time.Hour)
+	service.UseClickAnalytics(recorder, repo)
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		service.RecordClick(shortener.ClickEvent{URLID: 1, ClickedAt: now, IPHash: "ip"})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, recorder.Close(ctx))
+	assert.False(t, recorder.Record(shortener.ClickEvent{URLID: 1, ClickedAt: now}))
+
+	stats, err := service.URLStats(ctx, 1, shortener.ClickStatsOptions{Now: now, Hours: 24, Days: 7, TopReferrers: 5})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), stats.Total)
+	assert.Equal(t, int64(1), stats.UniqueVisitors)
+	assert.Len(t, stats.Hourly, 24)
+	assert.Len(t, stats.Daily, 7)
+	assert.Equal(t, int64(3), stats.Hourly[23].Clicks)
+}
+
+// blockingClickStorage blocks writes until release is closed
+type blockingClickStorage struct {
+	release chan struct{}
+}
+
+func (s *blockingClickStorage) SaveClicks(ctx context.Context, events []shortener.ClickEvent) error {
+	<-s.release
+	return nil
+}
+
+func (s *blockingClickStorage) ClickStats(ctx context.Context, urlID int64, opts shortener.ClickStatsOptions) (shortener.ClickStats, error) {
+	return shortener.ClickStats{}, nil
+}
+
+func TestClickRecorder_DropsWhenFull(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+
+	clickStorage := &blockingClickStorage{release: make(chan struct{})}
+	recorder := shortener.NewClickRecorder(l, clickStorage, 1, 1, time.Hour)
+
+	// the writer holds at most one event, the buffer holds one more
+	for i := 0; i < 10; i++ {
+		recorder.Record(shortener.ClickEvent{URLID: 1})
+	}
+	assert.GreaterOrEqual(t, recorder.Dropped(), int64(8))
+
+	close(clickStorage.release)
+	require.NoError(t, recorder.Close(context.Background()))
+}
@@ -0,0 +1,126 @@
+package shortener
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// ClickEvent - one redirect through the short url
+type ClickEvent struct {
+	URLID     int64     `json:"url_id" db:"url_id"`
+	ClickedAt time.Time `json:"clicked_at" db:"clicked_at"`
+	Referrer  string    `json:"referrer" db:"referrer"`
+	UserAgent string    `json:"user_agent" db:"user_agent"`
+	IPHash    string    `json:"ip_hash" db:"ip_hash"` // client ip is never stored as is
+}
+
+// ClickBucket - count of clicks in the time bucket
+type ClickBucket struct {
+	Start  time.Time `json:"start"`
+	Clicks int64     `json:"clicks"`
+}
+
+// ReferrerCount - count of clicks from the referrer
+type ReferrerCount struct {
+	Referrer string `json:"referrer"`
+	Clicks   int64  `json:"clicks"`
+}
+
+// ClickStats - aggregated clicks of the url
+type ClickStats struct {
+	Total          int64           `json:"total"`
+	UniqueVisitors int64           `json:"unique_visitors"`
+	Hourly         []ClickBucket   `json:"hourly"`
+	Daily          []ClickBucket   `json:"daily"`
+	TopReferrers   []ReferrerCount `json:"top_referrers"`
+}
+
+// ClickStatsOptions - window of the click statistics
+type ClickStatsOptions struct {
+	Now          time.Time // end of the window
+	Hours        int       // count of hourly buckets
+	Days         int       // count of daily buckets
+	TopReferrers int       // max count of referrers
+}
+
+// ClickStorage - persists click events. Buckets returned by ClickStats may be sparse
+type ClickStorage interface {
+	SaveClicks(ctx context.Context, events []ClickEvent) error
+	ClickStats(ctx context.Context, urlID int64, opts ClickStatsOptions) (ClickStats, error)
+}
+
+// HourlyFrom returns the start of the first hourly bucket
+func (opts ClickStatsOptions) HourlyFrom() time.Time {
+	return opts.Now.UTC().Truncate(time.Hour).Add(-time.Duration(opts.Hours-1) * time.Hour)
+}
+
+// DailyFrom returns the start of the first daily bucket
+func (opts ClickStatsOptions) DailyFrom() time.Time {
+	return truncateDay(opts.Now).AddDate(0, 0, -(opts.Days - 1))
+}
+
+// AggregateClicks calculates statistics of the events in memory.
+// Used by the storages without query engine
+func AggregateClicks(events []ClickEvent, opts ClickStatsOptions) ClickStats {
+	stats := ClickStats{}
+	hourlyFrom, dailyFrom := opts.HourlyFrom(), opts.DailyFrom()
+	visitors := make(map[string]struct{})
+	hourly := make(map[time.Time]int64)
+	daily := make(map[time.Time]int64)
+	referrers := make(map[string]int64)
+
+	for _, ev := range events {
+		stats.Total++
+		visitors[ev.IPHash] = struct{}{}
+		at := ev.ClickedAt.UTC()
+		if !at.Before(hourlyFrom) {
+			hourly[at.Truncate(time.Hour)]++
+		}
+		if !at.Before(dailyFrom) {
+			daily[truncateDay(at)]++
+		}
+		if ev.Referrer != "" {
+			referrers[ev.Referrer]++
+		}
+	}
+	stats.UniqueVisitors = int64(len(visitors))
+
+	for start, clicks := range hourly {
+		stats.Hourly = append(stats.Hourly, ClickBucket{Start: start, Clicks: clicks})
+	}
+	for start, clicks := range daily {
+		stats.Daily = append(stats.Daily, ClickBucket{Start: start, Clicks: clicks})
+	}
+	for referrer, clicks := range referrers {
+		stats.TopReferrers = append(stats.TopReferrers, ReferrerCount{Referrer: referrer, Clicks: clicks})
+	}
+	sort.Slice(stats.TopReferrers, func(i, j int) bool {
+		if stats.TopReferrers[i].Clicks != stats.TopReferrers[j].Clicks {
+			return stats.TopReferrers[i].Clicks > stats.TopReferrers[j].Clicks
+		}
+		return stats.TopReferrers[i].Referrer < stats.TopReferrers[j].Referrer
+	})
+	if len(stats.TopReferrers) > opts.TopReferrers {
+		stats.TopReferrers = stats.TopReferrers[:opts.TopReferrers]
+	}
+	return stats
+}
+
+// fillBuckets returns count buckets of the given size starting from the moment from, absent buckets are zero
+func fillBuckets(sparse []ClickBucket, from time.Time, count int, next func(time.Time) time.Time) []ClickBucket {
+	clicks := make(map[time.Time]int64, len(sparse))
+	for _, b := range sparse {
+		clicks[b.Start.UTC()] += b.Clicks
+	}
+	buckets := make([]ClickBucket, 0, count)
+	for i, start := 0, from; i < count; i, start = i+1, next(start) {
+		buckets = append(buckets, ClickBucket{Start: start, Clicks: clicks[start]})
+	}
+	return buckets
+}
+
+func truncateDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,45 @@
+package shortener
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestAggregateClicks(t *testing.T) {
+	now := time.Date(2023, time.March, 20, 15, 30, 0, 0, time.UTC)
+	events := []ClickEvent{
+		{URLID: 1, ClickedAt: now.Add(-10 * time.Minute), Referrer: "https://a.example", IPHash: "ip1"},
+		{URLID: 1, ClickedAt: now.Add(-70 * time.Minute), Referrer: "https://a.example", IPHash: "ip1"},
+		{URLID: 1, ClickedAt: now.Add(-50 * time.Hour), Referrer: "https://b.example", IPHash: "ip2"},
+		{URLID: 1, ClickedAt: now.AddDate(0, 0, -40), IPHash: "ip3"},
+	}
+
+	stats := AggregateClicks(events, ClickStatsOptions{Now: now, Hours: 24, Days: 30, TopReferrers: 1})
+
+	assert.Equal(t, int64(4), stats.Total)
+	assert.Equal(t, int64(3), stats.UniqueVisitors)
+	assert.ElementsMatch(t, []ClickBucket{
+		{Start: time.Date(2023, time.March, 20, 15, 0, 0, 0, time.UTC), Clicks: 1},
+		{Start: time.Date(2023, time.March, 20, 14, 0, 0, 0, time.UTC), Clicks: 1},
+	}, stats.Hourly)
+	assert.ElementsMatch(t, []ClickBucket{
+		{Start: time.Date(2023, time.March, 20, 0, 0, 0, 0, time.UTC), Clicks: 2},
+		{Start: time.Date(2023, time.March, 18, 0, 0, 0, 0, time.UTC), Clicks: 1},
+	}, stats.Daily)
+	assert.Equal(t, []ReferrerCount{{Referrer: "https://a.example", Clicks: 2}}, stats.TopReferrers)
+}
+
+func TestFillBuckets(t *testing.T) {
+	from := time.Date(2023, time.March, 20, 0, 0, 0, 0, time.UTC)
+	buckets := fillBuckets(
+		[]ClickBucket{{Start: from.Add(time.Hour), Clicks: 5}},
+		from, 3,
+		func(t time.Time) time.Time { return t.Add(time.Hour) },
+	)
+	assert.Equal(t, []ClickBucket{
+		{Start: from, Clicks: 0},
+		{Start: from.Add(time.Hour), Clicks: 5},
+		{Start: from.Add(2 * time.Hour), Clicks: 0},
+	}, buckets)
+}
@@ -0,0 +1,155 @@
+package shortener
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Base62Alphabet - default alphabet of the short codes
+const Base62Alphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// MaxCodeAttempts - how many times the storage asks the generator for a new code
+// before giving up with ErrCodeCollision
+const MaxCodeAttempts = 10
+
+// Code generator kinds, see NewCodeGenerator
+const (
+	CodeGeneratorSequence = "sequence"
+	CodeGeneratorRandom   = "random"
+)
+
+// ErrCodeCollision - storage could not find a free short code
+var ErrCodeCollision = errors.New(`short code collision`)
+
+// CodeGenerator - generates short codes for the stored urls.
+// The storage is responsible for the uniqueness of the code:
+// on collision it calls Generate again with the incremented attempt number.
+type CodeGenerator interface {
+	// Generate returns short code candidate for the record with the given numeric id.
+	// attempt is the zero-based number of the try
+	Generate(id int64, attempt int) (string, error)
+}
+
+// NewCodeGenerator - constructor. kind is one of CodeGeneratorSequence, CodeGeneratorRandom.
+// Empty alphabet means Base62Alphabet
+func NewCodeGenerator(kind string, alphabet string, length int) (CodeGenerator, error) {
+	if alphabet == "" {
+		alphabet = Base62Alphabet
+	}
+	if len(alphabet) < 2 {
+		return nil, errors.New("code generator: alphabet must contain at least 2 symbols")
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("code generator: invalid code length %d", length)
+	}
+	seen := make(map[rune]struct{}, len(alphabet))
+	for _, r := range alphabet {
+		if r > 127 {
+			return nil, fmt.Errorf("code generator: alphabet symbol %q is not ascii", r)
+		}
+		if _, ok := seen[r]; ok {
+			return nil, fmt.Errorf("code generator: alphabet symbol %q is duplicated", r)
+		}
+		seen[r] = struct{}{}
+	}
+
+	switch kind {
+	case CodeGeneratorSequence:
+		return NewSequenceGenerator(alphabet, length), nil
+	case CodeGeneratorRandom, "":
+		if length == 0 {
+			return nil, errors.New("code generator: random codes require length > 0")
+		}
+		return NewRandomGenerator(alphabet, length), nil
+	default:
+		return nil, fmt.Errorf("code generator: unknown kind %q", kind)
+	}
+}
+
+// SequenceGenerator - encodes the numeric id of the record in the given alphabet
+type SequenceGenerator struct {
+	alphabet  string
+	minLength int
+}
+
+// NewSequenceGenerator - constructor. Codes shorter than minLength are left-padded with the first alphabet symbol
+func NewSequenceGenerator(alphabet string, minLength int) *SequenceGenerator {
+	return &SequenceGenerator{alphabet: alphabet, minLength: minLength}
+}
+
+// Generate - see CodeGenerator.
+// Retries append the encoded attempt number to keep the code stable for the id
+func (g *SequenceGenerator) Generate(id int64, attempt int) (string, error) {
+	if id < 0 {
+		return "", fmt.Errorf("sequence generator: negative id %d", id)
+	}
+	code := encode(uint64(id), g.alphabet)
+	for len(code) < g.minLength {
+		code = g.alphabet[:1] + code
+	}
+	if attempt > 0 {
+		code += encode(uint64(attempt), g.alphabet)
+	}
+	return code, nil
+}
+
+// RandomGenerator - generates random fixed-length codes, the id is ignored
+type RandomGenerator struct {
+	alphabet string
+	length   int
+}
+
+// NewRandomGenerator - constructor
+func NewRandomGenerator(alphabet string, length int) *RandomGenerator {
+	return &RandomGenerator{alphabet: alphabet, length: length}
+}
+
+// Generate - see CodeGenerator
+func (g *RandomGenerator) Generate(_ int64, _ int) (string, error) {
+	max := big.NewInt(int64(len(g.alphabet)))
+	code := make([]byte, g.length)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = g.alphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// GenerateUniqueCode asks gen for codes until taken reports a free one
+// or MaxCodeAttempts is exceeded
+func GenerateUniqueCode(gen CodeGenerator, id int64, taken func(code string) (bool, error)) (string, error) {
+	for attempt := 0; attempt < MaxCodeAttempts; attempt++ {
+		code, err := gen.Generate(id, attempt)
+		if err != nil {
+			return "", err
+		}
+		busy, err := taken(code)
+		if err != nil {
+			return "", err
+		}
+		if !busy {
+			return code, nil
+		}
+	}
+	return "", fmt.Errorf("%w: id %d", ErrCodeCollision, id)
+}
+
+func encode(n uint64, alphabet string) string {
+	base := uint64(len(alphabet))
+	if n == 0 {
+		return alphabet[:1]
+	}
+	var buf [64]byte
+	i := len(buf)
+	for n > 0 {
+		i--
+		buf[i] = alphabet[n%base]
+		n /= base
+	}
+	return string(buf[i:])
+}
@@ -0,0 +1,68 @@
+package shortener
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSequenceGenerator_Generate(t *testing.T) {
+	gen := NewSequenceGenerator(Base62Alphabet, 0)
+
+	tests := []struct {
+		id      int64
+		attempt int
+		want    string
+	}{
+		{id: 0, want: "0"},
+		{id: 61, want: "Z"},
+		{id: 62, want: "10"},
+		{id: 63, attempt: 1, want: "111"},
+	}
+	for _, tt := range tests {
+		code, err := gen.Generate(tt.id, tt.attempt)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, code)
+	}
+
+	padded, err := NewSequenceGenerator("ab", 4).Generate(1, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "aaab", padded)
+}
+
+func TestRandomGenerator_Generate(t *testing.T) {
+	gen := NewRandomGenerator("xyz", 6)
+	code, err := gen.Generate(1, 0)
+	require.NoError(t, err)
+	assert.Len(t, code, 6)
+	assert.Regexp(t, "^[xyz]{6}$", code)
+}
+
+func TestNewCodeGenerator(t *testing.T) {
+	_, err := NewCodeGenerator("unknown", "", 8)
+	assert.Error(t, err)
+
+	_, err = NewCodeGenerator(CodeGeneratorRandom, "aa", 8)
+	assert.Error(t, err)
+
+	_, err = NewCodeGenerator(CodeGeneratorRandom, "", 0)
+	assert.Error(t, err)
+
+	gen, err := NewCodeGenerator(CodeGeneratorSequence, "", 0)
+	require.NoError(t, err)
+	assert.IsType(t, &SequenceGenerator{}, gen)
+}
+
+func TestGenerateUniqueCode(t *testing.T) {
+	taken := map[string]bool{"5": true, "51": true}
+	code, err := GenerateUniqueCode(NewSequenceGenerator(Base62Alphabet, 0), 5, func(code string) (bool, error) {
+		return taken[code], nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "52", code)
+
+	_, err = GenerateUniqueCode(NewRandomGenerator("a", 1), 1, func(code string) (bool, error) {
+		return true, nil
+	})
+	assert.ErrorIs(t, err, ErrCodeCollision)
+}
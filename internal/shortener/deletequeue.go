@@ -0,0 +1,188 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/itksb/go-url-shortener/pkg/logger"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// deleteRetryDelay - delay before the first retry, doubled for every next one
+const deleteRetryDelay = 50 * time.Millisecond
+
+// ErrDeleteQueueFull - the deletion request is rejected, client should retry later
+var ErrDeleteQueueFull = errors.New(`delete queue is full`)
+
+// ErrDeleteQueueClosed - the deletion request is rejected due to the shutdown
+var ErrDeleteQueueClosed = errors.New(`delete queue is closed`)
+
+// URLDeleter - marks urls of the user as deleted. Unknown and foreign codes are skipped
+type URLDeleter interface {
+	DeleteURLBatch(ctx context.Context, userID string, ids []string) error
+}
+
+// DeleteTask - short codes of the user to delete
+type DeleteTask struct {
+	UserID string
+	Codes  []string
+}
+
+// DeleteQueueMetrics - counters of the delete queue
+type DeleteQueueMetrics struct {
+	Pending  int   `json:"pending"`  // tasks waiting in the queue
+	Enqueued int64 `json:"enqueued"` // tasks accepted
+	Rejected int64 `json:"rejected"` // tasks rejected, because the queue is full
+	Deleted  int64 `json:"deleted"`  // codes passed to the storage
+	Failed   int64 `json:"failed"`   // codes lost after all retries
+	Retries  int64 `json:"retries"`  // failed storage calls which are retried
+}
+
+// DeleteQueue - bounded queue of the deletion requests.
+// The worker merges tasks into batches by size and time and retries failed batches
+type DeleteQueue struct {
+	logger        logger.Interface
+	storage       URLDeleter
+	tasks         chan DeleteTask
+	batchSize     int
+	flushInterval time.Duration
+	maxRetries    int
+
+	mtx    sync.RWMutex
+	closed bool
+	doneCh chan struct{}
+
+	enqueued atomic.Int64
+	rejected atomic.Int64
+	deleted  atomic.Int64
+	failed   atomic.Int64
+	retries  atomic.Int64
+}
+
+// NewDeleteQueue - constructor. Starts the background worker
+func NewDeleteQueue(
+	l logger.Interface,
+	storage URLDeleter,
+	queueSize int,
+	batchSize int,
+	flushInterval time.Duration,
+	maxRetries int,
+) *DeleteQueue {
+	q := &DeleteQueue{
+		logger:        l,
+		storage:       storage,
+		tasks:         make(chan DeleteTask, queueSize),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		maxRetries:    maxRetries,
+		doneCh:        make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+// Enqueue adds the task without blocking.
+// Returns ErrDeleteQueueFull or ErrDeleteQueueClosed if the task is rejected
+func (q *DeleteQueue) Enqueue(task DeleteTask) error {
+	q.mtx.RLock()
+	defer q.mtx.RUnlock()
+	if q.closed {
+		return ErrDeleteQueueClosed
+	}
+	select {
+	case q.tasks <- task:
+		q.enqueued.Add(1)
+		return nil
+	default:
+		q.rejected.Add(1)
+		return ErrDeleteQueueFull
+	}
+}
+
+// Metrics returns the current counters
+func (q *DeleteQueue) Metrics() DeleteQueueMetrics {
+	return DeleteQueueMetrics{
+		Pending:  len(q.tasks),
+		Enqueued: q.enqueued.Load(),
+		Rejected: q.rejected.Load(),
+		Deleted:  q.deleted.Load(),
+		Failed:   q.failed.Load(),
+		Retries:  q.retries.Load(),
+	}
+}
+
+// Close stops accepting tasks and waits until the pending ones are processed or ctx is done
+func (q *DeleteQueue) Close(ctx context.Context) error {
+	q.mtx.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.tasks)
+	}
+	q.mtx.Unlock()
+
+	select {
+	case <-q.doneCh:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("delete queue close: %w", ctx.Err())
+	}
+}
+
+func (q *DeleteQueue) run() {
+	defer close(q.doneCh)
+	ticker := time.NewTicker(q.flushInterval)
+	defer ticker.Stop()
+
+	// codes of the batch grouped by user
+	batch := make(map[string][]string)
+	size := 0
+	for {
+		select {
+		case task, ok := <-q.tasks:
+			if !ok {
+				q.flush(batch)
+				return
+			}
+			batch[task.UserID] = append(batch[task.UserID], task.Codes...)
+			size += len(task.Codes)
+			if size >= q.batchSize {
+				q.flush(batch)
+				batch, size = make(map[string][]string), 0
+			}
+		case <-ticker.C:
+			if size > 0 {
+				q.flush(batch)
+				batch, size = make(map[string][]string), 0
+			}
+		}
+	}
+}
+
+func (q *DeleteQueue) flush(batch map[string][]string) {
+	for userID, codes := range batch {
+		if err := q.delete(userID, codes); err != nil {
+			q.failed.Add(int64(len(codes)))
+			q.logger.Error(fmt.Sprintf("delete queue: %d codes of the user %s are not deleted: %s", len(codes), userID, err.Error()))
+			continue
+		}
+		q.deleted.Add(int64(len(codes)))
+	}
+}
+
+// delete calls the storage, retries with the exponential backoff
+func (q *DeleteQueue) delete(userID string, codes []string) error {
+	delay := deleteRetryDelay
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err := q.storage.DeleteURLBatch(ctx, userID, codes)
+		cancel()
+		if err == nil || attempt >= q.maxRetries {
+			return err
+		}
+		q.retries.Add(1)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
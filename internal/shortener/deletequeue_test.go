@@ -0,0 +1,134 @@
+package shortener_test
+
+import (
+	"context"
+	"errors"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/itksb/go-url-shortener/internal/storage"
+	"github.com/itksb/go-url-shortener/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDeleteQueue_FlushOnClose(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+
+	repo := storage.NewStorage(l, shortener.NewSequenceGenerator(shortener.Base62Alphabet, 0))
+	service := shortener.NewShortener(l, repo)
+	// batch and interval are never reached: urls must be deleted by Close
+	queue := shortener.NewDeleteQueue(l, repo, 10, 100, time.Hour, 0)
+	service.UseDeleteQueue(queue)
+
+	ctx := context.Background()
+	own, err := service.ShortenURL(ctx, "https://example.com/own", "owner", shortener.SaveOptions{})
+	require.NoError(t, err)
+	foreign, err := service.ShortenURL(ctx, "https://example.com/foreign", "stranger", shortener.SaveOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, service.DeleteURLBatch(ctx, "owner", []string{own, foreign, "unknown"}))
+	require.NoError(t, queue.Close(ctx))
+	assert.ErrorIs(t, service.DeleteURLBatch(ctx, "owner", []string{own}), shortener.ErrDeleteQueueClosed)
+
+	item, err := service.GetURL(ctx, own)
+	require.NoError(t, err)
+	assert.NotNil(t, item.DeletedAt)
+	item, err = service.GetURL(ctx, foreign)
+	require.NoError(t, err)
+	assert.Nil(t, item.DeletedAt)
+
+	metrics := queue.Metrics()
+	assert.Equal(t, int64(1), metrics.Enqueued)
+	assert.Equal(t, int64(3), metrics.Deleted)
+}
+
+// flakyDeleter fails the given count of calls and records the successful ones
+type flakyDeleter struct {
+	mtx      sync.Mutex
+	failures int
+	calls    []shortener.DeleteTask
+	release  chan struct{}
+}
+
+func (d *flakyDeleter) DeleteURLBatch(ctx context.Context, userID string, ids []string) error {
+	if d.release != nil {
+		<-d.release
+	}
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	if d.failures > 0 {
+		d.failures--
+		return errors.New("connection refused")
+	}
+	d.calls = append(d.calls, shortener.DeleteTask{UserID: userID, Codes: ids})
+	return nil
+}
+
+func (d *flakyDeleter) tasks() []shortener.DeleteTask {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	return append([]shortener.DeleteTask(nil), d.calls...)
+}
+
+func TestDeleteQueue_BatchesAndRetries(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+
+	deleter := &flakyDeleter{failures: 1}
+	queue := shortener.NewDeleteQueue(l, deleter, 10, 3, time.Hour, 2)
+
+	// tasks of the user are merged, the batch is written when it reaches 3 codes
+	require.NoError(t, queue.Enqueue(shortener.DeleteTask{UserID: "u1", Codes: []string{"a", "b"}}))
+	require.NoError(t, queue.Enqueue(shortener.DeleteTask{UserID: "u1", Codes: []string{"c"}}))
+
+	require.Eventually(t, func() bool {
+		return len(deleter.tasks()) == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []shortener.DeleteTask{{UserID: "u1", Codes: []string{"a", "b", "c"}}}, deleter.tasks())
+
+	require.NoError(t, queue.Close(context.Background()))
+	metrics := queue.Metrics()
+	assert.Equal(t, int64(3), metrics.Deleted)
+	assert.Equal(t, int64(1), metrics.Retries)
+	assert.Equal(t, int64(0), metrics.Failed)
+}
+
+func TestDeleteQueue_FlushByInterval(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+
+	deleter := &flakyDeleter{failures: 10}
+	queue := shortener.NewDeleteQueue(l, deleter, 10, 100, 20*time.Millisecond, 1)
+	require.NoError(t, queue.Enqueue(shortener.DeleteTask{UserID: "u1", Codes: []string{"a"}}))
+
+	// every call fails, so the codes are lost after the retry
+	require.Eventually(t, func() bool {
+		return queue.Metrics().Failed == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int64(1), queue.Metrics().Retries)
+	require.NoError(t, queue.Close(context.Background()))
+}
+
+func TestDeleteQueue_RejectsWhenFull(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+
+	deleter := &flakyDeleter{release: make(chan struct{})}
+	queue := shortener.NewDeleteQueue(l, deleter, 1, 1, time.Hour, 0)
+
+	// the worker holds at most one task, the queue holds one more
+	var rejected int
+	for i := 0; i < 10; i++ {
+		if errors.Is(queue.Enqueue(shortener.DeleteTask{UserID: "u1", Codes: []string{"a"}}), shortener.ErrDeleteQueueFull) {
+			rejected++
+		}
+	}
+	assert.GreaterOrEqual(t, rejected, 8)
+	assert.Equal(t, int64(rejected), queue.Metrics().Rejected)
+
+	close(deleter.release)
+	require.NoError(t, queue.Close(context.Background()))
+}
@@ -0,0 +1,234 @@
+package shortener
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/itksb/go-url-shortener/pkg/logger"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// ErrURLDenied - host of the url is in the denylist
+var ErrURLDenied = errors.New(`url host is denied`)
+
+// denyRules - parsed denylist
+type denyRules struct {
+	domains  map[string]struct{} // exact hosts
+	suffixes []string            // ".example.com" of the "*.example.com" patterns
+	regexps  []*regexp.Regexp
+}
+
+// parseDenylist reads the rules, one per line. Empty lines and lines starting with # are skipped.
+// A rule is the domain "example.com" matching the host exactly, the pattern "*.example.com" matching
+// its subdomains at any depth or the regular expression "/^phish[0-9]+\./" matching the host.
+// Hosts are matched in lowercase punycode form without the port
+func parseDenylist(r io.Reader) (*denyRules, error) {
+	rules := &denyRules{domains: make(map[string]struct{})}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case len(line) > 1 && strings.HasPrefix(line, "/") && strings.HasSuffix(line, "/"):
+			re, err := regexp.Compile(line[1 : len(line)-1])
+			if err != nil {
+				return nil, fmt.Errorf("denylist line %d: %w", n, err)
+			}
+			rules.regexps = append(rules.regexps, re)
+		case strings.HasPrefix(line, "*."):
+			domain, err := denylistDomain(line[2:])
+			if err != nil {
+				return nil, fmt.Errorf("denylist line %d: %w", n, err)
+			}
+			rules.suffixes = append(rules.suffixes, "."+domain)
+		default:
+			domain, err := denylistDomain(line)
+			if err != nil {
+				return nil, fmt.Errorf("denylist line %d: %w", n, err)
+			}
+			rules.domains[domain] = struct{}{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("denylist read error: %w", err)
+	}
+	return rules, nil
+}
+
+// denylistDomain - the domain of the rule in the form of the matched hosts
+func denylistDomain(domain string) (string, error) {
+	if strings.ContainsAny(domain, "/*: ") {
+		return "", fmt.Errorf("invalid domain %q", domain)
+	}
+	ascii, err := hostProfile.ToASCII(strings.TrimSuffix(domain, "."))
+	if err != nil || ascii == "" {
+		return "", fmt.Errorf("invalid domain %q", domain)
+	}
+	return ascii, nil
+}
+
+func (rules *denyRules) match(host string) bool {
+	if _, ok := rules.domains[host]; ok {
+		return true
+	}
+	for _, suffix := range rules.suffixes {
+		if strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	for _, re := range rules.regexps {
+		if re.MatchString(host) {
+			return true
+		}
+	}
+	return false
+}
+
+// Denylist - hosts the urls are not shortened to and not redirected to.
+// The rules are reloaded from the file on SIGHUP and when the file is changed, see Start
+type Denylist struct {
+	logger   logger.Interface
+	path     string
+	interval time.Duration
+
+	mtx     sync.RWMutex
+	rules   *denyRules
+	modTime time.Time
+	size    int64
+
+	started  atomic.Bool
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// NewDenylist - constructor, loads the rules from the file. The file is checked for changes every interval
+func NewDenylist(l logger.Interface, path string, interval time.Duration) (*Denylist, error) {
+	d := &Denylist{
+		logger:   l,
+		path:     path,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+		doneCh:   make(chan struct{}),
+	}
+	if err := d.Reload(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Reload replaces the rules by the ones of the file. The current rules are kept if the file is not valid,
+// it is not reloaded on change until it is changed again
+func (d *Denylist) Reload() error {
+	f, err := os.Open(d.path)
+	if err != nil {
+		return fmt.Errorf("denylist open error: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("denylist stat error: %w", err)
+	}
+	rules, err := parseDenylist(f)
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	d.modTime = info.ModTime()
+	d.size = info.Size()
+	if err != nil {
+		return err
+	}
+	d.rules = rules
+	return nil
+}
+
+// Check returns ErrURLDenied if the host of the url is in the denylist
+func (d *Denylist) Check(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ErrURLMalformed
+	}
+	host, err := hostProfile.ToASCII(strings.TrimSuffix(u.Hostname(), "."))
+	if err != nil {
+		host = strings.ToLower(u.Hostname())
+	}
+
+	d.mtx.RLock()
+	defer d.mtx.RUnlock()
+	if d.rules.match(host) {
+		return fmt.Errorf("%w: %s", ErrURLDenied, host)
+	}
+	return nil
+}
+
+// Start reloads the rules in the background on SIGHUP and when the file is changed.
+// Repeated calls are ignored
+func (d *Denylist) Start() {
+	if !d.started.CompareAndSwap(false, true) {
+		return
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer close(d.doneCh)
+		defer signal.Stop(hup)
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-d.stopCh:
+				return
+			case <-hup:
+				d.reload("SIGHUP")
+			case <-ticker.C:
+				if d.changed() {
+					d.reload("file change")
+				}
+			}
+		}
+	}()
+}
+
+// Stop signals the reloading to stop and waits for it to finish or ctx to expire
+func (d *Denylist) Stop(ctx context.Context) error {
+	d.stopOnce.Do(func() { close(d.stopCh) })
+	if !d.started.Load() {
+		return nil
+	}
+	select {
+	case <-d.doneCh:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("denylist stop: %w", ctx.Err())
+	}
+}
+
+// changed reports whether the file differs from the loaded one by the modification time or size
+func (d *Denylist) changed() bool {
+	info, err := os.Stat(d.path)
+	if err != nil {
+		d.logger.Error(fmt.Sprintf("denylist stat error: %s", err.Error()))
+		return false
+	}
+	d.mtx.RLock()
+	defer d.mtx.RUnlock()
+	return !info.ModTime().Equal(d.modTime) || info.Size() != d.size
+}
+
+func (d *Denylist) reload(reason string) {
+	if err := d.Reload(); err != nil {
+		d.logger.Error(fmt.Sprintf("denylist reload on %s error: %s", reason, err.Error()))
+		return
+	}
+	d.logger.Info("denylist reloaded on", reason)
+}
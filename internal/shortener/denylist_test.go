@@ -0,0 +1,156 @@
+package shortener_test
+
+import (
+	"context"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/itksb/go-url-shortener/internal/storage"
+	"github.com/itksb/go-url-shortener/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// writeDenylist replaces the file atomically, the reloading never sees it partially written
+func writeDenylist(t *testing.T, path string, rules string) {
+	tmp := path + ".tmp"
+	require.NoError(t, os.WriteFile(tmp, []byte(rules), 0o600))
+	require.NoError(t, os.Rename(tmp, path))
+}
+
+func TestDenylist_Check(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "denylist.txt")
+	writeDenylist(t, path, `
+# phishing hosts
+Phish.example
+*.bad.example
+/^login-[0-9]+\./
+bücher.example
+`)
+	denylist, err := shortener.NewDenylist(l, path, time.Hour)
+	require.NoError(t, err)
+
+	tests := []struct {
+		url    string
+		denied bool
+	}{
+		{url: "https://phish.example/login", denied: true},
+		{url: "https://PHISH.example:8443/", denied: true},
+		{url: "https://phish.example./", denied: true},
+		{url: "https://www.phish.example/"},
+		{url: "https://a.b.bad.example/", denied: true},
+		{url: "https://bad.example/"},
+		{url: "https://notbad.example/"},
+		{url: "https://login-42.example.com/", denied: true},
+		{url: "https://login.example.com/"},
+		{url: "https://BÜCHER.example/", denied: true},
+		{url: "https://xn--bcher-kva.example/", denied: true},
+		{url: "https://example.com/?next=phish.example"},
+	}
+	for _, tt := range tests {
+		err := denylist.Check(tt.url)
+		if tt.denied {
+			assert.ErrorIs(t, err, shortener.ErrURLDenied, tt.url)
+		} else {
+			assert.NoError(t, err, tt.url)
+		}
+	}
+
+	_, err = shortener.NewDenylist(l, filepath.Join(t.TempDir(), "absent.txt"), time.Hour)
+	assert.Error(t, err)
+	writeDenylist(t, path, "/[/\n")
+	_, err = shortener.NewDenylist(l, path, time.Hour)
+	assert.ErrorContains(t, err, "line 1")
+	writeDenylist(t, path, "http://phish.example/\n")
+	_, err = shortener.NewDenylist(l, path, time.Hour)
+	assert.ErrorContains(t, err, "line 1")
+}
+
+func TestDenylist_Reload(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "denylist.txt")
+	writeDenylist(t, path, "phish.example\n")
+	denylist, err := shortener.NewDenylist(l, path, 10*time.Millisecond)
+	require.NoError(t, err)
+	denylist.Start()
+	defer denylist.Stop(context.Background())
+
+	// the changed file is reloaded
+	writeDenylist(t, path, "phish.example\nscam.example\n")
+	require.Eventually(t, func() bool {
+		return denylist.Check("https://scam.example/") != nil
+	}, time.Second, 5*time.Millisecond)
+
+	// the invalid file does not replace the rules
+	writeDenylist(t, path, "/[/\n")
+	time.Sleep(50 * time.Millisecond)
+	assert.ErrorIs(t, denylist.Check("https://scam.example/"), shortener.ErrURLDenied)
+
+	require.NoError(t, denylist.Stop(context.Background()))
+	assert.ErrorContains(t, denylist.Reload(), "line 1")
+	assert.ErrorIs(t, denylist.Check("https://scam.example/"), shortener.ErrURLDenied)
+}
+
+func TestDenylist_ReloadOnSIGHUP(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "denylist.txt")
+	writeDenylist(t, path, "phish.example\n")
+	// the file is not checked for changes during the test
+	denylist, err := shortener.NewDenylist(l, path, time.Hour)
+	require.NoError(t, err)
+	denylist.Start()
+	defer denylist.Stop(context.Background())
+
+	writeDenylist(t, path, "scam.example\n")
+	assert.NoError(t, denylist.Check("https://scam.example/"))
+	process, err := os.FindProcess(os.Getpid())
+	require.NoError(t, err)
+	require.NoError(t, process.Signal(syscall.SIGHUP))
+	require.Eventually(t, func() bool {
+		return denylist.Check("https://scam.example/") != nil
+	}, time.Second, 5*time.Millisecond)
+	assert.NoError(t, denylist.Check("https://phish.example/"))
+}
+
+func TestService_Denylist(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "denylist.txt")
+	writeDenylist(t, path, "phish.example\n")
+	denylist, err := shortener.NewDenylist(l, path, time.Hour)
+	require.NoError(t, err)
+
+	service := shortener.NewShortener(l, storage.NewStorage(l, shortener.NewSequenceGenerator(shortener.Base62Alphabet, 0)))
+	ctx := context.Background()
+	code, err := service.ShortenURL(ctx, "https://scam.example/", "user1", shortener.SaveOptions{})
+	require.NoError(t, err)
+
+	service.UseDenylist(denylist)
+	_, err = service.ShortenURL(ctx, "https://phish.example/", "user1", shortener.SaveOptions{})
+	assert.ErrorIs(t, err, shortener.ErrURLDenied)
+	_, err = service.ShortenURLBatch(ctx, "user1", []shortener.BatchItem{
+		{OriginalURL: "https://example.com/"},
+		{OriginalURL: "https://PHISH.example/"},
+	})
+	var itemErr *shortener.BatchItemError
+	require.ErrorAs(t, err, &itemErr)
+	assert.Equal(t, 1, itemErr.Index)
+	assert.ErrorIs(t, err, shortener.ErrURLDenied)
+	assert.ErrorIs(t, service.UpdateURL(ctx, code, "user1", "https://phish.example/"), shortener.ErrURLDenied)
+
+	// the existing urls are checked on redirect
+	writeDenylist(t, path, "phish.example\nscam.example\n")
+	require.NoError(t, denylist.Reload())
+	assert.ErrorIs(t, service.CheckURLDenied("https://scam.example/"), shortener.ErrURLDenied)
+}
@@ -0,0 +1,17 @@
+package shortener
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrURLShared - the url is owned by other users too, so its target can not be changed
+var ErrURLShared = errors.New(`url is shared with other users`)
+
+// URLChange - change of the original url
+type URLChange struct {
+	UserID    string    `db:"user_id"` // who changed the url
+	OldURL    string    `db:"old_url"`
+	NewURL    string    `db:"new_url"`
+	ChangedAt time.Time `db:"changed_at"`
+}
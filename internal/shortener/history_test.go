@@ -0,0 +1,61 @@
+package shortener_test
+
+import (
+	"context"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/itksb/go-url-shortener/internal/storage"
+	"github.com/itksb/go-url-shortener/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestService_UpdateURL(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+
+	service := shortener.NewShortener(l, storage.NewStorage(l, shortener.NewSequenceGenerator(shortener.Base62Alphabet, 0)))
+	ctx := context.Background()
+
+	code, err := service.ShortenURL(ctx, "https://old.example.com", "user1", shortener.SaveOptions{})
+	require.NoError(t, err)
+	other, err := service.ShortenURL(ctx, "https://other.example.com", "user1", shortener.SaveOptions{})
+	require.NoError(t, err)
+
+	changes, err := service.URLHistory(ctx, code)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+	assert.NotNil(t, changes)
+
+	require.NoError(t, service.UpdateURL(ctx, code, "user1", "https://new.example.com"))
+	item, err := service.GetURL(ctx, code)
+	require.NoError(t, err)
+	assert.Equal(t, "https://new.example.com", item.OriginalURL)
+	assert.NotEmpty(t, item.UpdatedAt)
+
+	// the unchanged url is not recorded
+	require.NoError(t, service.UpdateURL(ctx, code, "user1", "https://new.example.com"))
+	changes, err = service.URLHistory(ctx, code)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, "user1", changes[0].UserID)
+	assert.Equal(t, "https://old.example.com", changes[0].OldURL)
+	assert.Equal(t, "https://new.example.com", changes[0].NewURL)
+	assert.False(t, changes[0].ChangedAt.IsZero())
+
+	err = service.UpdateURL(ctx, code, "user2", "https://stranger.example.com")
+	assert.ErrorIs(t, err, shortener.ErrNotFound)
+	err = service.UpdateURL(ctx, code, "user1", "https://other.example.com")
+	assert.ErrorIs(t, err, shortener.ErrDuplicate)
+	err = service.UpdateURL(ctx, code, "user1", "")
+	assert.Error(t, err)
+
+	// the shared url would change for the other owners too
+	_, err = service.ShortenURL(ctx, "https://other.example.com", "user2", shortener.SaveOptions{})
+	assert.ErrorIs(t, err, shortener.ErrDuplicate)
+	err = service.UpdateURL(ctx, other, "user1", "https://changed.example.com")
+	assert.ErrorIs(t, err, shortener.ErrURLShared)
+
+	_, err = service.URLHistory(ctx, "missing")
+	assert.ErrorIs(t, err, shortener.ErrNotFound)
+}
@@ -0,0 +1,290 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/itksb/go-url-shortener/pkg/logger"
+	"sync"
+	"time"
+)
+
+// jobCallTimeout - timeout of one storage call of the job worker
+const jobCallTimeout = 30 * time.Second
+
+// ErrJobQueueFull - the job is rejected, client should retry later
+var ErrJobQueueFull = errors.New(`job queue is full`)
+
+// ErrJobRunnerClosed - the job is rejected due to the shutdown
+var ErrJobRunnerClosed = errors.New(`job runner is closed`)
+
+// JobShortener - shortens the items of the job, the invalid items get their own errors
+type JobShortener interface {
+	ShortenURLBatchPartial(ctx context.Context, userID string, items []BatchItem) ([]BatchResult, []error, error)
+}
+
+// JobRunner - pool of workers processing the shortening jobs in chunks.
+// Progress is saved after every chunk, so the job interrupted by the shutdown is resumed on start
+type JobRunner struct {
+	logger    logger.Interface
+	shortener JobShortener
+	storage   JobStorage
+	queue     chan string // ids of the jobs
+	chunkSize int
+	maxItems  int
+
+	mtx    sync.RWMutex
+	closed bool
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewJobRunner - constructor. Starts the workers
+func NewJobRunner(
+	l logger.Interface,
+	shortener JobShortener,
+	storage JobStorage,
+	workers int,
+	queueSize int,
+	chunkSize int,
+	maxItems int,
+) *JobRunner {
+	r := &JobRunner{
+		logger:    l,
+		shortener: shortener,
+		storage:   storage,
+		queue:     make(chan string, queueSize),
+		chunkSize: chunkSize,
+		maxItems:  maxItems,
+		stopCh:    make(chan struct{}),
+	}
+	r.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go r.work()
+	}
+	return r
+}
+
+// Submit persists the job of the user and enqueues it without blocking.
+// Returns ErrInvalidJob if there are no items or too many of them, ErrJobQueueFull or ErrJobRunnerClosed if the job is rejected
+func (r *JobRunner) Submit(ctx context.Context, userID string, items []JobItem) (Job, error) {
+	if len(items) == 0 || len(items) > r.maxItems {
+		return Job{}, fmt.Errorf("%w: from 1 to %d items are expected", ErrInvalidJob, r.maxItems)
+	}
+
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	if r.closed {
+		return Job{}, ErrJobRunnerClosed
+	}
+	if len(r.queue) == cap(r.queue) {
+		return Job{}, ErrJobQueueFull
+	}
+
+	now := time.Now().UTC()
+	job := Job{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Status:    JobQueued,
+		Total:     len(items),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := r.storage.CreateJob(ctx, job, items); err != nil {
+		return Job{}, err
+	}
+	select {
+	case r.queue <- job.ID:
+		return job, nil
+	default:
+		// the place is taken by the resumed job
+		if _, err := r.storage.SetJobStatus(ctx, job.ID, []string{JobQueued}, JobFailed, ErrJobQueueFull.Error()); err != nil {
+			r.logger.Error(fmt.Sprintf("job runner: job %s is left queued: %s", job.ID, err.Error()))
+		}
+		return Job{}, ErrJobQueueFull
+	}
+}
+
+// Resume enqueues the unfinished jobs of the previous run.
+// The jobs which do not fit the queue wait in the background for the free place
+func (r *JobRunner) Resume(ctx context.Context) error {
+	ids, err := r.storage.UnfinishedJobIDs(ctx)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	r.logger.Info(fmt.Sprintf("job runner: %d unfinished jobs are resumed", len(ids)))
+	go func() {
+		for _, id := range ids {
+			select {
+			case r.queue <- id:
+			case <-r.stopCh:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Get returns the job of the user
+func (r *JobRunner) Get(ctx context.Context, id string, userID string) (Job, error) {
+	job, err := r.storage.GetJob(ctx, id)
+	if err != nil {
+		return job, err
+	}
+	// foreign jobs are reported as absent, not to disclose them
+	if job.UserID != userID {
+		return Job{}, fmt.Errorf("%w: id %s", ErrJobNotFound, id)
+	}
+	return job, nil
+}
+
+// Results returns the results of the processed items of the user job
+func (r *JobRunner) Results(ctx context.Context, id string, userID string) ([]JobResult, error) {
+	if _, err := r.Get(ctx, id, userID); err != nil {
+		return nil, err
+	}
+	return r.storage.JobResults(ctx, id)
+}
+
+// Cancel stops the user job after the current chunk, the results of the processed items are kept.
+// Returns ErrJobFinished if the job is already finished
+func (r *JobRunner) Cancel(ctx context.Context, id string, userID string) (Job, error) {
+	job, err := r.Get(ctx, id, userID)
+	if err != nil {
+		return job, err
+	}
+	if job.IsFinished() {
+		return job, ErrJobFinished
+	}
+	canceled, err := r.storage.SetJobStatus(ctx, id, []string{JobQueued, JobRunning}, JobCanceled, "")
+	if err != nil {
+		return job, err
+	}
+	job, err = r.storage.GetJob(ctx, id)
+	if err == nil && !canceled {
+		err = ErrJobFinished
+	}
+	return job, err
+}
+
+// Close stops the workers after their current chunks and waits for them or until ctx is done.
+// The interrupted jobs are queued again, so the persistent storage resumes them on start
+func (r *JobRunner) Close(ctx context.Context) error {
+	r.mtx.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.stopCh)
+	}
+	r.mtx.Unlock()
+
+	doneCh := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(doneCh)
+	}()
+	select {
+	case <-doneCh:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("job runner close: %w", ctx.Err())
+	}
+}
+
+func (r *JobRunner) work() {
+	defer r.wg.Done()
+	for {
+		select {
+		case <-r.stopCh:
+			return
+		case id := <-r.queue:
+			if err := r.process(id); err != nil {
+				r.logger.Error(fmt.Sprintf("job runner: job %s is failed: %s", id, err.Error()))
+				r.setStatus(id, []string{JobRunning}, JobFailed, "shortener service error")
+			}
+		}
+	}
+}
+
+// process shortens the items of the job chunk by chunk until it is done, canceled or the runner is stopped
+func (r *JobRunner) process(id string) error {
+	// running job is left by the crashed run
+	if !r.setStatus(id, []string{JobQueued, JobRunning}, JobRunning, "") {
+		return nil
+	}
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), jobCallTimeout)
+		job, err := r.storage.GetJob(ctx, id)
+		cancel()
+		if err != nil {
+			return err
+		}
+		if job.Status != JobRunning {
+			return nil
+		}
+		if job.Processed >= job.Total {
+			r.setStatus(id, []string{JobRunning}, JobDone, "")
+			return nil
+		}
+		select {
+		case <-r.stopCh:
+			r.setStatus(id, []string{JobRunning}, JobQueued, "")
+			return nil
+		default:
+		}
+
+		if err = r.processChunk(job); err != nil {
+			return err
+		}
+	}
+}
+
+// processChunk shortens the next chunk of the job items and saves the results
+func (r *JobRunner) processChunk(job Job) error {
+	ctx, cancel := context.WithTimeout(context.Background(), jobCallTimeout)
+	defer cancel()
+
+	items, err := r.storage.JobItems(ctx, job.ID, job.Processed, r.chunkSize)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return fmt.Errorf("no items from %d of %d", job.Processed, job.Total)
+	}
+	batch := make([]BatchItem, 0, len(items))
+	for _, item := range items {
+		batch = append(batch, item.BatchItem())
+	}
+
+	saved, errs, err := r.shortener.ShortenURLBatchPartial(ctx, job.UserID, batch)
+	if err != nil {
+		return err
+	}
+	results := make([]JobResult, 0, len(items))
+	for i, item := range items {
+		result := JobResult{CorrelationID: item.CorrelationID}
+		if errs[i] != nil {
+			result.Error = errs[i].Error()
+		} else {
+			result.ShortCode = saved[i].ShortCode
+			result.Duplicate = saved[i].Duplicate
+		}
+		results = append(results, result)
+	}
+	return r.storage.SaveJobResults(ctx, job.ID, job.Processed, results)
+}
+
+// setStatus changes the status of the job, reports whether it is changed
+func (r *JobRunner) setStatus(id string, from []string, to string, reason string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), jobCallTimeout)
+	defer cancel()
+	changed, err := r.storage.SetJobStatus(ctx, id, from, to, reason)
+	if err != nil {
+		r.logger.Error(fmt.Sprintf("job runner: status %s of the job %s is not saved: %s", to, id, err.Error()))
+	}
+	return changed
+}
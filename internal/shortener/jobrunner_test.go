@@ -0,0 +1,239 @@
+package shortener_test
+
+import (
+	"context"
+	"errors"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/itksb/go-url-shortener/internal/storage"
+	"github.com/itksb/go-url-shortener/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// blockingShortener waits for the release before every chunk
+type blockingShortener struct {
+	shortener.JobShortener
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingShortener) ShortenURLBatchPartial(ctx context.Context, userID string, items []shortener.BatchItem) ([]shortener.BatchResult, []error, error) {
+	s.started <- struct{}{}
+	<-s.release
+	return s.JobShortener.ShortenURLBatchPartial(ctx, userID, items)
+}
+
+func newBlockingShortener(l logger.Interface) *blockingShortener {
+	repo := storage.NewStorage(l, shortener.NewSequenceGenerator(shortener.Base62Alphabet, 0))
+	return &blockingShortener{
+		JobShortener: shortener.NewShortener(l, repo),
+		started:      make(chan struct{}),
+		release:      make(chan struct{}),
+	}
+}
+
+func waitJob(t *testing.T, runner *shortener.JobRunner, id string, status string) shortener.Job {
+	var job shortener.Job
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = runner.Get(context.Background(), id, "owner")
+		require.NoError(t, err)
+		return job.Status == status
+	}, time.Second, 5*time.Millisecond)
+	return job
+}
+
+func TestJobRunner_Done(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+
+	repo := storage.NewStorage(l, shortener.NewSequenceGenerator(shortener.Base62Alphabet, 0))
+	service := shortener.NewShortener(l, repo)
+	runner := shortener.NewJobRunner(l, service, storage.NewJobStorage(), 2, 10, 2, 100)
+	service.UseJobs(runner)
+	defer runner.Close(context.Background())
+
+	ctx := context.Background()
+	existing, err := service.ShortenURL(ctx, "https://example.com/existing", "owner", shortener.SaveOptions{})
+	require.NoError(t, err)
+
+	job, err := service.SubmitJob(ctx, "owner", []shortener.JobItem{
+		{CorrelationID: "1", OriginalURL: "https://example.com/1"},
+		{CorrelationID: "2", OriginalURL: ""},
+		{CorrelationID: "3", OriginalURL: "https://example.com/existing"},
+		{CorrelationID: "4", OriginalURL: "https://example.com/4", Alias: "bad alias"},
+		{CorrelationID: "5", OriginalURL: "https://example.com/5", Tags: []string{"work"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, shortener.JobQueued, job.Status)
+	assert.Equal(t, 5, job.Total)
+
+	job = waitJob(t, runner, job.ID, shortener.JobDone)
+	assert.Equal(t, 5, job.Processed)
+	assert.Equal(t, 2, job.Failed)
+
+	results, err := service.JobResults(ctx, job.ID, "owner")
+	require.NoError(t, err)
+	require.Len(t, results, 5)
+	for i, result := range results {
+		assert.Equal(t, string(rune('1'+i)), result.CorrelationID)
+	}
+	assert.NotEmpty(t, results[0].ShortCode)
+	assert.NotEmpty(t, results[1].Error)
+	assert.Equal(t, shortener.JobResult{CorrelationID: "3", ShortCode: existing, Duplicate: true}, results[2])
+	assert.Contains(t, results[3].Error, shortener.ErrInvalidAlias.Error())
+	assert.NotEmpty(t, results[4].ShortCode)
+
+	// foreign jobs are not visible
+	_, err = service.GetJob(ctx, job.ID, "stranger")
+	assert.ErrorIs(t, err, shortener.ErrJobNotFound)
+	_, err = service.JobResults(ctx, job.ID, "stranger")
+	assert.ErrorIs(t, err, shortener.ErrJobNotFound)
+	_, err = service.CancelJob(ctx, job.ID, "owner")
+	assert.ErrorIs(t, err, shortener.ErrJobFinished)
+}
+
+func TestJobRunner_Cancel(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+
+	blocking := newBlockingShortener(l)
+	runner := shortener.NewJobRunner(l, blocking, storage.NewJobStorage(), 1, 10, 1, 100)
+	defer runner.Close(context.Background())
+
+	ctx := context.Background()
+	job, err := runner.Submit(ctx, "owner", []shortener.JobItem{
+		{OriginalURL: "https://example.com/1"},
+		{OriginalURL: "https://example.com/2"},
+		{OriginalURL: "https://example.com/3"},
+	})
+	require.NoError(t, err)
+
+	<-blocking.started
+	job, err = runner.Cancel(ctx, job.ID, "owner")
+	require.NoError(t, err)
+	assert.Equal(t, shortener.JobCanceled, job.Status)
+	// the current chunk is finished, the next ones are not started
+	blocking.release <- struct{}{}
+	require.Eventually(t, func() bool {
+		job, err = runner.Get(ctx, job.ID, "owner")
+		return err == nil && job.Processed == 1
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, shortener.JobCanceled, job.Status)
+
+	results, err := runner.Results(ctx, job.ID, "owner")
+	require.NoError(t, err)
+	assert.Len(t, results, 1)
+	_, err = runner.Cancel(ctx, job.ID, "owner")
+	assert.ErrorIs(t, err, shortener.ErrJobFinished)
+	_, err = runner.Cancel(ctx, job.ID, "stranger")
+	assert.ErrorIs(t, err, shortener.ErrJobNotFound)
+}
+
+func TestJobRunner_CloseResumes(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+
+	jobStorage := storage.NewJobStorage()
+	blocking := newBlockingShortener(l)
+	runner := shortener.NewJobRunner(l, blocking, jobStorage, 1, 10, 1, 100)
+
+	ctx := context.Background()
+	job, err := runner.Submit(ctx, "owner", []shortener.JobItem{
+		{OriginalURL: "https://example.com/1"},
+		{OriginalURL: "https://example.com/2"},
+		{OriginalURL: "https://example.com/3"},
+	})
+	require.NoError(t, err)
+
+	<-blocking.started
+	closed := make(chan error)
+	go func() { closed <- runner.Close(ctx) }()
+	// the chunk is finished after the runner is stopped
+	require.Eventually(t, func() bool {
+		_, err = runner.Submit(ctx, "owner", []shortener.JobItem{{OriginalURL: "https://example.com/4"}})
+		return errors.Is(err, shortener.ErrJobRunnerClosed)
+	}, time.Second, 5*time.Millisecond)
+	blocking.release <- struct{}{}
+	require.NoError(t, <-closed)
+
+	job, err = runner.Get(ctx, job.ID, "owner")
+	require.NoError(t, err)
+	assert.Equal(t, shortener.JobQueued, job.Status)
+	assert.Equal(t, 1, job.Processed)
+
+	// the next run continues from the saved progress
+	resumed := shortener.NewJobRunner(l, blocking.JobShortener, jobStorage, 1, 10, 1, 100)
+	defer resumed.Close(ctx)
+	require.NoError(t, resumed.Resume(ctx))
+	job = waitJob(t, resumed, job.ID, shortener.JobDone)
+	assert.Equal(t, 3, job.Processed)
+	results, err := resumed.Results(ctx, job.ID, "owner")
+	require.NoError(t, err)
+	assert.Len(t, results, 3)
+}
+
+func TestJobRunner_Rejects(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+
+	repo := storage.NewStorage(l, shortener.NewSequenceGenerator(shortener.Base62Alphabet, 0))
+	// no workers, the queue is never drained
+	runner := shortener.NewJobRunner(l, shortener.NewShortener(l, repo), storage.NewJobStorage(), 0, 1, 10, 2)
+
+	ctx := context.Background()
+	item := shortener.JobItem{OriginalURL: "https://example.com"}
+	_, err = runner.Submit(ctx, "owner", nil)
+	assert.ErrorIs(t, err, shortener.ErrInvalidJob)
+	_, err = runner.Submit(ctx, "owner", []shortener.JobItem{item, item, item})
+	assert.ErrorIs(t, err, shortener.ErrInvalidJob)
+
+	_, err = runner.Submit(ctx, "owner", []shortener.JobItem{item})
+	require.NoError(t, err)
+	_, err = runner.Submit(ctx, "owner", []shortener.JobItem{item})
+	assert.ErrorIs(t, err, shortener.ErrJobQueueFull)
+
+	require.NoError(t, runner.Close(ctx))
+	_, err = runner.Submit(ctx, "owner", []shortener.JobItem{item})
+	assert.ErrorIs(t, err, shortener.ErrJobRunnerClosed)
+
+	_, err = shortener.NewShortener(l, repo).SubmitJob(ctx, "owner", []shortener.JobItem{item})
+	assert.ErrorIs(t, err, shortener.ErrJobsDisabled)
+}
+
+func TestService_SubmitJobHashesPassword(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+
+	repo := storage.NewStorage(l, shortener.NewSequenceGenerator(shortener.Base62Alphabet, 0))
+	service := shortener.NewShortener(l, repo)
+	service.UsePasswordCost(bcrypt.MinCost)
+	jobStorage := storage.NewJobStorage()
+	// no workers, the persisted items stay as submitted
+	runner := shortener.NewJobRunner(l, service, jobStorage, 0, 10, 10, 100)
+	service.UseJobs(runner)
+
+	ctx := context.Background()
+	job, err := service.SubmitJob(ctx, "owner", []shortener.JobItem{
+		{OriginalURL: "https://example.com/1", Password: "secret"},
+		{OriginalURL: "https://example.com/2"},
+	})
+	require.NoError(t, err)
+
+	items, err := jobStorage.JobItems(ctx, job.ID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	assert.Empty(t, items[0].Password)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(items[0].PasswordHash), []byte("secret")))
+	assert.Empty(t, items[1].PasswordHash)
+
+	_, err = service.SubmitJob(ctx, "owner", []shortener.JobItem{
+		{OriginalURL: "https://example.com/3", Password: strings.Repeat("x", shortener.MaxPasswordLength+1)},
+	})
+	assert.ErrorIs(t, err, shortener.ErrInvalidJob)
+	require.NoError(t, runner.Close(ctx))
+}
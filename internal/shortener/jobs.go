@@ -0,0 +1,108 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// statuses of the shortening job
+const (
+	JobQueued   = "queued"   // waiting for a worker
+	JobRunning  = "running"  // items are being shortened
+	JobDone     = "done"     // all items are processed, some of them may be failed
+	JobFailed   = "failed"   // stopped by the storage error, see Job.Error
+	JobCanceled = "canceled" // stopped by the user
+)
+
+// ErrJobNotFound - there is no job with the id or it belongs to another user
+var ErrJobNotFound = errors.New(`job not found`)
+
+// ErrJobFinished - the job can not be canceled, because it is already finished
+var ErrJobFinished = errors.New(`job is already finished`)
+
+// ErrInvalidJob - the job has no items or too many of them
+var ErrInvalidJob = errors.New(`invalid job`)
+
+// ErrJobsDisabled - asynchronous jobs are not configured
+var ErrJobsDisabled = errors.New(`jobs are disabled`)
+
+// Job - asynchronous shortening of the batch of urls
+type Job struct {
+	ID        string    `db:"id"`
+	UserID    string    `db:"user_id"`
+	Status    string    `db:"status"`
+	Total     int       `db:"total"`     // count of items
+	Processed int       `db:"processed"` // count of items with results, they are processed in order
+	Failed    int       `db:"failed"`    // count of processed items with errors
+	Error     string    `db:"error"`     // reason of the failed status
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
+// IsFinished reports whether the job will not be processed anymore
+func (job Job) IsFinished() bool {
+	return job.Status == JobDone || job.Status == JobFailed || job.Status == JobCanceled
+}
+
+// JobItem - url of the job with its own options
+type JobItem struct {
+	CorrelationID string     `json:"correlation_id,omitempty"`
+	OriginalURL   string     `json:"original_url"`
+	Alias         string     `json:"alias,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	MaxClicks     int64      `json:"max_clicks,omitempty"`
+	Tags          []string   `json:"tags,omitempty"`
+	Password      string     `json:"-"` // hashed by Service.SubmitJob, never persisted
+	PasswordHash  string     `json:"password_hash,omitempty"`
+	RedirectCode  int        `json:"redirect_code,omitempty"`
+	PassQuery     bool       `json:"pass_query,omitempty"`
+	Title         string     `json:"title,omitempty"`
+	Preview       bool       `json:"preview,omitempty"`
+}
+
+// BatchItem returns the item to shorten
+func (item JobItem) BatchItem() BatchItem {
+	return BatchItem{
+		OriginalURL: item.OriginalURL,
+		Opts: SaveOptions{
+			Alias:        item.Alias,
+			ExpiresAt:    item.ExpiresAt,
+			MaxClicks:    item.MaxClicks,
+			Tags:         item.Tags,
+			PasswordHash: item.PasswordHash,
+			RedirectCode: item.RedirectCode,
+			PassQuery:    item.PassQuery,
+			Title:        item.Title,
+			Preview:      item.Preview,
+		},
+	}
+}
+
+// JobResult - result of the job item, ShortCode is empty if Error is set
+type JobResult struct {
+	CorrelationID string `db:"correlation_id"`
+	ShortCode     string `db:"short_code"`
+	Duplicate     bool   `db:"duplicate"`
+	Error         string `db:"error"`
+}
+
+// JobStorage - persists jobs with their items and results
+type JobStorage interface {
+	// CreateJob persists the new job with its items
+	CreateJob(ctx context.Context, job Job, items []JobItem) error
+	// GetJob returns ErrJobNotFound if there is no job with the id
+	GetJob(ctx context.Context, id string) (Job, error)
+	// JobItems returns up to limit items of the job starting from the offset
+	JobItems(ctx context.Context, id string, offset int, limit int) ([]JobItem, error)
+	// SaveJobResults stores the results of the items starting from the offset and updates the progress of the job.
+	// Saving the same results again changes nothing
+	SaveJobResults(ctx context.Context, id string, offset int, results []JobResult) error
+	// JobResults returns the results of the processed items in the order of items
+	JobResults(ctx context.Context, id string) ([]JobResult, error)
+	// SetJobStatus changes the status of the job if it is one of the from statuses, reports whether it is changed.
+	// The unknown job is not changed
+	SetJobStatus(ctx context.Context, id string, from []string, to string, reason string) (bool, error)
+	// UnfinishedJobIDs returns the queued and running jobs, the oldest first
+	UnfinishedJobIDs(ctx context.Context) ([]string, error)
+}
@@ -0,0 +1,142 @@
+package shortener
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+)
+
+// sort fields of the url listing
+const (
+	SortCreatedAt   = "created_at"
+	SortOriginalURL = "original_url"
+)
+
+// ErrInvalidCursor - the cursor is malformed or made for another sort
+var ErrInvalidCursor = errors.New(`invalid cursor`)
+
+// ListOptions - page of the user urls
+type ListOptions struct {
+	Limit          int         // max count of urls in the page
+	Cursor         *ListCursor // the page starts after it, nil means the first page
+	Sort           string      // SortCreatedAt|SortOriginalURL
+	Desc           bool        // descending order
+	Query          string      // case-insensitive substring of the original url
+	IncludeDeleted bool        // list deleted urls too
+	OnlyDeleted    bool        // list deleted urls only, the trash of the user
+	Tag            string      // list urls tagged by the user with the tag only
+}
+
+// ListCursor - position in the listing: sort key of the last url of the page and its id as a tie-breaker
+type ListCursor struct {
+	Sort string `json:"s"`
+	Desc bool   `json:"d,omitempty"`
+	Key  string `json:"k"`
+	ID   int64  `json:"i"`
+}
+
+// URLPage - page of the user urls
+type URLPage struct {
+	Items      []URLListItem
+	NextCursor *ListCursor // nil for the last page
+}
+
+// EncodeCursor returns the opaque representation of the cursor
+func EncodeCursor(cursor ListCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses the cursor made by EncodeCursor
+func DecodeCursor(s string) (ListCursor, error) {
+	cursor := ListCursor{}
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor, ErrInvalidCursor
+	}
+	if err = json.Unmarshal(data, &cursor); err != nil {
+		return cursor, ErrInvalidCursor
+	}
+	if cursor.Sort != SortCreatedAt && cursor.Sort != SortOriginalURL {
+		return cursor, ErrInvalidCursor
+	}
+	return cursor, nil
+}
+
+// SortKey returns the value of the item used for the sort
+func (item URLListItem) SortKey(sortField string) string {
+	if sortField == SortOriginalURL {
+		return item.OriginalURL
+	}
+	return item.CreatedAt
+}
+
+// PageURLs filters, sorts and cuts the page of the user urls in memory.
+// Used by the storages without query engine, items without creation time are ordered by id
+func PageURLs(items []URLListItem, opts ListOptions) URLPage {
+	query := strings.ToLower(opts.Query)
+	filtered := make([]URLListItem, 0, len(items))
+	for _, item := range items {
+		if !opts.IncludeDeleted && !opts.OnlyDeleted && item.IsDeleted() {
+			continue
+		}
+		if opts.OnlyDeleted && !item.IsDeleted() {
+			continue
+		}
+		if query != "" && !strings.Contains(strings.ToLower(item.OriginalURL), query) {
+			continue
+		}
+		if opts.Tag != "" && !item.HasTag(opts.Tag) {
+			continue
+		}
+		filtered = append(filtered, item)
+	}
+
+	less := func(a, b URLListItem) bool {
+		keyA, keyB := a.SortKey(opts.Sort), b.SortKey(opts.Sort)
+		if keyA != keyB {
+			return keyA < keyB
+		}
+		return a.ID < b.ID
+	}
+	sort.Slice(filtered, func(i, j int) bool {
+		if opts.Desc {
+			return less(filtered[j], filtered[i])
+		}
+		return less(filtered[i], filtered[j])
+	})
+
+	if opts.Cursor != nil {
+		last := URLListItem{ID: opts.Cursor.ID}
+		if opts.Sort == SortOriginalURL {
+			last.OriginalURL = opts.Cursor.Key
+		} else {
+			last.CreatedAt = opts.Cursor.Key
+		}
+		start := sort.Search(len(filtered), func(i int) bool {
+			if opts.Desc {
+				return less(filtered[i], last)
+			}
+			return less(last, filtered[i])
+		})
+		filtered = filtered[start:]
+	}
+
+	page := URLPage{Items: filtered}
+	if opts.Limit > 0 && len(filtered) > opts.Limit {
+		page.Items = filtered[:opts.Limit]
+		page.NextCursor = NextCursor(page.Items, opts)
+	}
+	return page
+}
+
+// NextCursor returns the cursor pointing after the last item of the page
+func NextCursor(items []URLListItem, opts ListOptions) *ListCursor {
+	if len(items) == 0 {
+		return nil
+	}
+	last := items[len(items)-1]
+	return &ListCursor{Sort: opts.Sort, Desc: opts.Desc, Key: last.SortKey(opts.Sort), ID: last.ID}
+}
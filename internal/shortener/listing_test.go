@@ -0,0 +1,58 @@
+package shortener
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestPageURLs(t *testing.T) {
+	deletedAt := "2023-03-01T10:00:00"
+	var items []URLListItem
+	for i, url := range []string{"https://b.example/x", "https://a.example/y", "https://c.example/x", "https://d.example/x"} {
+		items = append(items, URLListItem{ID: int64(i + 1), OriginalURL: url})
+	}
+	items[3].DeletedAt = &deletedAt
+
+	collect := func(opts ListOptions) []int64 {
+		var ids []int64
+		for {
+			page := PageURLs(items, opts)
+			for _, item := range page.Items {
+				ids = append(ids, item.ID)
+			}
+			if page.NextCursor == nil {
+				return ids
+			}
+			opts.Cursor = page.NextCursor
+		}
+	}
+
+	tests := []struct {
+		opts ListOptions
+		want []int64
+	}{
+		{opts: ListOptions{Limit: 1, Sort: SortCreatedAt}, want: []int64{1, 2, 3}},
+		{opts: ListOptions{Limit: 2, Sort: SortCreatedAt, Desc: true, IncludeDeleted: true}, want: []int64{4, 3, 2, 1}},
+		{opts: ListOptions{Limit: 2, Sort: SortOriginalURL}, want: []int64{2, 1, 3}},
+		{opts: ListOptions{Limit: 1, Sort: SortOriginalURL, Desc: true, Query: "/X"}, want: []int64{3, 1}},
+		{opts: ListOptions{Sort: SortCreatedAt, Query: "%"}, want: nil},
+		{opts: ListOptions{Sort: SortCreatedAt, OnlyDeleted: true}, want: []int64{4}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%+v", tt.opts), func(t *testing.T) {
+			assert.Equal(t, tt.want, collect(tt.opts))
+		})
+	}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	cursor := ListCursor{Sort: SortOriginalURL, Desc: true, Key: "https://example.com", ID: 42}
+	decoded, err := DecodeCursor(EncodeCursor(cursor))
+	require.NoError(t, err)
+	assert.Equal(t, cursor, decoded)
+
+	_, err = DecodeCursor("not a cursor")
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
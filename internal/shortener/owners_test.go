@@ -0,0 +1,67 @@
+package shortener_test
+
+import (
+	"context"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/itksb/go-url-shortener/internal/storage"
+	"github.com/itksb/go-url-shortener/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestService_SharedURL(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+
+	service := shortener.NewShortener(l, storage.NewStorage(l, shortener.NewSequenceGenerator(shortener.Base62Alphabet, 0)))
+	ctx := context.Background()
+	url := "https://shared.example.com"
+
+	code, err := service.ShortenURL(ctx, url, "user1", shortener.SaveOptions{})
+	require.NoError(t, err)
+	duplicate, err := service.ShortenURL(ctx, url, "user2", shortener.SaveOptions{})
+	assert.ErrorIs(t, err, shortener.ErrDuplicate)
+	assert.Equal(t, code, duplicate)
+
+	for _, userID := range []string{"user1", "user2"} {
+		page, err := service.ListURLs(ctx, userID, shortener.ListOptions{})
+		require.NoError(t, err)
+		require.Len(t, page.Items, 1, userID)
+		assert.Equal(t, code, page.Items[0].ShortCode)
+		owner, err := service.IsURLOwner(ctx, code, userID)
+		require.NoError(t, err)
+		assert.True(t, owner, userID)
+	}
+	owner, err := service.IsURLOwner(ctx, code, "user3")
+	require.NoError(t, err)
+	assert.False(t, owner)
+
+	// the url stays available while another owner keeps it
+	require.NoError(t, service.DeleteURLBatch(ctx, "user1", []string{code}))
+	item, err := service.GetURL(ctx, code)
+	require.NoError(t, err)
+	assert.False(t, item.IsDeleted())
+	page, err := service.ListURLs(ctx, "user1", shortener.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, page.Items)
+	page, err = service.ListURLs(ctx, "user1", shortener.ListOptions{IncludeDeleted: true})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.True(t, page.Items[0].Deleted)
+
+	// foreign users can not delete it
+	require.NoError(t, service.DeleteURLBatch(ctx, "user3", []string{code}))
+	item, err = service.GetURL(ctx, code)
+	require.NoError(t, err)
+	assert.False(t, item.IsDeleted())
+
+	require.NoError(t, service.DeleteURLBatch(ctx, "user2", []string{code}))
+	item, err = service.GetURL(ctx, code)
+	require.NoError(t, err)
+	assert.True(t, item.IsDeleted())
+
+	users, err := service.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), users.Users)
+}
@@ -0,0 +1,38 @@
+package shortener
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/bcrypt"
+	"time"
+)
+
+// MaxPasswordLength - max length of the link password in bytes, bcrypt ignores the rest
+const MaxPasswordLength = 72
+
+// defaults of the password attempts limit
+const (
+	DefaultPasswordAttempts = 5
+	DefaultPasswordWindow   = 15 * time.Minute
+)
+
+var (
+	// ErrInvalidPassword - password of the link is not valid
+	ErrInvalidPassword = errors.New(`invalid password`)
+	// ErrWrongPassword - password does not match the one of the link
+	ErrWrongPassword = errors.New(`wrong password`)
+	// ErrTooManyAttempts - wrong passwords of the link are entered too often
+	ErrTooManyAttempts = errors.New(`too many password attempts`)
+)
+
+// hashPassword - bcrypt hash of the link password of the cost
+func hashPassword(password string, cost int) (string, error) {
+	if len(password) > MaxPasswordLength {
+		return "", fmt.Errorf("%w: longer than %d bytes", ErrInvalidPassword, MaxPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		return "", fmt.Errorf("password hash error: %w", err)
+	}
+	return string(hash), nil
+}
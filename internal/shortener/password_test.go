@@ -0,0 +1,89 @@
+package shortener_test
+
+import (
+	"context"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/itksb/go-url-shortener/internal/storage"
+	"github.com/itksb/go-url-shortener/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestService_ShortenURL_Password(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+
+	service := shortener.NewShortener(l, storage.NewStorage(l, shortener.NewSequenceGenerator(shortener.Base62Alphabet, 0)))
+	ctx := context.Background()
+
+	public, err := service.ShortenURL(ctx, "https://example.com/doc", "user1", shortener.SaveOptions{})
+	require.NoError(t, err)
+	protected, err := service.ShortenURL(ctx, "https://example.com/doc", "user1", shortener.SaveOptions{Password: "secret"})
+	require.NoError(t, err, "the protected url is not the duplicate of the public one")
+	other, err := service.ShortenURL(ctx, "https://example.com/doc", "user2", shortener.SaveOptions{Password: "secret"})
+	require.NoError(t, err, "the protected urls are never duplicates")
+	assert.NotEqual(t, public, protected)
+	assert.NotEqual(t, protected, other)
+
+	// the public url is still found as the duplicate
+	duplicate, err := service.ShortenURL(ctx, "https://example.com/doc", "user2", shortener.SaveOptions{})
+	assert.ErrorIs(t, err, shortener.ErrDuplicate)
+	assert.Equal(t, public, duplicate)
+
+	item, err := service.GetURL(ctx, protected)
+	require.NoError(t, err)
+	assert.True(t, item.IsProtected())
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(item.PasswordHash), []byte("secret")))
+	item, err = service.GetURL(ctx, public)
+	require.NoError(t, err)
+	assert.False(t, item.IsProtected())
+
+	_, err = service.ShortenURL(ctx, "https://example.com/long", "user1", shortener.SaveOptions{Password: strings.Repeat("x", 73)})
+	assert.ErrorIs(t, err, shortener.ErrInvalidPassword)
+
+	// the changed protected url stays unique
+	require.NoError(t, service.UpdateURL(ctx, protected, "user1", "https://example.com/other"))
+	_, err = service.ShortenURL(ctx, "https://example.com/other", "user1", shortener.SaveOptions{})
+	assert.NoError(t, err)
+
+	results, err := service.ShortenURLBatch(ctx, "user1", []shortener.BatchItem{
+		{OriginalURL: "https://example.com/doc", Opts: shortener.SaveOptions{Password: "secret"}},
+		{OriginalURL: "https://example.com/doc"},
+	})
+	require.NoError(t, err)
+	assert.False(t, results[0].Duplicate)
+	assert.Equal(t, shortener.BatchResult{ShortCode: public, Duplicate: true}, results[1])
+}
+
+func TestService_CheckURLPassword(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+
+	service := shortener.NewShortener(l, storage.NewStorage(l, shortener.NewSequenceGenerator(shortener.Base62Alphabet, 0)))
+	service.UsePasswordLimiter(shortener.NewPasswordLimiter(2, time.Hour))
+	ctx := context.Background()
+
+	code, err := service.ShortenURL(ctx, "https://example.com/doc", "user1", shortener.SaveOptions{Password: "secret"})
+	require.NoError(t, err)
+	item, err := service.GetURL(ctx, code)
+	require.NoError(t, err)
+	otherCode, err := service.ShortenURL(ctx, "https://example.com/other", "user1", shortener.SaveOptions{Password: "secret"})
+	require.NoError(t, err)
+	other, err := service.GetURL(ctx, otherCode)
+	require.NoError(t, err)
+
+	assert.NoError(t, service.CheckURLPassword(shortener.URLListItem{ShortCode: "public"}, ""))
+	// the right passwords are not counted
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, service.CheckURLPassword(item, "secret"))
+	}
+	assert.ErrorIs(t, service.CheckURLPassword(item, "guess1"), shortener.ErrWrongPassword)
+	assert.ErrorIs(t, service.CheckURLPassword(item, "guess2"), shortener.ErrWrongPassword)
+	assert.ErrorIs(t, service.CheckURLPassword(item, "secret"), shortener.ErrTooManyAttempts)
+	// the attempts are counted per link
+	assert.NoError(t, service.CheckURLPassword(other, "secret"))
+}
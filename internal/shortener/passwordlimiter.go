@@ -0,0 +1,67 @@
+package shortener
+
+import (
+	"sync"
+	"time"
+)
+
+// passwordAttempts - attempts of the link in the current window
+type passwordAttempts struct {
+	count   int
+	resetAt time.Time
+}
+
+// PasswordLimiter - limits the wrong password attempts per link within the window
+type PasswordLimiter struct {
+	maxAttempts int
+	window      time.Duration
+
+	mtx      sync.Mutex
+	attempts map[string]*passwordAttempts // by the short code
+	sweepAt  time.Time
+}
+
+// NewPasswordLimiter - constructor. maxAttempts wrong passwords are allowed within the window
+func NewPasswordLimiter(maxAttempts int, window time.Duration) *PasswordLimiter {
+	return &PasswordLimiter{
+		maxAttempts: maxAttempts,
+		window:      window,
+		attempts:    make(map[string]*passwordAttempts),
+	}
+}
+
+// take counts the attempt of the link, returns false and the time left until the end of the window
+// if the attempts are exhausted. The counted attempt is returned by forgive if the password is right
+func (l *PasswordLimiter) take(code string, now time.Time) (time.Duration, bool) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if now.After(l.sweepAt) {
+		// the windows which are over are forgotten, so the map does not grow with the probed links
+		for key, a := range l.attempts {
+			if !now.Before(a.resetAt) {
+				delete(l.attempts, key)
+			}
+		}
+		l.sweepAt = now.Add(l.window)
+	}
+	a, ok := l.attempts[code]
+	if !ok || !now.Before(a.resetAt) {
+		a = &passwordAttempts{resetAt: now.Add(l.window)}
+		l.attempts[code] = a
+	}
+	if a.count >= l.maxAttempts {
+		return a.resetAt.Sub(now), false
+	}
+	a.count++
+	return 0, true
+}
+
+// forgive returns the attempt taken by the right password
+func (l *PasswordLimiter) forgive(code string) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if a, ok := l.attempts[code]; ok && a.count > 0 {
+		a.count--
+	}
+}
@@ -0,0 +1,34 @@
+package shortener
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestPasswordLimiter(t *testing.T) {
+	limiter := NewPasswordLimiter(2, time.Minute)
+	now := time.Date(2023, 5, 15, 10, 0, 0, 0, time.UTC)
+
+	_, ok := limiter.take("abc", now)
+	assert.True(t, ok)
+	limiter.forgive("abc")
+	_, ok = limiter.take("abc", now)
+	assert.True(t, ok)
+	_, ok = limiter.take("abc", now.Add(time.Second))
+	assert.True(t, ok)
+	retryAfter, ok := limiter.take("abc", now.Add(10*time.Second))
+	assert.False(t, ok)
+	assert.Equal(t, 50*time.Second, retryAfter)
+	_, ok = limiter.take("other", now.Add(10*time.Second))
+	assert.True(t, ok, "the attempts are counted per link")
+
+	// the window is over
+	_, ok = limiter.take("abc", now.Add(time.Minute))
+	assert.True(t, ok)
+
+	// the windows which are over are forgotten
+	_, ok = limiter.take("new", now.Add(3*time.Minute))
+	assert.True(t, ok)
+	assert.Len(t, limiter.attempts, 1)
+}
@@ -0,0 +1,46 @@
+package shortener
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TitleMaxLength - max length of the url title in runes
+const TitleMaxLength = 200
+
+// CreatedAtLayout - layout of the creation time set by the storages without the database.
+// The layout is of the fixed width, so the times are sorted as the strings
+const CreatedAtLayout = "2006-01-02T15:04:05.000000Z"
+
+// ErrInvalidTitle - title of the url does not pass validation
+var ErrInvalidTitle = errors.New(`invalid title`)
+
+// NormalizeTitle trims the title of the url, the empty title means no title
+func NormalizeTitle(title string) (string, error) {
+	title = strings.TrimSpace(title)
+	if utf8.RuneCountInString(title) > TitleMaxLength {
+		return "", fmt.Errorf("%w: longer than %d symbols", ErrInvalidTitle, TitleMaxLength)
+	}
+	for _, r := range title {
+		if !unicode.IsPrint(r) {
+			return "", fmt.Errorf("%w: symbol %q is not allowed", ErrInvalidTitle, r)
+		}
+	}
+	return title, nil
+}
+
+// CreatedTime - creation time of the url, ok is false if the time is unknown
+func (item URLListItem) CreatedTime() (time.Time, bool) {
+	if item.CreatedAt == "" {
+		return time.Time{}, false
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, item.CreatedAt)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return createdAt, true
+}
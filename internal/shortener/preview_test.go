@@ -0,0 +1,53 @@
+package shortener_test
+
+import (
+	"context"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/itksb/go-url-shortener/internal/storage"
+	"github.com/itksb/go-url-shortener/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeTitle(t *testing.T) {
+	title, err := shortener.NormalizeTitle("  Spring sale  ")
+	assert.NoError(t, err)
+	assert.Equal(t, "Spring sale", title)
+
+	title, err = shortener.NormalizeTitle(strings.Repeat("я", shortener.TitleMaxLength))
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Repeat("я", shortener.TitleMaxLength), title)
+
+	_, err = shortener.NormalizeTitle(strings.Repeat("я", shortener.TitleMaxLength+1))
+	assert.ErrorIs(t, err, shortener.ErrInvalidTitle)
+	_, err = shortener.NormalizeTitle("line\nbreak")
+	assert.ErrorIs(t, err, shortener.ErrInvalidTitle)
+}
+
+func TestService_ShortenURL_Preview(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+
+	service := shortener.NewShortener(l, storage.NewStorage(l, shortener.NewSequenceGenerator(shortener.Base62Alphabet, 0)))
+	ctx := context.Background()
+
+	before := time.Now().UTC().Truncate(time.Microsecond)
+	code, err := service.ShortenURL(ctx, "https://example.com/doc", "user1", shortener.SaveOptions{Title: " Docs ", Preview: true})
+	require.NoError(t, err)
+	item, err := service.GetURL(ctx, code)
+	require.NoError(t, err)
+	assert.Equal(t, "Docs", item.Title)
+	assert.True(t, item.Preview)
+	createdAt, ok := item.CreatedTime()
+	require.True(t, ok)
+	assert.False(t, createdAt.Before(before))
+
+	_, ok = shortener.URLListItem{}.CreatedTime()
+	assert.False(t, ok, "the legacy url has no creation time")
+
+	_, err = service.ShortenURL(ctx, "https://example.com/other", "user1", shortener.SaveOptions{Title: "tab\there"})
+	assert.ErrorIs(t, err, shortener.ErrInvalidTitle)
+}
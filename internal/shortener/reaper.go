@@ -0,0 +1,95 @@
+package shortener
+
+import (
+	"context"
+	"fmt"
+	"github.com/itksb/go-url-shortener/pkg/logger"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Reaper - background job which periodically marks expired urls as deleted
+// and permanently removes urls deleted longer than the retention period ago
+type Reaper struct {
+	logger    logger.Interface
+	service   *Service
+	interval  time.Duration
+	retention time.Duration // 0 keeps deleted urls forever
+
+	started  atomic.Bool
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// NewReaper - constructor. Zero retention disables the purge of deleted urls
+func NewReaper(l logger.Interface, service *Service, interval time.Duration, retention time.Duration) *Reaper {
+	return &Reaper{
+		logger:    l,
+		service:   service,
+		interval:  interval,
+		retention: retention,
+		stopCh:    make(chan struct{}),
+		doneCh:    make(chan struct{}),
+	}
+}
+
+// Start runs the reaper in the background. Repeated calls are ignored
+func (r *Reaper) Start() {
+	if !r.started.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer close(r.doneCh)
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stopCh:
+				return
+			case <-ticker.C:
+				r.reap()
+			}
+		}
+	}()
+}
+
+// Stop signals the reaper to stop and waits for the current pass to finish or ctx to expire
+func (r *Reaper) Stop(ctx context.Context) error {
+	r.stopOnce.Do(func() { close(r.stopCh) })
+	if !r.started.Load() {
+		return nil
+	}
+	select {
+	case <-r.doneCh:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("reaper stop: %w", ctx.Err())
+	}
+}
+
+func (r *Reaper) reap() {
+	ctx, cancel := context.WithTimeout(context.Background(), r.interval)
+	defer cancel()
+	count, err := r.service.DeleteExpiredURLs(ctx)
+	if err != nil {
+		r.logger.Error(fmt.Sprintf("reaper: delete expired urls error: %s", err.Error()))
+		return
+	}
+	if count > 0 {
+		r.logger.Info("reaper: expired urls deleted", count)
+	}
+
+	if r.retention <= 0 {
+		return
+	}
+	count, err = r.service.PurgeDeletedURLs(ctx, r.retention)
+	if err != nil {
+		r.logger.Error(fmt.Sprintf("reaper: purge deleted urls error: %s", err.Error()))
+		return
+	}
+	if count > 0 {
+		r.logger.Info("reaper: deleted urls purged", count)
+	}
+}
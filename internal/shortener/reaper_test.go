@@ -0,0 +1,57 @@
+package shortener_test
+
+import (
+	"context"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/itksb/go-url-shortener/internal/storage"
+	"github.com/itksb/go-url-shortener/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestReaper(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+
+	repo := storage.NewStorage(l, shortener.NewSequenceGenerator(shortener.Base62Alphabet, 0))
+	service := shortener.NewShortener(l, repo)
+	ctx := context.Background()
+
+	expiresAt := time.Now().Add(50 * time.Millisecond)
+	expiring, err := service.ShortenURL(ctx, "https://expiring.example.com", "user1", shortener.SaveOptions{
+		ExpiresAt: &expiresAt,
+	})
+	require.NoError(t, err)
+	forever, err := service.ShortenURL(ctx, "https://forever.example.com", "user1", shortener.SaveOptions{})
+	require.NoError(t, err)
+
+	reaper := shortener.NewReaper(l, service, 10*time.Millisecond, 0)
+	reaper.Start()
+
+	assert.Eventually(t, func() bool {
+		item, err := service.GetURL(ctx, expiring)
+		return err == nil && item.DeletedAt != nil
+	}, time.Second, 10*time.Millisecond)
+
+	stopCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	require.NoError(t, reaper.Stop(stopCtx))
+
+	item, err := service.GetURL(ctx, forever)
+	require.NoError(t, err)
+	assert.Nil(t, item.DeletedAt)
+}
+
+func TestService_ShortenURL_PastExpiration(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+
+	service := shortener.NewShortener(l, storage.NewStorage(l, shortener.NewSequenceGenerator(shortener.Base62Alphabet, 0)))
+	expiresAt := time.Now().Add(-time.Minute)
+	_, err = service.ShortenURL(context.Background(), "https://example.com", "user1", shortener.SaveOptions{
+		ExpiresAt: &expiresAt,
+	})
+	assert.ErrorIs(t, err, shortener.ErrInvalidExpiration)
+}
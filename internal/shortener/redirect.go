@@ -0,0 +1,89 @@
+package shortener
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultRedirectCode - status of the redirect if the url has no redirect code
+const DefaultRedirectCode = http.StatusTemporaryRedirect
+
+// RedirectCodes - statuses the url may be redirected with
+var RedirectCodes = []int{
+	http.StatusMovedPermanently,
+	http.StatusFound,
+	http.StatusTemporaryRedirect,
+	http.StatusPermanentRedirect,
+}
+
+// ErrInvalidRedirectCode - redirect code of the url is not one of RedirectCodes
+var ErrInvalidRedirectCode = errors.New(`invalid redirect code`)
+
+// ValidateRedirectCode returns ErrInvalidRedirectCode if the code is neither 0 nor one of RedirectCodes
+func ValidateRedirectCode(code int) error {
+	if code == 0 {
+		return nil
+	}
+	for _, allowed := range RedirectCodes {
+		if code == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %d, allowed %v", ErrInvalidRedirectCode, code, RedirectCodes)
+}
+
+// RedirectStatus - status the url is redirected with
+func (item URLListItem) RedirectStatus() int {
+	if item.RedirectCode == 0 {
+		return DefaultRedirectCode
+	}
+	return item.RedirectCode
+}
+
+// RedirectURL - url the request with the raw query is redirected to.
+// If the url passes the query, the parameters of the request are added to the original url
+// replacing its parameters of the same names, otherwise the original url is returned
+func (item URLListItem) RedirectURL(rawQuery string) string {
+	if !item.PassQuery || rawQuery == "" {
+		return item.OriginalURL
+	}
+	u, err := url.Parse(item.OriginalURL)
+	if err != nil {
+		return item.OriginalURL
+	}
+	u.RawQuery = mergeQuery(u.RawQuery, rawQuery)
+	return u.String()
+}
+
+// mergeQuery appends the raw query to the target one, the target parameters of the same names are dropped.
+// The parameters are not re-encoded, so their order and encoding are kept
+func mergeQuery(target string, rawQuery string) string {
+	passed := make(map[string]struct{})
+	for _, param := range strings.Split(rawQuery, "&") {
+		passed[queryKey(param)] = struct{}{}
+	}
+	merged := make([]string, 0)
+	for _, param := range strings.Split(target, "&") {
+		if _, ok := passed[queryKey(param)]; !ok && param != "" {
+			merged = append(merged, param)
+		}
+	}
+	for _, param := range strings.Split(rawQuery, "&") {
+		if param != "" {
+			merged = append(merged, param)
+		}
+	}
+	return strings.Join(merged, "&")
+}
+
+// queryKey - unescaped name of the raw query parameter
+func queryKey(param string) string {
+	key, _, _ := strings.Cut(param, "=")
+	if unescaped, err := url.QueryUnescape(key); err == nil {
+		return unescaped
+	}
+	return key
+}
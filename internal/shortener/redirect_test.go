@@ -0,0 +1,67 @@
+package shortener_test
+
+import (
+	"context"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/itksb/go-url-shortener/internal/storage"
+	"github.com/itksb/go-url-shortener/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func TestURLListItem_RedirectURL(t *testing.T) {
+	tests := []struct {
+		url       string
+		passQuery bool
+		query     string
+		want      string
+	}{
+		{url: "https://example.com/a?x=1", query: "utm_source=ad", want: "https://example.com/a?x=1"},
+		{url: "https://example.com/a?x=1", passQuery: true, want: "https://example.com/a?x=1"},
+		{url: "https://example.com/a", passQuery: true, query: "utm_source=ad", want: "https://example.com/a?utm_source=ad"},
+		{url: "https://example.com/a?x=1&utm_source=mail", passQuery: true, query: "utm_source=ad&y=2",
+			want: "https://example.com/a?x=1&utm_source=ad&y=2"},
+		{url: "https://example.com/a?q=a%20b#top", passQuery: true, query: "utm%5Fsource=ad&", want: "https://example.com/a?q=a%20b&utm%5Fsource=ad#top"},
+		{url: "https://example.com/a?tag=1&tag=2", passQuery: true, query: "tag=3", want: "https://example.com/a?tag=3"},
+	}
+	for _, tt := range tests {
+		item := shortener.URLListItem{OriginalURL: tt.url, PassQuery: tt.passQuery}
+		assert.Equal(t, tt.want, item.RedirectURL(tt.query), tt.url)
+	}
+}
+
+func TestURLListItem_RedirectStatus(t *testing.T) {
+	assert.Equal(t, http.StatusTemporaryRedirect, shortener.URLListItem{}.RedirectStatus())
+	assert.Equal(t, http.StatusMovedPermanently, shortener.URLListItem{RedirectCode: http.StatusMovedPermanently}.RedirectStatus())
+
+	assert.NoError(t, shortener.ValidateRedirectCode(0))
+	for _, code := range []int{301, 302, 307, 308} {
+		assert.NoError(t, shortener.ValidateRedirectCode(code))
+	}
+	for _, code := range []int{200, 300, 303, 304, 404} {
+		assert.ErrorIs(t, shortener.ValidateRedirectCode(code), shortener.ErrInvalidRedirectCode)
+	}
+}
+
+func TestService_ShortenURL_RedirectOptions(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+
+	service := shortener.NewShortener(l, storage.NewStorage(l, shortener.NewSequenceGenerator(shortener.Base62Alphabet, 0)))
+	ctx := context.Background()
+
+	code, err := service.ShortenURL(ctx, "https://example.com/a", "user1", shortener.SaveOptions{
+		RedirectCode: http.StatusPermanentRedirect,
+		PassQuery:    true,
+	})
+	require.NoError(t, err)
+	item, err := service.GetURL(ctx, code)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusPermanentRedirect, item.RedirectCode)
+	assert.True(t, item.PassQuery)
+
+	_, err = service.ShortenURL(ctx, "https://example.com/b", "user1", shortener.SaveOptions{RedirectCode: http.StatusSeeOther})
+	assert.ErrorIs(t, err, shortener.ErrInvalidRedirectCode)
+}
@@ -0,0 +1,100 @@
+package shortener
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// KnownShortenerHosts - hosts of the well-known third-party shorteners expanded by default
+var KnownShortenerHosts = []string{
+	"bit.ly", "bitly.com", "tinyurl.com", "t.co", "goo.gl", "ow.ly", "is.gd", "v.gd",
+	"buff.ly", "rebrand.ly", "cutt.ly", "shorturl.at", "rb.gy", "t.ly", "tiny.cc",
+}
+
+// ErrURLUnresolvable - third-party short link can not be expanded
+var ErrURLUnresolvable = fmt.Errorf("%w: short link can not be expanded", ErrInvalidURL)
+
+// URLResolver - expands the short links of the third-party shorteners before they are shortened,
+// so the links of the service do not depend on them
+type URLResolver interface {
+	// Resolve returns the url the short link redirects to, the url itself if it is not a short link.
+	// The error wrapping ErrURLUnresolvable is returned if the short link does not redirect
+	Resolve(ctx context.Context, url string) (string, error)
+}
+
+// HTTPResolver - URLResolver requesting the short links of the known hosts and following their redirects
+// while they lead to the known hosts
+type HTTPResolver struct {
+	client  *http.Client
+	hosts   map[string]struct{}
+	maxHops int
+}
+
+var _ URLResolver = (*HTTPResolver)(nil)
+
+// NewHTTPResolver - constructor. Hosts are matched exactly without the port, timeout limits every request
+func NewHTTPResolver(hosts []string, timeout time.Duration, maxHops int) *HTTPResolver {
+	r := &HTTPResolver{
+		client: &http.Client{
+			Timeout: timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		hosts:   make(map[string]struct{}, len(hosts)),
+		maxHops: maxHops,
+	}
+	for _, host := range hosts {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			r.hosts[host] = struct{}{}
+		}
+	}
+	return r
+}
+
+// Resolve - see URLResolver
+func (r *HTTPResolver) Resolve(ctx context.Context, rawURL string) (string, error) {
+	current := rawURL
+	for hop := 0; ; hop++ {
+		u, err := url.Parse(current)
+		if err != nil {
+			return "", fmt.Errorf("%w: %s", ErrURLUnresolvable, err.Error())
+		}
+		if _, ok := r.hosts[strings.ToLower(u.Hostname())]; !ok {
+			return current, nil
+		}
+		if hop == r.maxHops {
+			return "", fmt.Errorf("%w: more than %d redirects", ErrURLUnresolvable, r.maxHops)
+		}
+		current, err = r.follow(ctx, u)
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+// follow requests the short link and returns the absolute url of its redirect
+func (r *HTTPResolver) follow(ctx context.Context, u *url.URL) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrURLUnresolvable, err.Error())
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrURLUnresolvable, err.Error())
+	}
+	defer resp.Body.Close()
+	// the body of the redirect is small, it is drained to reuse the connection
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	location, err := resp.Location()
+	if resp.StatusCode < 300 || resp.StatusCode >= 400 || err != nil {
+		return "", fmt.Errorf("%w: %s responded %d", ErrURLUnresolvable, u.Host, resp.StatusCode)
+	}
+	return location.String(), nil
+}
@@ -0,0 +1,194 @@
+package shortener_test
+
+import (
+	"context"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/itksb/go-url-shortener/internal/storage"
+	"github.com/itksb/go-url-shortener/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSelfLinks_ShortCode(t *testing.T) {
+	links, err := shortener.NewSelfLinks("https://short.example/s/", []string{"http://sho.rt:8080", " "})
+	require.NoError(t, err)
+
+	tests := []struct {
+		url  string
+		code string
+		self bool
+	}{
+		{url: "https://short.example/s/abc", code: "abc", self: true},
+		{url: "http://SHORT.example/s/abc?x=1", code: "abc", self: true},
+		{url: "http://short.example:443/s/abc"},
+		{url: "https://short.example:443/s/abc/preview", code: "abc", self: true},
+		{url: "https://short.example/s", code: "", self: true},
+		{url: "https://short.example/other/abc"},
+		{url: "https://short.example/sabc"},
+		{url: "https://short.example:8443/s/abc"},
+		{url: "https://sho.rt:8080/a%20b", code: "a b", self: true},
+		{url: "https://sho.rt/abc"},
+		{url: "https://example.com/https://short.example/s/abc"},
+	}
+	for _, tt := range tests {
+		code, self := links.ShortCode(tt.url)
+		assert.Equal(t, tt.self, self, tt.url)
+		assert.Equal(t, tt.code, code, tt.url)
+	}
+
+	_, err = shortener.NewSelfLinks("https://short.example", []string{"not a base"})
+	assert.Error(t, err)
+}
+
+// newShortenerStandIn - third-party shortener redirecting the paths of the links to the urls
+func newShortenerStandIn(t *testing.T, links map[string]string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target, ok := links[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHTTPResolver_Resolve(t *testing.T) {
+	srv := newShortenerStandIn(t, map[string]string{
+		"/a":    "https://example.com/target?q=1",
+		"/b":    "/a",
+		"/loop": "/loop",
+		"/bad":  "/missing",
+	})
+	resolver := shortener.NewHTTPResolver([]string{"127.0.0.1"}, time.Second, 3)
+	ctx := context.Background()
+
+	expanded, err := resolver.Resolve(ctx, srv.URL+"/a")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/target?q=1", expanded)
+
+	// the relative redirect leads to another short link
+	expanded, err = resolver.Resolve(ctx, srv.URL+"/b")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/target?q=1", expanded)
+
+	// the urls of the other hosts are not requested
+	expanded, err = resolver.Resolve(ctx, "https://example.com/a")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/a", expanded)
+
+	_, err = resolver.Resolve(ctx, srv.URL+"/loop")
+	assert.ErrorIs(t, err, shortener.ErrURLUnresolvable)
+	_, err = resolver.Resolve(ctx, srv.URL+"/bad")
+	assert.ErrorIs(t, err, shortener.ErrURLUnresolvable)
+	assert.ErrorIs(t, err, shortener.ErrInvalidURL)
+}
+
+func TestService_SelfLinks(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+
+	service := shortener.NewShortener(l, storage.NewStorage(l, shortener.NewSequenceGenerator(shortener.Base62Alphabet, 0)))
+	links, err := shortener.NewSelfLinks("http://short.base", []string{"https://sho.rt"})
+	require.NoError(t, err)
+	service.UseSelfLinks(links)
+	ctx := context.Background()
+
+	code, err := service.ShortenURL(ctx, "https://example.com/a", "user1", shortener.SaveOptions{})
+	require.NoError(t, err)
+
+	// the short link resolves to the existing one
+	resolved, err := service.ShortenURL(ctx, "https://sho.rt/"+code, "user2", shortener.SaveOptions{})
+	assert.ErrorIs(t, err, shortener.ErrDuplicate)
+	assert.Equal(t, code, resolved)
+	owner, err := service.IsURLOwner(ctx, code, "user2")
+	require.NoError(t, err)
+	assert.False(t, owner)
+
+	_, err = service.ShortenURL(ctx, "http://short.base/missing", "user2", shortener.SaveOptions{})
+	assert.ErrorIs(t, err, shortener.ErrURLSelfReference)
+	_, err = service.ShortenURL(ctx, "http://short.base/api/user/urls", "user2", shortener.SaveOptions{})
+	assert.ErrorIs(t, err, shortener.ErrURLSelfReference)
+	_, err = service.ShortenURL(ctx, "http://short.base/", "user2", shortener.SaveOptions{})
+	assert.ErrorIs(t, err, shortener.ErrURLSelfReference)
+
+	results, err := service.ShortenURLBatch(ctx, "user2", []shortener.BatchItem{
+		{OriginalURL: "https://example.com/b"},
+		{OriginalURL: "http://short.base/" + code},
+		{OriginalURL: "https://example.com/c"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, shortener.BatchResult{ShortCode: code, Duplicate: true}, results[1])
+	assert.NotEmpty(t, results[0].ShortCode)
+	assert.NotEmpty(t, results[2].ShortCode)
+
+	_, err = service.ShortenURLBatch(ctx, "user2", []shortener.BatchItem{
+		{OriginalURL: "http://short.base/" + code},
+		{OriginalURL: "https://example.com/d", Opts: shortener.SaveOptions{Alias: "bad alias"}},
+	})
+	var itemErr *shortener.BatchItemError
+	require.ErrorAs(t, err, &itemErr)
+	assert.Equal(t, 1, itemErr.Index)
+
+	partial, errs, err := service.ShortenURLBatchPartial(ctx, "user2", []shortener.BatchItem{
+		{OriginalURL: "http://short.base/missing"},
+		{OriginalURL: "http://short.base/" + code},
+		{OriginalURL: "https://example.com/e"},
+	})
+	require.NoError(t, err)
+	assert.ErrorIs(t, errs[0], shortener.ErrURLSelfReference)
+	assert.Equal(t, shortener.BatchResult{ShortCode: code, Duplicate: true}, partial[1])
+	assert.NoError(t, errs[2])
+
+	// the link can not be changed to the short link
+	other, err := service.ShortenURL(ctx, "https://example.com/f", "user1", shortener.SaveOptions{})
+	require.NoError(t, err)
+	assert.ErrorIs(t, service.UpdateURL(ctx, other, "user1", "http://short.base/"+code), shortener.ErrURLSelfReference)
+	assert.ErrorIs(t, service.UpdateURL(ctx, other, "user1", "http://short.base/"+other), shortener.ErrURLSelfReference)
+}
+
+func TestService_URLResolver(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+
+	service := shortener.NewShortener(l, storage.NewStorage(l, shortener.NewSequenceGenerator(shortener.Base62Alphabet, 0)))
+	links, err := shortener.NewSelfLinks("http://short.base", nil)
+	require.NoError(t, err)
+	service.UseSelfLinks(links)
+	ctx := context.Background()
+
+	code, err := service.ShortenURL(ctx, "https://example.com/a", "user1", shortener.SaveOptions{})
+	require.NoError(t, err)
+
+	srv := newShortenerStandIn(t, map[string]string{
+		"/x":    "https://example.com/b",
+		"/a":    "https://example.com/a",
+		"/self": "http://short.base/" + code,
+		"/js":   "javascript:alert(1)",
+	})
+	service.UseURLResolver(shortener.NewHTTPResolver([]string{"127.0.0.1"}, time.Second, 3))
+
+	expanded, err := service.ShortenURL(ctx, srv.URL+"/x", "user1", shortener.SaveOptions{})
+	require.NoError(t, err)
+	item, err := service.GetURL(ctx, expanded)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/b", item.OriginalURL)
+
+	duplicate, err := service.ShortenURL(ctx, srv.URL+"/a", "user1", shortener.SaveOptions{})
+	assert.ErrorIs(t, err, shortener.ErrDuplicate)
+	assert.Equal(t, code, duplicate)
+
+	resolved, err := service.ShortenURL(ctx, srv.URL+"/self", "user1", shortener.SaveOptions{})
+	assert.ErrorIs(t, err, shortener.ErrDuplicate)
+	assert.Equal(t, code, resolved)
+
+	_, err = service.ShortenURL(ctx, srv.URL+"/js", "user1", shortener.SaveOptions{})
+	assert.ErrorIs(t, err, shortener.ErrURLSchemeNotAllowed)
+	_, err = service.ShortenURL(ctx, srv.URL+"/missing", "user1", shortener.SaveOptions{})
+	assert.ErrorIs(t, err, shortener.ErrURLUnresolvable)
+}
@@ -0,0 +1,102 @@
+package shortener_test
+
+import (
+	"context"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/itksb/go-url-shortener/internal/storage"
+	"github.com/itksb/go-url-shortener/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestService_RestoreURLBatch(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+
+	service := shortener.NewShortener(l, storage.NewStorage(l, shortener.NewSequenceGenerator(shortener.Base62Alphabet, 0)))
+	ctx := context.Background()
+
+	deleted, err := service.ShortenURL(ctx, "https://deleted.example.com", "user1", shortener.SaveOptions{})
+	require.NoError(t, err)
+	live, err := service.ShortenURL(ctx, "https://live.example.com", "user1", shortener.SaveOptions{})
+	require.NoError(t, err)
+	require.NoError(t, service.DeleteURLBatch(ctx, "user1", []string{deleted}))
+
+	trash, err := service.ListURLs(ctx, "user1", shortener.ListOptions{OnlyDeleted: true})
+	require.NoError(t, err)
+	require.Len(t, trash.Items, 1)
+	assert.Equal(t, deleted, trash.Items[0].ShortCode)
+	assert.True(t, trash.Items[0].Deleted)
+
+	// foreign users can not restore the url
+	restored, err := service.RestoreURLBatch(ctx, "user2", []string{deleted})
+	require.NoError(t, err)
+	assert.Empty(t, restored)
+
+	restored, err = service.RestoreURLBatch(ctx, "user1", []string{deleted, live, "unknown"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{deleted}, restored)
+	item, err := service.GetURL(ctx, deleted)
+	require.NoError(t, err)
+	assert.False(t, item.IsDeleted())
+	trash, err = service.ListURLs(ctx, "user1", shortener.ListOptions{OnlyDeleted: true})
+	require.NoError(t, err)
+	assert.Empty(t, trash.Items)
+}
+
+func TestService_ShortenURL_Deleted(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+
+	service := shortener.NewShortener(l, storage.NewStorage(l, shortener.NewSequenceGenerator(shortener.Base62Alphabet, 0)))
+	ctx := context.Background()
+
+	// the expiring url is never the duplicate
+	expiresAt := time.Now().Add(time.Hour)
+	expiring, err := service.ShortenURL(ctx, "https://example.com/doc", "user1", shortener.SaveOptions{ExpiresAt: &expiresAt})
+	require.NoError(t, err)
+	deleted, err := service.ShortenURL(ctx, "https://example.com/doc", "user2", shortener.SaveOptions{})
+	require.NoError(t, err, "the expiring url is not the duplicate of the public one")
+	assert.NotEqual(t, expiring, deleted)
+
+	// the deleted url is not the duplicate, the url is shortened again
+	require.NoError(t, service.DeleteURLBatch(ctx, "user2", []string{deleted}))
+	again, err := service.ShortenURL(ctx, "https://example.com/doc", "user3", shortener.SaveOptions{})
+	require.NoError(t, err)
+	assert.NotEqual(t, deleted, again)
+	duplicate, err := service.ShortenURL(ctx, "https://example.com/doc", "user2", shortener.SaveOptions{})
+	assert.ErrorIs(t, err, shortener.ErrDuplicate)
+	assert.Equal(t, again, duplicate)
+
+	// the deleted url is not restored while the key is taken
+	restored, err := service.RestoreURLBatch(ctx, "user2", []string{deleted})
+	require.NoError(t, err)
+	assert.Empty(t, restored)
+}
+
+func TestService_PurgeDeletedURLs(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+
+	service := shortener.NewShortener(l, storage.NewStorage(l, shortener.NewSequenceGenerator(shortener.Base62Alphabet, 0)))
+	ctx := context.Background()
+
+	code, err := service.ShortenURL(ctx, "https://purged.example.com", "user1", shortener.SaveOptions{})
+	require.NoError(t, err)
+	require.NoError(t, service.DeleteURLBatch(ctx, "user1", []string{code}))
+
+	count, err := service.PurgeDeletedURLs(ctx, time.Hour)
+	require.NoError(t, err)
+	assert.Zero(t, count, "the url is deleted less than the retention period ago")
+
+	count, err = service.PurgeDeletedURLs(ctx, -time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	_, err = service.GetURL(ctx, code)
+	assert.ErrorIs(t, err, shortener.ErrNotFound)
+	restored, err := service.RestoreURLBatch(ctx, "user1", []string{code})
+	require.NoError(t, err)
+	assert.Empty(t, restored)
+}
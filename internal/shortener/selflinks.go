@@ -0,0 +1,69 @@
+package shortener
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ErrURLSelfReference - url points to the shortener itself but not to the existing short link
+var ErrURLSelfReference = fmt.Errorf("%w: points to the shortener itself", ErrInvalidURL)
+
+// selfBase - host and path prefix of the base url
+type selfBase struct {
+	host   string // lowercased punycode host with the port if it is not the default one
+	prefix string // path without the trailing slash
+}
+
+// SelfLinks - recognizes the urls of the service by its base url and the aliases of it,
+// e.g. the other domains or the plain http version the short links are served at
+type SelfLinks struct {
+	bases []selfBase
+}
+
+// NewSelfLinks - constructor. Empty aliases are skipped
+func NewSelfLinks(baseURL string, aliases []string) (*SelfLinks, error) {
+	links := &SelfLinks{}
+	for _, raw := range append([]string{baseURL}, aliases...) {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		u, err := url.Parse(raw)
+		if err != nil || u.Hostname() == "" {
+			return nil, fmt.Errorf("self links: invalid base url %q", raw)
+		}
+		links.bases = append(links.bases, selfBase{
+			host:   canonicalHost(strings.ToLower(u.Scheme), u.Hostname(), u.Port()),
+			prefix: strings.TrimSuffix(u.EscapedPath(), "/"),
+		})
+	}
+	return links, nil
+}
+
+// ShortCode reports whether the url is on the host of the service and returns the first segment
+// of its path after the base path, it is the short code if the url is the short link.
+// The scheme is not compared, the default port of the scheme of the url is dropped
+func (l *SelfLinks) ShortCode(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "", false
+	}
+	host := canonicalHost(strings.ToLower(u.Scheme), strings.TrimSuffix(u.Hostname(), "."), u.Port())
+	path := u.EscapedPath()
+	for _, base := range l.bases {
+		if host != base.host {
+			continue
+		}
+		if path != base.prefix && !strings.HasPrefix(path, base.prefix+"/") {
+			continue
+		}
+		segment, _, _ := strings.Cut(strings.TrimPrefix(path[len(base.prefix):], "/"), "/")
+		code, err := url.PathUnescape(segment)
+		if err != nil {
+			code = segment
+		}
+		return code, true
+	}
+	return "", false
+}
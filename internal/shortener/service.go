@@ -3,8 +3,12 @@ package shortener
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/itksb/go-url-shortener/pkg/logger"
+	"golang.org/x/crypto/bcrypt"
 	"io"
+	"strings"
+	"time"
 )
 
 //goland:noinspection GoNameStartsWithPackageName
@@ -13,34 +17,164 @@ import (
 type Service struct {
 	logger  logger.Interface
 	storage ShortenerStorage
+	clicks  *ClickRecorder
+	clickDB ClickStorage
+	deletes *DeleteQueue
+	jobs    *JobRunner
+	urls    *URLValidator
+	canon   *URLCanonicalizer
+	deny    *Denylist
+	self    *SelfLinks
+	resolve URLResolver
+	pwds    *PasswordLimiter
+	pwdCost int // bcrypt cost of the link passwords
 	io.Closer
 }
 
+// ServiceStats - service-wide statistics
+type ServiceStats struct {
+	URLs        int64 `json:"urls"`
+	DeletedURLs int64 `json:"deleted_urls"`
+	Users       int64 `json:"users"`
+
+	DeleteQueue *DeleteQueueMetrics `json:"delete_queue,omitempty"` // nil if deletion is synchronous
+	Cache       *CacheMetrics       `json:"cache,omitempty"`        // nil if the storage is not cached
+}
+
 // URLListItem - .
 type URLListItem struct {
-	ID          int64   `json:"-" db:"id"`
-	UserID      string  `json:"-" db:"user_id"`
-	ShortURL    string  `json:"short_url" db:"sql.Null*"`
-	OriginalURL string  `json:"original_url" db:"original_url"`
-	CreatedAt   string  `json:"-"  db:"created_at,sql.Null*"`
-	UpdatedAt   string  `json:"-" db:"updated_at,sql.Null*"`
-	DeletedAt   *string `json:"-" db:"deleted_at,sql.Null*"`
+	ID           int64      `json:"-" db:"id"`
+	ShortCode    string     `json:"-" db:"short_code"`
+	UserID       string     `json:"-" db:"user_id"`
+	ShortURL     string     `json:"short_url" db:"sql.Null*"`
+	OriginalURL  string     `json:"original_url" db:"original_url"`
+	CanonicalURL string     `json:"-" db:"canonical_url"` // duplicate key of the url, empty for the legacy records
+	PasswordHash string     `json:"-" db:"password_hash"` // bcrypt hash of the password, empty for the public url
+	CreatedAt    string     `json:"-"  db:"created_at,sql.Null*"`
+	UpdatedAt    string     `json:"-" db:"updated_at,sql.Null*"`
+	DeletedAt    *string    `json:"-" db:"deleted_at,sql.Null*"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	ClicksLeft   *int64     `json:"clicks_left,omitempty" db:"clicks_left"`
+	RedirectCode int        `json:"redirect_code,omitempty" db:"redirect_code"` // 0 means DefaultRedirectCode
+	PassQuery    bool       `json:"pass_query,omitempty" db:"pass_query"`
+	Title        string     `json:"title,omitempty" db:"title"`
+	Preview      bool       `json:"preview,omitempty" db:"preview"` // the preview page is shown instead of the redirect
+	Deleted      bool       `json:"deleted,omitempty" db:"-"`       // filled for the listing with deleted urls
+	Tags         []string   `json:"tags,omitempty" db:"-"`          // tags of the owner, filled for the listing
+}
+
+// IsDeleted reports whether the url is marked as deleted
+func (item URLListItem) IsDeleted() bool {
+	return item.DeletedAt != nil && *item.DeletedAt != ""
+}
+
+// IsProtected reports whether the url is redirected to after the password is entered
+func (item URLListItem) IsProtected() bool {
+	return item.PasswordHash != ""
+}
+
+// IsExhausted reports whether the url with limited clicks has no clicks left
+func (item URLListItem) IsExhausted() bool {
+	return item.ClicksLeft != nil && *item.ClicksLeft <= 0
+}
+
+// IsReusable reports whether the url is returned as the duplicate of the same url at the moment now,
+// the deleted, expired and exhausted urls are not
+func (item URLListItem) IsReusable(now time.Time) bool {
+	return !item.IsDeleted() && !item.IsExpired(now) && !item.IsExhausted()
+}
+
+// IsExpired reports whether the url is expired at the moment now
+func (item URLListItem) IsExpired(now time.Time) bool {
+	return item.ExpiresAt != nil && !now.Before(*item.ExpiresAt)
 }
 
 // NewShortener - constructor
 func NewShortener(l logger.Interface, storage ShortenerStorage) *Service {
+	// the defaults are valid
+	urls, _ := NewURLValidator(DefaultURLSchemes, DefaultURLMaxLength)
 	return &Service{
 		logger:  l,
 		storage: storage,
+		urls:    urls,
+		canon:   NewURLCanonicalizer(false),
+		pwds:    NewPasswordLimiter(DefaultPasswordAttempts, DefaultPasswordWindow),
+		pwdCost: bcrypt.DefaultCost,
 	}
 }
 
-// ShortenURL - saves the given url to the database and returns record id
-func (s *Service) ShortenURL(ctx context.Context, url string, userID string) (string, error) {
-	if len(url) == 0 {
-		return "", errors.New("empty url")
+// UseURLValidator replaces the default validation of the original urls
+func (s *Service) UseURLValidator(validator *URLValidator) {
+	s.urls = validator
+}
+
+// UseURLCanonicalizer replaces the default canonicalization of the original urls
+func (s *Service) UseURLCanonicalizer(canonicalizer *URLCanonicalizer) {
+	s.canon = canonicalizer
+}
+
+// UseDenylist enables rejection of the urls of the denied hosts
+func (s *Service) UseDenylist(denylist *Denylist) {
+	s.deny = denylist
+}
+
+// UseSelfLinks enables detection of the urls pointing to the service itself
+func (s *Service) UseSelfLinks(links *SelfLinks) {
+	s.self = links
+}
+
+// UseURLResolver enables expansion of the third-party short links
+func (s *Service) UseURLResolver(resolver URLResolver) {
+	s.resolve = resolver
+}
+
+// UsePasswordLimiter replaces the default limit of the wrong password attempts
+func (s *Service) UsePasswordLimiter(limiter *PasswordLimiter) {
+	s.pwds = limiter
+}
+
+// UsePasswordCost replaces the default bcrypt cost of the link passwords, the tests use bcrypt.MinCost
+func (s *Service) UsePasswordCost(cost int) {
+	s.pwdCost = cost
+}
+
+// UseClickAnalytics enables recording of the redirects
+func (s *Service) UseClickAnalytics(recorder *ClickRecorder, storage ClickStorage) {
+	s.clicks = recorder
+	s.clickDB = storage
+}
+
+// UseDeleteQueue makes deletion asynchronous
+func (s *Service) UseDeleteQueue(queue *DeleteQueue) {
+	s.deletes = queue
+}
+
+// UseJobs enables asynchronous shortening jobs
+func (s *Service) UseJobs(runner *JobRunner) {
+	s.jobs = runner
+}
+
+// ShortenURL - saves the given url to the database and returns the short code of the record.
+// The url is saved as submitted, duplicates are detected by its canonical form, see URLCanonicalizer.
+// Returns ErrInvalidAlias if opts.Alias is not valid and ErrAliasTaken if it is used by another url.
+// Returns the error wrapping ErrInvalidURL if the url does not pass validation, see URLValidator, and ErrURLDenied if its host is denied.
+// Returns ErrInvalidExpiration if opts.ExpiresAt is not in the future and ErrInvalidMaxClicks if opts.MaxClicks is negative.
+// The url with opts.Password is never a duplicate, ErrInvalidPassword is returned if the password is too long.
+// The third-party short link is expanded, see UseURLResolver. The short link of the service is not shortened again,
+// its code is returned with ErrDuplicate, the user does not become its owner. Other urls of the service are ErrURLSelfReference
+func (s *Service) ShortenURL(ctx context.Context, url string, userID string, opts SaveOptions) (string, error) {
+	url, code, err := s.resolveURL(ctx, url)
+	if err != nil {
+		return "", err
 	}
-	id, err := s.storage.SaveURL(ctx, url, userID)
+	if code != "" {
+		return code, fmt.Errorf("%w: the url is the short link %s", ErrDuplicate, code)
+	}
+	opts, err = s.prepareURL(url, opts)
+	if err != nil {
+		return "", err
+	}
+	id, err := s.storage.SaveURL(ctx, url, userID, opts)
 	if err != nil && !errors.Is(err, ErrDuplicate) {
 		return "", err
 	}
@@ -50,26 +184,470 @@ func (s *Service) ShortenURL(ctx context.Context, url string, userID string) (st
 		return "", err
 	}
 
-	if savedItem.OriginalURL != url {
+	if DuplicateKey(savedItem.OriginalURL, savedItem.CanonicalURL) != opts.CanonicalURL {
 		return "", errors.New("ShortenerStorage error: savedItem != url")
 	}
 
 	return id, err
 }
 
-// GetURL - retrieves url by the id
+// GetURL - retrieves url by the short code
 func (s *Service) GetURL(ctx context.Context, id string) (URLListItem, error) {
 	return s.storage.GetURL(ctx, id)
 }
 
+// CheckURLDenied - returns ErrURLDenied if the host of the url is in the denylist.
+// The urls shortened before the host is denied are checked on redirect
+func (s *Service) CheckURLDenied(url string) error {
+	if s.deny == nil {
+		return nil
+	}
+	return s.deny.Check(url)
+}
+
+// CheckURLPassword - returns nil if the url is public or the password is right, ErrWrongPassword otherwise.
+// ErrTooManyAttempts is returned without the check if the wrong passwords of the url are entered too often
+func (s *Service) CheckURLPassword(item URLListItem, password string) error {
+	if !item.IsProtected() {
+		return nil
+	}
+	if retryAfter, ok := s.pwds.take(item.ShortCode, time.Now()); !ok {
+		return fmt.Errorf("%w: retry in %s", ErrTooManyAttempts, retryAfter.Round(time.Second))
+	}
+	if bcrypt.CompareHashAndPassword([]byte(item.PasswordHash), []byte(password)) != nil {
+		return ErrWrongPassword
+	}
+	s.pwds.forgive(item.ShortCode)
+	return nil
+}
+
+// IsURLOwner - reports whether the user shortened the url
+func (s *Service) IsURLOwner(ctx context.Context, id string, userID string) (bool, error) {
+	return s.storage.IsURLOwner(ctx, id, userID)
+}
+
 // ListURLByUserID - list urls shortened by the user
 func (s *Service) ListURLByUserID(ctx context.Context, userID string) ([]URLListItem, error) {
 	return s.storage.ListURLByUserID(ctx, userID)
 }
 
-// DeleteURLBatch - makrs urls
+// ListURLs - page of the urls shortened by the user
+func (s *Service) ListURLs(ctx context.Context, userID string, opts ListOptions) (URLPage, error) {
+	if opts.Sort == "" {
+		opts.Sort = SortCreatedAt
+	}
+	if opts.Sort != SortCreatedAt && opts.Sort != SortOriginalURL {
+		return URLPage{}, fmt.Errorf("unknown sort field %q", opts.Sort)
+	}
+	if opts.Cursor != nil && (opts.Cursor.Sort != opts.Sort || opts.Cursor.Desc != opts.Desc) {
+		return URLPage{}, fmt.Errorf("%w: it is made for another sort", ErrInvalidCursor)
+	}
+	if opts.Tag != "" {
+		tag, err := NormalizeTag(opts.Tag)
+		if err != nil {
+			return URLPage{}, err
+		}
+		opts.Tag = tag
+	}
+	page, err := s.storage.ListURLs(ctx, userID, opts)
+	if err != nil {
+		return page, err
+	}
+	if opts.IncludeDeleted || opts.OnlyDeleted {
+		for i := range page.Items {
+			page.Items[i].Deleted = page.Items[i].IsDeleted()
+		}
+	}
+	return page, nil
+}
+
+// RecordClick - asynchronously records the redirect. Does nothing if analytics is not enabled
+func (s *Service) RecordClick(ev ClickEvent) {
+	if s.clicks == nil {
+		return
+	}
+	s.clicks.Record(ev)
+}
+
+// URLStats - click statistics of the url, buckets are filled with zeros
+func (s *Service) URLStats(ctx context.Context, urlID int64, opts ClickStatsOptions) (ClickStats, error) {
+	if s.clickDB == nil {
+		return ClickStats{}, ErrAnalyticsDisabled
+	}
+	stats, err := s.clickDB.ClickStats(ctx, urlID, opts)
+	if err != nil {
+		return stats, err
+	}
+	stats.Hourly = fillBuckets(stats.Hourly, opts.HourlyFrom(), opts.Hours, func(t time.Time) time.Time {
+		return t.Add(time.Hour)
+	})
+	stats.Daily = fillBuckets(stats.Daily, opts.DailyFrom(), opts.Days, func(t time.Time) time.Time {
+		return t.AddDate(0, 0, 1)
+	})
+	if stats.TopReferrers == nil {
+		stats.TopReferrers = []ReferrerCount{}
+	}
+	return stats, nil
+}
+
+// ShortenURLBatch - shortens all the urls in one storage call, the short links are resolved as by ShortenURL.
+// Returns *BatchItemError if one of the items is not valid
+func (s *Service) ShortenURLBatch(ctx context.Context, userID string, items []BatchItem) ([]BatchResult, error) {
+	results := make([]BatchResult, len(items))
+	batch := make([]BatchItem, 0, len(items))
+	indexes := make([]int, 0, len(items)) // batch item -> item
+	for i, item := range items {
+		prepared, code, err := s.prepareBatchItem(ctx, item)
+		if err != nil {
+			return nil, &BatchItemError{Index: i, Err: err}
+		}
+		if code != "" {
+			results[i] = BatchResult{ShortCode: code, Duplicate: true}
+			continue
+		}
+		batch = append(batch, prepared)
+		indexes = append(indexes, i)
+	}
+
+	saved, err := s.saveURLBatch(ctx, userID, batch)
+	var itemErr *BatchItemError
+	if errors.As(err, &itemErr) && itemErr.Index < len(indexes) {
+		return nil, &BatchItemError{Index: indexes[itemErr.Index], Err: itemErr.Err}
+	}
+	if err != nil {
+		return nil, err
+	}
+	for j, result := range saved {
+		results[indexes[j]] = result
+	}
+	return results, nil
+}
+
+// ShortenURLBatchPartial - shortens the valid items, the items failing the batch are excluded one by one.
+// Results and errors are in the order of items: every item has either the result or the error.
+// The error is returned if the storage fails, errors of the items excluded before it are kept
+func (s *Service) ShortenURLBatchPartial(ctx context.Context, userID string, items []BatchItem) ([]BatchResult, []error, error) {
+	results := make([]BatchResult, len(items))
+	errs := make([]error, len(items))
+	// the items are prepared once, not on every retry of the batch
+	batch := make([]BatchItem, 0, len(items))
+	indexes := make([]int, 0, len(items)) // batch item -> item
+	for i, item := range items {
+		prepared, code, err := s.prepareBatchItem(ctx, item)
+		switch {
+		case err != nil:
+			errs[i] = err
+		case code != "":
+			results[i] = BatchResult{ShortCode: code, Duplicate: true}
+		default:
+			batch = append(batch, prepared)
+			indexes = append(indexes, i)
+		}
+	}
+
+	for len(batch) > 0 {
+		saved, err := s.saveURLBatch(ctx, userID, batch)
+		var itemErr *BatchItemError
+		if errors.As(err, &itemErr) && itemErr.Index < len(batch) {
+			errs[indexes[itemErr.Index]] = itemErr.Err
+			batch = append(batch[:itemErr.Index], batch[itemErr.Index+1:]...)
+			indexes = append(indexes[:itemErr.Index], indexes[itemErr.Index+1:]...)
+			continue
+		}
+		if err != nil {
+			return nil, errs, err
+		}
+		for j, result := range saved {
+			results[indexes[j]] = result
+		}
+		break
+	}
+	return results, errs, nil
+}
+
+// prepareBatchItem resolves and prepares the item as ShortenURL does.
+// Returns the code of the short link if the url is the working short link of the service
+func (s *Service) prepareBatchItem(ctx context.Context, item BatchItem) (BatchItem, string, error) {
+	url, code, err := s.resolveURL(ctx, item.OriginalURL)
+	if err != nil || code != "" {
+		return item, code, err
+	}
+	opts, err := s.prepareURL(url, item.Opts)
+	if err != nil {
+		return item, "", err
+	}
+	return BatchItem{OriginalURL: url, Opts: opts}, "", nil
+}
+
+// saveURLBatch saves the prepared items in one storage call
+func (s *Service) saveURLBatch(ctx context.Context, userID string, items []BatchItem) ([]BatchResult, error) {
+	if len(items) == 0 {
+		return []BatchResult{}, nil
+	}
+	results, err := s.storage.SaveURLBatch(ctx, userID, items)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) != len(items) {
+		return nil, fmt.Errorf("ShortenerStorage error: %d results for %d items", len(results), len(items))
+	}
+	return results, nil
+}
+
+// SubmitJob - starts asynchronous shortening of the items, see JobRunner.Submit.
+// The passwords are hashed before the job is persisted, the invalid password is ErrInvalidJob
+func (s *Service) SubmitJob(ctx context.Context, userID string, items []JobItem) (Job, error) {
+	if s.jobs == nil {
+		return Job{}, ErrJobsDisabled
+	}
+	for i := range items {
+		if items[i].Password == "" {
+			continue
+		}
+		hash, err := hashPassword(items[i].Password, s.pwdCost)
+		if err != nil {
+			return Job{}, fmt.Errorf("%w: item %d: %s", ErrInvalidJob, i, err.Error())
+		}
+		items[i].Password = ""
+		items[i].PasswordHash = hash
+	}
+	return s.jobs.Submit(ctx, userID, items)
+}
+
+// GetJob - job of the user with its progress
+func (s *Service) GetJob(ctx context.Context, id string, userID string) (Job, error) {
+	if s.jobs == nil {
+		return Job{}, ErrJobsDisabled
+	}
+	return s.jobs.Get(ctx, id, userID)
+}
+
+// JobResults - results of the processed items of the user job
+func (s *Service) JobResults(ctx context.Context, id string, userID string) ([]JobResult, error) {
+	if s.jobs == nil {
+		return nil, ErrJobsDisabled
+	}
+	results, err := s.jobs.Results(ctx, id, userID)
+	if results == nil && err == nil {
+		results = []JobResult{}
+	}
+	return results, err
+}
+
+// CancelJob - stops the user job, see JobRunner.Cancel
+func (s *Service) CancelJob(ctx context.Context, id string, userID string) (Job, error) {
+	if s.jobs == nil {
+		return Job{}, ErrJobsDisabled
+	}
+	return s.jobs.Cancel(ctx, id, userID)
+}
+
+// resolveURL validates the url and expands it if it is the third-party short link.
+// Returns the code of the short link if the url is the working short link of the service
+func (s *Service) resolveURL(ctx context.Context, url string) (string, string, error) {
+	if err := s.urls.Validate(url); err != nil {
+		return url, "", err
+	}
+	if s.resolve != nil {
+		expanded, err := s.resolve.Resolve(ctx, url)
+		if err != nil {
+			return url, "", err
+		}
+		if expanded != url {
+			// the short link may lead anywhere
+			if err := s.urls.Validate(expanded); err != nil {
+				return url, "", fmt.Errorf("%w: expanded to %s", err, expanded)
+			}
+			url = expanded
+		}
+	}
+	if s.self == nil {
+		return url, "", nil
+	}
+	code, ok := s.self.ShortCode(url)
+	if !ok {
+		return url, "", nil
+	}
+	if code == "" {
+		return url, "", ErrURLSelfReference
+	}
+	item, err := s.storage.GetURL(ctx, code)
+	if err != nil || item.ShortCode == "" || item.IsDeleted() || item.IsExpired(time.Now()) || item.IsExhausted() {
+		return url, "", fmt.Errorf("%w: there is no working short link %s", ErrURLSelfReference, code)
+	}
+	return url, item.ShortCode, nil
+}
+
+// prepareURL validates the url with options, normalizes the options, sets the canonical url and hashes the password
+func (s *Service) prepareURL(url string, opts SaveOptions) (SaveOptions, error) {
+	if err := s.urls.Validate(url); err != nil {
+		return opts, err
+	}
+	canonicalURL, err := s.canon.Canonicalize(url)
+	if err != nil {
+		return opts, err
+	}
+	opts.CanonicalURL = canonicalURL
+	if err := s.CheckURLDenied(url); err != nil {
+		return opts, err
+	}
+	if opts.Alias != "" {
+		if err := ValidateAlias(opts.Alias); err != nil {
+			return opts, err
+		}
+	}
+	if opts.ExpiresAt != nil {
+		if !opts.ExpiresAt.After(time.Now()) {
+			return opts, fmt.Errorf("%w: expiration time is in the past", ErrInvalidExpiration)
+		}
+		expiresAt := opts.ExpiresAt.UTC()
+		opts.ExpiresAt = &expiresAt
+	}
+	if opts.MaxClicks < 0 {
+		return opts, fmt.Errorf("%w: must be positive", ErrInvalidMaxClicks)
+	}
+	if err := ValidateRedirectCode(opts.RedirectCode); err != nil {
+		return opts, err
+	}
+	tags, err := NormalizeTags(opts.Tags)
+	if err != nil {
+		return opts, err
+	}
+	opts.Tags = tags
+	title, err := NormalizeTitle(opts.Title)
+	if err != nil {
+		return opts, err
+	}
+	opts.Title = title
+	// the hash is the slowest part, the options are valid by now
+	if opts.Password != "" {
+		opts.PasswordHash, err = hashPassword(opts.Password, s.pwdCost)
+		if err != nil {
+			return opts, err
+		}
+		opts.Password = ""
+	}
+	if opts.PasswordHash != "" || opts.MaxClicks > 0 || opts.ExpiresAt != nil {
+		opts.CanonicalURL = uniqueKey(opts.CanonicalURL)
+	}
+	return opts, nil
+}
+
+// Stats - service-wide statistics
+func (s *Service) Stats(ctx context.Context) (ServiceStats, error) {
+	stats := ServiceStats{}
+	var err error
+	stats.URLs, stats.DeletedURLs, err = s.storage.CountURLs(ctx)
+	if err != nil {
+		return stats, err
+	}
+	stats.Users, err = s.storage.CountUsers(ctx)
+	if s.deletes != nil {
+		metrics := s.deletes.Metrics()
+		stats.DeleteQueue = &metrics
+	}
+	if cache, ok := s.storage.(*CachedStorage); ok {
+		metrics := cache.Metrics()
+		stats.Cache = &metrics
+	}
+	return stats, err
+}
+
+// ConsumeClick - takes one click of the url with limited clicks
+func (s *Service) ConsumeClick(ctx context.Context, id string) error {
+	return s.storage.ConsumeClick(ctx, id)
+}
+
+// DeleteExpiredURLs - marks as deleted urls which are expired by now
+func (s *Service) DeleteExpiredURLs(ctx context.Context) (int64, error) {
+	return s.storage.DeleteExpiredURLs(ctx, time.Now().UTC())
+}
+
+// DeleteURLBatch - marks urls of the user as deleted.
+// With the delete queue the urls are deleted in the background, ErrDeleteQueueFull means the client should retry later
 func (s *Service) DeleteURLBatch(ctx context.Context, userID string, ids []string) error {
-	return s.storage.DeleteURLBatch(ctx, userID, ids)
+	codes := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if code := strings.TrimSpace(id); code != "" {
+			codes = append(codes, code)
+		}
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	if s.deletes != nil {
+		return s.deletes.Enqueue(DeleteTask{UserID: userID, Codes: codes})
+	}
+	return s.storage.DeleteURLBatch(ctx, userID, codes)
+}
+
+// UpdateURL - changes the original url, the short code stays the same.
+// Only the user who owns the url alone may change it, see ShortenerStorage.UpdateURL.
+// The third-party short link is expanded, the url of the service is ErrURLSelfReference
+func (s *Service) UpdateURL(ctx context.Context, id string, userID string, url string) error {
+	url, code, err := s.resolveURL(ctx, url)
+	if err != nil {
+		return err
+	}
+	if code != "" {
+		return fmt.Errorf("%w: the url is the short link %s", ErrURLSelfReference, code)
+	}
+	opts, err := s.prepareURL(url, SaveOptions{})
+	if err != nil {
+		return err
+	}
+	// the password, the clicks limit and the expiration stay, so the url stays unique
+	if item, err := s.storage.GetURL(ctx, id); err == nil && (item.IsProtected() || item.ClicksLeft != nil || item.ExpiresAt != nil) {
+		opts.CanonicalURL = uniqueKey(opts.CanonicalURL)
+	}
+	return s.storage.UpdateURL(ctx, id, userID, url, opts.CanonicalURL)
+}
+
+// URLHistory - changes of the original url, the oldest first
+func (s *Service) URLHistory(ctx context.Context, id string) ([]URLChange, error) {
+	changes, err := s.storage.URLHistory(ctx, id)
+	if changes == nil && err == nil {
+		changes = []URLChange{}
+	}
+	return changes, err
+}
+
+// UpdateURLTags - removes and then adds the tags of the url owned by the user, returns the resulting tags
+func (s *Service) UpdateURLTags(ctx context.Context, id string, userID string, add []string, remove []string) ([]string, error) {
+	add, err := NormalizeTags(add)
+	if err != nil {
+		return nil, err
+	}
+	// removed tags are not validated, the unknown ones are skipped by the storage
+	removed := make([]string, 0, len(remove))
+	for _, tag := range remove {
+		removed = append(removed, strings.ToLower(strings.TrimSpace(tag)))
+	}
+	tags, err := s.storage.UpdateURLTags(ctx, id, userID, add, removed)
+	if tags == nil && err == nil {
+		tags = []string{}
+	}
+	return tags, err
+}
+
+// RestoreURLBatch - restores urls deleted by the user, returns the restored codes.
+// A deletion still waiting in the delete queue is applied after the restoration
+func (s *Service) RestoreURLBatch(ctx context.Context, userID string, ids []string) ([]string, error) {
+	codes := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if code := strings.TrimSpace(id); code != "" {
+			codes = append(codes, code)
+		}
+	}
+	if len(codes) == 0 {
+		return []string{}, nil
+	}
+	return s.storage.RestoreURLBatch(ctx, userID, codes)
+}
+
+// PurgeDeletedURLs - permanently removes urls deleted longer than the retention period ago
+func (s *Service) PurgeDeletedURLs(ctx context.Context, retention time.Duration) (int64, error) {
+	return s.storage.PurgeDeletedURLs(ctx, time.Now().UTC().Add(-retention))
 }
 
 // Close destructor
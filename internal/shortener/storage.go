@@ -3,20 +3,124 @@ package shortener
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"time"
 )
 
 // ShortenerStorage -
+// One original url has one short code, shared by all the users who shortened it.
+// Urls are the same if their duplicate keys are equal, see DuplicateKey.
+// Every such user owns the url: it is in the user listing and the user may delete it.
+// The url itself is marked as deleted when the last owner deletes it
 //
 //goland:noinspection GoNameStartsWithPackageName
 type ShortenerStorage interface {
-	SaveURL(ctx context.Context, url string, userID string) (string, error)
+	// SaveURL returns the existing code and ErrDuplicate if the url is already stored, the user becomes its owner.
+	// The tags are added to the tags of the user
+	SaveURL(ctx context.Context, url string, userID string, opts SaveOptions) (string, error)
+	// SaveURLBatch persists all the urls or none of them.
+	// Results are in the order of items, already shortened urls are marked as duplicates
+	SaveURLBatch(ctx context.Context, userID string, items []BatchItem) ([]BatchResult, error)
+	// GetURL returns ErrNotFound or the empty item if there is no url with the short code
 	GetURL(ctx context.Context, id string) (URLListItem, error)
+	// ListURLByUserID returns urls owned by the user, DeletedAt is set if the user deleted the url or it is deleted
 	ListURLByUserID(ctx context.Context, userID string) ([]URLListItem, error)
+	// ListURLs returns the page of the urls owned by the user, see ListOptions
+	ListURLs(ctx context.Context, userID string, opts ListOptions) (URLPage, error)
+	// DeleteURLBatch removes the user from the owners of the urls, unknown and foreign codes are skipped
 	DeleteURLBatch(ctx context.Context, userID string, ids []string) error
+	// RestoreURLBatch restores the urls deleted by the user, returns the restored codes.
+	// Expired, foreign and live urls are skipped
+	RestoreURLBatch(ctx context.Context, userID string, ids []string) ([]string, error)
+	// PurgeDeletedURLs permanently removes urls deleted before the moment with their clicks,
+	// and the ownerships deleted before it. Returns count of removed urls
+	PurgeDeletedURLs(ctx context.Context, before time.Time) (int64, error)
+	// UpdateURL changes the original url of the live url owned by the user alone and records the change.
+	// Returns ErrNotFound if the user does not own the url, ErrURLShared if other users own it too
+	// and ErrDuplicate if the new url is already shortened, canonicalURL is its duplicate key as in SaveOptions
+	UpdateURL(ctx context.Context, id string, userID string, url string, canonicalURL string) error
+	// URLHistory returns changes of the original url in the order they are made
+	URLHistory(ctx context.Context, id string) ([]URLChange, error)
+	// UpdateURLTags removes and then adds the tags of the url owned by the user, returns the resulting tags.
+	// Tags belong to the owner, other owners of the url do not see them. Returns ErrNotFound if the user does not own the url
+	UpdateURLTags(ctx context.Context, id string, userID string, add []string, remove []string) ([]string, error)
+	// IsURLOwner reports whether the user shortened the url, even if the user deleted it later
+	IsURLOwner(ctx context.Context, id string, userID string) (bool, error)
+	// DeleteExpiredURLs marks as deleted urls expired at the moment now, returns count of deleted urls
+	DeleteExpiredURLs(ctx context.Context, now time.Time) (int64, error)
+	// ConsumeClick atomically decrements the clicks left of the url with limited clicks.
+	// Returns ErrClicksExhausted if there are no clicks left
+	ConsumeClick(ctx context.Context, id string) error
+	// CountURLs returns count of live and deleted urls
+	CountURLs(ctx context.Context) (live int64, deleted int64, err error)
+	// CountUsers returns count of distinct owners of urls
+	CountUsers(ctx context.Context) (int64, error)
 
 	io.Closer
 }
 
+// SaveOptions - optional attributes of the saved url
+type SaveOptions struct {
+	Alias        string     // custom short code instead of the generated one
+	ExpiresAt    *time.Time // the url is not available after the moment, nil means forever
+	MaxClicks    int64      // the url is not available after this count of redirects, 0 means unlimited
+	Tags         []string   // tags of the user, normalized by NormalizeTags
+	Password     string     // the url is redirected to after the password is entered, empty means public
+	RedirectCode int        // status of the redirect, one of RedirectCodes, 0 means DefaultRedirectCode
+	PassQuery    bool       // the query of the request is added to the original url, see URLListItem.RedirectURL
+	Title        string     // title of the owner shown on the preview page, normalized by NormalizeTitle
+	Preview      bool       // the preview page is shown instead of the redirect
+
+	CanonicalURL string // duplicate key of the url set by the service, see URLCanonicalizer and DuplicateKey
+	PasswordHash string // bcrypt hash of the password set by the service, Password is cleared
+}
+
+// BatchItem - url of the batch with its own options
+type BatchItem struct {
+	OriginalURL string
+	Opts        SaveOptions
+}
+
+// BatchResult - saved url of the batch
+type BatchResult struct {
+	ShortCode string
+	Duplicate bool // the url was shortened before, ShortCode is the existing one
+}
+
+// BatchItemError - the batch is not saved because of the item
+type BatchItemError struct {
+	Index int // index of the item in the batch
+	Err   error
+}
+
+// Error implements error interface
+func (e *BatchItemError) Error() string {
+	return fmt.Sprintf("batch item %d: %s", e.Index, e.Err.Error())
+}
+
+// Unwrap returns the error of the item
+func (e *BatchItemError) Unwrap() error {
+	return e.Err
+}
+
+// ErrNotFound - there is no url with the short code
+var ErrNotFound = errors.New(`url not found`)
+
 // ErrDuplicate - duplication error returns from the storage
 var ErrDuplicate = errors.New(`duplicate entity`)
+
+// ErrAliasTaken - the requested alias is used by another url
+var ErrAliasTaken = errors.New(`alias is already taken`)
+
+// ErrInvalidExpiration - expiration time of the url is not valid
+var ErrInvalidExpiration = errors.New(`invalid expiration`)
+
+// ErrInvalidMaxClicks - clicks limit of the url is not valid
+var ErrInvalidMaxClicks = errors.New(`invalid max clicks`)
+
+// ErrClicksExhausted - the url with limited clicks has no clicks left
+var ErrClicksExhausted = errors.New(`clicks exhausted`)
+
+// ErrAnalyticsDisabled - click analytics is not configured
+var ErrAnalyticsDisabled = errors.New(`click analytics is disabled`)
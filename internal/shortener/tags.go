@@ -0,0 +1,88 @@
+package shortener
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Tag limits
+const (
+	TagMaxLength = 64 // in runes
+	MaxTags      = 20 // tags of one url in one request
+)
+
+// ErrInvalidTag - tag does not pass validation
+var ErrInvalidTag = errors.New(`invalid tag`)
+
+// NormalizeTag trims the tag and lowers its case, so the tags differing in case are the same tag
+func NormalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if tag == "" || utf8.RuneCountInString(tag) > TagMaxLength {
+		return "", fmt.Errorf("%w: length must be from 1 to %d", ErrInvalidTag, TagMaxLength)
+	}
+	for _, r := range tag {
+		if !unicode.IsPrint(r) {
+			return "", fmt.Errorf("%w: symbol %q is not allowed", ErrInvalidTag, r)
+		}
+	}
+	return tag, nil
+}
+
+// NormalizeTags normalizes the tags, removes repeated ones and sorts them
+func NormalizeTags(tags []string) ([]string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	seen := make(map[string]struct{}, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag, err := NormalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+		seen[tag] = struct{}{}
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) > MaxTags {
+		return nil, fmt.Errorf("%w: more than %d tags", ErrInvalidTag, MaxTags)
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+// MergeTags applies the change to the tags: removes the tags, then adds the new ones. The result is sorted
+func MergeTags(tags []string, add []string, remove []string) []string {
+	set := make(map[string]struct{}, len(tags)+len(add))
+	for _, tag := range tags {
+		set[tag] = struct{}{}
+	}
+	for _, tag := range remove {
+		delete(set, tag)
+	}
+	for _, tag := range add {
+		set[tag] = struct{}{}
+	}
+	merged := make([]string, 0, len(set))
+	for tag := range set {
+		merged = append(merged, tag)
+	}
+	sort.Strings(merged)
+	return merged
+}
+
+// HasTag reports whether the item is tagged with the tag
+func (item URLListItem) HasTag(tag string) bool {
+	for _, t := range item.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package shortener_test
+
+import (
+	"context"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/itksb/go-url-shortener/internal/storage"
+	"github.com/itksb/go-url-shortener/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tags, err := shortener.NormalizeTags([]string{" Work ", "news", "work", "Новости"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"news", "work", "новости"}, tags)
+
+	for _, invalid := range [][]string{{" "}, {"a\tb"}, {strings.Repeat("x", shortener.TagMaxLength+1)}} {
+		_, err = shortener.NormalizeTags(invalid)
+		assert.ErrorIs(t, err, shortener.ErrInvalidTag, invalid)
+	}
+	tooMany := make([]string, 0, shortener.MaxTags+1)
+	for i := 0; i <= shortener.MaxTags; i++ {
+		tooMany = append(tooMany, strings.Repeat("x", i+1))
+	}
+	_, err = shortener.NormalizeTags(tooMany)
+	assert.ErrorIs(t, err, shortener.ErrInvalidTag)
+}
+
+func TestService_Tags(t *testing.T) {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+
+	service := shortener.NewShortener(l, storage.NewStorage(l, shortener.NewSequenceGenerator(shortener.Base62Alphabet, 0)))
+	ctx := context.Background()
+
+	code, err := service.ShortenURL(ctx, "https://a.example.com", "user1", shortener.SaveOptions{Tags: []string{"Work"}})
+	require.NoError(t, err)
+	_, err = service.ShortenURLBatch(ctx, "user1", []shortener.BatchItem{
+		{OriginalURL: "https://b.example.com", Opts: shortener.SaveOptions{Tags: []string{"news"}}},
+		{OriginalURL: "https://c.example.com"},
+	})
+	require.NoError(t, err)
+	// the other owner of the shared url has own tags
+	_, err = service.ShortenURL(ctx, "https://a.example.com", "user2", shortener.SaveOptions{Tags: []string{"later"}})
+	assert.ErrorIs(t, err, shortener.ErrDuplicate)
+
+	page, err := service.ListURLs(ctx, "user1", shortener.ListOptions{Tag: "WORK"})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, code, page.Items[0].ShortCode)
+	assert.Equal(t, []string{"work"}, page.Items[0].Tags)
+	page, err = service.ListURLs(ctx, "user2", shortener.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, []string{"later"}, page.Items[0].Tags)
+
+	tags, err := service.UpdateURLTags(ctx, code, "user1", []string{"urgent"}, []string{" WORK"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"urgent"}, tags)
+	page, err = service.ListURLs(ctx, "user1", shortener.ListOptions{Tag: "work"})
+	require.NoError(t, err)
+	assert.Empty(t, page.Items)
+
+	tags, err = service.UpdateURLTags(ctx, code, "user1", nil, []string{"urgent"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{}, tags)
+
+	_, err = service.UpdateURLTags(ctx, code, "user3", []string{"spam"}, nil)
+	assert.ErrorIs(t, err, shortener.ErrNotFound)
+	_, err = service.UpdateURLTags(ctx, code, "user1", []string{""}, nil)
+	assert.ErrorIs(t, err, shortener.ErrInvalidTag)
+	_, err = service.ShortenURL(ctx, "https://d.example.com", "user1", shortener.SaveOptions{Tags: []string{" "}})
+	assert.ErrorIs(t, err, shortener.ErrInvalidTag)
+}
@@ -0,0 +1,83 @@
+package shortener
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultURLMaxLength - max length of the original url in bytes
+const DefaultURLMaxLength = 2048
+
+// DefaultURLSchemes - schemes of the original urls allowed by default
+var DefaultURLSchemes = []string{"http", "https"}
+
+// ErrInvalidURL - original url does not pass validation, it is wrapped by the errors of the particular rules
+var ErrInvalidURL = errors.New(`invalid url`)
+
+// errors of the url validation rules
+var (
+	ErrURLEmpty            = fmt.Errorf("%w: empty", ErrInvalidURL)
+	ErrURLTooLong          = fmt.Errorf("%w: too long", ErrInvalidURL)
+	ErrURLControlChars     = fmt.Errorf("%w: control characters and invalid utf-8 are not allowed", ErrInvalidURL)
+	ErrURLMalformed        = fmt.Errorf("%w: can not be parsed", ErrInvalidURL)
+	ErrURLNotAbsolute      = fmt.Errorf("%w: must be absolute", ErrInvalidURL)
+	ErrURLSchemeNotAllowed = fmt.Errorf("%w: scheme is not allowed", ErrInvalidURL)
+	ErrURLNoHost           = fmt.Errorf("%w: host is empty", ErrInvalidURL)
+)
+
+// URLValidator - checks the original urls before they are shortened
+type URLValidator struct {
+	schemes   map[string]struct{}
+	maxLength int
+}
+
+// NewURLValidator - constructor. Schemes are case-insensitive
+func NewURLValidator(schemes []string, maxLength int) (*URLValidator, error) {
+	if len(schemes) == 0 {
+		return nil, errors.New("url validator: no schemes are allowed")
+	}
+	if maxLength < 1 {
+		return nil, fmt.Errorf("url validator: max length %d must be positive", maxLength)
+	}
+	v := &URLValidator{schemes: make(map[string]struct{}, len(schemes)), maxLength: maxLength}
+	for _, scheme := range schemes {
+		scheme = strings.ToLower(strings.TrimSpace(scheme))
+		if scheme == "" {
+			return nil, errors.New("url validator: empty scheme")
+		}
+		v.schemes[scheme] = struct{}{}
+	}
+	return v, nil
+}
+
+// Validate checks that the url is the absolute url of the allowed scheme with the host,
+// it is not too long and has no control characters
+func (v *URLValidator) Validate(raw string) error {
+	if raw == "" {
+		return ErrURLEmpty
+	}
+	if len(raw) > v.maxLength {
+		return fmt.Errorf("%w: %d bytes, max %d", ErrURLTooLong, len(raw), v.maxLength)
+	}
+	if !utf8.ValidString(raw) || strings.IndexFunc(raw, unicode.IsControl) >= 0 {
+		return ErrURLControlChars
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ErrURLMalformed
+	}
+	if !u.IsAbs() {
+		return ErrURLNotAbsolute
+	}
+	if _, ok := v.schemes[strings.ToLower(u.Scheme)]; !ok {
+		return fmt.Errorf("%w: %s", ErrURLSchemeNotAllowed, u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return ErrURLNoHost
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package shortener
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestURLValidator_Validate(t *testing.T) {
+	v, err := NewURLValidator(DefaultURLSchemes, 64)
+	require.NoError(t, err)
+
+	tests := []struct {
+		url     string
+		wantErr error
+	}{
+		{url: "https://example.com/path?q=1#top"},
+		{url: "HTTP://Example.com"},
+		{url: "http://[::1]:8080/"},
+		{url: "", wantErr: ErrURLEmpty},
+		{url: "https://example.com/" + strings.Repeat("a", 64), wantErr: ErrURLTooLong},
+		{url: "https://example.com/\nSet-Cookie: a=b", wantErr: ErrURLControlChars},
+		{url: "https://example.com/\x7f", wantErr: ErrURLControlChars},
+		{url: "https://example.com/\xff\xfe", wantErr: ErrURLControlChars},
+		{url: "http://exa mple.com", wantErr: ErrURLMalformed},
+		{url: "not a url", wantErr: ErrURLNotAbsolute},
+		{url: "//example.com/path", wantErr: ErrURLNotAbsolute},
+		{url: "javascript:alert(1)", wantErr: ErrURLSchemeNotAllowed},
+		{url: "ftp://example.com/file", wantErr: ErrURLSchemeNotAllowed},
+		{url: "https:///path", wantErr: ErrURLNoHost},
+		{url: "http://:8080/", wantErr: ErrURLNoHost},
+	}
+	for _, tt := range tests {
+		err := v.Validate(tt.url)
+		if tt.wantErr != nil {
+			assert.ErrorIs(t, err, tt.wantErr, tt.url)
+			assert.ErrorIs(t, err, ErrInvalidURL, tt.url)
+		} else {
+			assert.NoError(t, err, tt.url)
+		}
+	}
+}
+
+func TestNewURLValidator(t *testing.T) {
+	v, err := NewURLValidator([]string{" FTP "}, DefaultURLMaxLength)
+	require.NoError(t, err)
+	assert.NoError(t, v.Validate("ftp://example.com/file"))
+	assert.ErrorIs(t, v.Validate("https://example.com"), ErrURLSchemeNotAllowed)
+
+	_, err = NewURLValidator(nil, DefaultURLMaxLength)
+	assert.Error(t, err)
+	_, err = NewURLValidator([]string{"http", ""}, DefaultURLMaxLength)
+	assert.Error(t, err)
+	_, err = NewURLValidator(DefaultURLSchemes, 0)
+	assert.Error(t, err)
+}
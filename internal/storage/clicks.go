@@ -0,0 +1,25 @@
+package storage
+
+import (
+	"context"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+)
+
+// SaveClicks persist click events
+func (s *storage) SaveClicks(ctx context.Context, events []shortener.ClickEvent) error {
+	s.clickMtx.Lock()
+	defer s.clickMtx.Unlock()
+
+	for _, ev := range events {
+		s.clicks[ev.URLID] = append(s.clicks[ev.URLID], ev)
+	}
+	return nil
+}
+
+// ClickStats aggregates click events of the url
+func (s *storage) ClickStats(ctx context.Context, urlID int64, opts shortener.ClickStatsOptions) (shortener.ClickStats, error) {
+	s.clickMtx.RLock()
+	defer s.clickMtx.RUnlock()
+
+	return shortener.AggregateClicks(s.clicks[urlID], opts), nil
+}
@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"sort"
+	"sync"
+	"time"
+)
+
+// memoryJob - job with its items and the results of the processed ones
+type memoryJob struct {
+	job     shortener.Job
+	items   []shortener.JobItem
+	results []shortener.JobResult
+}
+
+type jobStorage struct {
+	jobs map[string]*memoryJob
+	mtx  sync.RWMutex
+}
+
+// NewJobStorage - constructor of the in-memory job storage, jobs are lost on restart
+func NewJobStorage() *jobStorage {
+	return &jobStorage{jobs: make(map[string]*memoryJob)}
+}
+
+// CreateJob persists the new job with its items
+func (s *jobStorage) CreateJob(ctx context.Context, job shortener.Job, items []shortener.JobItem) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if _, ok := s.jobs[job.ID]; ok {
+		return fmt.Errorf("job %s already exists", job.ID)
+	}
+	job.Total = len(items)
+	s.jobs[job.ID] = &memoryJob{
+		job:   job,
+		items: append([]shortener.JobItem(nil), items...),
+	}
+	return nil
+}
+
+// GetJob returns the job by id
+func (s *jobStorage) GetJob(ctx context.Context, id string) (shortener.Job, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	stored, ok := s.jobs[id]
+	if !ok {
+		return shortener.Job{}, fmt.Errorf("%w: id %s", shortener.ErrJobNotFound, id)
+	}
+	return stored.job, nil
+}
+
+// JobItems returns up to limit items of the job starting from the offset
+func (s *jobStorage) JobItems(ctx context.Context, id string, offset int, limit int) ([]shortener.JobItem, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	stored, ok := s.jobs[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: id %s", shortener.ErrJobNotFound, id)
+	}
+	if offset >= len(stored.items) {
+		return nil, nil
+	}
+	end := offset + limit
+	if end > len(stored.items) {
+		end = len(stored.items)
+	}
+	return append([]shortener.JobItem(nil), stored.items[offset:end]...), nil
+}
+
+// SaveJobResults stores the results of the items starting from the offset and updates the progress
+func (s *jobStorage) SaveJobResults(ctx context.Context, id string, offset int, results []shortener.JobResult) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	stored, ok := s.jobs[id]
+	if !ok {
+		return fmt.Errorf("%w: id %s", shortener.ErrJobNotFound, id)
+	}
+	if offset > len(stored.results) || offset+len(results) > len(stored.items) {
+		return fmt.Errorf("results %d-%d of the job %s are out of order", offset, offset+len(results), id)
+	}
+	// already saved results are skipped
+	saved := len(stored.results) - offset
+	if saved > len(results) {
+		saved = len(results)
+	}
+	for _, result := range results[saved:] {
+		stored.results = append(stored.results, result)
+		if result.Error != "" {
+			stored.job.Failed++
+		}
+	}
+	stored.job.Processed = len(stored.results)
+	stored.job.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+// JobResults returns the results of the processed items
+func (s *jobStorage) JobResults(ctx context.Context, id string) ([]shortener.JobResult, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	stored, ok := s.jobs[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: id %s", shortener.ErrJobNotFound, id)
+	}
+	return append([]shortener.JobResult(nil), stored.results...), nil
+}
+
+// SetJobStatus changes the status of the job if it is one of the from statuses
+func (s *jobStorage) SetJobStatus(ctx context.Context, id string, from []string, to string, reason string) (bool, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	stored, ok := s.jobs[id]
+	if !ok {
+		return false, nil
+	}
+	for _, status := range from {
+		if stored.job.Status == status {
+			stored.job.Status = to
+			stored.job.Error = reason
+			stored.job.UpdatedAt = time.Now().UTC()
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// UnfinishedJobIDs returns the queued and running jobs, the oldest first
+func (s *jobStorage) UnfinishedJobIDs(ctx context.Context) ([]string, error) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	var jobs []shortener.Job
+	for _, stored := range s.jobs {
+		if !stored.job.IsFinished() {
+			jobs = append(jobs, stored.job)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.Before(jobs[j].CreatedAt) })
+	ids := make([]string, 0, len(jobs))
+	for _, job := range jobs {
+		ids = append(ids, job.ID)
+	}
+	return ids, nil
+}
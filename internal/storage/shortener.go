@@ -5,90 +5,467 @@ import (
 	"errors"
 	"fmt"
 	"github.com/itksb/go-url-shortener/internal/shortener"
-	"strconv"
 	"time"
 )
 
 // SaveURL persist the given url
-func (s *storage) SaveURL(ctx context.Context, url string, userID string) (string, error) {
+func (s *storage) SaveURL(ctx context.Context, url string, userID string, opts shortener.SaveOptions) (string, error) {
 	s.urlMtx.Lock()
 	defer s.urlMtx.Unlock()
 
+	if opts.Alias != "" {
+		if _, ok := s.codes[opts.Alias]; ok {
+			return "", fmt.Errorf("%w: %s", shortener.ErrAliasTaken, opts.Alias)
+		}
+	}
+	code, err := s.saveURL(url, userID, opts)
+	if err == nil || errors.Is(err, shortener.ErrDuplicate) {
+		s.own(s.codes[code], userID)
+		s.tag(s.codes[code], userID, opts.Tags, nil)
+	}
+	return code, err
+}
+
+// SaveURLBatch persist the given urls. Aliases are checked before the first write,
+// so the batch is saved entirely or not at all
+func (s *storage) SaveURLBatch(ctx context.Context, userID string, items []shortener.BatchItem) ([]shortener.BatchResult, error) {
+	s.urlMtx.Lock()
+	defer s.urlMtx.Unlock()
+
+	aliases := make(map[string]struct{})
+	for i, item := range items {
+		alias := item.Opts.Alias
+		if alias == "" {
+			continue
+		}
+		_, taken := s.codes[alias]
+		_, repeated := aliases[alias]
+		if taken || repeated {
+			return nil, &shortener.BatchItemError{Index: i, Err: fmt.Errorf("%w: %s", shortener.ErrAliasTaken, alias)}
+		}
+		aliases[alias] = struct{}{}
+	}
+
+	results := make([]shortener.BatchResult, 0, len(items))
+	for _, item := range items {
+		code, err := s.saveURL(item.OriginalURL, userID, item.Opts)
+		if err != nil && !errors.Is(err, shortener.ErrDuplicate) {
+			// rollback the saved part of the batch
+			for _, saved := range results {
+				if !saved.Duplicate {
+					s.unindex(s.urls[s.codes[saved.ShortCode]])
+					delete(s.urls, s.codes[saved.ShortCode])
+					delete(s.codes, saved.ShortCode)
+				}
+			}
+			return nil, err
+		}
+		results = append(results, shortener.BatchResult{ShortCode: code, Duplicate: err != nil})
+	}
+	for i, saved := range results {
+		s.own(s.codes[saved.ShortCode], userID)
+		s.tag(s.codes[saved.ShortCode], userID, items[i].Opts.Tags, nil)
+	}
+	return results, nil
+}
+
+// saveURL stores the url, the caller holds the lock, checks the alias and sets the owner.
+// Returns the existing code and ErrDuplicate if the url with the same duplicate key is already stored
+func (s *storage) saveURL(url string, userID string, opts shortener.SaveOptions) (string, error) {
+	key := shortener.DuplicateKey(url, opts.CanonicalURL)
+	if entry, ok := s.reusable(key); ok {
+		return entry.ShortCode, fmt.Errorf("%w", shortener.ErrDuplicate)
+	}
+
 	id := s.currentURLID
 	s.currentURLID++
 	if _, ok := s.urls[id]; ok {
 		return "0", fmt.Errorf("url with id %d already exists", id)
 	}
-	s.urls[id] = shortener.URLListItem{
-		ID:          id,
-		UserID:      userID,
-		OriginalURL: url,
+	code := opts.Alias
+	if code == "" {
+		var err error
+		code, err = shortener.GenerateUniqueCode(s.codegen, id, func(code string) (bool, error) {
+			_, ok := s.codes[code]
+			return ok, nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	item := shortener.URLListItem{
+		ID:           id,
+		ShortCode:    code,
+		UserID:       userID,
+		OriginalURL:  url,
+		CanonicalURL: key,
+		ExpiresAt:    opts.ExpiresAt,
+		PasswordHash: opts.PasswordHash,
+		RedirectCode: opts.RedirectCode,
+		PassQuery:    opts.PassQuery,
+		Title:        opts.Title,
+		Preview:      opts.Preview,
+		CreatedAt:    time.Now().UTC().Format(shortener.CreatedAtLayout),
 	}
-	return fmt.Sprint(id), nil
+	if opts.MaxClicks > 0 {
+		clicksLeft := opts.MaxClicks
+		item.ClicksLeft = &clicksLeft
+	}
+	s.urls[id] = item
+	s.codes[code] = id
+	s.keys[key] = id
+	return code, nil
 }
 
-// GetURL retrieve url
+// GetURL retrieve url by the short code
 func (s *storage) GetURL(ctx context.Context, id string) (shortener.URLListItem, error) {
 	s.urlMtx.RLock()
 	defer s.urlMtx.RUnlock()
 
 	result := shortener.URLListItem{}
 
-	idInt64, err := strconv.ParseInt(id, 10, 64)
-	if err != nil {
-		return result, err
-	}
-
-	urlListItem, ok := s.urls[idInt64]
+	idInt64, ok := s.codes[id]
 	if !ok {
-		return result, fmt.Errorf("urlListItem with id %d is not exists", idInt64)
+		return result, fmt.Errorf("%w: code %s", shortener.ErrNotFound, id)
 	}
 
-	return urlListItem, nil
+	return s.urls[idInt64], nil
 }
 
-// ListURLByUserID returns the list of urls
+// ListURLByUserID returns the list of urls owned by the user
 func (s *storage) ListURLByUserID(ctx context.Context, userID string) ([]shortener.URLListItem, error) {
 	s.urlMtx.RLock()
 	defer s.urlMtx.RUnlock()
-	var items []shortener.URLListItem
+	return s.ownedURLs(userID), nil
+}
+
+// ListURLs returns the page of the urls owned by the user
+func (s *storage) ListURLs(ctx context.Context, userID string, opts shortener.ListOptions) (shortener.URLPage, error) {
+	s.urlMtx.RLock()
+	defer s.urlMtx.RUnlock()
+	return shortener.PageURLs(s.ownedURLs(userID), opts), nil
+}
 
-	for _, item := range s.urls {
-		if item.UserID == userID {
-			items = append(items, item)
+// ownedURLs returns urls of the user, deleted by the user are marked as deleted. The caller holds the lock
+func (s *storage) ownedURLs(userID string) []shortener.URLListItem {
+	var items []shortener.URLListItem
+	for id, owners := range s.owners {
+		deletedAt, ok := owners[userID]
+		if !ok {
+			continue
 		}
+		item := s.urls[id]
+		item.UserID = userID
+		item.Tags = append([]string(nil), s.tags[id][userID]...)
+		if deletedAt != "" {
+			item.DeletedAt = &deletedAt
+		}
+		items = append(items, item)
 	}
-
-	return items, nil
+	return items
 }
 
-// DeleteURLBatch removes urls
+// DeleteURLBatch removes the user from the owners of the urls, unknown and foreign codes are skipped.
+// The url is marked as deleted when it has no owners left
 func (s *storage) DeleteURLBatch(ctx context.Context, userID string, ids []string) error {
 	s.urlMtx.Lock()
 	defer s.urlMtx.Unlock()
-	var hasError bool
+	tCurr := time.Now().UTC().Format(deletedAtLayout)
 	for i := 0; i < len(ids); i++ {
-		idInt64, err := strconv.ParseInt(ids[i], 10, 64)
-		if err != nil {
-			return err
+		idInt64, ok := s.codes[ids[i]]
+		if !ok {
+			continue
+		}
+		owners := s.owners[idInt64]
+		if deletedAt, ok := owners[userID]; !ok || deletedAt != "" {
+			continue
+		}
+		owners[userID] = tCurr
+		if hasLiveOwner(owners) {
+			continue
 		}
 
 		// get a "copy" here
-		if entry, ok := s.urls[idInt64]; ok {
-			if entry.UserID == userID {
-				tCurr := time.Now().Format("2006-01-02T15:04:05")
-				entry.DeletedAt = &tCurr
-				s.urls[idInt64] = entry
+		if entry, ok := s.urls[idInt64]; ok && !entry.IsDeleted() {
+			entry.DeletedAt = &tCurr
+			s.urls[idInt64] = entry
+			s.unindex(entry)
+		}
+	}
+	return nil
+}
+
+// RestoreURLBatch restores the urls deleted by the user, expired and foreign urls are skipped
+func (s *storage) RestoreURLBatch(ctx context.Context, userID string, ids []string) ([]string, error) {
+	s.urlMtx.Lock()
+	defer s.urlMtx.Unlock()
+	now := time.Now()
+	restored := make([]string, 0, len(ids))
+	for _, code := range ids {
+		idInt64, ok := s.codes[code]
+		if !ok {
+			continue
+		}
+		owners := s.owners[idInt64]
+		deletedAt, ok := owners[userID]
+		entry := s.urls[idInt64]
+		if !ok || entry.IsExpired(now) || (deletedAt == "" && !entry.IsDeleted()) {
+			continue
+		}
+		// the url shortened again after the deletion keeps the key
+		if entry.IsDeleted() && s.keyTaken(entry.CanonicalURL, entry.ID) {
+			continue
+		}
+		owners[userID] = ""
+		if entry.IsDeleted() {
+			entry.DeletedAt = nil
+			s.keys[entry.CanonicalURL] = entry.ID
+		}
+		s.urls[idInt64] = entry
+		restored = append(restored, code)
+	}
+	return restored, nil
+}
+
+// keyTaken reports whether the duplicate key is taken by the other reusable url. The caller holds the lock
+func (s *storage) keyTaken(key string, id int64) bool {
+	other, ok := s.reusable(key)
+	return ok && other.ID != id
+}
+
+// reusable returns the reusable url of the duplicate key. The caller holds the lock
+func (s *storage) reusable(key string) (shortener.URLListItem, bool) {
+	id, ok := s.keys[key]
+	if !ok {
+		return shortener.URLListItem{}, false
+	}
+	entry, ok := s.urls[id]
+	return entry, ok && entry.IsReusable(time.Now())
+}
+
+// unindex removes the url from the duplicate key index, the caller holds the lock
+func (s *storage) unindex(entry shortener.URLListItem) {
+	if id, ok := s.keys[entry.CanonicalURL]; ok && id == entry.ID {
+		delete(s.keys, entry.CanonicalURL)
+	}
+}
+
+// PurgeDeletedURLs removes urls deleted before the moment with their clicks, and the ownerships deleted before it
+func (s *storage) PurgeDeletedURLs(ctx context.Context, before time.Time) (int64, error) {
+	s.urlMtx.Lock()
+	defer s.urlMtx.Unlock()
+	var purged []int64
+	for id, entry := range s.urls {
+		if entry.DeletedAt != nil && deletedBefore(*entry.DeletedAt, before) {
+			s.unindex(entry)
+			delete(s.urls, id)
+			delete(s.codes, entry.ShortCode)
+			delete(s.owners, id)
+			delete(s.history, id)
+			delete(s.tags, id)
+			purged = append(purged, id)
+			continue
+		}
+		for userID, deletedAt := range s.owners[id] {
+			if deletedBefore(deletedAt, before) {
+				delete(s.owners[id], userID)
+				delete(s.tags[id], userID)
 			}
-		} else {
-			hasError = true
 		}
 	}
-	if hasError {
-		return errors.New("some keys not used")
+
+	s.clickMtx.Lock()
+	defer s.clickMtx.Unlock()
+	for _, id := range purged {
+		delete(s.clicks, id)
+	}
+	return int64(len(purged)), nil
+}
+
+// deletedAtLayout - format of the deletion and update time
+const deletedAtLayout = "2006-01-02T15:04:05"
+
+// deletedBefore reports whether the deletion time in UTC is before the moment
+func deletedBefore(deletedAt string, before time.Time) bool {
+	t, err := time.Parse(deletedAtLayout, deletedAt)
+	return err == nil && t.Before(before)
+}
+
+// UpdateURL changes the original url of the live url owned by the user alone and records the change
+func (s *storage) UpdateURL(ctx context.Context, id string, userID string, url string, canonicalURL string) error {
+	s.urlMtx.Lock()
+	defer s.urlMtx.Unlock()
+
+	idInt64, ok := s.codes[id]
+	entry := s.urls[idInt64]
+	deletedAt, owned := s.owners[idInt64][userID]
+	if !ok || !owned || deletedAt != "" || entry.IsDeleted() {
+		return fmt.Errorf("%w: code %s", shortener.ErrNotFound, id)
+	}
+	if len(s.owners[idInt64]) > 1 {
+		return fmt.Errorf("%w: code %s", shortener.ErrURLShared, id)
+	}
+	if entry.OriginalURL == url {
+		return nil
+	}
+	key := shortener.DuplicateKey(url, canonicalURL)
+	if s.keyTaken(key, entry.ID) {
+		return fmt.Errorf("%w", shortener.ErrDuplicate)
+	}
+
+	now := time.Now().UTC()
+	s.history[idInt64] = append(s.history[idInt64], shortener.URLChange{
+		UserID:    userID,
+		OldURL:    entry.OriginalURL,
+		NewURL:    url,
+		ChangedAt: now,
+	})
+	s.unindex(entry)
+	entry.OriginalURL = url
+	entry.CanonicalURL = key
+	entry.UpdatedAt = now.Format(deletedAtLayout)
+	s.urls[idInt64] = entry
+	s.keys[key] = entry.ID
+	return nil
+}
+
+// URLHistory returns changes of the original url
+func (s *storage) URLHistory(ctx context.Context, id string) ([]shortener.URLChange, error) {
+	s.urlMtx.RLock()
+	defer s.urlMtx.RUnlock()
+
+	idInt64, ok := s.codes[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: code %s", shortener.ErrNotFound, id)
+	}
+	return append([]shortener.URLChange(nil), s.history[idInt64]...), nil
+}
+
+// IsURLOwner reports whether the user shortened the url
+func (s *storage) IsURLOwner(ctx context.Context, id string, userID string) (bool, error) {
+	s.urlMtx.RLock()
+	defer s.urlMtx.RUnlock()
+	idInt64, ok := s.codes[id]
+	if !ok {
+		return false, nil
+	}
+	_, ok = s.owners[idInt64][userID]
+	return ok, nil
+}
+
+// UpdateURLTags removes and then adds the tags of the url owned by the user
+func (s *storage) UpdateURLTags(ctx context.Context, id string, userID string, add []string, remove []string) ([]string, error) {
+	s.urlMtx.Lock()
+	defer s.urlMtx.Unlock()
+
+	idInt64, ok := s.codes[id]
+	if _, owned := s.owners[idInt64][userID]; !ok || !owned {
+		return nil, fmt.Errorf("%w: code %s", shortener.ErrNotFound, id)
+	}
+	return append([]string(nil), s.tag(idInt64, userID, add, remove)...), nil
+}
+
+// tag applies the change to the tags of the owner, the caller holds the lock
+func (s *storage) tag(id int64, userID string, add []string, remove []string) []string {
+	if len(add) == 0 && len(remove) == 0 {
+		return s.tags[id][userID]
+	}
+	tags, ok := s.tags[id]
+	if !ok {
+		tags = make(map[string][]string)
+		s.tags[id] = tags
+	}
+	tags[userID] = shortener.MergeTags(tags[userID], add, remove)
+	return tags[userID]
+}
+
+// own makes the user the owner of the url, the caller holds the lock
+func (s *storage) own(id int64, userID string) {
+	owners, ok := s.owners[id]
+	if !ok {
+		owners = make(map[string]string)
+		s.owners[id] = owners
+	}
+	owners[userID] = ""
+}
+
+// hasLiveOwner reports whether some owner has not deleted the url
+func hasLiveOwner(owners map[string]string) bool {
+	for _, deletedAt := range owners {
+		if deletedAt == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// ConsumeClick decrements clicks left of the url
+func (s *storage) ConsumeClick(ctx context.Context, id string) error {
+	s.urlMtx.Lock()
+	defer s.urlMtx.Unlock()
+
+	idInt64, ok := s.codes[id]
+	if !ok {
+		return fmt.Errorf("%w: code %s", shortener.ErrNotFound, id)
+	}
+	entry := s.urls[idInt64]
+	if entry.ClicksLeft == nil {
+		return nil
+	}
+	if entry.IsExhausted() {
+		return shortener.ErrClicksExhausted
 	}
+	// the pointer may be shared with the copies returned by GetURL
+	clicksLeft := *entry.ClicksLeft - 1
+	entry.ClicksLeft = &clicksLeft
+	s.urls[idInt64] = entry
 	return nil
 }
 
+// DeleteExpiredURLs marks expired urls as deleted
+func (s *storage) DeleteExpiredURLs(ctx context.Context, now time.Time) (int64, error) {
+	s.urlMtx.Lock()
+	defer s.urlMtx.Unlock()
+	var count int64
+	for id, entry := range s.urls {
+		if entry.IsExpired(now) && (entry.DeletedAt == nil || *entry.DeletedAt == "") {
+			tCurr := now.UTC().Format(deletedAtLayout)
+			entry.DeletedAt = &tCurr
+			s.urls[id] = entry
+			s.unindex(entry)
+			count++
+		}
+	}
+	return count, nil
+}
+
+// CountURLs returns count of live and deleted urls
+func (s *storage) CountURLs(ctx context.Context) (int64, int64, error) {
+	s.urlMtx.RLock()
+	defer s.urlMtx.RUnlock()
+	var live, deleted int64
+	for _, entry := range s.urls {
+		if entry.DeletedAt != nil && *entry.DeletedAt != "" {
+			deleted++
+		} else {
+			live++
+		}
+	}
+	return live, deleted, nil
+}
+
+// CountUsers returns count of distinct owners
+func (s *storage) CountUsers(ctx context.Context) (int64, error) {
+	s.urlMtx.RLock()
+	defer s.urlMtx.RUnlock()
+	users := make(map[string]struct{})
+	for _, owners := range s.owners {
+		for userID := range owners {
+			users[userID] = struct{}{}
+		}
+	}
+	return int64(len(users)), nil
+}
+
 // Close destructor
 func (s *storage) Close() error { return nil }
@@ -0,0 +1,270 @@
+package storage
+
+import (
+	"context"
+	"github.com/itksb/go-url-shortener/internal/shortener"
+	"github.com/itksb/go-url-shortener/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func newTestStorage(t *testing.T) *storage {
+	l, err := logger.NewLogger()
+	require.NoError(t, err)
+	return NewStorage(l, shortener.NewSequenceGenerator(shortener.Base62Alphabet, 0))
+}
+
+func TestStorage_SaveURLBatch_Duplicates(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+
+	first, err := s.SaveURL(ctx, "https://example.com/a", "user", shortener.SaveOptions{})
+	require.NoError(t, err)
+
+	results, err := s.SaveURLBatch(ctx, "user", []shortener.BatchItem{
+		{OriginalURL: "https://example.com/a"},
+		{OriginalURL: "https://example.com/b"},
+		{OriginalURL: "https://example.com/b"},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, shortener.BatchResult{ShortCode: first, Duplicate: true}, results[0])
+	assert.False(t, results[1].Duplicate)
+	assert.Equal(t, shortener.BatchResult{ShortCode: results[1].ShortCode, Duplicate: true}, results[2])
+
+	// the deleted url leaves the index, the url is shortened again
+	require.NoError(t, s.DeleteURLBatch(ctx, "user", []string{results[1].ShortCode}))
+	again, err := s.SaveURL(ctx, "https://example.com/b", "user", shortener.SaveOptions{})
+	require.NoError(t, err)
+	assert.NotEqual(t, results[1].ShortCode, again)
+	restored, err := s.RestoreURLBatch(ctx, "user", []string{results[1].ShortCode})
+	require.NoError(t, err)
+	assert.Empty(t, restored)
+
+	// the updated url moves to the new key
+	require.NoError(t, s.UpdateURL(ctx, first, "user", "https://example.com/c", ""))
+	code, err := s.SaveURL(ctx, "https://example.com/c", "user", shortener.SaveOptions{})
+	assert.ErrorIs(t, err, shortener.ErrDuplicate)
+	assert.Equal(t, first, code)
+	code, err = s.SaveURL(ctx, "https://example.com/a", "user", shortener.SaveOptions{})
+	require.NoError(t, err)
+	assert.NotEqual(t, first, code)
+}
+
+// the duplicates are found by the index, the linear scan made the batch quadratic
+func TestStorage_SaveURLBatch_Many(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+
+	items := make([]shortener.BatchItem, 0, 20000)
+	for i := 0; i < cap(items); i++ {
+		items = append(items, shortener.BatchItem{OriginalURL: "https://example.com/" + strconv.Itoa(i)})
+	}
+	results, err := s.SaveURLBatch(ctx, "user", items)
+	require.NoError(t, err)
+	for _, result := range results {
+		require.False(t, result.Duplicate)
+	}
+	results, err = s.SaveURLBatch(ctx, "user", items)
+	require.NoError(t, err)
+	for _, result := range results {
+		require.True(t, result.Duplicate)
+	}
+}
+
+func TestStorage_PurgeDeletedURLs(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+
+	deleted, err := s.SaveURL(ctx, "https://example.com/deleted", "user", shortener.SaveOptions{})
+	require.NoError(t, err)
+	past := time.Now().Add(-time.Hour)
+	expired, err := s.SaveURL(ctx, "https://example.com/expired", "user", shortener.SaveOptions{ExpiresAt: &past})
+	require.NoError(t, err)
+	live, err := s.SaveURL(ctx, "https://example.com/live", "user", shortener.SaveOptions{Tags: []string{"kept"}})
+	require.NoError(t, err)
+	_, err = s.SaveURL(ctx, "https://example.com/live", "other", shortener.SaveOptions{Tags: []string{"dropped"}})
+	require.ErrorIs(t, err, shortener.ErrDuplicate)
+
+	deletedItem, err := s.GetURL(ctx, deleted)
+	require.NoError(t, err)
+	liveItem, err := s.GetURL(ctx, live)
+	require.NoError(t, err)
+	require.NoError(t, s.SaveClicks(ctx, []shortener.ClickEvent{
+		{URLID: deletedItem.ID, ClickedAt: time.Now()},
+		{URLID: liveItem.ID, ClickedAt: time.Now()},
+	}))
+
+	require.NoError(t, s.DeleteURLBatch(ctx, "user", []string{deleted}))
+	require.NoError(t, s.DeleteURLBatch(ctx, "other", []string{live}))
+	count, err := s.DeleteExpiredURLs(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	// nothing is deleted before the moment
+	count, err = s.PurgeDeletedURLs(ctx, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+
+	count, err = s.PurgeDeletedURLs(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	for _, code := range []string{deleted, expired} {
+		_, err = s.GetURL(ctx, code)
+		assert.ErrorIs(t, err, shortener.ErrNotFound)
+	}
+	urls, err := s.ListURLByUserID(ctx, "user")
+	require.NoError(t, err)
+	require.Len(t, urls, 1)
+	assert.Equal(t, live, urls[0].ShortCode)
+	assert.Equal(t, []string{"kept"}, urls[0].Tags)
+	owner, err := s.IsURLOwner(ctx, live, "other")
+	require.NoError(t, err)
+	assert.False(t, owner)
+	assert.Equal(t, map[string][]string{"user": {"kept"}}, s.tags[liveItem.ID])
+
+	stats, err := s.ClickStats(ctx, deletedItem.ID, shortener.ClickStatsOptions{Now: time.Now(), Hours: 1, Days: 1})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), stats.Total)
+	stats, err = s.ClickStats(ctx, liveItem.ID, shortener.ClickStatsOptions{Now: time.Now(), Hours: 1, Days: 1})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stats.Total)
+
+	// the purged url is shortened again
+	code, err := s.SaveURL(ctx, "https://example.com/deleted", "user", shortener.SaveOptions{})
+	require.NoError(t, err)
+	assert.NotEqual(t, deleted, code)
+}
+
+func TestStorage_Owners(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+
+	code, err := s.SaveURL(ctx, "https://example.com", "alice", shortener.SaveOptions{})
+	require.NoError(t, err)
+	shared, err := s.SaveURL(ctx, "https://example.com", "bob", shortener.SaveOptions{})
+	assert.ErrorIs(t, err, shortener.ErrDuplicate)
+	assert.Equal(t, code, shared)
+
+	isOwner := func(code string, userID string) bool {
+		owner, err := s.IsURLOwner(ctx, code, userID)
+		require.NoError(t, err)
+		return owner
+	}
+	isDeletedBy := func(userID string) bool {
+		urls, err := s.ListURLByUserID(ctx, userID)
+		require.NoError(t, err)
+		require.Len(t, urls, 1)
+		assert.Equal(t, userID, urls[0].UserID)
+		return urls[0].IsDeleted()
+	}
+	isDeleted := func() bool {
+		item, err := s.GetURL(ctx, code)
+		require.NoError(t, err)
+		return item.IsDeleted()
+	}
+
+	assert.True(t, isOwner(code, "alice"))
+	assert.True(t, isOwner(code, "bob"))
+	assert.False(t, isOwner(code, "carol"))
+	assert.False(t, isOwner("unknown", "alice"))
+
+	// the url is deleted when its last owner deletes it
+	require.NoError(t, s.DeleteURLBatch(ctx, "alice", []string{code}))
+	assert.True(t, isDeletedBy("alice"))
+	assert.False(t, isDeletedBy("bob"))
+	assert.False(t, isDeleted())
+	require.NoError(t, s.DeleteURLBatch(ctx, "bob", []string{code}))
+	assert.True(t, isDeleted())
+
+	restored, err := s.RestoreURLBatch(ctx, "alice", []string{code})
+	require.NoError(t, err)
+	assert.Equal(t, []string{code}, restored)
+	assert.False(t, isDeleted())
+	assert.False(t, isDeletedBy("alice"))
+	assert.True(t, isDeletedBy("bob"))
+}
+
+func TestStorage_UpdateURL(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+
+	code, err := s.SaveURL(ctx, "https://example.com/a", "alice", shortener.SaveOptions{})
+	require.NoError(t, err)
+	_, err = s.SaveURL(ctx, "https://example.com/taken", "alice", shortener.SaveOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, s.UpdateURL(ctx, code, "alice", "https://example.com/b", ""))
+	require.NoError(t, s.UpdateURL(ctx, code, "alice", "https://example.com/c", ""))
+	// the same url is not a change
+	require.NoError(t, s.UpdateURL(ctx, code, "alice", "https://example.com/c", ""))
+	assert.ErrorIs(t, s.UpdateURL(ctx, code, "alice", "https://example.com/taken", ""), shortener.ErrDuplicate)
+	assert.ErrorIs(t, s.UpdateURL(ctx, code, "bob", "https://example.com/d", ""), shortener.ErrNotFound)
+	assert.ErrorIs(t, s.UpdateURL(ctx, "unknown", "alice", "https://example.com/d", ""), shortener.ErrNotFound)
+
+	// the url is found by the new key, the old one is free
+	dup, err := s.SaveURL(ctx, "https://example.com/c", "bob", shortener.SaveOptions{})
+	assert.ErrorIs(t, err, shortener.ErrDuplicate)
+	assert.Equal(t, code, dup)
+	_, err = s.SaveURL(ctx, "https://example.com/a", "alice", shortener.SaveOptions{})
+	require.NoError(t, err)
+	// the shared url is not changed
+	assert.ErrorIs(t, s.UpdateURL(ctx, code, "alice", "https://example.com/d", ""), shortener.ErrURLShared)
+
+	_, err = s.URLHistory(ctx, "unknown")
+	assert.ErrorIs(t, err, shortener.ErrNotFound)
+	check := func() {
+		item, err := s.GetURL(ctx, code)
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/c", item.OriginalURL)
+		assert.NotEmpty(t, item.UpdatedAt)
+
+		history, err := s.URLHistory(ctx, code)
+		require.NoError(t, err)
+		require.Len(t, history, 2)
+		assert.Equal(t, shortener.URLChange{UserID: "alice", OldURL: "https://example.com/a", NewURL: "https://example.com/b", ChangedAt: history[0].ChangedAt}, history[0])
+		assert.Equal(t, shortener.URLChange{UserID: "alice", OldURL: "https://example.com/b", NewURL: "https://example.com/c", ChangedAt: history[1].ChangedAt}, history[1])
+		assert.False(t, history[1].ChangedAt.Before(history[0].ChangedAt))
+	}
+	check()
+}
+
+func TestStorage_UpdateURLTags(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+
+	code, err := s.SaveURL(ctx, "https://example.com", "alice", shortener.SaveOptions{Tags: []string{"b", "a"}})
+	require.NoError(t, err)
+	_, err = s.SaveURL(ctx, "https://example.com", "bob", shortener.SaveOptions{Tags: []string{"c"}})
+	require.ErrorIs(t, err, shortener.ErrDuplicate)
+
+	tags, err := s.UpdateURLTags(ctx, code, "alice", []string{"d"}, []string{"a"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b", "d"}, tags)
+	_, err = s.UpdateURLTags(ctx, code, "carol", []string{"d"}, nil)
+	assert.ErrorIs(t, err, shortener.ErrNotFound)
+	_, err = s.UpdateURLTags(ctx, "unknown", "alice", []string{"d"}, nil)
+	assert.ErrorIs(t, err, shortener.ErrNotFound)
+
+	// every owner has its own tags
+	check := func() {
+		for userID, want := range map[string][]string{"alice": {"b", "d"}, "bob": {"c"}} {
+			urls, err := s.ListURLByUserID(ctx, userID)
+			require.NoError(t, err)
+			require.Len(t, urls, 1)
+			assert.Equal(t, want, urls[0].Tags)
+		}
+		page, err := s.ListURLs(ctx, "alice", shortener.ListOptions{Limit: 10, Tag: "d"})
+		require.NoError(t, err)
+		require.Len(t, page.Items, 1)
+		assert.Equal(t, code, page.Items[0].ShortCode)
+		page, err = s.ListURLs(ctx, "alice", shortener.ListOptions{Limit: 10, Tag: "c"})
+		require.NoError(t, err)
+		assert.Empty(t, page.Items)
+	}
+	check()
+}
@@ -9,15 +9,33 @@ import (
 type storage struct {
 	logger logger.Interface
 	urls   map[int64]shortener.URLListItem
+	codes  map[string]int64 // short code -> id
+	// duplicate key -> id of the url saved, restored or updated last with the key, the only one which may be reusable
+	keys map[string]int64
+	// url id -> owner user id -> deletion time of the ownership, empty if the user owns the url
+	owners  map[int64]map[string]string
+	history map[int64][]shortener.URLChange // url id -> changes of the original url
+	tags    map[int64]map[string][]string   // url id -> owner user id -> sorted tags
+	codegen shortener.CodeGenerator
 
 	currentURLID int64
 	urlMtx       sync.RWMutex
+
+	clicks   map[int64][]shortener.ClickEvent // url id -> events
+	clickMtx sync.RWMutex
 }
 
 // NewStorage - constructor
-func NewStorage(logger logger.Interface) *storage {
+func NewStorage(logger logger.Interface, codegen shortener.CodeGenerator) *storage {
 	return &storage{
-		logger: logger,
-		urls:   make(map[int64]shortener.URLListItem),
+		logger:  logger,
+		urls:    make(map[int64]shortener.URLListItem),
+		codes:   make(map[string]int64),
+		keys:    make(map[string]int64),
+		owners:  make(map[int64]map[string]string),
+		history: make(map[int64][]shortener.URLChange),
+		tags:    make(map[int64]map[string][]string),
+		codegen: codegen,
+		clicks:  make(map[int64][]shortener.ClickEvent),
 	}
 }
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE urls ADD COLUMN short_code CHARACTER VARYING;
+-- existing links keep working: their code is the numeric id
+UPDATE urls SET short_code = id::text WHERE short_code IS NULL;
+ALTER TABLE urls ALTER COLUMN short_code SET NOT NULL;
+ALTER TABLE urls
+    ADD CONSTRAINT urls_short_code_idx
+        UNIQUE (short_code);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE urls
+    DROP CONSTRAINT urls_short_code_idx;
+ALTER TABLE urls DROP COLUMN short_code;
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- NULL means unlimited clicks
+ALTER TABLE urls ADD COLUMN clicks_left INTEGER;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE urls DROP COLUMN clicks_left;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS clicks
+(
+    id         BIGSERIAL PRIMARY KEY,
+    url_id     INTEGER           NOT NULL REFERENCES urls (id) ON DELETE CASCADE,
+    clicked_at TIMESTAMP         NOT NULL,
+    referrer   CHARACTER VARYING NOT NULL DEFAULT '',
+    user_agent CHARACTER VARYING NOT NULL DEFAULT '',
+    ip_hash    CHARACTER VARYING NOT NULL DEFAULT ''
+);
+CREATE INDEX clicks_url_id_clicked_at_idx ON clicks (url_id, clicked_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS clicks;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- keyset pagination of the user urls, id is the tie-breaker
+CREATE INDEX urls_user_id_created_at_idx ON urls (user_id, created_at, id);
+CREATE INDEX urls_user_id_original_url_idx ON urls (user_id, original_url, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS urls_user_id_original_url_idx;
+DROP INDEX IF EXISTS urls_user_id_created_at_idx;
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+-- users who shortened the url, urls.user_id is the first of them
+CREATE TABLE IF NOT EXISTS url_owners
+(
+    url_id     INTEGER               NOT NULL REFERENCES urls (id) ON DELETE CASCADE,
+    user_id    CHARACTER VARYING(36) NOT NULL,
+    created_at TIMESTAMP             NOT NULL DEFAULT now(),
+    deleted_at TIMESTAMP,
+    PRIMARY KEY (url_id, user_id)
+);
+INSERT INTO url_owners (url_id, user_id, created_at, deleted_at)
+SELECT id, user_id, created_at, deleted_at
+FROM urls;
+-- keyset pagination of the user urls moves to the owners
+CREATE INDEX url_owners_user_id_created_at_idx ON url_owners (user_id, created_at, url_id);
+DROP INDEX IF EXISTS urls_user_id_created_at_idx;
+DROP INDEX IF EXISTS urls_user_id_original_url_idx;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE INDEX urls_user_id_created_at_idx ON urls (user_id, created_at, id);
+CREATE INDEX urls_user_id_original_url_idx ON urls (user_id, original_url, id);
+DROP TABLE IF EXISTS url_owners;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- the purge of the deleted urls and ownerships
+CREATE INDEX urls_deleted_at_idx ON urls (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX url_owners_deleted_at_idx ON url_owners (deleted_at) WHERE deleted_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS url_owners_deleted_at_idx;
+DROP INDEX IF EXISTS urls_deleted_at_idx;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS url_history
+(
+    id         BIGSERIAL PRIMARY KEY,
+    url_id     INTEGER               NOT NULL REFERENCES urls (id) ON DELETE CASCADE,
+    user_id    CHARACTER VARYING(36) NOT NULL,
+    old_url    CHARACTER VARYING     NOT NULL,
+    new_url    CHARACTER VARYING     NOT NULL,
+    changed_at TIMESTAMP             NOT NULL DEFAULT now()
+);
+CREATE INDEX url_history_url_id_idx ON url_history (url_id, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS url_history;
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS tags
+(
+    id   SERIAL PRIMARY KEY,
+    name CHARACTER VARYING(64) NOT NULL,
+    CONSTRAINT tags_name_idx UNIQUE (name)
+);
+
+-- tags belong to the owner of the url, other owners of the shared url do not see them
+CREATE TABLE IF NOT EXISTS url_tags
+(
+    url_id  INTEGER               NOT NULL,
+    user_id CHARACTER VARYING(36) NOT NULL,
+    tag_id  INTEGER               NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+    PRIMARY KEY (url_id, user_id, tag_id),
+    FOREIGN KEY (url_id, user_id) REFERENCES url_owners (url_id, user_id) ON DELETE CASCADE
+);
+CREATE INDEX url_tags_user_id_tag_id_idx ON url_tags (user_id, tag_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS url_tags;
+DROP TABLE IF EXISTS tags;
+-- +goose StatementEnd
@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS jobs
+(
+    id         CHARACTER VARYING(36) PRIMARY KEY,
+    user_id    CHARACTER VARYING(36) NOT NULL,
+    status     CHARACTER VARYING(16) NOT NULL,
+    total      INTEGER               NOT NULL,
+    processed  INTEGER               NOT NULL DEFAULT 0,
+    failed     INTEGER               NOT NULL DEFAULT 0,
+    error      CHARACTER VARYING     NOT NULL DEFAULT '',
+    created_at TIMESTAMP             NOT NULL DEFAULT now(),
+    updated_at TIMESTAMP             NOT NULL DEFAULT now()
+);
+CREATE INDEX jobs_status_idx ON jobs (status, created_at);
+
+-- the item keeps its result, it is filled when the item is processed
+CREATE TABLE IF NOT EXISTS job_items
+(
+    job_id     CHARACTER VARYING(36) NOT NULL REFERENCES jobs (id) ON DELETE CASCADE,
+    position   INTEGER               NOT NULL,
+    item       JSONB                 NOT NULL,
+    processed  BOOLEAN               NOT NULL DEFAULT false,
+    short_code CHARACTER VARYING     NOT NULL DEFAULT '',
+    duplicate  BOOLEAN               NOT NULL DEFAULT false,
+    error      CHARACTER VARYING     NOT NULL DEFAULT '',
+    PRIMARY KEY (job_id, position)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS job_items;
+DROP TABLE IF EXISTS jobs;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+-- duplicates are detected by the canonical url, the original url is kept as submitted
+ALTER TABLE urls
+    ADD COLUMN canonical_url CHARACTER VARYING;
+UPDATE urls
+SET canonical_url = original_url;
+ALTER TABLE urls
+    ALTER COLUMN canonical_url SET NOT NULL,
+    DROP CONSTRAINT urls_unique_idx,
+    ADD CONSTRAINT urls_canonical_url_idx
+        UNIQUE (canonical_url);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE urls
+    DROP CONSTRAINT urls_canonical_url_idx,
+    DROP COLUMN canonical_url,
+    ADD CONSTRAINT urls_unique_idx
+        UNIQUE (original_url);
+-- +goose StatementEnd
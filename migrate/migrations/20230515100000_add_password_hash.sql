@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- bcrypt hash of the link password, NULL means the link is public
+ALTER TABLE urls ADD COLUMN password_hash TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE urls DROP COLUMN password_hash;
+-- +goose StatementEnd
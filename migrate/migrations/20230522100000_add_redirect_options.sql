@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- 0 means the default redirect status
+ALTER TABLE urls ADD COLUMN redirect_code SMALLINT NOT NULL DEFAULT 0;
+ALTER TABLE urls ADD COLUMN pass_query BOOLEAN NOT NULL DEFAULT FALSE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE urls DROP COLUMN pass_query;
+ALTER TABLE urls DROP COLUMN redirect_code;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- the title of the owner is shown on the preview page
+ALTER TABLE urls ADD COLUMN title TEXT NOT NULL DEFAULT '';
+ALTER TABLE urls ADD COLUMN preview BOOLEAN NOT NULL DEFAULT FALSE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE urls DROP COLUMN preview;
+ALTER TABLE urls DROP COLUMN title;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- the click-limited urls are never duplicates, see shortener.uniqueKey.
+-- The space is escaped in the canonical url, so the key never equals the key of the public url
+UPDATE urls
+SET canonical_url = canonical_url || ' ' || id
+WHERE clicks_left IS NOT NULL
+  AND password_hash IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- the unique keys are kept, the limited urls may share the canonical url with the public ones
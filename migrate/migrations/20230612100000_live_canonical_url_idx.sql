@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+-- the expiring urls are never duplicates, see shortener.uniqueKey
+UPDATE urls
+SET canonical_url = canonical_url || ' ' || id
+WHERE expires_at IS NOT NULL
+  AND clicks_left IS NULL
+  AND password_hash IS NULL;
+-- the deleted urls are not duplicates, the url is shortened again after the deletion
+ALTER TABLE urls
+    DROP CONSTRAINT urls_canonical_url_idx;
+CREATE UNIQUE INDEX urls_canonical_url_idx ON urls (canonical_url) WHERE deleted_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- the deleted duplicates of the other urls get the unique keys, so the constraint can be restored
+UPDATE urls d
+SET canonical_url = d.canonical_url || ' ' || d.id
+WHERE d.deleted_at IS NOT NULL
+  AND EXISTS (SELECT 1 FROM urls u WHERE u.canonical_url = d.canonical_url AND u.id <> d.id
+                                     AND (u.deleted_at IS NULL OR u.id < d.id));
+DROP INDEX urls_canonical_url_idx;
+ALTER TABLE urls
+    ADD CONSTRAINT urls_canonical_url_idx
+        UNIQUE (canonical_url);
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+-- the timestamps without time zone are in UTC whatever the time zone of the session is,
+-- the service compares them with the UTC moments
+ALTER TABLE urls
+    ALTER COLUMN created_at SET DEFAULT (now() AT TIME ZONE 'UTC'),
+    ALTER COLUMN updated_at SET DEFAULT (now() AT TIME ZONE 'UTC');
+ALTER TABLE url_owners
+    ALTER COLUMN created_at SET DEFAULT (now() AT TIME ZONE 'UTC');
+ALTER TABLE url_history
+    ALTER COLUMN changed_at SET DEFAULT (now() AT TIME ZONE 'UTC');
+ALTER TABLE jobs
+    ALTER COLUMN created_at SET DEFAULT (now() AT TIME ZONE 'UTC'),
+    ALTER COLUMN updated_at SET DEFAULT (now() AT TIME ZONE 'UTC');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE urls
+    ALTER COLUMN created_at SET DEFAULT now(),
+    ALTER COLUMN updated_at SET DEFAULT now();
+ALTER TABLE url_owners
+    ALTER COLUMN created_at SET DEFAULT now();
+ALTER TABLE url_history
+    ALTER COLUMN changed_at SET DEFAULT now();
+ALTER TABLE jobs
+    ALTER COLUMN created_at SET DEFAULT now(),
+    ALTER COLUMN updated_at SET DEFAULT now();
+-- +goose StatementEnd
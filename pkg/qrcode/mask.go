@@ -0,0 +1,120 @@
+package qrcode
+
+// penalty weights of ISO/IEC 18004 section 7.8.3
+const (
+	penaltyRun      = 3
+	penaltyBlock    = 3
+	penaltyFinder   = 40
+	penaltyDarkness = 10
+)
+
+// finderLikePatterns - 1:1:3:1:1 patterns with four light modules on either side
+var finderLikePatterns = [2][11]bool{
+	{true, false, true, true, true, false, true, false, false, false, false},
+	{false, false, false, false, true, false, true, true, true, false, true},
+}
+
+// masked reports whether the mask pattern inverts the module at x, y
+func masked(mask int, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
+
+// applyMask inverts the data modules of the mask pattern, applying the same mask again undoes it
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.size; y++ {
+		for x := 0; x < c.size; x++ {
+			if !c.function[y*c.size+x] && masked(mask, x, y) {
+				c.modules[y*c.size+x] = !c.modules[y*c.size+x]
+			}
+		}
+	}
+}
+
+// penalty - score of the symbol, the mask with the lowest score is used
+func (c *Code) penalty() int {
+	result := 0
+	line := make([]bool, c.size)
+	for i := 0; i < c.size; i++ {
+		for j := 0; j < c.size; j++ {
+			line[j] = c.Dark(j, i)
+		}
+		result += linePenalty(line)
+		for j := 0; j < c.size; j++ {
+			line[j] = c.Dark(i, j)
+		}
+		result += linePenalty(line)
+	}
+
+	dark := 0
+	for y := 0; y < c.size; y++ {
+		for x := 0; x < c.size; x++ {
+			if c.Dark(x, y) {
+				dark++
+			}
+			if x+1 < c.size && y+1 < c.size {
+				color := c.Dark(x, y)
+				if c.Dark(x+1, y) == color && c.Dark(x, y+1) == color && c.Dark(x+1, y+1) == color {
+					result += penaltyBlock
+				}
+			}
+		}
+	}
+
+	// every 5% of the deviation from the half of the dark modules
+	total := c.size * c.size
+	deviation := dark*20 - total*10
+	if deviation < 0 {
+		deviation = -deviation
+	}
+	result += (deviation+total-1)/total*penaltyDarkness - penaltyDarkness
+	return result
+}
+
+// linePenalty - score of the runs and the finder-like patterns of the row or column
+func linePenalty(line []bool) int {
+	result := 0
+	run := 1
+	for i := 1; i <= len(line); i++ {
+		if i < len(line) && line[i] == line[i-1] {
+			run++
+			continue
+		}
+		if run >= 5 {
+			result += penaltyRun + run - 5
+		}
+		run = 1
+	}
+
+	for start := 0; start+11 <= len(line); start++ {
+		for _, pattern := range finderLikePatterns {
+			matched := true
+			for i, dark := range pattern {
+				if line[start+i] != dark {
+					matched = false
+					break
+				}
+			}
+			if matched {
+				result += penaltyFinder
+			}
+		}
+	}
+	return result
+}
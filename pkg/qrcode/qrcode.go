@@ -0,0 +1,370 @@
+// Package qrcode encodes the text into the QR Code symbol (ISO/IEC 18004) and renders it as PNG or SVG.
+// The text is encoded in the byte mode, the smallest version fitting the text is chosen
+package qrcode
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Level - error correction level, the higher level restores more damaged modules of the bigger symbol
+type Level int
+
+// error correction levels
+const (
+	Low      Level = iota // about 7% of the codewords may be restored
+	Medium                // about 15%
+	Quartile              // about 25%
+	High                  // about 30%
+)
+
+// version limits
+const (
+	MinVersion = 1
+	MaxVersion = 40
+)
+
+var (
+	// ErrTooLong - the text does not fit the biggest symbol of the level
+	ErrTooLong = errors.New(`qrcode: the text is too long`)
+	// ErrInvalidLevel - the error correction level is not one of L, M, Q, H
+	ErrInvalidLevel = errors.New(`qrcode: invalid error correction level`)
+)
+
+// ParseLevel parses the level letter L, M, Q or H in any case
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToUpper(s) {
+	case "L":
+		return Low, nil
+	case "M":
+		return Medium, nil
+	case "Q":
+		return Quartile, nil
+	case "H":
+		return High, nil
+	}
+	return Low, fmt.Errorf("%w: %q, allowed L, M, Q, H", ErrInvalidLevel, s)
+}
+
+// String returns the level letter
+func (l Level) String() string {
+	switch l {
+	case Low:
+		return "L"
+	case Medium:
+		return "M"
+	case Quartile:
+		return "Q"
+	case High:
+		return "H"
+	}
+	return fmt.Sprintf("Level(%d)", int(l))
+}
+
+// formatBits - bits of the level in the format information
+func (l Level) formatBits() int {
+	return [...]int{1, 0, 3, 2}[l]
+}
+
+// Code - QR Code symbol, the square of the dark and light modules without the quiet zone
+type Code struct {
+	version  int
+	level    Level
+	mask     int
+	size     int
+	modules  []bool // dark modules by rows
+	function []bool // modules of the function patterns, they are never masked
+}
+
+// Encode encodes the text with the level into the smallest symbol fitting it
+func Encode(text string, level Level) (*Code, error) {
+	if level < Low || level > High {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidLevel, int(level))
+	}
+	data := []byte(text)
+	version := MinVersion
+	for ; ; version++ {
+		if version > MaxVersion {
+			return nil, fmt.Errorf("%w: %d bytes", ErrTooLong, len(data))
+		}
+		if 4+countBits(version)+len(data)*8 <= dataCodewords(version, level)*8 {
+			break
+		}
+	}
+
+	c := &Code{
+		version:  version,
+		level:    level,
+		size:     version*4 + 17,
+		modules:  make([]bool, (version*4+17)*(version*4+17)),
+		function: make([]bool, (version*4+17)*(version*4+17)),
+	}
+	c.drawFunctionPatterns()
+	c.drawCodewords(c.addErrorCorrection(c.encodeData(data)))
+
+	bestPenalty := -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormatBits(mask)
+		if penalty := c.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			bestPenalty = penalty
+			c.mask = mask
+		}
+		c.applyMask(mask)
+	}
+	c.applyMask(c.mask)
+	c.drawFormatBits(c.mask)
+	return c, nil
+}
+
+// Version - version of the symbol from MinVersion to MaxVersion
+func (c *Code) Version() int {
+	return c.version
+}
+
+// Level - error correction level of the symbol
+func (c *Code) Level() Level {
+	return c.level
+}
+
+// Size - modules of the symbol side without the quiet zone
+func (c *Code) Size() int {
+	return c.size
+}
+
+// Dark reports whether the module at the column x and the row y is dark, the modules outside the symbol are light
+func (c *Code) Dark(x, y int) bool {
+	return x >= 0 && x < c.size && y >= 0 && y < c.size && c.modules[y*c.size+x]
+}
+
+// countBits - bits of the character count indicator of the byte mode
+func countBits(version int) int {
+	if version <= 9 {
+		return 8
+	}
+	return 16
+}
+
+// encodeData - data codewords of the byte mode segment with the terminator and the padding
+func (c *Code) encodeData(data []byte) []byte {
+	capacity := dataCodewords(c.version, c.level) * 8
+	bits := make([]bool, 0, capacity)
+	appendBits := func(value int, length int) {
+		for i := length - 1; i >= 0; i-- {
+			bits = append(bits, (value>>i)&1 != 0)
+		}
+	}
+	appendBits(0b0100, 4) // byte mode
+	appendBits(len(data), countBits(c.version))
+	for _, b := range data {
+		appendBits(int(b), 8)
+	}
+	terminator := capacity - len(bits)
+	if terminator > 4 {
+		terminator = 4
+	}
+	appendBits(0, terminator)
+	appendBits(0, (8-len(bits)%8)%8)
+
+	codewords := make([]byte, 0, capacity/8)
+	for i := 0; i < len(bits); i += 8 {
+		var b byte
+		for j := 0; j < 8; j++ {
+			if bits[i+j] {
+				b |= 1 << (7 - j)
+			}
+		}
+		codewords = append(codewords, b)
+	}
+	for pad := byte(0xEC); len(codewords) < capacity/8; pad ^= 0xEC ^ 0x11 {
+		codewords = append(codewords, pad)
+	}
+	return codewords
+}
+
+// addErrorCorrection splits the data into the blocks, adds their error correction codewords
+// and interleaves the blocks
+func (c *Code) addErrorCorrection(data []byte) []byte {
+	blocks := eccBlocks[c.level][c.version]
+	eccLen := eccCodewordsPerBlock[c.level][c.version]
+	raw := rawDataModules(c.version) / 8
+	// the long blocks have one data codeword more
+	shortBlocks := blocks - raw%blocks
+	shortLen := raw / blocks
+
+	divisor := rsDivisor(eccLen)
+	interleaved := make([][]byte, blocks)
+	for i, k := 0, 0; i < blocks; i++ {
+		dataLen := shortLen - eccLen
+		if i >= shortBlocks {
+			dataLen++
+		}
+		block := make([]byte, 0, shortLen+1)
+		block = append(block, data[k:k+dataLen]...)
+		k += dataLen
+		ecc := rsRemainder(block, divisor)
+		if i < shortBlocks {
+			block = append(block, 0) // the place of the missing codeword, skipped below
+		}
+		interleaved[i] = append(block, ecc...)
+	}
+
+	result := make([]byte, 0, raw)
+	for i := range interleaved[0] {
+		for j, block := range interleaved {
+			if i != shortLen-eccLen || j >= shortBlocks {
+				result = append(result, block[i])
+			}
+		}
+	}
+	return result
+}
+
+// setFunction sets the module of the function pattern
+func (c *Code) setFunction(x, y int, dark bool) {
+	c.modules[y*c.size+x] = dark
+	c.function[y*c.size+x] = true
+}
+
+// drawFunctionPatterns draws the timing, finder and alignment patterns, reserves the format
+// and draws the version information
+func (c *Code) drawFunctionPatterns() {
+	for i := 0; i < c.size; i++ {
+		c.setFunction(6, i, i%2 == 0)
+		c.setFunction(i, 6, i%2 == 0)
+	}
+
+	c.drawFinder(3, 3)
+	c.drawFinder(c.size-4, 3)
+	c.drawFinder(3, c.size-4)
+
+	positions := alignmentPositions(c.version)
+	last := len(positions) - 1
+	for i, x := range positions {
+		for j, y := range positions {
+			// the corners are taken by the finder patterns
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			c.drawAlignment(x, y)
+		}
+	}
+
+	c.drawFormatBits(0)
+	c.drawVersion()
+}
+
+// drawFinder draws the finder pattern with its separator centred at x, y
+func (c *Code) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || xx >= c.size || yy < 0 || yy >= c.size {
+				continue
+			}
+			dist := maxInt(absInt(dx), absInt(dy))
+			c.setFunction(xx, yy, dist != 2 && dist != 4)
+		}
+	}
+}
+
+// drawAlignment draws the alignment pattern centred at x, y
+func (c *Code) drawAlignment(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			c.setFunction(x+dx, y+dy, maxInt(absInt(dx), absInt(dy)) != 1)
+		}
+	}
+}
+
+// drawFormatBits draws both copies of the format information of the level and the mask
+func (c *Code) drawFormatBits(mask int) {
+	data := c.level.formatBits()<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return (bits>>i)&1 != 0 }
+
+	// around the top left finder
+	for i := 0; i <= 5; i++ {
+		c.setFunction(8, i, bit(i))
+	}
+	c.setFunction(8, 7, bit(6))
+	c.setFunction(8, 8, bit(7))
+	c.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.setFunction(14-i, 8, bit(i))
+	}
+
+	// next to the top right and the bottom left finders
+	for i := 0; i < 8; i++ {
+		c.setFunction(c.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.setFunction(8, c.size-15+i, bit(i))
+	}
+	c.setFunction(8, c.size-8, true) // the dark module
+}
+
+// drawVersion draws both copies of the version information of the versions from 7
+func (c *Code) drawVersion() {
+	if c.version < 7 {
+		return
+	}
+	rem := c.version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	bits := c.version<<12 | rem
+	for i := 0; i < 18; i++ {
+		dark := (bits>>i)&1 != 0
+		a, b := c.size-11+i%3, i/3
+		c.setFunction(a, b, dark)
+		c.setFunction(b, a, dark)
+	}
+}
+
+// drawCodewords places the codewords into the data modules in the zigzag order of the column pairs
+// from the bottom right corner
+func (c *Code) drawCodewords(codewords []byte) {
+	i := 0
+	for right := c.size - 1; right >= 1; right -= 2 {
+		// the vertical timing pattern is skipped
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < c.size; vert++ {
+			y := vert
+			if upward {
+				y = c.size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if c.function[y*c.size+x] || i >= len(codewords)*8 {
+					continue
+				}
+				// the remainder bits are left light
+				c.modules[y*c.size+x] = (codewords[i>>3]>>(7-i&7))&1 != 0
+				i++
+			}
+		}
+	}
+}
+
+func absInt(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,278 @@
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestRSRemainder(t *testing.T) {
+	// "HELLO WORLD" of the version 1-M in the alphanumeric mode
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := rsRemainder(data, rsDivisor(len(want))); !bytes.Equal(got, want) {
+		t.Errorf("expected error correction %v, but got %v", want, got)
+	}
+}
+
+func TestEncode_Version(t *testing.T) {
+	tests := []struct {
+		length  int
+		level   Level
+		version int
+	}{
+		{length: 17, level: Low, version: 1},
+		{length: 14, level: Medium, version: 1},
+		{length: 15, level: Medium, version: 2},
+		{length: 7, level: High, version: 1},
+		{length: 271, level: Low, version: 10},
+		{length: 272, level: Low, version: 11},
+		{length: 2953, level: Low, version: 40},
+		{length: 1273, level: High, version: 40},
+	}
+	for _, tt := range tests {
+		c, err := Encode(strings.Repeat("a", tt.length), tt.level)
+		if err != nil {
+			t.Fatalf("%d bytes %s: %v", tt.length, tt.level, err)
+		}
+		if c.Version() != tt.version || c.Size() != tt.version*4+17 {
+			t.Errorf("%d bytes %s: expected version %d, but got %d of size %d", tt.length, tt.level, tt.version, c.Version(), c.Size())
+		}
+	}
+
+	if _, err := Encode(strings.Repeat("a", 2954), Low); !errors.Is(err, ErrTooLong) {
+		t.Errorf("expected %v, but got %v", ErrTooLong, err)
+	}
+	if _, err := Encode("a", Level(4)); !errors.Is(err, ErrInvalidLevel) {
+		t.Errorf("expected %v, but got %v", ErrInvalidLevel, err)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for _, level := range []Level{Low, Medium, Quartile, High} {
+		parsed, err := ParseLevel(strings.ToLower(level.String()))
+		if err != nil || parsed != level {
+			t.Errorf("expected level %s, but got %s, %v", level, parsed, err)
+		}
+	}
+	if _, err := ParseLevel("X"); !errors.Is(err, ErrInvalidLevel) {
+		t.Errorf("expected %v, but got %v", ErrInvalidLevel, err)
+	}
+}
+
+func TestEncode_Decode(t *testing.T) {
+	texts := []string{
+		"http://short.base/Ab3",
+		"https://example.com/" + strings.Repeat("путь/", 30),
+		strings.Repeat("0123456789", 50),
+	}
+	for _, text := range texts {
+		for _, level := range []Level{Low, Medium, Quartile, High} {
+			c, err := Encode(text, level)
+			if err != nil {
+				t.Fatalf("%s: %v", level, err)
+			}
+			checkFunctionPatterns(t, c)
+			gotLevel, mask := readFormat(t, c)
+			if gotLevel != level || mask != c.mask {
+				t.Errorf("expected format %s/%d, but got %s/%d", level, c.mask, gotLevel, mask)
+			}
+			if c.version >= 7 {
+				checkVersion(t, c)
+			}
+			if got := decode(t, c); got != text {
+				t.Errorf("%s: expected %q, but decoded %q", level, text, got)
+			}
+		}
+	}
+}
+
+func TestCode_Render(t *testing.T) {
+	c, err := Encode("http://short.base/Ab3", Medium)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if err = c.WritePNG(buf, 3, DefaultMargin); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	side := (c.Size() + 2*DefaultMargin) * 3
+	if img.Bounds().Dx() != side || img.Bounds().Dy() != side {
+		t.Errorf("expected image %dx%d, but got %v", side, side, img.Bounds())
+	}
+	// the top left module of the finder is dark, the quiet zone is light
+	if r, _, _, _ := img.At(DefaultMargin*3, DefaultMargin*3).RGBA(); r != 0 {
+		t.Errorf("expected dark finder module")
+	}
+	if r, _, _, _ := img.At(DefaultMargin*3-1, DefaultMargin*3).RGBA(); r == 0 {
+		t.Errorf("expected light quiet zone")
+	}
+
+	buf.Reset()
+	if err = c.WriteSVG(buf, 200, 2); err != nil {
+		t.Fatal(err)
+	}
+	svg := buf.String()
+	if !strings.HasPrefix(svg, "<svg ") || !strings.Contains(svg, `width="200"`) || !strings.Contains(svg, "M2 2h1v1h-1z") {
+		t.Errorf("unexpected svg %q", svg)
+	}
+}
+
+// checkFunctionPatterns checks the finder and timing patterns and the dark module
+func checkFunctionPatterns(t *testing.T, c *Code) {
+	t.Helper()
+	for _, corner := range [][2]int{{0, 0}, {c.size - 7, 0}, {0, c.size - 7}} {
+		for i := 0; i < 7; i++ {
+			if !c.Dark(corner[0]+i, corner[1]) || !c.Dark(corner[0], corner[1]+i) || c.Dark(corner[0]+1, corner[1]+1+i%5) {
+				t.Fatalf("broken finder pattern at %v", corner)
+			}
+		}
+	}
+	for i := 8; i < c.size-8; i++ {
+		if c.Dark(i, 6) != (i%2 == 0) || c.Dark(6, i) != (i%2 == 0) {
+			t.Fatalf("broken timing pattern at %d", i)
+		}
+	}
+	if !c.Dark(8, c.size-8) {
+		t.Fatalf("no dark module")
+	}
+}
+
+// readFormat reads both copies of the format information and checks its BCH code
+func readFormat(t *testing.T, c *Code) (Level, int) {
+	t.Helper()
+	first, second := 0, 0
+	firstCoords := [][2]int{{8, 0}, {8, 1}, {8, 2}, {8, 3}, {8, 4}, {8, 5}, {8, 7}, {8, 8}, {7, 8}, {5, 8}, {4, 8}, {3, 8}, {2, 8}, {1, 8}, {0, 8}}
+	for i, xy := range firstCoords {
+		if c.Dark(xy[0], xy[1]) {
+			first |= 1 << i
+		}
+	}
+	for i := 0; i < 15; i++ {
+		x, y := c.size-1-i, 8
+		if i >= 8 {
+			x, y = 8, c.size-15+i
+		}
+		if c.Dark(x, y) {
+			second |= 1 << i
+		}
+	}
+	if first != second {
+		t.Fatalf("format copies differ: %015b, %015b", first, second)
+	}
+	bits := first ^ 0x5412
+	rem := bits
+	for i := 14; i >= 10; i-- {
+		if rem&(1<<i) != 0 {
+			rem ^= 0x537 << (i - 10)
+		}
+	}
+	if rem != 0 {
+		t.Fatalf("format %015b is not the BCH code", first)
+	}
+	levels := map[int]Level{1: Low, 0: Medium, 3: Quartile, 2: High}
+	return levels[bits>>13], bits >> 10 & 7
+}
+
+// checkVersion reads both copies of the version information
+func checkVersion(t *testing.T, c *Code) {
+	t.Helper()
+	first, second := 0, 0
+	for i := 0; i < 18; i++ {
+		a, b := c.size-11+i%3, i/3
+		if c.Dark(a, b) {
+			first |= 1 << i
+		}
+		if c.Dark(b, a) {
+			second |= 1 << i
+		}
+	}
+	if first != second || first>>12 != c.version {
+		t.Fatalf("expected version %d, but got %018b and %018b", c.version, first, second)
+	}
+}
+
+// decode reads the data modules in the placement order, checks the error correction of the blocks
+// and returns the text of the byte mode segment
+func decode(t *testing.T, c *Code) string {
+	t.Helper()
+	var bits []bool
+	for right := c.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right--
+		}
+		for vert := 0; vert < c.size; vert++ {
+			y := vert
+			if (right+1)&2 == 0 {
+				y = c.size - 1 - vert
+			}
+			for x := right; x > right-2; x-- {
+				if !c.function[y*c.size+x] {
+					bits = append(bits, c.Dark(x, y) != masked(c.mask, x, y))
+				}
+			}
+		}
+	}
+	raw := rawDataModules(c.version) / 8
+	codewords := make([]byte, raw)
+	for i := range codewords {
+		for j := 0; j < 8; j++ {
+			if bits[i*8+j] {
+				codewords[i] |= 1 << (7 - j)
+			}
+		}
+	}
+
+	// de-interleave the data codewords, then the error correction ones
+	blocks := eccBlocks[c.level][c.version]
+	eccLen := eccCodewordsPerBlock[c.level][c.version]
+	shortBlocks := blocks - raw%blocks
+	shortDataLen := raw/blocks - eccLen
+	data := make([][]byte, blocks)
+	ecc := make([][]byte, blocks)
+	k := 0
+	for i := 0; i <= shortDataLen; i++ {
+		for j := 0; j < blocks; j++ {
+			if i < shortDataLen || j >= shortBlocks {
+				data[j] = append(data[j], codewords[k])
+				k++
+			}
+		}
+	}
+	for i := 0; i < eccLen; i++ {
+		for j := 0; j < blocks; j++ {
+			ecc[j] = append(ecc[j], codewords[k])
+			k++
+		}
+	}
+	var stream []byte
+	for j := range data {
+		if !bytes.Equal(rsRemainder(data[j], rsDivisor(eccLen)), ecc[j]) {
+			t.Fatalf("block %d: wrong error correction", j)
+		}
+		stream = append(stream, data[j]...)
+	}
+
+	read := func(offset int, length int) int {
+		value := 0
+		for i := offset; i < offset+length; i++ {
+			value = value<<1 | int(stream[i/8]>>(7-i%8)&1)
+		}
+		return value
+	}
+	if mode := read(0, 4); mode != 0b0100 {
+		t.Fatalf("expected byte mode, but got %04b", mode)
+	}
+	length := read(4, countBits(c.version))
+	text := make([]byte, length)
+	for i := range text {
+		text[i] = byte(read(4+countBits(c.version)+i*8, 8))
+	}
+	return string(text)
+}
@@ -0,0 +1,70 @@
+package qrcode
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// DefaultMargin - width of the quiet zone in modules recommended by the standard
+const DefaultMargin = 4
+
+// palette of the rendered image: light and dark modules
+var palette = color.Palette{color.White, color.Black}
+
+// Image renders the symbol with the quiet zone of margin modules, every module is scale pixels wide
+func (c *Code) Image(scale int, margin int) *image.Paletted {
+	if scale < 1 {
+		scale = 1
+	}
+	if margin < 0 {
+		margin = 0
+	}
+	side := (c.size + 2*margin) * scale
+	img := image.NewPaletted(image.Rect(0, 0, side, side), palette)
+	for y := 0; y < c.size; y++ {
+		for x := 0; x < c.size; x++ {
+			if !c.Dark(x, y) {
+				continue
+			}
+			left, top := (x+margin)*scale, (y+margin)*scale
+			for py := top; py < top+scale; py++ {
+				row := img.Pix[py*img.Stride:]
+				for px := left; px < left+scale; px++ {
+					row[px] = 1
+				}
+			}
+		}
+	}
+	return img
+}
+
+// WritePNG writes the symbol rendered by Image as PNG
+func (c *Code) WritePNG(w io.Writer, scale int, margin int) error {
+	return png.Encode(w, c.Image(scale, margin))
+}
+
+// WriteSVG writes the symbol as SVG, the symbol with the quiet zone is side pixels wide.
+// The modules are drawn by one path in the module units
+func (c *Code) WriteSVG(w io.Writer, side int, margin int) error {
+	if margin < 0 {
+		margin = 0
+	}
+	units := c.size + 2*margin
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" version="1.1" viewBox="0 0 %d %d" width="%d" height="%d" shape-rendering="crispEdges">`,
+		units, units, side, side)
+	bw.WriteString(`<rect width="100%" height="100%" fill="#fff"/><path fill="#000" d="`)
+	for y := 0; y < c.size; y++ {
+		for x := 0; x < c.size; x++ {
+			if c.Dark(x, y) {
+				fmt.Fprintf(bw, "M%d %dh1v1h-1z", x+margin, y+margin)
+			}
+		}
+	}
+	bw.WriteString(`"/></svg>`)
+	return bw.Flush()
+}